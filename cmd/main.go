@@ -102,7 +102,7 @@ func main() {
 	cluster.NewToolchainClusterService(cl, cacheLog, configuration.Namespace(), 5*time.Second)
 	cluster.GetMemberClusters()
 
-	_, err = auth.InitializeDefaultTokenParser()
+	tokenParser, err := auth.InitializeDefaultTokenParser()
 	if err != nil {
 		panic(errs.Wrap(err, "failed to init default token parser"))
 	}
@@ -120,6 +120,10 @@ func main() {
 	if err != nil {
 		panic(errs.Wrap(err, "failed to create proxy"))
 	}
+	if handler := p.AccessCacheEventHandler(); handler != nil {
+		informer.AddAccessEventHandler(handler)
+	}
+	informer.AddAccessEventHandler(p.WorkspaceWatchEventHandler())
 	proxySrv := p.StartProxy(proxy.DefaultPort)
 
 	// stop the informer when proxy server shuts down
@@ -138,6 +142,9 @@ func main() {
 		panic(err.Error())
 	}
 
+	// stop the background refresh of the public keys when the registration service shuts down
+	regsvcSrv.HTTPServer().RegisterOnShutdown(tokenParser.Stop)
+
 	routesToPrint := regsvcSrv.GetRegisteredRoutes()
 	log.Infof(nil, "Configured routes: %s", routesToPrint)
 
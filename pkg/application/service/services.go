@@ -18,6 +18,12 @@ type InformerService interface {
 	GetNSTemplateTier(name string) (*toolchainv1alpha1.NSTemplateTier, error)
 }
 
+// EffectiveSpaceBindingLister is implemented by the InformerServices which can list the SpaceBindings of a Space including
+// the ones inherited from its parent Spaces, without listing the parent Spaces and their SpaceBindings one by one.
+type EffectiveSpaceBindingLister interface {
+	ListEffectiveSpaceBindings(space *toolchainv1alpha1.Space, murName string) ([]toolchainv1alpha1.SpaceBinding, error)
+}
+
 type SignupService interface {
 	Signup(ctx *gin.Context) (*toolchainv1alpha1.UserSignup, error)
 	GetSignup(ctx *gin.Context, userID, username string) (*signup.Signup, error)
@@ -0,0 +1,205 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+
+	"github.com/golang-jwt/jwt/v5"
+	"k8s.io/apiserver/pkg/util/wsstream"
+)
+
+const (
+	// APITokenIssuer is the issuer of the API tokens signed by the registration service
+	APITokenIssuer = "registration-service"
+	// APITokensAnnotationKey is the annotation of the UserSignup which contains the JSON-encoded records of the API tokens of the user.
+	// An API token is revoked by removing its record.
+	APITokensAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "api-tokens"
+
+	// apiTokenMinSecretLength is the minimum length of the secret used to sign the API tokens (HS256)
+	apiTokenMinSecretLength = 32
+)
+
+// APITokenRecord is the record of an API token, stored in the annotation of the UserSignup of its owner
+type APITokenRecord struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Workspace string     `json:"workspace"`
+	ReadOnly  bool       `json:"readOnly,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// APITokenRecords returns the records of the API tokens of the given UserSignup
+func APITokenRecords(userSignup *toolchainv1alpha1.UserSignup) ([]APITokenRecord, error) {
+	value, found := userSignup.Annotations[APITokensAnnotationKey]
+	if !found || value == "" {
+		return []APITokenRecord{}, nil
+	}
+	records := []APITokenRecord{}
+	if err := json.Unmarshal([]byte(value), &records); err != nil {
+		return nil, fmt.Errorf("invalid API token records of UserSignup '%s': %w", userSignup.Name, err)
+	}
+	return records, nil
+}
+
+// SetAPITokenRecords stores the given records of API tokens in the annotation of the given UserSignup
+func SetAPITokenRecords(userSignup *toolchainv1alpha1.UserSignup, records []APITokenRecord) error {
+	if len(records) == 0 {
+		delete(userSignup.Annotations, APITokensAnnotationKey)
+		return nil
+	}
+	value, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	if userSignup.Annotations == nil {
+		userSignup.Annotations = map[string]string{}
+	}
+	userSignup.Annotations[APITokensAnnotationKey] = string(value)
+	return nil
+}
+
+// APITokenClaims are the claims of an API token. The subject is the name of the UserSignup of the owner of the token
+// and the ID is the ID of its record.
+type APITokenClaims struct {
+	jwt.RegisteredClaims
+	Workspace string `json:"workspace"`
+	ReadOnly  bool   `json:"read_only,omitempty"`
+}
+
+// APITokenSigner signs and verifies the API tokens with a secret key (HS256)
+type APITokenSigner struct {
+	secret []byte
+}
+
+// NewAPITokenSigner creates a new APITokenSigner with the given secret
+func NewAPITokenSigner(secret string) (*APITokenSigner, error) {
+	if len(secret) < apiTokenMinSecretLength {
+		return nil, fmt.Errorf("the secret of the API tokens must contain at least %d characters", apiTokenMinSecretLength)
+	}
+	return &APITokenSigner{
+		secret: []byte(secret),
+	}, nil
+}
+
+// Sign returns a new API token for the given UserSignup and record
+func (s *APITokenSigner) Sign(userSignupName string, record APITokenRecord) (string, error) {
+	claims := APITokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:   APITokenIssuer,
+			Subject:  userSignupName,
+			ID:       record.ID,
+			IssuedAt: jwt.NewNumericDate(record.CreatedAt),
+		},
+		Workspace: record.Workspace,
+		ReadOnly:  record.ReadOnly,
+	}
+	if record.ExpiresAt != nil {
+		claims.ExpiresAt = jwt.NewNumericDate(*record.ExpiresAt)
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.secret)
+}
+
+// Parse verifies the given API token and returns its claims
+func (s *APITokenSigner) Parse(token string) (*APITokenClaims, error) {
+	claims := &APITokenClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) {
+		return s.secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithIssuer(APITokenIssuer), jwt.WithLeeway(leeway))
+	if err != nil {
+		return nil, err
+	}
+	if claims.Subject == "" || claims.ID == "" || claims.Workspace == "" {
+		return nil, errors.New("token does not comply to expected claims: subject, ID or workspace missing")
+	}
+	return claims, nil
+}
+
+// isAPIToken returns true if the given token was issued by the registration service. The signature is not verified.
+func isAPIToken(token string) bool {
+	claims := jwt.RegisteredClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(token, &claims); err != nil {
+		return false
+	}
+	return claims.Issuer == APITokenIssuer
+}
+
+// APITokenAuthenticator authenticates the requests with the API tokens signed by the registration service.
+// The request is authenticated as the owner of the token, as long as the record of the token exists in its UserSignup.
+type APITokenAuthenticator struct {
+	signer        *APITokenSigner
+	getUserSignup func(name string) (*toolchainv1alpha1.UserSignup, error)
+}
+
+// NewAPITokenAuthenticator returns a new APITokenAuthenticator which verifies the tokens with the given signer
+// and looks up their records in the UserSignups returned by the given function
+func NewAPITokenAuthenticator(signer *APITokenSigner, getUserSignup func(name string) (*toolchainv1alpha1.UserSignup, error)) *APITokenAuthenticator {
+	return &APITokenAuthenticator{
+		signer:        signer,
+		getUserSignup: getUserSignup,
+	}
+}
+
+func (a *APITokenAuthenticator) Authenticate(req *http.Request) (*Identity, error) {
+	token := bearerToken(req)
+	if token == "" || !isAPIToken(token) {
+		// not an API token, let the other authenticators handle the request
+		return nil, nil
+	}
+	claims, err := a.signer.Parse(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid API token: %w", err)
+	}
+	userSignup, err := a.getUserSignup(claims.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get the owner of the API token: %w", err)
+	}
+	records, err := APITokenRecords(userSignup)
+	if err != nil {
+		return nil, err
+	}
+	if !containsAPITokenRecord(records, claims.ID) {
+		return nil, errors.New("API token has been revoked")
+	}
+	return &Identity{
+		Subject:     userSignup.Spec.IdentityClaims.Sub,
+		Username:    userSignup.Spec.IdentityClaims.PreferredUsername,
+		Email:       userSignup.Spec.IdentityClaims.Email,
+		GivenName:   userSignup.Spec.IdentityClaims.GivenName,
+		FamilyName:  userSignup.Spec.IdentityClaims.FamilyName,
+		Company:     userSignup.Spec.IdentityClaims.Company,
+		OriginalSub: userSignup.Spec.IdentityClaims.OriginalSub,
+		UserID:      userSignup.Spec.IdentityClaims.UserID,
+		AccountID:   userSignup.Spec.IdentityClaims.AccountID,
+		APIToken:    claims,
+	}, nil
+}
+
+func containsAPITokenRecord(records []APITokenRecord, id string) bool {
+	for _, r := range records {
+		if r.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// bearerToken returns the bearer token of the given request, from the WebSocket sub-protocol or the Authorization header,
+// or an empty string if there is no valid bearer token
+func bearerToken(req *http.Request) string {
+	if wsstream.IsWebSocketRequest(req) {
+		token, _ := extractTokenFromWebSocketRequest(req)
+		return token
+	}
+	fields := strings.Fields(req.Header.Get("Authorization"))
+	if len(fields) != 2 || fields[0] != "Bearer" {
+		return ""
+	}
+	return fields[1]
+}
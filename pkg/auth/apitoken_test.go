@@ -0,0 +1,255 @@
+package auth_test
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/auth"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const apiTokenSecret = "0123456789abcdef0123456789abcdef"
+
+func TestAPITokenRecords(t *testing.T) {
+	// given
+	userSignup := &toolchainv1alpha1.UserSignup{}
+	createdAt := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
+	records := []auth.APITokenRecord{
+		{ID: "1", Name: "ci", Workspace: "john", CreatedAt: createdAt},
+		{ID: "2", Name: "dashboard", Workspace: "john-dev", ReadOnly: true, CreatedAt: createdAt, ExpiresAt: &createdAt},
+	}
+
+	// when
+	err := auth.SetAPITokenRecords(userSignup, records)
+
+	// then
+	require.NoError(t, err)
+	actual, err := auth.APITokenRecords(userSignup)
+	require.NoError(t, err)
+	assert.Equal(t, records, actual)
+
+	t.Run("no records", func(t *testing.T) {
+		err := auth.SetAPITokenRecords(userSignup, nil)
+
+		require.NoError(t, err)
+		assert.NotContains(t, userSignup.Annotations, auth.APITokensAnnotationKey)
+		actual, err := auth.APITokenRecords(userSignup)
+		require.NoError(t, err)
+		assert.Empty(t, actual)
+	})
+
+	t.Run("invalid records", func(t *testing.T) {
+		userSignup := &toolchainv1alpha1.UserSignup{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "john",
+				Annotations: map[string]string{auth.APITokensAnnotationKey: "{"},
+			},
+		}
+
+		_, err := auth.APITokenRecords(userSignup)
+
+		require.EqualError(t, err, "invalid API token records of UserSignup 'john': unexpected end of JSON input")
+	})
+}
+
+func TestAPITokenSigner(t *testing.T) {
+	signer, err := auth.NewAPITokenSigner(apiTokenSecret)
+	require.NoError(t, err)
+	record := auth.APITokenRecord{
+		ID:        "b4c8b5d6",
+		Name:      "ci",
+		Workspace: "john",
+		ReadOnly:  true,
+		CreatedAt: time.Now(),
+	}
+
+	t.Run("short secret", func(t *testing.T) {
+		_, err := auth.NewAPITokenSigner("secret")
+		require.EqualError(t, err, "the secret of the API tokens must contain at least 32 characters")
+	})
+
+	t.Run("sign and parse", func(t *testing.T) {
+		// when
+		token, err := signer.Sign("john", record)
+		require.NoError(t, err)
+		claims, err := signer.Parse(token)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, auth.APITokenIssuer, claims.Issuer)
+		assert.Equal(t, "john", claims.Subject)
+		assert.Equal(t, "b4c8b5d6", claims.ID)
+		assert.Equal(t, "john", claims.Workspace)
+		assert.True(t, claims.ReadOnly)
+		assert.Nil(t, claims.ExpiresAt)
+	})
+
+	t.Run("expired token", func(t *testing.T) {
+		// given
+		expired := record
+		expiresAt := time.Now().Add(-time.Hour)
+		expired.ExpiresAt = &expiresAt
+		token, err := signer.Sign("john", expired)
+		require.NoError(t, err)
+
+		// when
+		_, err = signer.Parse(token)
+
+		// then
+		require.ErrorIs(t, err, jwt.ErrTokenExpired)
+	})
+
+	t.Run("signed with another secret", func(t *testing.T) {
+		// given
+		other, err := auth.NewAPITokenSigner("fedcba9876543210fedcba9876543210")
+		require.NoError(t, err)
+		token, err := other.Sign("john", record)
+		require.NoError(t, err)
+
+		// when
+		_, err = signer.Parse(token)
+
+		// then
+		require.ErrorIs(t, err, jwt.ErrTokenSignatureInvalid)
+	})
+}
+
+func TestAPITokenAuthenticator(t *testing.T) {
+	signer, err := auth.NewAPITokenSigner(apiTokenSecret)
+	require.NoError(t, err)
+	record := auth.APITokenRecord{
+		ID:        "b4c8b5d6",
+		Name:      "ci",
+		Workspace: "john-dev",
+		CreatedAt: time.Now(),
+	}
+	userSignup := &toolchainv1alpha1.UserSignup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "john",
+		},
+		Spec: toolchainv1alpha1.UserSignupSpec{
+			IdentityClaims: toolchainv1alpha1.IdentityClaimsEmbedded{
+				PropagatedClaims: toolchainv1alpha1.PropagatedClaims{
+					Sub:   "f:528d76ff:john",
+					Email: "john@email.tld",
+				},
+				PreferredUsername: "john@email.tld",
+			},
+		},
+	}
+	require.NoError(t, auth.SetAPITokenRecords(userSignup, []auth.APITokenRecord{record}))
+	authenticator := auth.NewAPITokenAuthenticator(signer, func(name string) (*toolchainv1alpha1.UserSignup, error) {
+		if name != userSignup.Name {
+			return nil, errors.New("not found")
+		}
+		return userSignup, nil
+	})
+	token, err := signer.Sign("john", record)
+	require.NoError(t, err)
+	newRequest := func(token string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/namespaces", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		return req
+	}
+
+	t.Run("valid token", func(t *testing.T) {
+		// when
+		identity, err := authenticator.Authenticate(newRequest(token))
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "f:528d76ff:john", identity.Subject)
+		assert.Equal(t, "john@email.tld", identity.Username)
+		assert.Equal(t, "john@email.tld", identity.Email)
+		assert.Nil(t, identity.Claims)
+		require.NotNil(t, identity.APIToken)
+		assert.Equal(t, "john-dev", identity.APIToken.Workspace)
+	})
+
+	t.Run("valid token in websocket protocol", func(t *testing.T) {
+		// given
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/namespaces/john-dev/pods/app/exec", nil)
+		req.Header.Set("Connection", "upgrade")
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Sec-WebSocket-Protocol", auth.WebSocketBearerProtocolPrefix+base64.RawURLEncoding.EncodeToString([]byte(token)))
+
+		// when
+		identity, err := authenticator.Authenticate(req)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "john@email.tld", identity.Username)
+	})
+
+	t.Run("not an API token", func(t *testing.T) {
+		// given
+		other := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{Issuer: "codeready-toolchain"})
+		otherToken, err := other.SignedString([]byte(apiTokenSecret))
+		require.NoError(t, err)
+
+		for name, req := range map[string]*http.Request{
+			"no token":            httptest.NewRequest(http.MethodGet, "/api/v1/namespaces", nil),
+			"invalid token":       newRequest("invalid"),
+			"token of SSO issuer": newRequest(otherToken),
+		} {
+			t.Run(name, func(t *testing.T) {
+				// when
+				identity, err := authenticator.Authenticate(req)
+
+				// then
+				require.NoError(t, err)
+				assert.Nil(t, identity)
+			})
+		}
+	})
+
+	t.Run("revoked token", func(t *testing.T) {
+		// given
+		revoked := record
+		revoked.ID = "a1b2c3d4"
+		revokedToken, err := signer.Sign("john", revoked)
+		require.NoError(t, err)
+
+		// when
+		identity, err := authenticator.Authenticate(newRequest(revokedToken))
+
+		// then
+		require.EqualError(t, err, "API token has been revoked")
+		assert.Nil(t, identity)
+	})
+
+	t.Run("unknown owner", func(t *testing.T) {
+		// given
+		unknownToken, err := signer.Sign("jane", record)
+		require.NoError(t, err)
+
+		// when
+		_, err = authenticator.Authenticate(newRequest(unknownToken))
+
+		// then
+		require.EqualError(t, err, "unable to get the owner of the API token: not found")
+	})
+
+	t.Run("tampered token", func(t *testing.T) {
+		// given
+		other, err := auth.NewAPITokenSigner("fedcba9876543210fedcba9876543210")
+		require.NoError(t, err)
+		otherToken, err := other.Sign("john", record)
+		require.NoError(t, err)
+
+		// when
+		_, err = authenticator.Authenticate(newRequest(otherToken))
+
+		// then
+		require.EqualError(t, err, "invalid API token: token signature is invalid: signature is invalid")
+	})
+}
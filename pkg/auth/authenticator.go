@@ -0,0 +1,269 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+
+	"k8s.io/apiserver/pkg/util/wsstream"
+)
+
+// WebSocketBearerProtocolPrefix is the prefix of the WebSocket sub-protocol used by Kubernetes clients to send a bearer token
+const WebSocketBearerProtocolPrefix = "base64url.bearer.authorization.k8s.io." //nolint:gosec
+
+// WebSocketProtocolHeader is the header which contains the WebSocket sub-protocols
+var WebSocketProtocolHeader = textproto.CanonicalMIMEHeaderKey("Sec-WebSocket-Protocol")
+
+// ErrNoCredentials is returned by the AuthenticatorChain when none of its authenticators found credentials in the request
+var ErrNoCredentials = errors.New("no token found: a Bearer token is expected")
+
+// Identity is the authenticated user of a request
+type Identity struct {
+	Subject     string
+	Username    string
+	Email       string
+	GivenName   string
+	FamilyName  string
+	Company     string
+	OriginalSub string
+	UserID      string
+	AccountID   string
+	// Roles are the roles granted to the user (eg. admin)
+	Roles []string
+	// Claims are the claims of the token used to authenticate the request, or nil if the request was not authenticated with a token
+	Claims *TokenClaims
+	// APIToken are the claims of the API token used to authenticate the request, or nil if the request was not authenticated
+	// with an API token. The access of the request is restricted to the workspace of the API token.
+	APIToken *APITokenClaims
+}
+
+func newIdentityFromClaims(claims *TokenClaims) *Identity {
+	return &Identity{
+		Subject:     claims.Subject,
+		Username:    claims.PreferredUsername,
+		Email:       claims.Email,
+		GivenName:   claims.GivenName,
+		FamilyName:  claims.FamilyName,
+		Company:     claims.Company,
+		OriginalSub: claims.OriginalSub,
+		UserID:      claims.UserID,
+		AccountID:   claims.AccountID,
+		Roles:       claims.Roles,
+		Claims:      claims,
+	}
+}
+
+// ContextSetter stores a value in the context of a request. It is implemented by both gin.Context and echo.Context.
+type ContextSetter interface {
+	Set(key string, value interface{})
+}
+
+// StoreInto stores the identity and its fields in the given request context
+func (i *Identity) StoreInto(ctx ContextSetter) {
+	ctx.Set(context.IdentityKey, i)
+	ctx.Set(context.SubKey, i.Subject)
+	ctx.Set(context.UsernameKey, i.Username)
+	ctx.Set(context.EmailKey, i.Email)
+	ctx.Set(context.GivenNameKey, i.GivenName)
+	ctx.Set(context.FamilyNameKey, i.FamilyName)
+	ctx.Set(context.CompanyKey, i.Company)
+	ctx.Set(context.OriginalSubKey, i.OriginalSub)
+	ctx.Set(context.UserIDKey, i.UserID)
+	ctx.Set(context.AccountIDKey, i.AccountID)
+	ctx.Set(context.RolesKey, i.Roles)
+	if i.Claims != nil {
+		ctx.Set(context.JWTClaimsKey, i.Claims)
+	}
+}
+
+// Authenticator authenticates the user of a request
+type Authenticator interface {
+	// Authenticate returns the identity of the user of the given request. It returns a nil identity and no error
+	// if the request does not contain the kind of credentials handled by the authenticator.
+	Authenticate(req *http.Request) (*Identity, error)
+}
+
+// AuthenticatorChain authenticates the requests with the first of its authenticators which finds credentials in the request
+type AuthenticatorChain []Authenticator
+
+// NewDefaultAuthenticatorChain returns the chain of authenticators used by the registration service and the proxy:
+// bearer token in the WebSocket sub-protocol, bearer token in the Authorization header and, if the CAs of the client
+// certificates are configured, client certificate.
+func NewDefaultAuthenticatorChain(tokenParser *TokenParser) AuthenticatorChain {
+	chain := AuthenticatorChain{
+		NewWebSocketBearerTokenAuthenticator(tokenParser),
+		NewBearerTokenAuthenticator(tokenParser),
+	}
+	if configuration.GetRegistrationServiceConfig().Auth().ClientCAFile() != "" {
+		chain = append(chain, NewClientCertificateAuthenticator())
+	}
+	return chain
+}
+
+// Authenticate returns the identity returned by the first authenticator which finds credentials in the request,
+// or ErrNoCredentials if none of the authenticators finds credentials
+func (c AuthenticatorChain) Authenticate(req *http.Request) (*Identity, error) {
+	for _, authenticator := range c {
+		identity, err := authenticator.Authenticate(req)
+		if err != nil {
+			return nil, err
+		}
+		if identity != nil {
+			return identity, nil
+		}
+	}
+	return nil, ErrNoCredentials
+}
+
+// BearerTokenAuthenticator authenticates the requests with the bearer token in the Authorization header
+type BearerTokenAuthenticator struct {
+	tokenParser *TokenParser
+}
+
+// NewBearerTokenAuthenticator returns a new BearerTokenAuthenticator which verifies the tokens with the given parser
+func NewBearerTokenAuthenticator(tokenParser *TokenParser) *BearerTokenAuthenticator {
+	return &BearerTokenAuthenticator{
+		tokenParser: tokenParser,
+	}
+}
+
+func (a *BearerTokenAuthenticator) Authenticate(req *http.Request) (*Identity, error) {
+	header := req.Header.Get("Authorization")
+	if header == "" {
+		return nil, nil
+	}
+	// we're failing fast here, if there is an Authorization header, it is used or it fails
+	if !strings.HasPrefix(header, "Bearer ") {
+		return nil, errors.New("found unknown authorization header")
+	}
+	fields := strings.Fields(header)
+	if len(fields) != 2 {
+		return nil, errors.New("found bearer token header, but no token")
+	}
+	return parseToken(a.tokenParser, fields[1])
+}
+
+// WebSocketBearerTokenAuthenticator authenticates the WebSocket requests with the bearer token in the WebSocket sub-protocol
+type WebSocketBearerTokenAuthenticator struct {
+	tokenParser *TokenParser
+}
+
+// NewWebSocketBearerTokenAuthenticator returns a new WebSocketBearerTokenAuthenticator which verifies the tokens with the given parser
+func NewWebSocketBearerTokenAuthenticator(tokenParser *TokenParser) *WebSocketBearerTokenAuthenticator {
+	return &WebSocketBearerTokenAuthenticator{
+		tokenParser: tokenParser,
+	}
+}
+
+func (a *WebSocketBearerTokenAuthenticator) Authenticate(req *http.Request) (*Identity, error) {
+	if !wsstream.IsWebSocketRequest(req) {
+		return nil, nil
+	}
+	token, err := extractTokenFromWebSocketRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	return parseToken(a.tokenParser, token)
+}
+
+func extractTokenFromWebSocketRequest(req *http.Request) (string, error) {
+	token := ""
+	sawTokenProtocol := false
+	for _, protocolHeader := range req.Header[WebSocketProtocolHeader] {
+		for _, protocol := range strings.Split(protocolHeader, ",") {
+			protocol = strings.TrimSpace(protocol)
+			if !strings.HasPrefix(protocol, WebSocketBearerProtocolPrefix) {
+				continue
+			}
+
+			if sawTokenProtocol {
+				return "", errors.New("multiple base64.bearer.authorization tokens specified")
+			}
+			sawTokenProtocol = true
+
+			encodedToken := strings.TrimPrefix(protocol, WebSocketBearerProtocolPrefix)
+			decodedToken, err := base64.RawURLEncoding.DecodeString(encodedToken)
+			if err != nil {
+				return "", fmt.Errorf("invalid base64.bearer.authorization token encoding: %w", err)
+			}
+			if !utf8.Valid(decodedToken) {
+				return "", errors.New("invalid base64.bearer.authorization token: contains non UTF-8-encoded runes")
+			}
+			token = string(decodedToken)
+		}
+	}
+
+	if len(token) == 0 {
+		return "", errors.New("no base64.bearer.authorization token found")
+	}
+
+	return token, nil
+}
+
+func parseToken(tokenParser *TokenParser, token string) (*Identity, error) {
+	claims, err := tokenParser.FromString(token)
+	if err != nil {
+		return nil, fmt.Errorf("unable to extract userID from token: %w", err)
+	}
+	return newIdentityFromClaims(claims), nil
+}
+
+// ClientCertificateAuthenticator authenticates the requests with the client certificate verified during the TLS handshake
+// (see ConfigureClientCertificates). The common name of the certificate is used as the subject and the username.
+type ClientCertificateAuthenticator struct{}
+
+// NewClientCertificateAuthenticator returns a new ClientCertificateAuthenticator
+func NewClientCertificateAuthenticator() *ClientCertificateAuthenticator {
+	return &ClientCertificateAuthenticator{}
+}
+
+func (a *ClientCertificateAuthenticator) Authenticate(req *http.Request) (*Identity, error) {
+	if req.TLS == nil || len(req.TLS.PeerCertificates) == 0 {
+		return nil, nil
+	}
+	if len(req.TLS.VerifiedChains) == 0 || len(req.TLS.VerifiedChains[0]) == 0 {
+		return nil, errors.New("client certificate is not verified")
+	}
+	cert := req.TLS.VerifiedChains[0][0]
+	if cert.Subject.CommonName == "" {
+		return nil, errors.New("client certificate does not comply to expected subject: common name missing")
+	}
+	identity := &Identity{
+		Subject:  cert.Subject.CommonName,
+		Username: cert.Subject.CommonName,
+	}
+	if len(cert.EmailAddresses) > 0 {
+		identity.Email = cert.EmailAddresses[0]
+	}
+	if len(cert.Subject.Organization) > 0 {
+		identity.Company = cert.Subject.Organization[0]
+	}
+	return identity, nil
+}
+
+// ConfigureClientCertificates configures the given TLS config to verify the client certificates signed by the CAs of the
+// given PEM file during the handshake. The clients which do not send a certificate are still accepted, so that they can
+// authenticate with a token.
+func ConfigureClientCertificates(tlsConfig *tls.Config, caFile string) error {
+	caCerts, err := os.ReadFile(caFile)
+	if err != nil {
+		return fmt.Errorf("unable to read the CAs of the client certificates: %w", err)
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(caCerts) {
+		return fmt.Errorf("no certificate found in '%s'", caFile)
+	}
+	tlsConfig.ClientCAs = clientCAs
+	tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	return nil
+}
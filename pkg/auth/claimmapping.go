@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+
+	"github.com/golang-jwt/jwt/v5"
+	"k8s.io/utils/pointer"
+)
+
+// The identity fields which can be mapped from the claims of the tokens
+const (
+	UsernameField    = "username"
+	EmailField       = "email"
+	SubjectField     = "subject"
+	NameField        = "name"
+	GivenNameField   = "givenName"
+	FamilyNameField  = "familyName"
+	CompanyField     = "company"
+	OriginalSubField = "originalSub"
+	UserIDField      = "userID"
+	AccountIDField   = "accountID"
+)
+
+// identityFields returns a pointer to the TokenClaims field of each identity field. The order of the list is the order
+// in which the missing required fields are reported.
+var identityFields = []struct {
+	name  string
+	field func(claims *TokenClaims) *string
+}{
+	{UsernameField, func(claims *TokenClaims) *string { return &claims.PreferredUsername }},
+	{EmailField, func(claims *TokenClaims) *string { return &claims.Email }},
+	{SubjectField, func(claims *TokenClaims) *string { return &claims.Subject }},
+	{NameField, func(claims *TokenClaims) *string { return &claims.Name }},
+	{GivenNameField, func(claims *TokenClaims) *string { return &claims.GivenName }},
+	{FamilyNameField, func(claims *TokenClaims) *string { return &claims.FamilyName }},
+	{CompanyField, func(claims *TokenClaims) *string { return &claims.Company }},
+	{OriginalSubField, func(claims *TokenClaims) *string { return &claims.OriginalSub }},
+	{UserIDField, func(claims *TokenClaims) *string { return &claims.UserID }},
+	{AccountIDField, func(claims *TokenClaims) *string { return &claims.AccountID }},
+}
+
+// DefaultClaimMappings returns the mappings used for the identity fields which are not configured
+func DefaultClaimMappings() map[string]configuration.ClaimMapping {
+	return map[string]configuration.ClaimMapping{
+		UsernameField:    {Claim: "preferred_username", Required: pointer.Bool(true)},
+		EmailField:       {Claim: "email", Required: pointer.Bool(true)},
+		SubjectField:     {Claim: "sub", Required: pointer.Bool(true)},
+		NameField:        {Claim: "name"},
+		GivenNameField:   {Claim: "given_name"},
+		FamilyNameField:  {Claim: "family_name"},
+		CompanyField:     {Claim: "company"},
+		OriginalSubField: {Claim: "original_sub"},
+		UserIDField:      {Claim: "user_id"},
+		AccountIDField:   {Claim: "account_id"},
+	}
+}
+
+// defaultClaimMapper uses the default claim mappings
+var defaultClaimMapper = &ClaimMapper{
+	mappings: DefaultClaimMappings(),
+}
+
+// ClaimMapper sets the identity fields of the TokenClaims from the claims of the tokens
+type ClaimMapper struct {
+	mappings map[string]configuration.ClaimMapping
+}
+
+// NewClaimMapper creates a new ClaimMapper with the given mappings, indexed by identity field.
+// The default mappings are used for the identity fields which are not in the given mappings, and the default requirement
+// of an identity field is kept if its mapping does not explicitly set it.
+func NewClaimMapper(mappings map[string]configuration.ClaimMapping) (*ClaimMapper, error) {
+	m := &ClaimMapper{
+		mappings: DefaultClaimMappings(),
+	}
+	for field, mapping := range mappings {
+		if _, known := m.mappings[field]; !known {
+			return nil, fmt.Errorf("unknown identity field '%s' in claim mappings", field)
+		}
+		if mapping.Claim == "" {
+			return nil, fmt.Errorf("no claim given for identity field '%s' in claim mappings", field)
+		}
+		if mapping.Required == nil {
+			mapping.Required = m.mappings[field].Required
+		}
+		m.mappings[field] = mapping
+	}
+	return m, nil
+}
+
+// apply sets the identity fields from the given raw claims and verifies that the required ones are not empty
+func (m *ClaimMapper) apply(claims *TokenClaims, rawClaims jwt.MapClaims) error {
+	for _, f := range identityFields {
+		mapping := m.mappings[f.name]
+		value := lookupClaim(rawClaims, mapping.Claim)
+		*f.field(claims) = value
+		if mapping.Required != nil && *mapping.Required && value == "" {
+			return fmt.Errorf("token does not comply to expected claims: %s missing", f.name)
+		}
+	}
+	return nil
+}
+
+// lookupClaim returns the value of the claim with the given path, as a string.
+// Returns an empty string if the claim does not exist or if it is not a string, a number or a boolean.
+func lookupClaim(rawClaims map[string]interface{}, path string) string {
+	value, _ := lookupRawClaim(rawClaims, path)
+	return claimValue(value)
+}
+
+// lookupRawClaim returns the value of the claim with the given path. The segments of the path of a nested claim
+// are separated by dots (eg. `org.id`), but a top-level claim whose name contains dots is matched first.
+func lookupRawClaim(rawClaims map[string]interface{}, path string) (interface{}, bool) {
+	if value, found := rawClaims[path]; found {
+		return value, true
+	}
+	segments := strings.SplitN(path, ".", 2)
+	if len(segments) < 2 {
+		return nil, false
+	}
+	if nested, ok := rawClaims[segments[0]].(map[string]interface{}); ok {
+		return lookupRawClaim(nested, segments[1])
+	}
+	return nil, false
+}
+
+func claimValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,190 @@
+package auth_test
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/auth"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	commonconfig "github.com/codeready-toolchain/toolchain-common/pkg/configuration"
+	commontest "github.com/codeready-toolchain/toolchain-common/pkg/test"
+	testconfig "github.com/codeready-toolchain/toolchain-common/pkg/test/config"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/utils/pointer"
+)
+
+func (s *TestTokenParserSuite) TestClaimMappings() {
+	restore := commontest.SetEnvVarAndRestore(s.T(), commonconfig.WatchNamespaceEnvVar, commontest.HostOperatorNs)
+	defer restore()
+	s.OverrideApplicationDefault(testconfig.RegistrationService().
+		Environment(configuration.UnitTestsEnvironment))
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(s.T(), err)
+	keyServer := newRotatingKeyServer(s.T())
+	defer keyServer.Close()
+	keyServer.setKeys(s.T(), map[string]crypto.Signer{"rsa": key})
+	keyManager, err := auth.NewKeyManagerForURL(keyServer.URL)
+	require.NoError(s.T(), err)
+	defer keyManager.Stop()
+
+	signedToken := func(claims jwt.MapClaims) string {
+		claims["exp"] = time.Now().Add(time.Hour).Unix()
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "rsa"
+		signed, err := token.SignedString(key)
+		require.NoError(s.T(), err)
+		return signed
+	}
+
+	claimMapper, err := auth.NewClaimMapper(map[string]configuration.ClaimMapping{
+		auth.UsernameField:  {Claim: "upn", Required: pointer.Bool(true)},
+		auth.EmailField:     {Claim: "mail", Required: pointer.Bool(false)},
+		auth.AccountIDField: {Claim: "org.id", Required: pointer.Bool(true)},
+		auth.CompanyField:   {Claim: "https://claims.example.com/org.name"},
+	})
+	require.NoError(s.T(), err)
+	tokenParser, err := auth.NewTokenParserWithClaimMapper(keyManager, claimMapper)
+	require.NoError(s.T(), err)
+
+	s.Run("invalid mappings", func() {
+		_, err := auth.NewClaimMapper(map[string]configuration.ClaimMapping{"nickname": {Claim: "nick"}})
+		require.EqualError(s.T(), err, "unknown identity field 'nickname' in claim mappings")
+
+		_, err = auth.NewClaimMapper(map[string]configuration.ClaimMapping{auth.UsernameField: {Required: pointer.Bool(true)}})
+		require.EqualError(s.T(), err, "no claim given for identity field 'username' in claim mappings")
+	})
+
+	s.Run("default mappings", func() {
+		tokenParser, err := auth.NewTokenParser(keyManager)
+		require.NoError(s.T(), err)
+
+		claims, err := tokenParser.FromString(signedToken(jwt.MapClaims{
+			"sub":                "f:528d76ff:jsmith",
+			"preferred_username": "jsmith",
+			"email":              "jsmith@email.tld",
+			"given_name":         "John",
+			"family_name":        "Smith",
+			"company":            "Acme",
+			"original_sub":       "original",
+			"user_id":            "123",
+			"account_id":         "456",
+		}))
+
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), "f:528d76ff:jsmith", claims.Subject)
+		assert.Equal(s.T(), "jsmith", claims.PreferredUsername)
+		assert.Equal(s.T(), "jsmith@email.tld", claims.Email)
+		assert.Equal(s.T(), "John", claims.GivenName)
+		assert.Equal(s.T(), "Smith", claims.FamilyName)
+		assert.Equal(s.T(), "Acme", claims.Company)
+		assert.Equal(s.T(), "original", claims.OriginalSub)
+		assert.Equal(s.T(), "123", claims.UserID)
+		assert.Equal(s.T(), "456", claims.AccountID)
+	})
+
+	s.Run("custom mappings", func() {
+		claims, err := tokenParser.FromString(signedToken(jwt.MapClaims{
+			"sub":  "f:528d76ff:jsmith",
+			"upn":  "jsmith@example.com",
+			"mail": "john.smith@example.com",
+			"org": map[string]interface{}{
+				"id": 1234567,
+			},
+			"https://claims.example.com/org.name": "Acme",
+			// ignored, since they are not mapped
+			"preferred_username": "other",
+			"email":              "other@email.tld",
+		}))
+
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), "f:528d76ff:jsmith", claims.Subject)
+		assert.Equal(s.T(), "jsmith@example.com", claims.PreferredUsername)
+		assert.Equal(s.T(), "john.smith@example.com", claims.Email)
+		assert.Equal(s.T(), "1234567", claims.AccountID)
+		assert.Equal(s.T(), "Acme", claims.Company)
+	})
+
+	s.Run("optional claim missing", func() {
+		claims, err := tokenParser.FromString(signedToken(jwt.MapClaims{
+			"sub": "f:528d76ff:jsmith",
+			"upn": "jsmith@example.com",
+			"org": map[string]interface{}{
+				"id": "1234567",
+			},
+		}))
+
+		require.NoError(s.T(), err)
+		assert.Empty(s.T(), claims.Email)
+	})
+
+	s.Run("required nested claim missing", func() {
+		_, err := tokenParser.FromString(signedToken(jwt.MapClaims{
+			"sub": "f:528d76ff:jsmith",
+			"upn": "jsmith@example.com",
+			"org": map[string]interface{}{
+				"name": "Acme",
+			},
+		}))
+
+		require.EqualError(s.T(), err, "token does not comply to expected claims: accountID missing")
+	})
+
+	s.Run("required claim missing", func() {
+		_, err := tokenParser.FromString(signedToken(jwt.MapClaims{
+			"sub":                "f:528d76ff:jsmith",
+			"preferred_username": "jsmith",
+			"org": map[string]interface{}{
+				"id": "1234567",
+			},
+		}))
+
+		require.EqualError(s.T(), err, "token does not comply to expected claims: username missing")
+	})
+
+	s.Run("overridden claim keeps the default requirement", func() {
+		claimMapper, err := auth.NewClaimMapper(map[string]configuration.ClaimMapping{
+			auth.UsernameField: {Claim: "upn"},
+		})
+		require.NoError(s.T(), err)
+		tokenParser, err := auth.NewTokenParserWithClaimMapper(keyManager, claimMapper)
+		require.NoError(s.T(), err)
+
+		_, err = tokenParser.FromString(signedToken(jwt.MapClaims{
+			"sub":                "f:528d76ff:jsmith",
+			"preferred_username": "jsmith",
+			"email":              "jsmith@email.tld",
+		}))
+
+		require.EqualError(s.T(), err, "token does not comply to expected claims: username missing")
+	})
+
+	s.Run("mappings of an issuer", func() {
+		issuerMapper, err := auth.NewClaimMapper(map[string]configuration.ClaimMapping{
+			auth.SubjectField: {Claim: "oid", Required: pointer.Bool(true)},
+		})
+		require.NoError(s.T(), err)
+		tokenParser, err := auth.NewTokenParserForIssuers(auth.Issuer{
+			Name:        "https://sso.test.org",
+			KeyManager:  keyManager,
+			ClaimMapper: issuerMapper,
+		})
+		require.NoError(s.T(), err)
+
+		claims, err := tokenParser.FromString(signedToken(jwt.MapClaims{
+			"iss":                "https://sso.test.org",
+			"sub":                "pairwise-subject",
+			"oid":                "object-id",
+			"preferred_username": "jsmith",
+			"email":              "jsmith@email.tld",
+		}))
+
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), "object-id", claims.Subject)
+	})
+}
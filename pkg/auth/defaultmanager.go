@@ -3,6 +3,8 @@ package auth
 import (
 	"errors"
 	"sync"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
 )
 
 // DefaultTokenParserConfiguration represents a partition of the configuration
@@ -15,6 +17,7 @@ type DefaultTokenParserConfiguration interface {
 var (
 	initDefaultTokenParserOnce = &sync.Once{}
 	defaultTokenParser         *TokenParser
+	defaultOIDCDiscovery       *OIDCDiscovery
 )
 
 // InitializeDefaultTokenParser creates the default token parser if it has not created yet.
@@ -23,12 +26,38 @@ var (
 func InitializeDefaultTokenParser() (*TokenParser, error) {
 	var returnErr error
 	initDefaultTokenParserOnce.Do(func() {
-		keyManager, err := NewKeyManager()
+		tokenParser, err := newTokenParserFromConfig()
+		if err != nil {
+			returnErr = err
+			return
+		}
+		// the cache is shared by the registration service and the proxy, since they both use the default parser
+		authCfg := configuration.GetRegistrationServiceConfig().Auth()
+		roleMapper, err := NewRoleMapper(authCfg.RoleMappings())
 		if err != nil {
 			returnErr = err
 			return
 		}
-		defaultTokenParser, returnErr = NewTokenParser(keyManager)
+		tokenParser.UseRoleMapper(roleMapper)
+		if size := authCfg.TokenCacheSize(); size > 0 {
+			tokenParser.UseCache(NewTokenCache(size, authCfg.TokenCacheMaxTTL()))
+		}
+		if endpoint := authCfg.IntrospectionURL(); endpoint != "" {
+			introspector, err := NewTokenIntrospector(TokenIntrospectorOptions{
+				Endpoint:     endpoint,
+				ClientID:     authCfg.IntrospectionClientID(),
+				ClientSecret: authCfg.IntrospectionClientSecret(),
+				CacheSize:    authCfg.IntrospectionCacheSize(),
+				CacheTTL:     authCfg.IntrospectionCacheTTL(),
+				FailOpen:     authCfg.IntrospectionFailOpen(),
+			})
+			if err != nil {
+				returnErr = err
+				return
+			}
+			tokenParser.UseIntrospector(introspector)
+		}
+		defaultTokenParser = tokenParser
 	})
 	if returnErr != nil {
 		return nil, returnErr
@@ -36,6 +65,93 @@ func InitializeDefaultTokenParser() (*TokenParser, error) {
 	return defaultTokenParser, nil
 }
 
+// newTokenParserFromConfig creates a TokenParser for the configured OpenID Provider and trusted issuers, or for the
+// configured public keys URL if none of them is configured
+func newTokenParserFromConfig() (*TokenParser, error) {
+	authCfg := configuration.GetRegistrationServiceConfig().Auth()
+	trustedIssuers, err := authCfg.TrustedIssuers()
+	if err != nil {
+		return nil, err
+	}
+	discoveries := map[string]*OIDCDiscovery{}
+	if issuerURL := authCfg.OIDCIssuerURL(); issuerURL != "" {
+		discovery, err := NewOIDCDiscovery(issuerURL)
+		if err != nil {
+			return nil, err
+		}
+		defaultOIDCDiscovery = discovery
+		discoveries[discovery.Metadata().Issuer] = discovery
+		if !containsIssuer(trustedIssuers, discovery.Metadata().Issuer) {
+			// accept the tokens from the OpenID Provider regardless of their audience
+			trustedIssuers = append(trustedIssuers, configuration.TrustedIssuer{Issuer: discovery.Metadata().Issuer})
+		}
+	}
+	if len(trustedIssuers) == 0 {
+		keyManager, err := NewKeyManager()
+		if err != nil {
+			return nil, err
+		}
+		claimMapper, err := NewClaimMapper(authCfg.ClaimMappings())
+		if err != nil {
+			return nil, err
+		}
+		return NewTokenParserWithClaimMapper(keyManager, claimMapper)
+	}
+	issuers := make([]Issuer, 0, len(trustedIssuers))
+	for _, trustedIssuer := range trustedIssuers {
+		keyManager, err := newKeyManagerForTrustedIssuer(trustedIssuer, discoveries)
+		if err != nil {
+			return nil, err
+		}
+		// the mappings of the issuer override the global ones
+		mappings := authCfg.ClaimMappings()
+		if mappings == nil {
+			mappings = map[string]configuration.ClaimMapping{}
+		}
+		for field, mapping := range trustedIssuer.ClaimMappings {
+			mappings[field] = mapping
+		}
+		claimMapper, err := NewClaimMapper(mappings)
+		if err != nil {
+			return nil, err
+		}
+		issuers = append(issuers, Issuer{
+			Name:           trustedIssuer.Issuer,
+			KeyManager:     keyManager,
+			Audiences:      trustedIssuer.Audiences,
+			RequiredClaims: trustedIssuer.RequiredClaims,
+			Algorithms:     trustedIssuer.Algorithms,
+			ClaimMapper:    claimMapper,
+		})
+	}
+	return NewTokenParserForIssuers(issuers...)
+}
+
+// newKeyManagerForTrustedIssuer creates a KeyManager for the configured public keys URL of the given issuer,
+// or for the keys URL discovered from its OpenID configuration
+func newKeyManagerForTrustedIssuer(trustedIssuer configuration.TrustedIssuer, discoveries map[string]*OIDCDiscovery) (*KeyManager, error) {
+	if trustedIssuer.PublicKeysURL != "" {
+		return NewKeyManagerForURL(trustedIssuer.PublicKeysURL)
+	}
+	discovery, found := discoveries[trustedIssuer.Issuer]
+	if !found {
+		var err error
+		if discovery, err = NewOIDCDiscovery(trustedIssuer.Issuer); err != nil {
+			return nil, err
+		}
+	}
+	return NewKeyManagerForDiscovery(discovery)
+}
+
+func containsIssuer(trustedIssuers []configuration.TrustedIssuer, issuer string) bool {
+	for _, trustedIssuer := range trustedIssuers {
+		if trustedIssuer.Issuer == issuer {
+			return true
+		}
+	}
+	return false
+}
+
 // DefaultTokenParser returns the existing TokenManager instance.
 func DefaultTokenParser() (*TokenParser, error) { //nolint:unparam
 	if defaultTokenParser == nil {
@@ -43,3 +159,9 @@ func DefaultTokenParser() (*TokenParser, error) { //nolint:unparam
 	}
 	return defaultTokenParser, nil
 }
+
+// DefaultOIDCDiscovery returns the discovery of the configured OpenID Provider, or nil if no OpenID Provider is configured.
+// The default TokenParser must have been initialized first.
+func DefaultOIDCDiscovery() *OIDCDiscovery {
+	return defaultOIDCDiscovery
+}
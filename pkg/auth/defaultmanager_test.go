@@ -1,12 +1,19 @@
 package auth
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"sync"
 	"testing"
 
 	"github.com/codeready-toolchain/registration-service/pkg/configuration"
 	"github.com/codeready-toolchain/registration-service/test"
 	"github.com/codeready-toolchain/registration-service/test/fake"
+	authsupport "github.com/codeready-toolchain/toolchain-common/pkg/test/auth"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -92,3 +99,80 @@ func (s *TestDefaultManagerSuite) TestKeyManagerDefaultTokenParser() {
 		}
 	})
 }
+
+func (s *TestDefaultManagerSuite) TestDefaultTokenParserWithOIDCDiscovery() {
+	// reset the singletons
+	defaultTokenParser = nil
+	defaultOIDCDiscovery = nil
+	initDefaultTokenParserOnce = &sync.Once{}
+	defer func() {
+		defaultTokenParser = nil
+		defaultOIDCDiscovery = nil
+		initDefaultTokenParserOnce = &sync.Once{}
+	}()
+
+	tokengenerator := authsupport.NewTokenManager()
+	kid := uuid.NewString()
+	_, err := tokengenerator.AddPrivateKey(kid)
+	require.NoError(s.T(), err)
+	keyServer := tokengenerator.NewKeyServer()
+	defer keyServer.Close()
+	var providerURL string
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, err := fmt.Fprintf(w, `{"issuer":"%[1]s","jwks_uri":"%[2]s","authorization_endpoint":"%[1]s/protocol/openid-connect/auth"}`, providerURL, keyServer.URL)
+		assert.NoError(s.T(), err)
+	}))
+	defer provider.Close()
+	providerURL = provider.URL
+	s.T().Setenv(configuration.AuthOIDCIssuerURLEnvVar, provider.URL)
+
+	// when
+	tokenParser, err := InitializeDefaultTokenParser()
+
+	// then
+	require.NoError(s.T(), err)
+	discovery := DefaultOIDCDiscovery()
+	require.NotNil(s.T(), discovery)
+	defer discovery.Stop()
+	assert.Equal(s.T(), provider.URL+"/protocol/openid-connect/auth", discovery.Metadata().AuthorizationEndpoint)
+
+	s.Run("token from the discovered issuer", func() {
+		token, err := tokengenerator.GenerateSignedToken(*authsupport.NewIdentity(), kid, authsupport.WithEmailClaim("jsmith@email.tld"),
+			func(token *jwt.Token) {
+				token.Claims.(*authsupport.MyClaims).Issuer = provider.URL
+			})
+		require.NoError(s.T(), err)
+
+		_, err = tokenParser.FromString(token)
+
+		require.NoError(s.T(), err)
+	})
+
+	s.Run("token from another issuer", func() {
+		token, err := tokengenerator.GenerateSignedToken(*authsupport.NewIdentity(), kid, authsupport.WithEmailClaim("jsmith@email.tld"))
+		require.NoError(s.T(), err)
+
+		_, err = tokenParser.FromString(token)
+
+		require.EqualError(s.T(), err, "token issuer is not trusted: 'codeready-toolchain'")
+	})
+}
+
+func (s *TestDefaultManagerSuite) TestDefaultTokenParserWithInvalidTrustedIssuers() {
+	// reset the singletons
+	defaultTokenParser = nil
+	initDefaultTokenParserOnce = &sync.Once{}
+	defer func() {
+		defaultTokenParser = nil
+		initDefaultTokenParserOnce = &sync.Once{}
+	}()
+	fake.MockKeycloakCertsCall(s.T())
+	s.T().Setenv(configuration.AuthTrustedIssuersEnvVar, `{"issuer":"https://sso.test.org"}`)
+
+	// when
+	_, err := InitializeDefaultTokenParser()
+
+	// then
+	require.EqualError(s.T(), err, "unable to parse 'REGISTRATION_SERVICE_AUTH_TRUSTED_ISSUERS': json: cannot unmarshal object into Go value of type []configuration.TrustedIssuer")
+}
@@ -0,0 +1,176 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+
+	"k8s.io/utils/lru"
+)
+
+// ErrTokenNotActive is returned when the token introspection endpoint reports that a token is not active,
+// for example because it was revoked or because the SSO session it belongs to was terminated
+var ErrTokenNotActive = errors.New("token is not active")
+
+// TokenIntrospectorOptions are the options of a TokenIntrospector
+type TokenIntrospectorOptions struct {
+	// Endpoint is the URL of the OAuth 2.0 token introspection endpoint
+	Endpoint string
+	// ClientID and ClientSecret are used to authenticate with the introspection endpoint, if not empty
+	ClientID     string
+	ClientSecret string
+	// CacheSize is the maximum number of introspection results kept in the cache
+	CacheSize int
+	// CacheTTL is the time an introspection result is kept in the cache. The cache is disabled if zero or negative.
+	CacheTTL time.Duration
+	// FailOpen accepts the tokens when the introspection endpoint fails, instead of rejecting them
+	FailOpen bool
+}
+
+// TokenIntrospector checks that the tokens are still active with an OAuth 2.0 token introspection endpoint (RFC 7662).
+// The results are cached for a short time, so that the endpoint is not called for each request.
+type TokenIntrospector struct {
+	options TokenIntrospectorOptions
+	client  *http.Client
+	cache   *lru.Cache
+	now     func() time.Time
+}
+
+type introspectionResult struct {
+	active    bool
+	expiresAt time.Time
+}
+
+// introspectionResponse is the part of the introspection response used by the TokenIntrospector
+type introspectionResponse struct {
+	Active bool  `json:"active"`
+	Exp    int64 `json:"exp,omitempty"`
+}
+
+// NewTokenIntrospector creates a new TokenIntrospector with the given options
+func NewTokenIntrospector(options TokenIntrospectorOptions) (*TokenIntrospector, error) {
+	if options.Endpoint == "" {
+		return nil, errors.New("no endpoint given when creating TokenIntrospector")
+	}
+	i := &TokenIntrospector{
+		options: options,
+		client:  newHTTPClient(),
+		now:     time.Now,
+	}
+	i.client.Timeout = 5 * time.Second
+	if options.CacheTTL > 0 && options.CacheSize > 0 {
+		i.cache = lru.New(options.CacheSize)
+	}
+	return i, nil
+}
+
+// Introspect returns ErrTokenNotActive if the given token is not active. If the introspection endpoint fails,
+// it returns an error unless the TokenIntrospector fails open.
+func (i *TokenIntrospector) Introspect(token string) error {
+	key := sha256.Sum256([]byte(token))
+	if result, found := i.cached(key); found {
+		return activeOrError(result.active)
+	}
+	response, err := i.introspect(token)
+	if err != nil {
+		if i.options.FailOpen {
+			tokenIntrospectionCounterVec.WithLabelValues(metricsLabelFailOpen).Inc()
+			log.Error(nil, err, "token introspection failed, accepting the token")
+			return nil
+		}
+		tokenIntrospectionCounterVec.WithLabelValues(metricsLabelFailure).Inc()
+		return fmt.Errorf("unable to introspect token: %w", err)
+	}
+	if response.Active {
+		tokenIntrospectionCounterVec.WithLabelValues(metricsLabelActive).Inc()
+	} else {
+		tokenIntrospectionCounterVec.WithLabelValues(metricsLabelInactive).Inc()
+	}
+	i.add(key, response)
+	return activeOrError(response.Active)
+}
+
+func activeOrError(active bool) error {
+	if !active {
+		return ErrTokenNotActive
+	}
+	return nil
+}
+
+func (i *TokenIntrospector) cached(key [sha256.Size]byte) (*introspectionResult, bool) {
+	if i.cache == nil {
+		return nil, false
+	}
+	value, found := i.cache.Get(key)
+	if !found {
+		tokenIntrospectionCacheLookupsCounterVec.WithLabelValues(metricsLabelMiss).Inc()
+		return nil, false
+	}
+	result := value.(*introspectionResult)
+	if !i.now().Before(result.expiresAt) {
+		i.cache.Remove(key)
+		tokenIntrospectionCacheLookupsCounterVec.WithLabelValues(metricsLabelMiss).Inc()
+		return nil, false
+	}
+	tokenIntrospectionCacheLookupsCounterVec.WithLabelValues(metricsLabelHit).Inc()
+	return result, true
+}
+
+// add caches the given result until the end of the TTL of the cache, or until the token expires if it expires earlier
+func (i *TokenIntrospector) add(key [sha256.Size]byte, response *introspectionResponse) {
+	if i.cache == nil {
+		return
+	}
+	expiresAt := i.now().Add(i.options.CacheTTL)
+	if response.Exp > 0 && time.Unix(response.Exp, 0).Before(expiresAt) {
+		expiresAt = time.Unix(response.Exp, 0)
+	}
+	i.cache.Add(key, &introspectionResult{
+		active:    response.Active,
+		expiresAt: expiresAt,
+	})
+}
+
+func (i *TokenIntrospector) introspect(token string) (*introspectionResponse, error) {
+	form := url.Values{}
+	form.Set("token", token)
+	form.Set("token_type_hint", "access_token")
+	req, err := http.NewRequest(http.MethodPost, i.options.Endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if i.options.ClientID != "" {
+		req.SetBasicAuth(url.QueryEscape(i.options.ClientID), url.QueryEscape(i.options.ClientSecret))
+	}
+	res, err := i.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := res.Body.Close(); err != nil {
+			log.Error(nil, err, "failed to close response after reading")
+		}
+	}()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response status from the introspection endpoint: %s", res.Status)
+	}
+	response := &introspectionResponse{}
+	if err := json.Unmarshal(body, response); err != nil {
+		return nil, fmt.Errorf("invalid response from the introspection endpoint: %w", err)
+	}
+	return response, nil
+}
@@ -0,0 +1,174 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/test/fake"
+	authsupport "github.com/codeready-toolchain/toolchain-common/pkg/test/auth"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenIntrospector(t *testing.T) {
+	tokengenerator, tokenParser := newTestTokenParser(t)
+	server := fake.NewIntrospectionServer(t, "registration-service", "s3cr3t")
+	reg := prometheus.NewRegistry()
+	tokenIntrospectionCounterVec.Reset()
+	tokenIntrospectionCacheLookupsCounterVec.Reset()
+	RegisterMetrics(reg)
+
+	newIntrospector := func(t *testing.T, options TokenIntrospectorOptions) *TokenIntrospector {
+		if options.Endpoint == "" {
+			options.Endpoint = server.URL
+		}
+		options.ClientID = "registration-service"
+		options.ClientSecret = "s3cr3t"
+		introspector, err := NewTokenIntrospector(options)
+		require.NoError(t, err)
+		return introspector
+	}
+	counter := func(result string) float64 {
+		return testutil.ToFloat64(tokenIntrospectionCounterVec.WithLabelValues(result))
+	}
+
+	t.Run("no endpoint", func(t *testing.T) {
+		_, err := NewTokenIntrospector(TokenIntrospectorOptions{})
+		require.EqualError(t, err, "no endpoint given when creating TokenIntrospector")
+	})
+
+	t.Run("active and inactive tokens", func(t *testing.T) {
+		// given
+		introspector := newIntrospector(t, TokenIntrospectorOptions{})
+		server.SetActive("active-token", true)
+		server.SetActive("revoked-token", false)
+		active, inactive := counter(metricsLabelActive), counter(metricsLabelInactive)
+
+		// when
+		errActive := introspector.Introspect("active-token")
+		errRevoked := introspector.Introspect("revoked-token")
+		errUnknown := introspector.Introspect("unknown-token")
+
+		// then
+		require.NoError(t, errActive)
+		require.ErrorIs(t, errRevoked, ErrTokenNotActive)
+		require.EqualError(t, errUnknown, "token is not active")
+		assert.InDelta(t, active+1, counter(metricsLabelActive), 0)
+		assert.InDelta(t, inactive+2, counter(metricsLabelInactive), 0)
+	})
+
+	t.Run("invalid client credentials", func(t *testing.T) {
+		// given
+		introspector, err := NewTokenIntrospector(TokenIntrospectorOptions{
+			Endpoint: server.URL,
+			ClientID: "other",
+		})
+		require.NoError(t, err)
+
+		// when
+		err = introspector.Introspect("active-token")
+
+		// then
+		require.EqualError(t, err, "unable to introspect token: unexpected response status from the introspection endpoint: 401 Unauthorized")
+	})
+
+	t.Run("results are cached", func(t *testing.T) {
+		// given
+		now := time.Now()
+		introspector := newIntrospector(t, TokenIntrospectorOptions{
+			CacheSize: 10,
+			CacheTTL:  30 * time.Second,
+		})
+		introspector.now = func() time.Time {
+			return now
+		}
+		server.SetActive("cached-token", true)
+		requests := server.Requests()
+
+		// when
+		err1 := introspector.Introspect("cached-token")
+		server.SetActive("cached-token", false)
+		err2 := introspector.Introspect("cached-token")
+		now = now.Add(30 * time.Second)
+		err3 := introspector.Introspect("cached-token")
+
+		// then
+		require.NoError(t, err1)
+		require.NoError(t, err2)
+		require.ErrorIs(t, err3, ErrTokenNotActive)
+		assert.Equal(t, requests+2, server.Requests())
+		assert.InDelta(t, float64(1), testutil.ToFloat64(tokenIntrospectionCacheLookupsCounterVec.WithLabelValues(metricsLabelHit)), 0)
+		assert.InDelta(t, float64(2), testutil.ToFloat64(tokenIntrospectionCacheLookupsCounterVec.WithLabelValues(metricsLabelMiss)), 0)
+	})
+
+	t.Run("failures", func(t *testing.T) {
+		server.SetActive("active-token", true)
+		server.SetFailing(true)
+		defer server.SetFailing(false)
+
+		t.Run("fail closed", func(t *testing.T) {
+			// given
+			introspector := newIntrospector(t, TokenIntrospectorOptions{
+				CacheSize: 10,
+				CacheTTL:  30 * time.Second,
+			})
+			failures := counter(metricsLabelFailure)
+
+			// when
+			err := introspector.Introspect("active-token")
+
+			// then
+			require.EqualError(t, err, "unable to introspect token: unexpected response status from the introspection endpoint: 500 Internal Server Error")
+			assert.InDelta(t, failures+1, counter(metricsLabelFailure), 0)
+		})
+
+		t.Run("fail open", func(t *testing.T) {
+			// given
+			introspector := newIntrospector(t, TokenIntrospectorOptions{
+				FailOpen: true,
+			})
+			failures := counter(metricsLabelFailOpen)
+
+			// when
+			err := introspector.Introspect("revoked-token")
+
+			// then
+			require.NoError(t, err)
+			assert.InDelta(t, failures+1, counter(metricsLabelFailOpen), 0)
+		})
+
+		t.Run("endpoint not reachable", func(t *testing.T) {
+			// given
+			introspector := newIntrospector(t, TokenIntrospectorOptions{
+				Endpoint: "http://127.0.0.1:1",
+			})
+
+			// when
+			err := introspector.Introspect("active-token")
+
+			// then
+			require.ErrorContains(t, err, "unable to introspect token: ")
+		})
+	})
+
+	t.Run("token parser rejects inactive tokens", func(t *testing.T) {
+		// given
+		tokenParser.UseCache(NewTokenCache(10, time.Minute))
+		tokenParser.UseIntrospector(newIntrospector(t, TokenIntrospectorOptions{}))
+		token, err := tokengenerator.GenerateSignedToken(*authsupport.NewIdentity(), tokengenerator.kid, authsupport.WithEmailClaim("jsmith@email.tld"))
+		require.NoError(t, err)
+		server.SetActive(token, true)
+		_, err = tokenParser.FromString(token)
+		require.NoError(t, err)
+
+		// when
+		server.SetActive(token, false)
+		_, err = tokenParser.FromString(token)
+
+		// then
+		require.EqualError(t, err, "token is not active")
+	})
+}
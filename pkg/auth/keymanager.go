@@ -2,12 +2,18 @@ package auth
 
 import (
 	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rsa"
 	"crypto/tls"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/codeready-toolchain/registration-service/pkg/configuration"
 	"github.com/codeready-toolchain/registration-service/pkg/log"
@@ -23,10 +29,11 @@ type KeyManagerConfiguration interface {
 	GetEnvironment() string
 }
 
-// PublicKey represents an RSA public key with a Key ID
+// PublicKey represents a public key with a Key ID.
+// The key is either an *rsa.PublicKey, an *ecdsa.PublicKey or an ed25519.PublicKey.
 type PublicKey struct {
 	KeyID string
-	Key   *rsa.PublicKey
+	Key   crypto.PublicKey
 }
 
 // JSONKeys the remote keys encoded in a json document
@@ -35,19 +42,63 @@ type JSONKeys struct {
 }
 
 // KeyManager manages the public keys for token validation.
+// The keys are refreshed periodically in the background and, with a rate limit, on demand when a token
+// is signed with a key which is not known yet (eg. after a key rotation on the SSO side).
 type KeyManager struct {
-	keyMap map[string]*rsa.PublicKey
+	// keysEndpointURL returns the URL to fetch the keys from, or is nil if the keys are not fetched from a remote service
+	keysEndpointURL    func() string
+	minRefetchInterval time.Duration
+	// mu guards the keyMap and lastFetch fields
+	mu        sync.RWMutex
+	keyMap    map[string]crypto.PublicKey
+	lastFetch time.Time
+	// fetchMu makes sure that only one fetch is running at a time
+	fetchMu    sync.Mutex
+	httpClient *http.Client
+	// discovery is the discovery of the OpenID Provider whose keys are fetched, or nil if the keys URL is not discovered
+	discovery *OIDCDiscovery
+	stop      chan struct{}
+	stopOnce  sync.Once
 }
 
-// NewKeyManager creates a new KeyManager and retrieves the public keys from the given URL.
+// NewKeyManager creates a new KeyManager and retrieves the public keys from the configured URL.
 func NewKeyManager() (*KeyManager, error) {
+	return NewKeyManagerForURL(configuration.GetRegistrationServiceConfig().Auth().AuthClientPublicKeysURL())
+}
+
+// NewKeyManagerForURL creates a new KeyManager and retrieves the public keys from the given URL.
+func NewKeyManagerForURL(keysEndpointURL string) (*KeyManager, error) {
+	if keysEndpointURL == "" {
+		return newKeyManager(nil)
+	}
+	return newKeyManager(func() string {
+		return keysEndpointURL
+	})
+}
+
+// NewKeyManagerForDiscovery creates a new KeyManager and retrieves the public keys from the `jwks_uri`
+// of the given OpenID Provider. The latest discovered `jwks_uri` is used each time the keys are refreshed.
+// The discovery is stopped along with the KeyManager.
+func NewKeyManagerForDiscovery(discovery *OIDCDiscovery) (*KeyManager, error) {
+	km, err := newKeyManager(func() string {
+		return discovery.Metadata().JWKSURI
+	})
+	if err != nil {
+		return nil, err
+	}
+	km.discovery = discovery
+	return km, nil
+}
+
+func newKeyManager(keysEndpointURL func() string) (*KeyManager, error) {
 	cfg := configuration.GetRegistrationServiceConfig()
-	keysEndpointURL := cfg.Auth().AuthClientPublicKeysURL()
 	km := &KeyManager{
-		keyMap: make(map[string]*rsa.PublicKey),
+		keyMap:     make(map[string]crypto.PublicKey),
+		httpClient: newHTTPClient(),
+		stop:       make(chan struct{}),
 	}
 	// fetch raw keys
-	if keysEndpointURL != "" {
+	if keysEndpointURL != nil {
 		if cfg.Environment() == "e2e-tests" {
 			log.Infof(nil, "fetching e2e public keys")
 			keys := authsupport.GetE2ETestPublicKey()
@@ -57,14 +108,14 @@ func NewKeyManager() (*KeyManager, error) {
 				km.keyMap[key.KeyID] = key.Key
 			}
 		} else {
-			log.Infof(nil, "fetching public keys from url: %s", keysEndpointURL)
-			keys, err := km.fetchKeys(keysEndpointURL)
-			if err != nil {
+			log.Infof(nil, "fetching public keys from url: %s", keysEndpointURL())
+			km.keysEndpointURL = keysEndpointURL
+			km.minRefetchInterval = cfg.Auth().PublicKeysMinRefetchInterval()
+			if err := km.refreshKeys(); err != nil {
 				return nil, err
 			}
-			// add them to the kid map
-			for _, key := range keys {
-				km.keyMap[key.KeyID] = key.Key
+			if interval := cfg.Auth().PublicKeysRefreshInterval(); interval > 0 {
+				go km.refreshPeriodically(interval)
 			}
 		}
 	} else {
@@ -74,12 +125,98 @@ func NewKeyManager() (*KeyManager, error) {
 }
 
 // Key retrieves the public key for a given kid.
-func (km *KeyManager) Key(kid string) (*rsa.PublicKey, error) {
+// If the kid is unknown, then the keys are fetched again (unless they were fetched very recently) before giving up.
+func (km *KeyManager) Key(kid string) (crypto.PublicKey, error) {
+	if key, ok := km.lookup(kid); ok {
+		return key, nil
+	}
+	if km.refetchOnUnknownKid() {
+		if key, ok := km.lookup(kid); ok {
+			return key, nil
+		}
+	}
+	return nil, errors.New("unknown kid")
+}
+
+// Stop stops the background refresh of the keys, and of the OpenID configuration they are discovered from
+func (km *KeyManager) Stop() {
+	km.stopOnce.Do(func() {
+		close(km.stop)
+	})
+	if km.discovery != nil {
+		km.discovery.Stop()
+	}
+}
+
+func (km *KeyManager) lookup(kid string) (crypto.PublicKey, bool) {
+	km.mu.RLock()
+	defer km.mu.RUnlock()
 	key, ok := km.keyMap[kid]
-	if !ok {
-		return nil, errors.New("unknown kid")
+	return key, ok
+}
+
+// refetchOnUnknownKid fetches the keys again unless the last fetch happened less than `minRefetchInterval` ago.
+// Returns true if the keys were fetched successfully.
+func (km *KeyManager) refetchOnUnknownKid() bool {
+	if km.keysEndpointURL == nil {
+		return false
+	}
+	km.fetchMu.Lock()
+	defer km.fetchMu.Unlock()
+	km.mu.RLock()
+	lastFetch := km.lastFetch
+	km.mu.RUnlock()
+	if time.Since(lastFetch) < km.minRefetchInterval {
+		return false
 	}
-	return key, nil
+	log.Infof(nil, "unknown kid, fetching public keys again from url: %s", km.keysEndpointURL())
+	if err := km.doRefreshKeys(); err != nil {
+		log.Error(nil, err, "failed to fetch public keys after an unknown kid was found")
+		return false
+	}
+	return true
+}
+
+func (km *KeyManager) refreshPeriodically(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-km.stop:
+			return
+		case <-ticker.C:
+			if err := km.refreshKeys(); err != nil {
+				// keep the existing keys, they will be refreshed during the next run
+				log.Error(nil, err, "failed to refresh public keys")
+			}
+		}
+	}
+}
+
+// refreshKeys fetches the keys and replaces the existing ones
+func (km *KeyManager) refreshKeys() error {
+	km.fetchMu.Lock()
+	defer km.fetchMu.Unlock()
+	return km.doRefreshKeys()
+}
+
+// doRefreshKeys must be called while holding the `fetchMu` lock
+func (km *KeyManager) doRefreshKeys() error {
+	keys, err := km.fetchKeys(km.keysEndpointURL())
+	km.mu.Lock()
+	defer km.mu.Unlock()
+	km.lastFetch = time.Now()
+	if err != nil {
+		publicKeysFetchCounterVec.WithLabelValues(metricsLabelFailure).Inc()
+		return err
+	}
+	publicKeysFetchCounterVec.WithLabelValues(metricsLabelSuccess).Inc()
+	keyMap := make(map[string]crypto.PublicKey, len(keys))
+	for _, key := range keys {
+		keyMap[key.KeyID] = key.Key
+	}
+	km.keyMap = keyMap
+	return nil
 }
 
 // unmarshalKeys unmarshals keys from given JSON.
@@ -111,11 +248,12 @@ func (km *KeyManager) unmarshalKey(jsonData []byte) (*PublicKey, error) {
 	if err != nil {
 		return nil, err
 	}
-	rsaKey, ok := key.Key.(*rsa.PublicKey)
-	if !ok {
-		return nil, errors.New("Key is not an *rsa.PublicKey")
+	switch publicKey := key.Key.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey:
+		return &PublicKey{key.KeyID, publicKey}, nil
+	default:
+		return nil, fmt.Errorf("unsupported type of public key: %T", key.Key)
 	}
-	return &PublicKey{key.KeyID, rsaKey}, nil
 }
 
 // unmarshalls the keys from a byte array.
@@ -132,21 +270,11 @@ func (km *KeyManager) fetchKeysFromBytes(keysBytes []byte) ([]*PublicKey, error)
 
 // fetchKeys fetches the keys from the given URL, unmarshalling them.
 func (km *KeyManager) fetchKeys(keysEndpointURL string) ([]*PublicKey, error) {
-	// use httpClient to perform request
-	transport := http.DefaultTransport
-	if !configuration.GetRegistrationServiceConfig().IsProdEnvironment() {
-		transport = &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: true, // nolint:gosec
-			},
-		}
-	}
-	httpClient := &http.Client{Transport: transport}
 	req, err := http.NewRequest("GET", keysEndpointURL, nil)
 	if err != nil {
 		return nil, err
 	}
-	res, err := httpClient.Do(req)
+	res, err := km.httpClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -181,3 +309,21 @@ func (km *KeyManager) fetchKeys(keysEndpointURL string) ([]*PublicKey, error) {
 	// unmarshal the keys
 	return km.fetchKeysFromBytes([]byte(bodyString))
 }
+
+// insecureTransport is shared by the clients which do not verify the certificates, so that their connections are reused
+var insecureTransport = func() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{
+		InsecureSkipVerify: true, // nolint:gosec
+	}
+	return transport
+}()
+
+// newHTTPClient returns a client for the requests to the SSO. The certificates are not verified outside of the prod environment.
+func newHTTPClient() *http.Client {
+	transport := http.DefaultTransport
+	if !configuration.GetRegistrationServiceConfig().IsProdEnvironment() {
+		transport = insecureTransport
+	}
+	return &http.Client{Transport: transport}
+}
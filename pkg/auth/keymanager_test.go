@@ -1,11 +1,22 @@
 package auth_test
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/codeready-toolchain/registration-service/pkg/auth"
 	"github.com/codeready-toolchain/registration-service/pkg/configuration"
@@ -18,9 +29,11 @@ import (
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	"gopkg.in/square/go-jose.v2"
 )
 
 type TestKeyManagerSuite struct {
@@ -298,3 +311,330 @@ func (s *TestKeyManagerSuite) TestE2EKeyFetching() {
 		checkE2EKeysNotFound()
 	})
 }
+
+func (s *TestKeyManagerSuite) TestKeyTypes() {
+	restore := commontest.SetEnvVarAndRestore(s.T(), commonconfig.WatchNamespaceEnvVar, commontest.HostOperatorNs)
+	defer restore()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(s.T(), err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(s.T(), err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(s.T(), err)
+
+	keyServer := newRotatingKeyServer(s.T())
+	defer keyServer.Close()
+	s.OverrideApplicationDefault(testconfig.RegistrationService().
+		Environment(configuration.DefaultEnvironment).
+		Auth().AuthClientPublicKeysURL(keyServer.URL))
+
+	s.Run("RSA, ECDSA and EdDSA keys", func() {
+		// given
+		keyServer.setKeys(s.T(), map[string]crypto.Signer{"rsa": rsaKey, "ec": ecKey, "ed": edKey})
+
+		// when
+		keyManager, err := auth.NewKeyManager()
+
+		// then
+		require.NoError(s.T(), err)
+		defer keyManager.Stop()
+		key, err := keyManager.Key("rsa")
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), &rsaKey.PublicKey, key)
+		key, err = keyManager.Key("ec")
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), &ecKey.PublicKey, key)
+		key, err = keyManager.Key("ed")
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), edKey.Public(), key)
+	})
+
+	s.Run("unsupported key type", func() {
+		// given
+		keyServer.setSymmetricKey(s.T(), "oct", []byte("secret"))
+
+		// when
+		_, err := auth.NewKeyManager()
+
+		// then
+		require.EqualError(s.T(), err, "unsupported type of public key: []uint8")
+	})
+}
+
+func (s *TestKeyManagerSuite) TestKeyRefresh() {
+	restore := commontest.SetEnvVarAndRestore(s.T(), commonconfig.WatchNamespaceEnvVar, commontest.HostOperatorNs)
+	defer restore()
+
+	reg := prometheus.NewRegistry()
+	auth.RegisterMetrics(reg)
+
+	kid0 := uuid.NewString()
+	key0, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(s.T(), err)
+	kid1 := uuid.NewString()
+	key1, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(s.T(), err)
+
+	keyServer := newRotatingKeyServer(s.T())
+	defer keyServer.Close()
+	s.OverrideApplicationDefault(testconfig.RegistrationService().
+		Environment(configuration.DefaultEnvironment).
+		Auth().AuthClientPublicKeysURL(keyServer.URL))
+
+	s.Run("unknown kid triggers a new fetch", func() {
+		// given
+		restoreInterval := commontest.SetEnvVarAndRestore(s.T(), configuration.AuthPublicKeysRefreshIntervalEnvVar, "0")
+		defer restoreInterval()
+		restoreMinInterval := commontest.SetEnvVarAndRestore(s.T(), configuration.AuthPublicKeysMinRefetchIntervalEnvVar, "0s")
+		defer restoreMinInterval()
+		keyServer.setKeys(s.T(), map[string]crypto.Signer{kid0: key0})
+		keyManager, err := auth.NewKeyManager()
+		require.NoError(s.T(), err)
+		defer keyManager.Stop()
+		successes := fetchCount(s.T(), reg, "success")
+		_, err = keyManager.Key(kid1)
+		require.EqualError(s.T(), err, "unknown kid")
+
+		// when the keys are rotated
+		keyServer.setKeys(s.T(), map[string]crypto.Signer{kid1: key1})
+
+		// then
+		_, err = keyManager.Key(kid1)
+		require.NoError(s.T(), err)
+		// the rotated key is not available anymore
+		_, err = keyManager.Key(kid0)
+		require.EqualError(s.T(), err, "unknown kid")
+		assert.InDelta(s.T(), successes+3, fetchCount(s.T(), reg, "success"), 0.01)
+	})
+
+	s.Run("fetch on unknown kid is rate limited", func() {
+		// given
+		restoreInterval := commontest.SetEnvVarAndRestore(s.T(), configuration.AuthPublicKeysRefreshIntervalEnvVar, "0")
+		defer restoreInterval()
+		restoreMinInterval := commontest.SetEnvVarAndRestore(s.T(), configuration.AuthPublicKeysMinRefetchIntervalEnvVar, "1h")
+		defer restoreMinInterval()
+		keyServer.setKeys(s.T(), map[string]crypto.Signer{kid0: key0})
+		keyManager, err := auth.NewKeyManager()
+		require.NoError(s.T(), err)
+		defer keyManager.Stop()
+		successes := fetchCount(s.T(), reg, "success")
+
+		// when
+		keyServer.setKeys(s.T(), map[string]crypto.Signer{kid0: key0, kid1: key1})
+
+		// then
+		_, err = keyManager.Key(kid1)
+		require.EqualError(s.T(), err, "unknown kid")
+		assert.InDelta(s.T(), successes, fetchCount(s.T(), reg, "success"), 0.01)
+	})
+
+	s.Run("failed fetch on unknown kid keeps the existing keys", func() {
+		// given
+		restoreInterval := commontest.SetEnvVarAndRestore(s.T(), configuration.AuthPublicKeysRefreshIntervalEnvVar, "0")
+		defer restoreInterval()
+		restoreMinInterval := commontest.SetEnvVarAndRestore(s.T(), configuration.AuthPublicKeysMinRefetchIntervalEnvVar, "0s")
+		defer restoreMinInterval()
+		keyServer.setKeys(s.T(), map[string]crypto.Signer{kid0: key0})
+		keyManager, err := auth.NewKeyManager()
+		require.NoError(s.T(), err)
+		defer keyManager.Stop()
+		failures := fetchCount(s.T(), reg, "failure")
+
+		// when
+		keyServer.setFailure(true)
+		defer keyServer.setFailure(false)
+
+		// then
+		_, err = keyManager.Key(kid1)
+		require.EqualError(s.T(), err, "unknown kid")
+		_, err = keyManager.Key(kid0)
+		require.NoError(s.T(), err)
+		assert.InDelta(s.T(), failures+1, fetchCount(s.T(), reg, "failure"), 0.01)
+	})
+
+	s.Run("keys are refreshed in the background", func() {
+		// given
+		restoreInterval := commontest.SetEnvVarAndRestore(s.T(), configuration.AuthPublicKeysRefreshIntervalEnvVar, "10ms")
+		defer restoreInterval()
+		restoreMinInterval := commontest.SetEnvVarAndRestore(s.T(), configuration.AuthPublicKeysMinRefetchIntervalEnvVar, "1h")
+		defer restoreMinInterval()
+		keyServer.setKeys(s.T(), map[string]crypto.Signer{kid0: key0})
+		keyManager, err := auth.NewKeyManager()
+		require.NoError(s.T(), err)
+		defer keyManager.Stop()
+
+		// when
+		keyServer.setKeys(s.T(), map[string]crypto.Signer{kid1: key1})
+
+		// then
+		require.Eventually(s.T(), func() bool {
+			_, err := keyManager.Key(kid1)
+			return err == nil
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+
+	s.Run("background refresh stops with the key manager", func() {
+		// given
+		restoreInterval := commontest.SetEnvVarAndRestore(s.T(), configuration.AuthPublicKeysRefreshIntervalEnvVar, "10ms")
+		defer restoreInterval()
+		keyServer.setKeys(s.T(), map[string]crypto.Signer{kid0: key0})
+		keyManager, err := auth.NewKeyManager()
+		require.NoError(s.T(), err)
+		successes := fetchCount(s.T(), reg, "success")
+		require.Eventually(s.T(), func() bool {
+			return fetchCount(s.T(), reg, "success") > successes
+		}, 5*time.Second, 10*time.Millisecond)
+
+		// when
+		keyManager.Stop()
+
+		// then
+		// wait for a refresh which may have been running when the key manager was stopped
+		time.Sleep(50 * time.Millisecond)
+		successes = fetchCount(s.T(), reg, "success")
+		time.Sleep(100 * time.Millisecond)
+		assert.InDelta(s.T(), successes, fetchCount(s.T(), reg, "success"), 0.01)
+	})
+
+	s.Run("connections are reused", func() {
+		for _, env := range []string{configuration.DefaultEnvironment, "dev"} {
+			s.Run(env, func() {
+				// given
+				s.SetConfig(testconfig.RegistrationService().
+					Environment(env).
+					Auth().AuthClientPublicKeysURL(keyServer.URL))
+				restoreInterval := commontest.SetEnvVarAndRestore(s.T(), configuration.AuthPublicKeysRefreshIntervalEnvVar, "0")
+				defer restoreInterval()
+				restoreMinInterval := commontest.SetEnvVarAndRestore(s.T(), configuration.AuthPublicKeysMinRefetchIntervalEnvVar, "0s")
+				defer restoreMinInterval()
+				keyServer.setKeys(s.T(), map[string]crypto.Signer{kid0: key0})
+				keyManager, err := auth.NewKeyManager()
+				require.NoError(s.T(), err)
+				defer keyManager.Stop()
+				connections := keyServer.connections.Load()
+
+				// when
+				for i := 0; i < 5; i++ {
+					_, err = keyManager.Key(kid1)
+					require.EqualError(s.T(), err, "unknown kid")
+				}
+
+				// then
+				assert.Equal(s.T(), connections, keyServer.connections.Load())
+			})
+		}
+		s.SetConfig(testconfig.RegistrationService().
+			Environment(configuration.DefaultEnvironment).
+			Auth().AuthClientPublicKeysURL(keyServer.URL))
+	})
+
+	s.Run("concurrent lookups", func() {
+		// given
+		restoreInterval := commontest.SetEnvVarAndRestore(s.T(), configuration.AuthPublicKeysRefreshIntervalEnvVar, "1ms")
+		defer restoreInterval()
+		restoreMinInterval := commontest.SetEnvVarAndRestore(s.T(), configuration.AuthPublicKeysMinRefetchIntervalEnvVar, "0s")
+		defer restoreMinInterval()
+		keyServer.setKeys(s.T(), map[string]crypto.Signer{kid0: key0, kid1: key1})
+		keyManager, err := auth.NewKeyManager()
+		require.NoError(s.T(), err)
+		defer keyManager.Stop()
+
+		// when
+		wg := sync.WaitGroup{}
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(kid string) {
+				defer wg.Done()
+				_, err := keyManager.Key(kid)
+				assert.NoError(s.T(), err)
+			}([]string{kid0, kid1}[i%2])
+		}
+
+		// then
+		wg.Wait()
+	})
+}
+
+// rotatingKeyServer is a public key server whose keys can be changed during the test
+type rotatingKeyServer struct {
+	*httptest.Server
+	mu      sync.RWMutex
+	body    []byte
+	failure bool
+	// connections is the number of connections opened by the clients
+	connections atomic.Int32
+}
+
+func newRotatingKeyServer(t *testing.T) *rotatingKeyServer {
+	ks := &rotatingKeyServer{}
+	ks.Server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		ks.mu.RLock()
+		defer ks.mu.RUnlock()
+		if ks.failure {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, err := w.Write(ks.body)
+		assert.NoError(t, err)
+	}))
+	ks.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			ks.connections.Add(1)
+		}
+	}
+	ks.Start()
+	return ks
+}
+
+func (ks *rotatingKeyServer) setKeys(t *testing.T, keys map[string]crypto.Signer) {
+	keySet := jose.JSONWebKeySet{}
+	for kid, key := range keys {
+		keySet.Keys = append(keySet.Keys, jose.JSONWebKey{
+			Key:   key.Public(),
+			KeyID: kid,
+			Use:   "sig",
+		})
+	}
+	body, err := json.Marshal(keySet)
+	require.NoError(t, err)
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.body = body
+}
+
+func (ks *rotatingKeyServer) setSymmetricKey(t *testing.T, kid string, key []byte) {
+	body, err := json.Marshal(jose.JSONWebKeySet{
+		Keys: []jose.JSONWebKey{{Key: key, KeyID: kid, Use: "sig"}},
+	})
+	require.NoError(t, err)
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.body = body
+}
+
+func (ks *rotatingKeyServer) setFailure(failure bool) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.failure = failure
+}
+
+func fetchCount(t *testing.T, reg *prometheus.Registry, result string) float64 {
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() != "sandbox_auth_public_keys_fetch_total" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "result" && l.GetValue() == result {
+					return m.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	metricsLabelSuccess  = "success"
+	metricsLabelFailure  = "failure"
+	metricsLabelHit      = "hit"
+	metricsLabelMiss     = "miss"
+	metricsLabelActive   = "active"
+	metricsLabelInactive = "inactive"
+	metricsLabelFailOpen = "fail_open"
+)
+
+var (
+	// publicKeysFetchCounterVec counts the fetches of the public keys, labeled by result (success or failure)
+	publicKeysFetchCounterVec = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "sandbox_auth_public_keys_fetch_total",
+		Help: "Number of fetches of the public keys used for token validation",
+	}, []string{"result"})
+
+	// oidcDiscoveryFetchCounterVec counts the reads of the OpenID configuration, labeled by result (success or failure)
+	oidcDiscoveryFetchCounterVec = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "sandbox_auth_oidc_discovery_fetch_total",
+		Help: "Number of reads of the OpenID configuration of the SSO",
+	}, []string{"result"})
+
+	// tokenCacheLookupsCounterVec counts the lookups in the cache of verified tokens, labeled by result (hit or miss)
+	tokenCacheLookupsCounterVec = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "sandbox_auth_token_cache_lookups_total",
+		Help: "Number of lookups in the cache of verified tokens",
+	}, []string{"result"})
+
+	// tokenIntrospectionCounterVec counts the calls to the token introspection endpoint, labeled by result
+	// (active, inactive, failure or fail_open when the failure was ignored)
+	tokenIntrospectionCounterVec = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "sandbox_auth_token_introspection_total",
+		Help: "Number of calls to the token introspection endpoint",
+	}, []string{"result"})
+
+	// tokenIntrospectionCacheLookupsCounterVec counts the lookups in the cache of introspection results, labeled by result (hit or miss)
+	tokenIntrospectionCacheLookupsCounterVec = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "sandbox_auth_token_introspection_cache_lookups_total",
+		Help: "Number of lookups in the cache of token introspection results",
+	}, []string{"result"})
+)
+
+// RegisterMetrics registers the metrics of the auth package in the given registry
+func RegisterMetrics(reg prometheus.Registerer) {
+	reg.MustRegister(publicKeysFetchCounterVec, oidcDiscoveryFetchCounterVec, tokenCacheLookupsCounterVec,
+		tokenIntrospectionCounterVec, tokenIntrospectionCacheLookupsCounterVec)
+}
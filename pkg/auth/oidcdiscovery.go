@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+)
+
+// WellKnownOpenIDConfigurationPath is the path of the OpenID Provider configuration document, relative to the issuer URL
+const WellKnownOpenIDConfigurationPath = "/.well-known/openid-configuration"
+
+// ProviderMetadata contains the fields of the OpenID Provider configuration document which are used by the service
+type ProviderMetadata struct {
+	Issuer                string `json:"issuer"`
+	JWKSURI               string `json:"jwks_uri"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+}
+
+// OIDCDiscovery reads the configuration document of an OpenID Provider.
+// The document is read again periodically in the background, so that changes on the SSO side are picked up.
+type OIDCDiscovery struct {
+	issuerURL  string
+	httpClient *http.Client
+	// mu guards the metadata field
+	mu       sync.RWMutex
+	metadata ProviderMetadata
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewOIDCDiscovery creates a new OIDCDiscovery and reads the configuration document of the given issuer.
+func NewOIDCDiscovery(issuerURL string) (*OIDCDiscovery, error) {
+	if issuerURL == "" {
+		return nil, errors.New("no issuer URL given when creating OIDCDiscovery")
+	}
+	d := &OIDCDiscovery{
+		issuerURL:  strings.TrimSuffix(issuerURL, "/"),
+		httpClient: newHTTPClient(),
+		stop:       make(chan struct{}),
+	}
+	if err := d.refresh(); err != nil {
+		return nil, err
+	}
+	if interval := configuration.GetRegistrationServiceConfig().Auth().OIDCDiscoveryRefreshInterval(); interval > 0 {
+		go d.refreshPeriodically(interval)
+	}
+	return d, nil
+}
+
+// WellKnownURL returns the URL of the configuration document
+func (d *OIDCDiscovery) WellKnownURL() string {
+	return d.issuerURL + WellKnownOpenIDConfigurationPath
+}
+
+// Metadata returns the last configuration read from the OpenID Provider
+func (d *OIDCDiscovery) Metadata() ProviderMetadata {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.metadata
+}
+
+// Stop stops the background refresh of the configuration
+func (d *OIDCDiscovery) Stop() {
+	d.stopOnce.Do(func() {
+		close(d.stop)
+	})
+}
+
+func (d *OIDCDiscovery) refreshPeriodically(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-d.stop:
+			return
+		case <-ticker.C:
+			if err := d.refresh(); err != nil {
+				// keep the existing configuration, it will be refreshed during the next run
+				log.Error(nil, err, "failed to refresh the OpenID configuration")
+			}
+		}
+	}
+}
+
+// refresh reads the configuration document and replaces the existing metadata
+func (d *OIDCDiscovery) refresh() error {
+	metadata, err := d.fetchMetadata()
+	if err != nil {
+		oidcDiscoveryFetchCounterVec.WithLabelValues(metricsLabelFailure).Inc()
+		return err
+	}
+	oidcDiscoveryFetchCounterVec.WithLabelValues(metricsLabelSuccess).Inc()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.metadata = metadata
+	return nil
+}
+
+func (d *OIDCDiscovery) fetchMetadata() (ProviderMetadata, error) {
+	metadata := ProviderMetadata{}
+	log.Infof(nil, "reading OpenID configuration from url: %s", d.WellKnownURL())
+	res, err := d.httpClient.Get(d.WellKnownURL())
+	if err != nil {
+		return metadata, err
+	}
+	defer func() {
+		if err := res.Body.Close(); err != nil {
+			log.Error(nil, err, "failed to close response after reading")
+		}
+	}()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return metadata, err
+	}
+	if res.StatusCode != http.StatusOK {
+		err := errors.New("unable to obtain OpenID configuration from remote service")
+		log.WithValues(map[string]interface{}{
+			"response_status":   res.Status,
+			"response_body":     string(body),
+			"configuration_url": d.WellKnownURL(),
+		}).Error(nil, err, "")
+		return metadata, err
+	}
+	if err := json.Unmarshal(body, &metadata); err != nil {
+		return metadata, err
+	}
+	// the issuer of the document must be the same as the one used to retrieve it (see OpenID Connect Discovery 1.0, section 4.3)
+	if strings.TrimSuffix(metadata.Issuer, "/") != d.issuerURL {
+		return metadata, fmt.Errorf("issuer of the OpenID configuration does not match: expected '%s', got '%s'", d.issuerURL, metadata.Issuer)
+	}
+	if metadata.JWKSURI == "" {
+		return metadata, errors.New("no jwks_uri in the OpenID configuration")
+	}
+	return metadata, nil
+}
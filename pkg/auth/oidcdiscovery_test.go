@@ -0,0 +1,246 @@
+package auth_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/auth"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/test"
+	commonconfig "github.com/codeready-toolchain/toolchain-common/pkg/configuration"
+	commontest "github.com/codeready-toolchain/toolchain-common/pkg/test"
+	testconfig "github.com/codeready-toolchain/toolchain-common/pkg/test/config"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type TestOIDCDiscoverySuite struct {
+	test.UnitTestSuite
+}
+
+func TestRunOIDCDiscoverySuite(t *testing.T) {
+	suite.Run(t, &TestOIDCDiscoverySuite{test.UnitTestSuite{}})
+}
+
+func (s *TestOIDCDiscoverySuite) TestOIDCDiscovery() {
+	restore := commontest.SetEnvVarAndRestore(s.T(), commonconfig.WatchNamespaceEnvVar, commontest.HostOperatorNs)
+	defer restore()
+	s.OverrideApplicationDefault(testconfig.RegistrationService().
+		Environment(configuration.UnitTestsEnvironment))
+
+	s.Run("read configuration", func() {
+		// given
+		provider := newFakeOIDCProvider(s.T())
+		defer provider.Close()
+
+		// when
+		discovery, err := auth.NewOIDCDiscovery(provider.URL + "/")
+
+		// then
+		require.NoError(s.T(), err)
+		defer discovery.Stop()
+		assert.Equal(s.T(), provider.URL+"/.well-known/openid-configuration", discovery.WellKnownURL())
+		assert.Equal(s.T(), auth.ProviderMetadata{
+			Issuer:                provider.URL,
+			JWKSURI:               provider.URL + "/certs",
+			AuthorizationEndpoint: provider.URL + "/auth",
+		}, discovery.Metadata())
+	})
+
+	s.Run("no issuer URL", func() {
+		_, err := auth.NewOIDCDiscovery("")
+
+		require.EqualError(s.T(), err, "no issuer URL given when creating OIDCDiscovery")
+	})
+
+	s.Run("issuer does not match", func() {
+		// given
+		provider := newFakeOIDCProvider(s.T())
+		defer provider.Close()
+		provider.setMetadata(auth.ProviderMetadata{Issuer: "https://other.issuer.com", JWKSURI: provider.URL + "/certs"})
+
+		// when
+		_, err := auth.NewOIDCDiscovery(provider.URL)
+
+		// then
+		require.EqualError(s.T(), err, "issuer of the OpenID configuration does not match: expected '"+provider.URL+"', got 'https://other.issuer.com'")
+	})
+
+	s.Run("no jwks_uri", func() {
+		// given
+		provider := newFakeOIDCProvider(s.T())
+		defer provider.Close()
+		provider.setMetadata(auth.ProviderMetadata{Issuer: provider.URL})
+
+		// when
+		_, err := auth.NewOIDCDiscovery(provider.URL)
+
+		// then
+		require.EqualError(s.T(), err, "no jwks_uri in the OpenID configuration")
+	})
+
+	s.Run("configuration not available", func() {
+		// given
+		provider := newFakeOIDCProvider(s.T())
+		defer provider.Close()
+		provider.setFailure(true)
+
+		// when
+		_, err := auth.NewOIDCDiscovery(provider.URL)
+
+		// then
+		require.EqualError(s.T(), err, "unable to obtain OpenID configuration from remote service")
+	})
+
+	s.Run("configuration is read again in the background", func() {
+		// given
+		s.T().Setenv(configuration.AuthOIDCDiscoveryRefreshIntervalEnvVar, "10ms")
+		provider := newFakeOIDCProvider(s.T())
+		defer provider.Close()
+		discovery, err := auth.NewOIDCDiscovery(provider.URL)
+		require.NoError(s.T(), err)
+		defer discovery.Stop()
+
+		// when
+		provider.setMetadata(auth.ProviderMetadata{Issuer: provider.URL, JWKSURI: provider.URL + "/rotated-certs"})
+
+		// then
+		assert.Eventually(s.T(), func() bool {
+			return discovery.Metadata().JWKSURI == provider.URL+"/rotated-certs"
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+
+	s.Run("failed read keeps the existing configuration", func() {
+		// given
+		s.T().Setenv(configuration.AuthOIDCDiscoveryRefreshIntervalEnvVar, "10ms")
+		provider := newFakeOIDCProvider(s.T())
+		defer provider.Close()
+		discovery, err := auth.NewOIDCDiscovery(provider.URL)
+		require.NoError(s.T(), err)
+		defer discovery.Stop()
+
+		// when
+		provider.setFailure(true)
+		provider.waitForRequests(2)
+
+		// then
+		assert.Equal(s.T(), provider.URL+"/certs", discovery.Metadata().JWKSURI)
+	})
+}
+
+func (s *TestOIDCDiscoverySuite) TestTokenValidationWithDiscovery() {
+	restore := commontest.SetEnvVarAndRestore(s.T(), commonconfig.WatchNamespaceEnvVar, commontest.HostOperatorNs)
+	defer restore()
+	s.OverrideApplicationDefault(testconfig.RegistrationService().
+		Environment(configuration.UnitTestsEnvironment))
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(s.T(), err)
+	keyServer := newRotatingKeyServer(s.T())
+	defer keyServer.Close()
+	keyServer.setKeys(s.T(), map[string]crypto.Signer{"ec": key})
+
+	provider := newFakeOIDCProvider(s.T())
+	defer provider.Close()
+	provider.setMetadata(auth.ProviderMetadata{Issuer: provider.URL, JWKSURI: keyServer.URL})
+
+	discovery, err := auth.NewOIDCDiscovery(provider.URL)
+	require.NoError(s.T(), err)
+	defer discovery.Stop()
+	keyManager, err := auth.NewKeyManagerForDiscovery(discovery)
+	require.NoError(s.T(), err)
+	defer keyManager.Stop()
+	tokenParser, err := auth.NewTokenParserForIssuers(auth.Issuer{
+		Name:       discovery.Metadata().Issuer,
+		KeyManager: keyManager,
+	})
+	require.NoError(s.T(), err)
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"iss":                provider.URL,
+		"sub":                uuid.NewString(),
+		"preferred_username": "jsmith",
+		"email":              "jsmith@email.tld",
+		"exp":                time.Now().Add(time.Hour).Unix(),
+	})
+	token.Header["kid"] = "ec"
+	signed, err := token.SignedString(key)
+	require.NoError(s.T(), err)
+
+	// when
+	claims, err := tokenParser.FromString(signed)
+
+	// then
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "jsmith", claims.PreferredUsername)
+}
+
+// fakeOIDCProvider serves an OpenID configuration document which can be changed during the test
+type fakeOIDCProvider struct {
+	*httptest.Server
+	mu       sync.RWMutex
+	metadata *auth.ProviderMetadata
+	failure  bool
+	requests int
+}
+
+func newFakeOIDCProvider(t *testing.T) *fakeOIDCProvider {
+	p := &fakeOIDCProvider{}
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.requests++
+		if p.failure || r.URL.Path != auth.WellKnownOpenIDConfigurationPath {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		metadata := auth.ProviderMetadata{
+			Issuer:                p.URL,
+			JWKSURI:               p.URL + "/certs",
+			AuthorizationEndpoint: p.URL + "/auth",
+		}
+		if p.metadata != nil {
+			metadata = *p.metadata
+		}
+		w.Header().Set("Content-Type", "application/json")
+		assert.NoError(t, json.NewEncoder(w).Encode(metadata))
+	}))
+	return p
+}
+
+func (p *fakeOIDCProvider) setMetadata(metadata auth.ProviderMetadata) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.metadata = &metadata
+}
+
+func (p *fakeOIDCProvider) setFailure(failure bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.failure = failure
+	p.requests = 0
+}
+
+func (p *fakeOIDCProvider) waitForRequests(count int) {
+	for {
+		p.mu.RLock()
+		requests := p.requests
+		p.mu.RUnlock()
+		if requests >= count {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
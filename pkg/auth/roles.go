@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+)
+
+// RoleMapper grants roles to the users from the groups or roles claims of their tokens
+type RoleMapper struct {
+	mappings []configuration.RoleMapping
+}
+
+// NewRoleMapper creates a new RoleMapper with the given mappings
+func NewRoleMapper(mappings []configuration.RoleMapping) (*RoleMapper, error) {
+	for _, mapping := range mappings {
+		if mapping.Role == "" {
+			return nil, fmt.Errorf("no role given in role mapping of claim '%s'", mapping.Claim)
+		}
+		if mapping.Claim == "" {
+			return nil, fmt.Errorf("no claim given in role mapping of role '%s'", mapping.Role)
+		}
+		if len(mapping.Values) == 0 {
+			return nil, fmt.Errorf("no value given in role mapping of role '%s'", mapping.Role)
+		}
+	}
+	return &RoleMapper{
+		mappings: mappings,
+	}, nil
+}
+
+// apply sets the roles granted by the given raw claims
+func (m *RoleMapper) apply(claims *TokenClaims, rawClaims map[string]interface{}) {
+	var roles []string
+	for _, mapping := range m.mappings {
+		if contains(roles, mapping.Role) {
+			continue
+		}
+		if containsAny(lookupClaimValues(rawClaims, mapping.Claim), mapping.Values) {
+			roles = append(roles, mapping.Role)
+		}
+	}
+	claims.Roles = roles
+}
+
+// lookupClaimValues returns the values of the claim with the given path, which is either a list of strings
+// (eg. `groups: [a, b]`) or a string of values separated by spaces (eg. `scope: "a b"`)
+func lookupClaimValues(rawClaims map[string]interface{}, path string) []string {
+	value, found := lookupRawClaim(rawClaims, path)
+	if !found {
+		return nil
+	}
+	switch v := value.(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+// HasRole returns true if the given role was granted to the identity
+func (i *Identity) HasRole(role string) bool {
+	return contains(i.Roles, role)
+}
@@ -0,0 +1,140 @@
+package auth_test
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/auth"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	commonconfig "github.com/codeready-toolchain/toolchain-common/pkg/configuration"
+	commontest "github.com/codeready-toolchain/toolchain-common/pkg/test"
+	testconfig "github.com/codeready-toolchain/toolchain-common/pkg/test/config"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *TestTokenParserSuite) TestRoleMappings() {
+	restore := commontest.SetEnvVarAndRestore(s.T(), commonconfig.WatchNamespaceEnvVar, commontest.HostOperatorNs)
+	defer restore()
+	s.OverrideApplicationDefault(testconfig.RegistrationService().
+		Environment(configuration.UnitTestsEnvironment))
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(s.T(), err)
+	keyServer := newRotatingKeyServer(s.T())
+	defer keyServer.Close()
+	keyServer.setKeys(s.T(), map[string]crypto.Signer{"rsa": key})
+	keyManager, err := auth.NewKeyManagerForURL(keyServer.URL)
+	require.NoError(s.T(), err)
+	defer keyManager.Stop()
+
+	signedToken := func(claims jwt.MapClaims) string {
+		for name, value := range map[string]string{"sub": "f:528d76ff:jsmith", "preferred_username": "jsmith", "email": "jsmith@email.tld"} {
+			if _, found := claims[name]; !found {
+				claims[name] = value
+			}
+		}
+		claims["exp"] = time.Now().Add(time.Hour).Unix()
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "rsa"
+		signed, err := token.SignedString(key)
+		require.NoError(s.T(), err)
+		return signed
+	}
+
+	roleMapper, err := auth.NewRoleMapper([]configuration.RoleMapping{
+		{Role: configuration.AdminRole, Claim: "groups", Values: []string{"crtadmin"}},
+		{Role: configuration.AdminRole, Claim: "realm_access.roles", Values: []string{"sandbox-admin"}},
+		{Role: "support", Claim: "scope", Values: []string{"sandbox:support"}},
+	})
+	require.NoError(s.T(), err)
+	tokenParser, err := auth.NewTokenParser(keyManager)
+	require.NoError(s.T(), err)
+	tokenParser.UseRoleMapper(roleMapper)
+
+	s.Run("invalid mappings", func() {
+		_, err := auth.NewRoleMapper([]configuration.RoleMapping{{Claim: "groups", Values: []string{"crtadmin"}}})
+		require.EqualError(s.T(), err, "no role given in role mapping of claim 'groups'")
+
+		_, err = auth.NewRoleMapper([]configuration.RoleMapping{{Role: configuration.AdminRole, Values: []string{"crtadmin"}}})
+		require.EqualError(s.T(), err, "no claim given in role mapping of role 'admin'")
+
+		_, err = auth.NewRoleMapper([]configuration.RoleMapping{{Role: configuration.AdminRole, Claim: "groups"}})
+		require.EqualError(s.T(), err, "no value given in role mapping of role 'admin'")
+	})
+
+	s.Run("no role mapper", func() {
+		tokenParser, err := auth.NewTokenParser(keyManager)
+		require.NoError(s.T(), err)
+
+		claims, err := tokenParser.FromString(signedToken(jwt.MapClaims{
+			"groups": []string{"crtadmin"},
+		}))
+
+		require.NoError(s.T(), err)
+		assert.Empty(s.T(), claims.Roles)
+	})
+
+	tests := map[string]struct {
+		claims        jwt.MapClaims
+		expectedRoles []string
+	}{
+		"groups claim": {
+			claims:        jwt.MapClaims{"groups": []string{"developers", "crtadmin"}},
+			expectedRoles: []string{configuration.AdminRole},
+		},
+		"nested roles claim": {
+			claims:        jwt.MapClaims{"realm_access": map[string]interface{}{"roles": []string{"sandbox-admin"}}},
+			expectedRoles: []string{configuration.AdminRole},
+		},
+		"role granted by several mappings": {
+			claims: jwt.MapClaims{
+				"groups":       []string{"crtadmin"},
+				"realm_access": map[string]interface{}{"roles": []string{"sandbox-admin"}},
+			},
+			expectedRoles: []string{configuration.AdminRole},
+		},
+		"space-separated claim": {
+			claims:        jwt.MapClaims{"scope": "openid sandbox:support"},
+			expectedRoles: []string{"support"},
+		},
+		"several roles": {
+			claims:        jwt.MapClaims{"groups": []string{"crtadmin"}, "scope": "sandbox:support"},
+			expectedRoles: []string{configuration.AdminRole, "support"},
+		},
+		"no matching value": {
+			claims: jwt.MapClaims{"groups": []string{"developers"}, "scope": "openid"},
+		},
+		"claim of unexpected type": {
+			claims: jwt.MapClaims{"groups": map[string]interface{}{"crtadmin": true}},
+		},
+		"no claim": {
+			claims: jwt.MapClaims{},
+		},
+		"username with crtadmin suffix": {
+			claims: jwt.MapClaims{"preferred_username": "jsmith-crtadmin"},
+		},
+	}
+	for name, tc := range tests {
+		s.Run(name, func() {
+			// when
+			claims, err := tokenParser.FromString(signedToken(tc.claims))
+
+			// then
+			require.NoError(s.T(), err)
+			assert.Equal(s.T(), tc.expectedRoles, claims.Roles)
+		})
+	}
+}
+
+func (s *TestTokenParserSuite) TestIdentityHasRole() {
+	identity := &auth.Identity{Roles: []string{configuration.AdminRole}}
+
+	assert.True(s.T(), identity.HasRole(configuration.AdminRole))
+	assert.False(s.T(), identity.HasRole("support"))
+	assert.False(s.T(), (&auth.Identity{}).HasRole(configuration.AdminRole))
+}
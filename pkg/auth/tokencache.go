@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"k8s.io/utils/lru"
+)
+
+// TokenCache is a bounded cache of the claims of the verified tokens, so that the signature of a token is not verified
+// again each time it is used. The entries are indexed by the SHA-256 hash of the token and expire with the token,
+// or after the maximum TTL of the cache if the token expires later.
+type TokenCache struct {
+	cache  *lru.Cache
+	maxTTL time.Duration
+	now    func() time.Time
+}
+
+type cachedClaims struct {
+	claims    TokenClaims
+	expiresAt time.Time
+}
+
+// NewTokenCache creates a new TokenCache with the given maximum number of entries and maximum TTL
+func NewTokenCache(size int, maxTTL time.Duration) *TokenCache {
+	return &TokenCache{
+		cache:  lru.New(size),
+		maxTTL: maxTTL,
+		now:    time.Now,
+	}
+}
+
+// get returns a deep copy of the cached claims of the given token, if any
+func (c *TokenCache) get(token string) (*TokenClaims, bool) {
+	key := sha256.Sum256([]byte(token))
+	value, found := c.cache.Get(key)
+	if !found {
+		tokenCacheLookupsCounterVec.WithLabelValues(metricsLabelMiss).Inc()
+		return nil, false
+	}
+	entry := value.(*cachedClaims)
+	if !c.now().Before(entry.expiresAt) {
+		c.cache.Remove(key)
+		tokenCacheLookupsCounterVec.WithLabelValues(metricsLabelMiss).Inc()
+		return nil, false
+	}
+	tokenCacheLookupsCounterVec.WithLabelValues(metricsLabelHit).Inc()
+	return entry.claims.deepCopy(), true
+}
+
+// add caches a deep copy of the claims of the given verified token
+func (c *TokenCache) add(token string, claims *TokenClaims) {
+	expiresAt := c.now().Add(c.maxTTL)
+	if claims.ExpiresAt != nil && claims.ExpiresAt.Before(expiresAt) {
+		expiresAt = claims.ExpiresAt.Time
+	}
+	c.cache.Add(sha256.Sum256([]byte(token)), &cachedClaims{
+		claims:    *claims.deepCopy(),
+		expiresAt: expiresAt,
+	})
+}
+
+// deepCopy returns a copy of the claims which does not share its slices and dates with the claims, so that the claims
+// returned for a request can be changed without changing the cached ones
+func (c *TokenClaims) deepCopy() *TokenClaims {
+	claims := *c
+	if c.Roles != nil {
+		claims.Roles = append([]string{}, c.Roles...)
+	}
+	if c.Audience != nil {
+		claims.Audience = append(jwt.ClaimStrings{}, c.Audience...)
+	}
+	claims.ExpiresAt = copyNumericDate(c.ExpiresAt)
+	claims.NotBefore = copyNumericDate(c.NotBefore)
+	claims.IssuedAt = copyNumericDate(c.IssuedAt)
+	return &claims
+}
+
+func copyNumericDate(date *jwt.NumericDate) *jwt.NumericDate {
+	if date == nil {
+		return nil
+	}
+	return jwt.NewNumericDate(date.Time)
+}
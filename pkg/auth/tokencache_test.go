@@ -0,0 +1,234 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	commonconfig "github.com/codeready-toolchain/toolchain-common/pkg/configuration"
+	commontest "github.com/codeready-toolchain/toolchain-common/pkg/test"
+	authsupport "github.com/codeready-toolchain/toolchain-common/pkg/test/auth"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenCache(t *testing.T) {
+	now := time.Now()
+	newCache := func(size int) *TokenCache {
+		cache := NewTokenCache(size, 5*time.Minute)
+		cache.now = func() time.Time {
+			return now
+		}
+		return cache
+	}
+	claims := func(username string, exp time.Time) *TokenClaims {
+		return &TokenClaims{
+			PreferredUsername: username,
+			RegisteredClaims: jwt.RegisteredClaims{
+				ExpiresAt: jwt.NewNumericDate(exp),
+			},
+		}
+	}
+
+	t.Run("get cached claims", func(t *testing.T) {
+		// given
+		cache := newCache(10)
+		cache.add("token-1", claims("jsmith", now.Add(time.Hour)))
+
+		// when
+		cached, found := cache.get("token-1")
+
+		// then
+		require.True(t, found)
+		assert.Equal(t, "jsmith", cached.PreferredUsername)
+		_, found = cache.get("token-2")
+		assert.False(t, found)
+	})
+
+	t.Run("returned claims are a copy", func(t *testing.T) {
+		// given
+		cache := newCache(10)
+		cache.add("token-1", claims("jsmith", now.Add(time.Hour)))
+		cached, _ := cache.get("token-1")
+
+		// when
+		cached.PreferredUsername = "johnsmith"
+
+		// then
+		cached, _ = cache.get("token-1")
+		assert.Equal(t, "jsmith", cached.PreferredUsername)
+	})
+
+	t.Run("returned roles and audiences are a copy", func(t *testing.T) {
+		// given
+		cache := newCache(10)
+		c := claims("jsmith", now.Add(time.Hour))
+		c.Roles = []string{"admin", "viewer"}
+		c.Audience = jwt.ClaimStrings{"sandbox-public"}
+		cache.add("token-1", c)
+		cached, _ := cache.get("token-1")
+
+		// when
+		cached.Roles[0] = "viewer"
+		cached.Roles = append(cached.Roles, "editor")
+		cached.Audience[0] = "other"
+		cached.ExpiresAt.Time = now.Add(24 * time.Hour)
+		// the claims which were added are not cached either
+		c.Roles[1] = "admin"
+
+		// then
+		cached, _ = cache.get("token-1")
+		assert.Equal(t, []string{"admin", "viewer"}, cached.Roles)
+		assert.Equal(t, jwt.ClaimStrings{"sandbox-public"}, cached.Audience)
+		assert.Equal(t, now.Add(time.Hour).Unix(), cached.ExpiresAt.Unix())
+	})
+
+	t.Run("entry expires with the token", func(t *testing.T) {
+		// given
+		cache := newCache(10)
+		cache.add("token-1", claims("jsmith", now.Add(time.Minute)))
+
+		// when
+		now = now.Add(time.Minute)
+
+		// then
+		_, found := cache.get("token-1")
+		assert.False(t, found)
+	})
+
+	t.Run("entry expires after the max TTL", func(t *testing.T) {
+		// given
+		cache := newCache(10)
+		cache.add("token-1", claims("jsmith", now.Add(time.Hour)))
+
+		// when
+		now = now.Add(4 * time.Minute)
+		_, foundBeforeMaxTTL := cache.get("token-1")
+		now = now.Add(time.Minute)
+		_, foundAfterMaxTTL := cache.get("token-1")
+
+		// then
+		assert.True(t, foundBeforeMaxTTL)
+		assert.False(t, foundAfterMaxTTL)
+	})
+
+	t.Run("least recently used entries are evicted", func(t *testing.T) {
+		// given
+		cache := newCache(2)
+		cache.add("token-1", claims("user1", now.Add(time.Hour)))
+		cache.add("token-2", claims("user2", now.Add(time.Hour)))
+		cache.get("token-1")
+
+		// when
+		cache.add("token-3", claims("user3", now.Add(time.Hour)))
+
+		// then
+		_, found := cache.get("token-1")
+		assert.True(t, found)
+		_, found = cache.get("token-2")
+		assert.False(t, found)
+		_, found = cache.get("token-3")
+		assert.True(t, found)
+	})
+}
+
+func TestTokenParserWithCache(t *testing.T) {
+	tokengenerator, tokenParser := newTestTokenParser(t)
+	reg := prometheus.NewRegistry()
+	tokenCacheLookupsCounterVec.Reset()
+	RegisterMetrics(reg)
+	tokenParser.UseCache(NewTokenCache(10, time.Minute))
+	kid := tokengenerator.kid
+
+	t.Run("valid token is cached", func(t *testing.T) {
+		// given
+		token, err := tokengenerator.GenerateSignedToken(*authsupport.NewIdentity(), kid, authsupport.WithEmailClaim("jsmith@email.tld"))
+		require.NoError(t, err)
+
+		// when
+		claims1, err1 := tokenParser.FromString(token)
+		claims2, err2 := tokenParser.FromString(token)
+
+		// then
+		require.NoError(t, err1)
+		require.NoError(t, err2)
+		assert.Equal(t, claims1, claims2)
+		assert.InDelta(t, float64(1), testutil.ToFloat64(tokenCacheLookupsCounterVec.WithLabelValues(metricsLabelHit)), 0)
+		assert.InDelta(t, float64(1), testutil.ToFloat64(tokenCacheLookupsCounterVec.WithLabelValues(metricsLabelMiss)), 0)
+	})
+
+	t.Run("invalid token is not cached", func(t *testing.T) {
+		// given
+		token, err := tokengenerator.GenerateSignedToken(*authsupport.NewIdentity(), kid)
+		require.NoError(t, err)
+
+		// when
+		_, err1 := tokenParser.FromString(token)
+		_, err2 := tokenParser.FromString(token)
+
+		// then
+		require.EqualError(t, err1, "token does not comply to expected claims: email missing")
+		require.EqualError(t, err2, "token does not comply to expected claims: email missing")
+		assert.InDelta(t, float64(1), testutil.ToFloat64(tokenCacheLookupsCounterVec.WithLabelValues(metricsLabelHit)), 0)
+		assert.InDelta(t, float64(3), testutil.ToFloat64(tokenCacheLookupsCounterVec.WithLabelValues(metricsLabelMiss)), 0)
+	})
+}
+
+func BenchmarkTokenParser(b *testing.B) {
+	tokengenerator, tokenParser := newTestTokenParser(b)
+	tokens := make([]string, 100)
+	for i := range tokens {
+		token, err := tokengenerator.GenerateSignedToken(*authsupport.NewIdentity(), tokengenerator.kid, authsupport.WithEmailClaim("jsmith@email.tld"))
+		require.NoError(b, err)
+		tokens[i] = token
+	}
+
+	b.Run("without cache", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := tokenParser.FromString(tokens[i%len(tokens)]); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("with cache", func(b *testing.B) {
+		tokenParser.UseCache(NewTokenCache(len(tokens), time.Minute))
+		defer tokenParser.UseCache(nil)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := tokenParser.FromString(tokens[i%len(tokens)]); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+type testTokenGenerator struct {
+	*authsupport.TokenManager
+	kid string
+}
+
+// newTestTokenParser returns a TokenParser for the tokens signed by the returned generator
+func newTestTokenParser(t testing.TB) (*testTokenGenerator, *TokenParser) {
+	log.Init("registration-service-testing")
+	t.Setenv(commonconfig.WatchNamespaceEnvVar, commontest.HostOperatorNs)
+	tokengenerator := &testTokenGenerator{
+		TokenManager: authsupport.NewTokenManager(),
+		kid:          uuid.NewString(),
+	}
+	_, err := tokengenerator.AddPrivateKey(tokengenerator.kid)
+	require.NoError(t, err)
+	keyServer := tokengenerator.NewKeyServer()
+	t.Cleanup(keyServer.Close)
+	keyManager, err := NewKeyManagerForURL(keyServer.URL)
+	require.NoError(t, err)
+	t.Cleanup(keyManager.Stop)
+	tokenParser, err := NewTokenParser(keyManager)
+	require.NoError(t, err)
+	return tokengenerator, tokenParser
+}
@@ -10,6 +10,14 @@ import (
 
 const leeway = 5 * time.Second
 
+// SupportedAlgorithms is the list of signing algorithms which are accepted by default
+var SupportedAlgorithms = []string{
+	jwt.SigningMethodRS256.Alg(), jwt.SigningMethodRS384.Alg(), jwt.SigningMethodRS512.Alg(),
+	jwt.SigningMethodPS256.Alg(), jwt.SigningMethodPS384.Alg(), jwt.SigningMethodPS512.Alg(),
+	jwt.SigningMethodES256.Alg(), jwt.SigningMethodES384.Alg(), jwt.SigningMethodES512.Alg(),
+	jwt.SigningMethodEdDSA.Alg(),
+}
+
 // TokenClaims represents access token claims
 type TokenClaims struct {
 	Name              string `json:"name"`
@@ -22,32 +30,187 @@ type TokenClaims struct {
 	OriginalSub       string `json:"original_sub"`
 	UserID            string `json:"user_id"`
 	AccountID         string `json:"account_id"`
+	// Roles are the roles granted by the RoleMapper of the TokenParser, from the groups or roles claims of the token
+	Roles []string `json:"-"`
 	jwt.RegisteredClaims
 }
 
+// Issuer represents a trusted token issuer
+type Issuer struct {
+	// Name is the expected value of the `iss` claim
+	Name string
+	// KeyManager manages the public keys used to verify the signature of the tokens from this issuer
+	KeyManager *KeyManager
+	// Audiences is the list of accepted values for the `aud` claim. Any audience is accepted when the list is empty.
+	Audiences []string
+	// RequiredClaims is the list of claims which must be present in the tokens, in addition to the default ones
+	RequiredClaims []string
+	// Algorithms is the list of accepted signing algorithms. All the SupportedAlgorithms are accepted when the list is empty.
+	Algorithms []string
+	// ClaimMapper sets the identity fields from the claims of the tokens. The default claim mappings are used when nil.
+	ClaimMapper *ClaimMapper
+}
+
 // TokenParser represents a parser for JWT tokens.
 type TokenParser struct {
+	// keyManager is used when no trusted issuer is configured, in which case the `iss` and `aud` claims are not verified
 	keyManager *KeyManager
+	// claimMapper is used when no trusted issuer is configured
+	claimMapper *ClaimMapper
+	// issuers is the set of trusted issuers, indexed by name
+	issuers map[string]*Issuer
+	// cache contains the claims of the tokens which were already verified, or is nil if the cache is disabled
+	cache *TokenCache
+	// introspector checks that the verified tokens are still active, or is nil if the introspection is disabled
+	introspector *TokenIntrospector
+	// roleMapper grants the roles to the users from the claims of their tokens, or is nil if no role is granted
+	roleMapper *RoleMapper
 }
 
-// NewTokenParser creates a new TokenParser.
+// NewTokenParser creates a new TokenParser which accepts the tokens signed with the keys of the given KeyManager,
+// regardless of their issuer and audience. The identity fields are set using the default claim mappings.
 func NewTokenParser(keyManager *KeyManager) (*TokenParser, error) {
+	return NewTokenParserWithClaimMapper(keyManager, nil)
+}
+
+// NewTokenParserWithClaimMapper creates a new TokenParser which accepts the tokens signed with the keys of the given KeyManager,
+// regardless of their issuer and audience. The identity fields are set using the given ClaimMapper, or using the default
+// claim mappings if nil.
+func NewTokenParserWithClaimMapper(keyManager *KeyManager, claimMapper *ClaimMapper) (*TokenParser, error) {
 	if keyManager == nil {
 		return nil, errors.New("no keyManager given when creating TokenParser")
 	}
+	if claimMapper == nil {
+		claimMapper = defaultClaimMapper
+	}
 	return &TokenParser{
-		keyManager: keyManager,
+		keyManager:  keyManager,
+		claimMapper: claimMapper,
 	}, nil
 }
 
+// NewTokenParserForIssuers creates a new TokenParser which only accepts the tokens from the given issuers.
+// The issuer of a token is selected from its `iss` claim.
+func NewTokenParserForIssuers(issuers ...Issuer) (*TokenParser, error) {
+	if len(issuers) == 0 {
+		return nil, errors.New("no issuer given when creating TokenParser")
+	}
+	tp := &TokenParser{
+		issuers: make(map[string]*Issuer, len(issuers)),
+	}
+	for i := range issuers {
+		issuer := issuers[i]
+		if issuer.Name == "" {
+			return nil, errors.New("no name given for issuer when creating TokenParser")
+		}
+		if issuer.KeyManager == nil {
+			return nil, fmt.Errorf("no keyManager given for issuer '%s' when creating TokenParser", issuer.Name)
+		}
+		for _, alg := range issuer.Algorithms {
+			if !contains(SupportedAlgorithms, alg) {
+				return nil, fmt.Errorf("unsupported signing algorithm '%s' given for issuer '%s' when creating TokenParser", alg, issuer.Name)
+			}
+		}
+		if issuer.ClaimMapper == nil {
+			issuer.ClaimMapper = defaultClaimMapper
+		}
+		if _, exists := tp.issuers[issuer.Name]; exists {
+			return nil, fmt.Errorf("issuer '%s' given more than once when creating TokenParser", issuer.Name)
+		}
+		tp.issuers[issuer.Name] = &issuer
+	}
+	return tp, nil
+}
+
+// UseCache enables the cache of verified tokens. Must be called before the TokenParser is used.
+func (tp *TokenParser) UseCache(cache *TokenCache) {
+	tp.cache = cache
+}
+
+// UseIntrospector enables the introspection of the verified tokens. Must be called before the TokenParser is used.
+func (tp *TokenParser) UseIntrospector(introspector *TokenIntrospector) {
+	tp.introspector = introspector
+}
+
+// UseRoleMapper enables the roles granted from the claims of the tokens. Must be called before the TokenParser is used.
+func (tp *TokenParser) UseRoleMapper(roleMapper *RoleMapper) {
+	tp.roleMapper = roleMapper
+}
+
+// Stop stops the background refresh of the keys of the TokenParser
+func (tp *TokenParser) Stop() {
+	if tp.keyManager != nil {
+		tp.keyManager.Stop()
+	}
+	for _, issuer := range tp.issuers {
+		issuer.KeyManager.Stop()
+	}
+}
+
 // FromString parses a JWT, validates the signature and returns the claims struct.
+// If the cache is enabled, the claims of a token which was already verified are returned without verifying the token again.
+// If the introspection is enabled, the token is also rejected when it is not active anymore.
 func (tp *TokenParser) FromString(jwtEncoded string) (*TokenClaims, error) {
+	claims, err := tp.verify(jwtEncoded)
+	if err != nil {
+		return nil, err
+	}
+	if tp.introspector != nil {
+		if err := tp.introspector.Introspect(jwtEncoded); err != nil {
+			return nil, err
+		}
+	}
+	return claims, nil
+}
+
+func (tp *TokenParser) verify(jwtEncoded string) (*TokenClaims, error) {
+	if tp.cache == nil {
+		return tp.parse(jwtEncoded)
+	}
+	if claims, found := tp.cache.get(jwtEncoded); found {
+		return claims, nil
+	}
+	claims, err := tp.parse(jwtEncoded)
+	if err != nil {
+		return nil, err
+	}
+	tp.cache.add(jwtEncoded, claims)
+	return claims, nil
+}
+
+func (tp *TokenParser) parse(jwtEncoded string) (*TokenClaims, error) {
+	keyManager := tp.keyManager
+	claimMapper := tp.claimMapper
+	var issuer *Issuer
+	var rawClaims jwt.MapClaims
+	var opts []jwt.ParserOption
+	if len(tp.issuers) > 0 {
+		// look-up the issuer before verifying the signature, since the keys depend on it
+		rawClaims = jwt.MapClaims{}
+		if _, _, err := jwt.NewParser().ParseUnverified(jwtEncoded, rawClaims); err != nil {
+			return nil, err
+		}
+		iss, _ := rawClaims["iss"].(string)
+		var found bool
+		if issuer, found = tp.issuers[iss]; !found {
+			return nil, fmt.Errorf("token issuer is not trusted: '%s'", iss)
+		}
+		keyManager = issuer.KeyManager
+		claimMapper = issuer.ClaimMapper
+		opts = append(opts, jwt.WithIssuer(issuer.Name))
+	}
+	opts = append(opts, jwt.WithLeeway(leeway))
+
 	token, err := jwt.ParseWithClaims(
 		jwtEncoded,
 		&TokenClaims{},
 		func(token *jwt.Token) (interface{}, error) {
 			// validate the alg is what we expect
-			if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+			algorithms := SupportedAlgorithms
+			if issuer != nil && len(issuer.Algorithms) > 0 {
+				algorithms = issuer.Algorithms
+			}
+			if !contains(algorithms, token.Method.Alg()) {
 				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 			}
 
@@ -60,29 +223,68 @@ func (tp *TokenParser) FromString(jwtEncoded string) (*TokenClaims, error) {
 				return nil, errors.New("given key id has unknown type")
 			}
 			// get the public key for kid from keyManager
-			publicKey, err := tp.keyManager.Key(kidStr)
+			publicKey, err := keyManager.Key(kidStr)
 			if err != nil {
 				return nil, err
 			}
 			return publicKey, nil
 		},
-		jwt.WithLeeway(leeway),
+		opts...,
 	)
 	if err != nil {
 		return nil, err
 	}
 	if claims, ok := token.Claims.(*TokenClaims); ok && token.Valid {
-		// we need username and email, so check if those are contained in the claims
-		if claims.PreferredUsername == "" {
-			return nil, errors.New("token does not comply to expected claims: username missing")
+		if rawClaims == nil {
+			rawClaims = jwt.MapClaims{}
+			if _, _, err := jwt.NewParser().ParseUnverified(jwtEncoded, rawClaims); err != nil {
+				return nil, err
+			}
 		}
-		if claims.Email == "" {
-			return nil, errors.New("token does not comply to expected claims: email missing")
+		// set the identity fields (username, email, subject, etc.) and check that the required ones are contained in the claims
+		if err := claimMapper.apply(claims, rawClaims); err != nil {
+			return nil, err
 		}
-		if claims.Subject == "" {
-			return nil, errors.New("token does not comply to expected claims: subject missing")
+		if tp.roleMapper != nil {
+			tp.roleMapper.apply(claims, rawClaims)
+		}
+		if issuer != nil {
+			if err := verifyIssuerClaims(issuer, claims, rawClaims); err != nil {
+				return nil, err
+			}
 		}
 		return claims, nil
 	}
 	return nil, errors.New("token does not comply to expected claims")
 }
+
+// verifyIssuerClaims verifies the audience and the required claims of the given issuer
+func verifyIssuerClaims(issuer *Issuer, claims *TokenClaims, rawClaims jwt.MapClaims) error {
+	if len(issuer.Audiences) > 0 && !containsAny(issuer.Audiences, claims.Audience) {
+		return fmt.Errorf("token does not comply to expected claims: audience not allowed: %v", []string(claims.Audience))
+	}
+	for _, claim := range issuer.RequiredClaims {
+		if value, found := rawClaims[claim]; !found || value == nil || value == "" {
+			return fmt.Errorf("token does not comply to expected claims: %s missing", claim)
+		}
+	}
+	return nil
+}
+
+func containsAny(allowed []string, values []string) bool {
+	for _, v := range values {
+		if contains(allowed, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -1,6 +1,12 @@
 package auth_test
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
 	"strings"
 	"testing"
 	"time"
@@ -377,3 +383,238 @@ func (s *TestTokenParserSuite) TestTokenParser() {
 		}
 	})
 }
+
+func (s *TestTokenParserSuite) TestTokenParserForIssuers() {
+	restore := commontest.SetEnvVarAndRestore(s.T(), commonconfig.WatchNamespaceEnvVar, commontest.HostOperatorNs)
+	defer restore()
+	s.OverrideApplicationDefault(testconfig.RegistrationService().
+		Environment(configuration.UnitTestsEnvironment))
+
+	// create a key server per issuer
+	tokengeneratorA := authsupport.NewTokenManager()
+	kidA := uuid.NewString()
+	_, err := tokengeneratorA.AddPrivateKey(kidA)
+	require.NoError(s.T(), err)
+	keyManagerA, err := auth.NewKeyManagerForURL(tokengeneratorA.NewKeyServer().URL)
+	require.NoError(s.T(), err)
+
+	tokengeneratorB := authsupport.NewTokenManager()
+	kidB := uuid.NewString()
+	_, err = tokengeneratorB.AddPrivateKey(kidB)
+	require.NoError(s.T(), err)
+	keyManagerB, err := auth.NewKeyManagerForURL(tokengeneratorB.NewKeyServer().URL)
+	require.NoError(s.T(), err)
+
+	tokenParser, err := auth.NewTokenParserForIssuers(
+		auth.Issuer{
+			Name:       "https://sso.issuer-a.com",
+			KeyManager: keyManagerA,
+			Audiences:  []string{"sandbox-public", "sandbox-cli"},
+		},
+		auth.Issuer{
+			Name:           "https://sso.issuer-b.com",
+			KeyManager:     keyManagerB,
+			RequiredClaims: []string{"account_id"},
+		},
+	)
+	require.NoError(s.T(), err)
+
+	identity := authsupport.Identity{
+		ID:       uuid.New(),
+		Username: uuid.NewString(),
+	}
+	email := identity.Username + "@email.tld"
+
+	withIssuer := func(iss string) authsupport.ExtraClaim {
+		return func(token *jwt.Token) {
+			token.Claims.(*authsupport.MyClaims).Issuer = iss
+		}
+	}
+
+	s.Run("invalid arguments to new", func() {
+		_, err := auth.NewTokenParserForIssuers()
+		require.EqualError(s.T(), err, "no issuer given when creating TokenParser")
+
+		_, err = auth.NewTokenParserForIssuers(auth.Issuer{KeyManager: keyManagerA})
+		require.EqualError(s.T(), err, "no name given for issuer when creating TokenParser")
+
+		_, err = auth.NewTokenParserForIssuers(auth.Issuer{Name: "https://sso.issuer-a.com"})
+		require.EqualError(s.T(), err, "no keyManager given for issuer 'https://sso.issuer-a.com' when creating TokenParser")
+
+		_, err = auth.NewTokenParserForIssuers(
+			auth.Issuer{Name: "https://sso.issuer-a.com", KeyManager: keyManagerA},
+			auth.Issuer{Name: "https://sso.issuer-a.com", KeyManager: keyManagerB})
+		require.EqualError(s.T(), err, "issuer 'https://sso.issuer-a.com' given more than once when creating TokenParser")
+	})
+
+	s.Run("valid token from issuer with audiences", func() {
+		jwt0, err := tokengeneratorA.GenerateSignedToken(identity, kidA, authsupport.WithEmailClaim(email),
+			withIssuer("https://sso.issuer-a.com"), authsupport.WithAudClaim([]string{"account", "sandbox-cli"}))
+		require.NoError(s.T(), err)
+
+		claims, err := tokenParser.FromString(jwt0)
+
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), identity.Username, claims.PreferredUsername)
+		assert.Equal(s.T(), "https://sso.issuer-a.com", claims.Issuer)
+	})
+
+	s.Run("valid token from issuer with required claims", func() {
+		jwt0, err := tokengeneratorB.GenerateSignedToken(identity, kidB, authsupport.WithEmailClaim(email),
+			withIssuer("https://sso.issuer-b.com"), authsupport.WithAccountIDClaim("1234"))
+		require.NoError(s.T(), err)
+
+		claims, err := tokenParser.FromString(jwt0)
+
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), "1234", claims.AccountID)
+	})
+
+	s.Run("untrusted issuer", func() {
+		jwt0, err := tokengeneratorA.GenerateSignedToken(identity, kidA, authsupport.WithEmailClaim(email),
+			authsupport.WithAudClaim([]string{"sandbox-public"}))
+		require.NoError(s.T(), err)
+
+		_, err = tokenParser.FromString(jwt0)
+
+		require.EqualError(s.T(), err, "token issuer is not trusted: 'codeready-toolchain'")
+	})
+
+	s.Run("token signed with the keys of another issuer", func() {
+		jwt0, err := tokengeneratorB.GenerateSignedToken(identity, kidB, authsupport.WithEmailClaim(email),
+			withIssuer("https://sso.issuer-a.com"), authsupport.WithAudClaim([]string{"sandbox-public"}))
+		require.NoError(s.T(), err)
+
+		_, err = tokenParser.FromString(jwt0)
+
+		require.Error(s.T(), err)
+		assert.Contains(s.T(), err.Error(), "unknown kid")
+	})
+
+	s.Run("audience not allowed", func() {
+		jwt0, err := tokengeneratorA.GenerateSignedToken(identity, kidA, authsupport.WithEmailClaim(email),
+			withIssuer("https://sso.issuer-a.com"), authsupport.WithAudClaim([]string{"account"}))
+		require.NoError(s.T(), err)
+
+		_, err = tokenParser.FromString(jwt0)
+
+		require.EqualError(s.T(), err, "token does not comply to expected claims: audience not allowed: [account]")
+	})
+
+	s.Run("audience missing", func() {
+		jwt0, err := tokengeneratorA.GenerateSignedToken(identity, kidA, authsupport.WithEmailClaim(email),
+			withIssuer("https://sso.issuer-a.com"))
+		require.NoError(s.T(), err)
+
+		_, err = tokenParser.FromString(jwt0)
+
+		require.EqualError(s.T(), err, "token does not comply to expected claims: audience not allowed: []")
+	})
+
+	s.Run("required claim missing", func() {
+		jwt0, err := tokengeneratorB.GenerateSignedToken(identity, kidB, authsupport.WithEmailClaim(email),
+			withIssuer("https://sso.issuer-b.com"))
+		require.NoError(s.T(), err)
+
+		_, err = tokenParser.FromString(jwt0)
+
+		require.EqualError(s.T(), err, "token does not comply to expected claims: account_id missing")
+	})
+}
+
+func (s *TestTokenParserSuite) TestTokenParserSigningAlgorithms() {
+	restore := commontest.SetEnvVarAndRestore(s.T(), commonconfig.WatchNamespaceEnvVar, commontest.HostOperatorNs)
+	defer restore()
+	s.OverrideApplicationDefault(testconfig.RegistrationService().
+		Environment(configuration.UnitTestsEnvironment))
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(s.T(), err)
+	ecKey256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(s.T(), err)
+	ecKey384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(s.T(), err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(s.T(), err)
+	keyServer := newRotatingKeyServer(s.T())
+	defer keyServer.Close()
+	keyServer.setKeys(s.T(), map[string]crypto.Signer{"rsa": rsaKey, "ec256": ecKey256, "ec384": ecKey384, "ed": edKey})
+	keyManager, err := auth.NewKeyManagerForURL(keyServer.URL)
+	require.NoError(s.T(), err)
+	defer keyManager.Stop()
+
+	signedToken := func(method jwt.SigningMethod, kid string, key crypto.Signer) string {
+		token := jwt.NewWithClaims(method, jwt.MapClaims{
+			"iss":                "https://sso.test.org",
+			"sub":                uuid.NewString(),
+			"preferred_username": "jsmith",
+			"email":              "jsmith@email.tld",
+			"exp":                time.Now().Add(time.Hour).Unix(),
+		})
+		token.Header["kid"] = kid
+		signed, err := token.SignedString(key)
+		require.NoError(s.T(), err)
+		return signed
+	}
+
+	s.Run("all supported algorithms are accepted by default", func() {
+		tokenParser, err := auth.NewTokenParser(keyManager)
+		require.NoError(s.T(), err)
+
+		for _, tc := range []struct {
+			method jwt.SigningMethod
+			kid    string
+			key    crypto.Signer
+		}{
+			{jwt.SigningMethodRS256, "rsa", rsaKey},
+			{jwt.SigningMethodRS512, "rsa", rsaKey},
+			{jwt.SigningMethodPS256, "rsa", rsaKey},
+			{jwt.SigningMethodES256, "ec256", ecKey256},
+			{jwt.SigningMethodES384, "ec384", ecKey384},
+			{jwt.SigningMethodEdDSA, "ed", edKey},
+		} {
+			s.Run(tc.method.Alg(), func() {
+				claims, err := tokenParser.FromString(signedToken(tc.method, tc.kid, tc.key))
+
+				require.NoError(s.T(), err)
+				assert.Equal(s.T(), "jsmith", claims.PreferredUsername)
+			})
+		}
+	})
+
+	s.Run("key type does not match the algorithm", func() {
+		tokenParser, err := auth.NewTokenParser(keyManager)
+		require.NoError(s.T(), err)
+		// token signed with an ECDSA key, but the kid refers to the RSA key
+		_, err = tokenParser.FromString(signedToken(jwt.SigningMethodES256, "rsa", ecKey256))
+
+		require.Error(s.T(), err)
+		assert.ErrorIs(s.T(), err, jwt.ErrTokenSignatureInvalid)
+	})
+
+	s.Run("algorithms allowed for the issuer", func() {
+		tokenParser, err := auth.NewTokenParserForIssuers(auth.Issuer{
+			Name:       "https://sso.test.org",
+			KeyManager: keyManager,
+			Algorithms: []string{"ES256", "EdDSA"},
+		})
+		require.NoError(s.T(), err)
+
+		_, err = tokenParser.FromString(signedToken(jwt.SigningMethodES256, "ec256", ecKey256))
+		require.NoError(s.T(), err)
+		_, err = tokenParser.FromString(signedToken(jwt.SigningMethodEdDSA, "ed", edKey))
+		require.NoError(s.T(), err)
+		_, err = tokenParser.FromString(signedToken(jwt.SigningMethodRS256, "rsa", rsaKey))
+		require.EqualError(s.T(), err, "token is unverifiable: error while executing keyfunc: unexpected signing method: RS256")
+	})
+
+	s.Run("unsupported algorithm for the issuer", func() {
+		_, err := auth.NewTokenParserForIssuers(auth.Issuer{
+			Name:       "https://sso.test.org",
+			KeyManager: keyManager,
+			Algorithms: []string{"ES256", "HS256"},
+		})
+
+		require.EqualError(s.T(), err, "unsupported signing algorithm 'HS256' given for issuer 'https://sso.test.org' when creating TokenParser")
+	})
+}
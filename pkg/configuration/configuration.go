@@ -3,6 +3,7 @@
 package configuration
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"strconv"
@@ -134,7 +135,11 @@ func (r RegistrationServiceConfig) Analytics() AnalyticsConfig {
 }
 
 func (r RegistrationServiceConfig) Auth() AuthConfig {
-	return AuthConfig{r.cfg.Host.RegistrationService.Auth}
+	return AuthConfig{
+		c:         r.cfg.Host.RegistrationService.Auth,
+		secretRef: commonconfig.GetString(r.cfg.Host.RegistrationService.Verification.Secret.Ref, ""),
+		secrets:   r.secrets,
+	}
 }
 
 func (r RegistrationServiceConfig) LogLevel() string {
@@ -145,6 +150,10 @@ func (r RegistrationServiceConfig) RegistrationServiceURL() string {
 	return commonconfig.GetString(r.cfg.Host.RegistrationService.RegistrationServiceURL, "https://registration.crt-placeholder.com")
 }
 
+func (r RegistrationServiceConfig) Proxy() ProxyConfig {
+	return ProxyConfig{}
+}
+
 func (r RegistrationServiceConfig) Verification() VerificationConfig {
 	return VerificationConfig{c: r.cfg.Host.RegistrationService.Verification, secrets: r.secrets}
 }
@@ -161,8 +170,23 @@ func (r AnalyticsConfig) DevSpacesSegmentWriteKey() string {
 	return commonconfig.GetString(r.c.DevSpaces.SegmentWriteKey, "")
 }
 
+// The keys of the secrets of the authentication in the secret of the registration service, which is referenced by
+// `host.registrationService.verification.secret.ref` in the ToolchainConfig
+const (
+	// AuthAPITokenSecretKey is the key of the secret used to sign the API tokens
+	AuthAPITokenSecretKey = "auth.apiTokenSecret" //nolint:gosec
+	// AuthIntrospectionClientSecretKey is the key of the client secret used to authenticate with the token introspection endpoint
+	AuthIntrospectionClientSecretKey = "auth.introspectionClientSecret" //nolint:gosec
+)
+
 type AuthConfig struct {
-	c toolchainv1alpha1.RegistrationServiceAuthConfig
+	c         toolchainv1alpha1.RegistrationServiceAuthConfig
+	secretRef string
+	secrets   map[string]map[string]string
+}
+
+func (r AuthConfig) registrationServiceSecret(secretKey string) string {
+	return r.secrets[r.secretRef][secretKey]
 }
 
 func (r AuthConfig) AuthClientLibraryURL() string {
@@ -181,6 +205,200 @@ func (r AuthConfig) AuthClientPublicKeysURL() string {
 	return commonconfig.GetString(r.c.AuthClientPublicKeysURL, "https://sso.devsandbox.dev/auth/realms/sandbox-dev/protocol/openid-connect/certs")
 }
 
+// PublicKeysRefreshInterval returns the interval at which the public keys are refreshed in the background
+func (r AuthConfig) PublicKeysRefreshInterval() time.Duration {
+	return getEnvDuration(AuthPublicKeysRefreshIntervalEnvVar, time.Hour)
+}
+
+// PublicKeysMinRefetchInterval returns the minimum interval between two fetches of the public keys triggered by an unknown kid
+func (r AuthConfig) PublicKeysMinRefetchInterval() time.Duration {
+	return getEnvDuration(AuthPublicKeysMinRefetchIntervalEnvVar, 30*time.Second)
+}
+
+// TrustedIssuer is a token issuer whose tokens are accepted by the registration service and the proxy
+type TrustedIssuer struct {
+	// Issuer is the expected value of the `iss` claim
+	Issuer string `json:"issuer"`
+	// PublicKeysURL is the URL of the JWKS used to verify the signature of the tokens.
+	// When empty, the URL is discovered from the `/.well-known/openid-configuration` document of the issuer.
+	PublicKeysURL string `json:"publicKeysURL,omitempty"`
+	// Audiences is the list of accepted values for the `aud` claim. Any audience is accepted when the list is empty.
+	Audiences []string `json:"audiences,omitempty"`
+	// RequiredClaims is the list of claims which must be present in the tokens, in addition to the default ones
+	RequiredClaims []string `json:"requiredClaims,omitempty"`
+	// Algorithms is the list of accepted signing algorithms (eg. RS256, ES256, EdDSA). All the supported algorithms are accepted when the list is empty.
+	Algorithms []string `json:"algorithms,omitempty"`
+	// ClaimMappings overrides the claim mappings (see AuthConfig.ClaimMappings) for the tokens of this issuer
+	ClaimMappings map[string]ClaimMapping `json:"claimMappings,omitempty"`
+}
+
+// ClaimMapping maps a claim of the tokens to an identity field (eg. username, email, subject, userID, accountID)
+type ClaimMapping struct {
+	// Claim is the path of the claim in the token. The segments of the path of a nested claim are separated by dots (eg. `org.id`).
+	Claim string `json:"claim"`
+	// Required is true if the tokens without this claim must be rejected. When it is not set, the requirement of the
+	// default mapping of the identity field is kept, so that overriding the claim does not make a required field optional.
+	Required *bool `json:"required,omitempty"`
+}
+
+// AdminRole is the role of the administrators of the toolchain. The administrators are not allowed to sign up.
+const AdminRole = "admin"
+
+// RoleMapping grants a role to the users whose tokens contain one of the given values in a groups or roles claim
+type RoleMapping struct {
+	// Role is the role granted to the users (eg. admin)
+	Role string `json:"role"`
+	// Claim is the path of the claim in the token, which contains a list of strings or a string of values separated by spaces
+	// (eg. `groups`, `realm_access.roles`)
+	Claim string `json:"claim"`
+	// Values is the list of the values of the claim which grant the role. The role is granted if any of them is in the claim.
+	Values []string `json:"values"`
+}
+
+// TrustedIssuers returns the list of trusted token issuers.
+// When the list is empty, the tokens signed with the keys from AuthClientPublicKeysURL are accepted regardless of their issuer and audience.
+// Unlike the other settings, an invalid value is returned as an error instead of falling back to the default value, since
+// the fallback would disable the validation of the issuer and audience of the tokens.
+func (r AuthConfig) TrustedIssuers() ([]TrustedIssuer, error) {
+	var issuers []TrustedIssuer
+	if found, err := parseEnvJSON(AuthTrustedIssuersEnvVar, &issuers); !found || err != nil {
+		return nil, err
+	}
+	return issuers, nil
+}
+
+// ClaimMappings returns the claim mappings, indexed by identity field. The default mapping is used for the fields which are not configured.
+func (r AuthConfig) ClaimMappings() map[string]ClaimMapping {
+	var mappings map[string]ClaimMapping
+	if !getEnvJSON(AuthClaimMappingsEnvVar, &mappings) {
+		return nil
+	}
+	return mappings
+}
+
+// RoleMappings returns the mappings which grant the roles to the users from the claims of their tokens.
+// By default, the admin role is granted to the members of the `crtadmin` group.
+func (r AuthConfig) RoleMappings() []RoleMapping {
+	var mappings []RoleMapping
+	if !getEnvJSON(AuthRoleMappingsEnvVar, &mappings) {
+		return []RoleMapping{{Role: AdminRole, Claim: "groups", Values: []string{"crtadmin"}}}
+	}
+	return mappings
+}
+
+// TokenCacheSize returns the maximum number of verified tokens kept in the cache. The cache is disabled if the size is zero or negative.
+func (r AuthConfig) TokenCacheSize() int {
+	return getEnvInt(AuthTokenCacheSizeEnvVar, 10000)
+}
+
+// TokenCacheMaxTTL returns the maximum time a verified token is kept in the cache
+func (r AuthConfig) TokenCacheMaxTTL() time.Duration {
+	return getEnvDuration(AuthTokenCacheMaxTTLEnvVar, 5*time.Minute)
+}
+
+// ClientCAFile returns the path of the certificates of the CAs which sign the client certificates accepted to authenticate
+// the requests to the proxy. The client certificates are not accepted when empty.
+func (r AuthConfig) ClientCAFile() string {
+	return getEnvString(AuthClientCAFileEnvVar, "")
+}
+
+// OIDCIssuerURL returns the URL of the OpenID Provider whose `/.well-known/openid-configuration` document is used for
+// token validation and web login. When empty, AuthClientPublicKeysURL, SSOBaseURL and SSORealm are used instead.
+func (r AuthConfig) OIDCIssuerURL() string {
+	return getEnvString(AuthOIDCIssuerURLEnvVar, "")
+}
+
+// OIDCDiscoveryRefreshInterval returns the interval at which the OpenID configuration is read again
+func (r AuthConfig) OIDCDiscoveryRefreshInterval() time.Duration {
+	return getEnvDuration(AuthOIDCDiscoveryRefreshIntervalEnvVar, time.Hour)
+}
+
+// APITokenSecret returns the secret used to sign the API tokens. The API tokens are disabled when empty.
+func (r AuthConfig) APITokenSecret() string {
+	return r.registrationServiceSecret(AuthAPITokenSecretKey)
+}
+
+// APITokenMaxPerUser returns the maximum number of unexpired API tokens of a user. There is no limit if it is zero or negative.
+func (r AuthConfig) APITokenMaxPerUser() int {
+	return getEnvInt(AuthAPITokenMaxPerUserEnvVar, 20)
+}
+
+// APITokenDefaultExpiration returns the lifetime of the API tokens created without an expiration.
+// These API tokens never expire if it is zero or negative.
+func (r AuthConfig) APITokenDefaultExpiration() time.Duration {
+	return getEnvDuration(AuthAPITokenDefaultExpirationEnvVar, 90*24*time.Hour)
+}
+
+// IntrospectionURL returns the URL of the OAuth 2.0 token introspection endpoint. The introspection is disabled when empty.
+func (r AuthConfig) IntrospectionURL() string {
+	return getEnvString(AuthIntrospectionURLEnvVar, "")
+}
+
+// IntrospectionClientID returns the client ID used to authenticate with the token introspection endpoint
+func (r AuthConfig) IntrospectionClientID() string {
+	return getEnvString(AuthIntrospectionClientIDEnvVar, "")
+}
+
+// IntrospectionClientSecret returns the client secret used to authenticate with the token introspection endpoint
+func (r AuthConfig) IntrospectionClientSecret() string {
+	return r.registrationServiceSecret(AuthIntrospectionClientSecretKey)
+}
+
+// IntrospectionCacheTTL returns the time the result of the introspection of a token is kept in the cache
+func (r AuthConfig) IntrospectionCacheTTL() time.Duration {
+	return getEnvDuration(AuthIntrospectionCacheTTLEnvVar, 30*time.Second)
+}
+
+// IntrospectionCacheSize returns the maximum number of introspection results kept in the cache.
+// The cache is disabled if the size is zero or negative.
+func (r AuthConfig) IntrospectionCacheSize() int {
+	return getEnvInt(AuthIntrospectionCacheSizeEnvVar, 10000)
+}
+
+// IntrospectionFailOpen returns true if the tokens are accepted when the token introspection endpoint fails
+func (r AuthConfig) IntrospectionFailOpen() bool {
+	return getEnvBool(AuthIntrospectionFailOpenEnvVar, false)
+}
+
+// KubeconfigExec returns the exec credential plugin of the kubeconfigs generated for the users, when they ask for a
+// kubeconfig without an embedded token. By default, the kubelogin plugin gets the tokens from the SSO.
+func (r AuthConfig) KubeconfigExec() KubeconfigExec {
+	var exec KubeconfigExec
+	if getEnvJSON(AuthKubeconfigExecEnvVar, &exec) {
+		return exec
+	}
+	issuer := r.OIDCIssuerURL()
+	if issuer == "" {
+		issuer = fmt.Sprintf("%s/auth/realms/%s", r.SSOBaseURL(), r.SSORealm())
+	}
+	clientConfig := struct {
+		ClientID string `json:"clientId"`
+	}{}
+	if err := json.Unmarshal([]byte(r.AuthClientConfigRaw()), &clientConfig); err != nil || clientConfig.ClientID == "" {
+		clientConfig.ClientID = "sandbox-public"
+	}
+	return KubeconfigExec{
+		Command: "kubectl",
+		Args: []string{
+			"oidc-login",
+			"get-token",
+			"--oidc-issuer-url=" + issuer,
+			"--oidc-client-id=" + clientConfig.ClientID,
+		},
+		InstallHint: "The kubelogin plugin is required to log in: https://github.com/int128/kubelogin",
+	}
+}
+
+// KubeconfigExec is the exec credential plugin which gets the tokens of the users in their kubeconfigs
+type KubeconfigExec struct {
+	// Command is the command to execute (eg. kubectl)
+	Command string `json:"command"`
+	// Args are the arguments of the command
+	Args []string `json:"args,omitempty"`
+	// InstallHint is shown to the users when the command is not found
+	InstallHint string `json:"installHint,omitempty"`
+}
+
 func (r AuthConfig) SSOBaseURL() string {
 	return commonconfig.GetString(r.c.SSOBaseURL, "https://sso.devsandbox.dev")
 }
@@ -189,6 +407,163 @@ func (r AuthConfig) SSORealm() string {
 	return commonconfig.GetString(r.c.SSORealm, "sandbox-dev")
 }
 
+// ProxyConfig is the configuration of the API proxy. Its settings are read from environment variables.
+type ProxyConfig struct{}
+
+// AuditRedaction redacts a field of the audit events
+type AuditRedaction struct {
+	// Field is the name of the field of the audit events (eg. `user`, `requestURI`, `sourceIP`)
+	Field string `json:"field"`
+	// Pattern is the regular expression matching the parts of the value to redact. The whole value is redacted when empty.
+	Pattern string `json:"pattern,omitempty"`
+}
+
+// TLSCertFile returns the path of the certificate with which the proxy serves HTTPS. The proxy serves plain HTTP when empty.
+func (r ProxyConfig) TLSCertFile() string {
+	return getEnvString(ProxyTLSCertFileEnvVar, "")
+}
+
+// TLSKeyFile returns the path of the private key of the certificate with which the proxy serves HTTPS
+func (r ProxyConfig) TLSKeyFile() string {
+	return getEnvString(ProxyTLSKeyFileEnvVar, "")
+}
+
+// AuditFile returns the path of the file to which the audit events are appended, or `-` for the standard output.
+// The file sink is disabled when empty.
+func (r ProxyConfig) AuditFile() string {
+	return getEnvString(ProxyAuditFileEnvVar, "")
+}
+
+// AuditWebhookURL returns the URL to which the audit events are posted. The webhook sink is disabled when empty.
+func (r ProxyConfig) AuditWebhookURL() string {
+	return getEnvString(ProxyAuditWebhookURLEnvVar, "")
+}
+
+// AuditQueueSize returns the maximum number of audit events waiting to be written to each sink
+func (r ProxyConfig) AuditQueueSize() int {
+	return getEnvInt(ProxyAuditQueueSizeEnvVar, 1000)
+}
+
+// AuditRedactions returns the rules which redact the fields of the audit events
+func (r ProxyConfig) AuditRedactions() []AuditRedaction {
+	var redactions []AuditRedaction
+	if !getEnvJSON(ProxyAuditRedactionsEnvVar, &redactions) {
+		return nil
+	}
+	return redactions
+}
+
+// RateLimit is a token-bucket rate limit
+type RateLimit struct {
+	// QPS is the number of requests per second allowed in the long run
+	QPS float64 `json:"qps"`
+	// Burst is the maximum number of requests allowed at once
+	Burst int `json:"burst"`
+}
+
+// RateLimits returns the rate limits of the proxied requests of each user, indexed by verb class (read, write or watch)
+func (r ProxyConfig) RateLimits() map[string]RateLimit {
+	var limits map[string]RateLimit
+	if !getEnvJSON(ProxyRateLimitsEnvVar, &limits) {
+		return nil
+	}
+	return limits
+}
+
+// MaxLongRunningRequests returns the maximum number of concurrent long-running requests (watch, exec, logs, etc.) of each user.
+// The number of long-running requests is not limited if zero or negative.
+func (r ProxyConfig) MaxLongRunningRequests() int {
+	return getEnvInt(ProxyMaxLongRunningRequestsEnvVar, 0)
+}
+
+// AccessCacheSize returns the maximum number of resolved accesses of the users to the workspaces kept in the cache.
+// The cache is disabled if the size is zero or negative.
+func (r ProxyConfig) AccessCacheSize() int {
+	return getEnvInt(ProxyAccessCacheSizeEnvVar, 10000)
+}
+
+// AccessCacheTTL returns the maximum time a resolved access is kept in the cache
+func (r ProxyConfig) AccessCacheTTL() time.Duration {
+	return getEnvDuration(ProxyAccessCacheTTLEnvVar, time.Minute)
+}
+
+// PolicyRule allows the requests with one of the given verbs on one of the given resources. The rules are matched as the
+// RBAC rules of the member clusters, but they can only restrict what the member clusters allow.
+type PolicyRule struct {
+	// Verbs are the allowed verbs (eg. get, list, watch, create). `*` allows all the verbs.
+	Verbs []string `json:"verbs"`
+	// APIGroups are the API groups of the resources. All the groups are allowed when empty or with `*`.
+	APIGroups []string `json:"apiGroups,omitempty"`
+	// Resources are the allowed resources and subresources (eg. pods, pods/log). All the resources are allowed when empty or with `*`.
+	Resources []string `json:"resources,omitempty"`
+}
+
+// SpaceRolePolicies returns the rules of the requests allowed for each SpaceRole, indexed by role, for the NSTemplateTiers
+// which do not define their own rules. The requests of the roles without rules are not restricted, nor the reviews of the
+// permissions of the users (eg. `kubectl auth can-i`). By default, the `viewer` role is only allowed to read the resources.
+func (r ProxyConfig) SpaceRolePolicies() map[string][]PolicyRule {
+	var policies map[string][]PolicyRule
+	if !getEnvJSON(ProxySpaceRolePoliciesEnvVar, &policies) {
+		return map[string][]PolicyRule{
+			"viewer": {{Verbs: []string{"get", "list", "watch"}}},
+		}
+	}
+	return policies
+}
+
+// The actions of the request policies
+const (
+	// RequestPolicyActionDeny rejects the matching requests
+	RequestPolicyActionDeny = "deny"
+	// RequestPolicyActionWarn forwards the matching requests with a warning returned to the client
+	RequestPolicyActionWarn = "warn"
+)
+
+// RequestPolicy is a CEL expression evaluated on the proxied requests. The expression returns true if the request matches
+// the policy. It can use the `request`, `user`, `workspace` and `object` variables (see the policy package).
+type RequestPolicy struct {
+	// Name identifies the policy in the logs and in the metrics
+	Name string `json:"name"`
+	// Expression is the CEL expression, which must return a bool
+	Expression string `json:"expression"`
+	// Action is the action on the matching requests: `deny` (default) or `warn`
+	Action string `json:"action,omitempty"`
+	// Message is returned to the client for the matching requests
+	Message string `json:"message,omitempty"`
+	// DryRun only logs and counts the matching requests
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+// RequestPolicies returns the CEL policies evaluated on the proxied requests. None by default.
+func (r ProxyConfig) RequestPolicies() []RequestPolicy {
+	var policies []RequestPolicy
+	if !getEnvJSON(ProxyRequestPoliciesEnvVar, &policies) {
+		return nil
+	}
+	return policies
+}
+
+// RequestPoliciesDryRun returns true if all the request policies only log and count the matching requests
+func (r ProxyConfig) RequestPoliciesDryRun() bool {
+	return getEnvBool(ProxyRequestPoliciesDryRunEnvVar, false)
+}
+
+// RequestPoliciesMaxBodySize returns the maximum size in bytes of the request bodies decoded for the request policies.
+// The requests with larger bodies are denied by the `deny` policies which use the body.
+func (r ProxyConfig) RequestPoliciesMaxBodySize() int {
+	return getEnvInt(ProxyRequestPoliciesMaxBodySizeEnvVar, 3*1024*1024)
+}
+
+// SubWorkspaceTiers returns the tiers of the sub-workspaces which can be created by the users, the first one being the default
+// one. None by default, ie. the users cannot create sub-workspaces.
+func (r ProxyConfig) SubWorkspaceTiers() []string {
+	var tiers []string
+	if !getEnvJSON(ProxySubWorkspaceTiersEnvVar, &tiers) {
+		return nil
+	}
+	return tiers
+}
+
 type VerificationConfig struct {
 	c       toolchainv1alpha1.RegistrationServiceVerificationConfig
 	secrets map[string]map[string]string
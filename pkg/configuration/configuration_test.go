@@ -2,6 +2,7 @@ package configuration_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/codeready-toolchain/registration-service/pkg/configuration"
 	"github.com/codeready-toolchain/registration-service/test"
@@ -10,6 +11,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	"k8s.io/utils/pointer"
 )
 
 type TestConfigurationSuite struct {
@@ -52,6 +54,49 @@ func TestRegistrationService(t *testing.T) {
 		assert.Equal(t, "https://sso.devsandbox.dev/auth/realms/sandbox-dev/protocol/openid-connect/certs", regServiceCfg.Auth().AuthClientPublicKeysURL())
 		assert.Equal(t, "https://sso.devsandbox.dev", regServiceCfg.Auth().SSOBaseURL())
 		assert.Equal(t, "sandbox-dev", regServiceCfg.Auth().SSORealm())
+		assert.Equal(t, time.Hour, regServiceCfg.Auth().PublicKeysRefreshInterval())
+		assert.Equal(t, 30*time.Second, regServiceCfg.Auth().PublicKeysMinRefetchInterval())
+		trustedIssuers, err := regServiceCfg.Auth().TrustedIssuers()
+		require.NoError(t, err)
+		assert.Empty(t, trustedIssuers)
+		assert.Empty(t, regServiceCfg.Auth().OIDCIssuerURL())
+		assert.Empty(t, regServiceCfg.Auth().ClientCAFile())
+		assert.Empty(t, regServiceCfg.Auth().ClaimMappings())
+		assert.Equal(t, []configuration.RoleMapping{{Role: "admin", Claim: "groups", Values: []string{"crtadmin"}}}, regServiceCfg.Auth().RoleMappings())
+		assert.Equal(t, 10000, regServiceCfg.Auth().TokenCacheSize())
+		assert.Equal(t, 5*time.Minute, regServiceCfg.Auth().TokenCacheMaxTTL())
+		assert.Equal(t, time.Hour, regServiceCfg.Auth().OIDCDiscoveryRefreshInterval())
+		assert.Empty(t, regServiceCfg.Auth().APITokenSecret())
+		assert.Equal(t, 20, regServiceCfg.Auth().APITokenMaxPerUser())
+		assert.Equal(t, 90*24*time.Hour, regServiceCfg.Auth().APITokenDefaultExpiration())
+		assert.Empty(t, regServiceCfg.Auth().IntrospectionURL())
+		assert.Empty(t, regServiceCfg.Auth().IntrospectionClientID())
+		assert.Empty(t, regServiceCfg.Auth().IntrospectionClientSecret())
+		assert.Equal(t, 30*time.Second, regServiceCfg.Auth().IntrospectionCacheTTL())
+		assert.Equal(t, 10000, regServiceCfg.Auth().IntrospectionCacheSize())
+		assert.False(t, regServiceCfg.Auth().IntrospectionFailOpen())
+		assert.Equal(t, configuration.KubeconfigExec{
+			Command:     "kubectl",
+			Args:        []string{"oidc-login", "get-token", "--oidc-issuer-url=https://sso.devsandbox.dev/auth/realms/sandbox-dev", "--oidc-client-id=sandbox-public"},
+			InstallHint: "The kubelogin plugin is required to log in: https://github.com/int128/kubelogin",
+		}, regServiceCfg.Auth().KubeconfigExec())
+		assert.Empty(t, regServiceCfg.Proxy().TLSCertFile())
+		assert.Empty(t, regServiceCfg.Proxy().TLSKeyFile())
+		assert.Empty(t, regServiceCfg.Proxy().AuditFile())
+		assert.Empty(t, regServiceCfg.Proxy().AuditWebhookURL())
+		assert.Equal(t, 1000, regServiceCfg.Proxy().AuditQueueSize())
+		assert.Empty(t, regServiceCfg.Proxy().AuditRedactions())
+		assert.Empty(t, regServiceCfg.Proxy().RateLimits())
+		assert.Equal(t, 0, regServiceCfg.Proxy().MaxLongRunningRequests())
+		assert.Equal(t, 10000, regServiceCfg.Proxy().AccessCacheSize())
+		assert.Equal(t, time.Minute, regServiceCfg.Proxy().AccessCacheTTL())
+		assert.Equal(t, map[string][]configuration.PolicyRule{
+			"viewer": {{Verbs: []string{"get", "list", "watch"}}},
+		}, regServiceCfg.Proxy().SpaceRolePolicies())
+		assert.Empty(t, regServiceCfg.Proxy().RequestPolicies())
+		assert.False(t, regServiceCfg.Proxy().RequestPoliciesDryRun())
+		assert.Equal(t, 3*1024*1024, regServiceCfg.Proxy().RequestPoliciesMaxBodySize())
+		assert.Empty(t, regServiceCfg.Proxy().SubWorkspaceTiers())
 		assert.False(t, regServiceCfg.Verification().Enabled())
 		assert.Equal(t, 5, regServiceCfg.Verification().DailyLimit())
 		assert.Equal(t, 3, regServiceCfg.Verification().AttemptsAllowed())
@@ -105,6 +150,40 @@ func TestRegistrationService(t *testing.T) {
 			AWSSecretAccessKey("aws.secretaccesskey").
 			RecaptchaServiceAccountFile("captcha.json"))
 
+		t.Setenv(configuration.AuthPublicKeysRefreshIntervalEnvVar, "15m")
+		t.Setenv(configuration.AuthPublicKeysMinRefetchIntervalEnvVar, "5s")
+		t.Setenv(configuration.AuthTokenCacheSizeEnvVar, "500")
+		t.Setenv(configuration.AuthTokenCacheMaxTTLEnvVar, "1m")
+		t.Setenv(configuration.AuthClaimMappingsEnvVar, `{"username":{"claim":"upn","required":true},"accountID":{"claim":"org.id"}}`)
+		t.Setenv(configuration.AuthRoleMappingsEnvVar, `[{"role":"admin","claim":"realm_access.roles","values":["sandbox-admin","sandbox-ops"]}]`)
+		t.Setenv(configuration.AuthClientCAFileEnvVar, "/etc/proxy/client-ca.crt")
+		t.Setenv(configuration.ProxyTLSCertFileEnvVar, "/etc/proxy/tls.crt")
+		t.Setenv(configuration.ProxyTLSKeyFileEnvVar, "/etc/proxy/tls.key")
+		t.Setenv(configuration.AuthOIDCIssuerURLEnvVar, "https://sso.test.org/realms/my-realm")
+		t.Setenv(configuration.AuthOIDCDiscoveryRefreshIntervalEnvVar, "10m")
+		t.Setenv(configuration.AuthAPITokenMaxPerUserEnvVar, "5")
+		t.Setenv(configuration.AuthAPITokenDefaultExpirationEnvVar, "720h")
+		t.Setenv(configuration.AuthIntrospectionURLEnvVar, "https://sso.test.org/introspect")
+		t.Setenv(configuration.AuthIntrospectionClientIDEnvVar, "registration-service")
+		t.Setenv(configuration.AuthIntrospectionCacheTTLEnvVar, "10s")
+		t.Setenv(configuration.AuthIntrospectionCacheSizeEnvVar, "500")
+		t.Setenv(configuration.AuthIntrospectionFailOpenEnvVar, "true")
+		t.Setenv(configuration.AuthKubeconfigExecEnvVar, `{"command":"oc","args":["whoami","--show-token"]}`)
+		t.Setenv(configuration.ProxyAuditFileEnvVar, "/var/log/proxy/audit.log")
+		t.Setenv(configuration.ProxyAuditWebhookURLEnvVar, "https://audit.test.org/events")
+		t.Setenv(configuration.ProxyAuditQueueSizeEnvVar, "50")
+		t.Setenv(configuration.ProxyRateLimitsEnvVar, `{"read":{"qps":50,"burst":100},"write":{"qps":10.5,"burst":20}}`)
+		t.Setenv(configuration.ProxyMaxLongRunningRequestsEnvVar, "10")
+		t.Setenv(configuration.ProxyAccessCacheSizeEnvVar, "500")
+		t.Setenv(configuration.ProxyAccessCacheTTLEnvVar, "30s")
+		t.Setenv(configuration.ProxySpaceRolePoliciesEnvVar, `{"viewer":[{"verbs":["get","list","watch"]},{"verbs":["create"],"resources":["pods/portforward"]}],"contributor":[{"verbs":["*"],"apiGroups":["","apps"]}]}`)
+		t.Setenv(configuration.ProxyRequestPoliciesEnvVar, `[{"name":"no-load-balancers","expression":"object.spec.type == 'LoadBalancer'","message":"LoadBalancer services are not allowed"},{"name":"large-configmaps","expression":"request.resource == 'configmaps' && request.contentLength > 100000","action":"warn","dryRun":true}]`)
+		t.Setenv(configuration.ProxyRequestPoliciesDryRunEnvVar, "true")
+		t.Setenv(configuration.ProxyRequestPoliciesMaxBodySizeEnvVar, "1024")
+		t.Setenv(configuration.ProxySubWorkspaceTiersEnvVar, `["appstudio-env","base1ns"]`)
+		t.Setenv(configuration.ProxyAuditRedactionsEnvVar, `[{"field":"sourceIP"},{"field":"requestURI","pattern":"token=[^&]*"}]`)
+		t.Setenv(configuration.AuthTrustedIssuersEnvVar, `[{"issuer":"https://sso.test.org/realms/my-realm","publicKeysURL":"https://sso.test.org/certs","audiences":["sandbox-public"]},{"issuer":"https://other.test.org","publicKeysURL":"https://other.test.org/certs","requiredClaims":["account_id"],"algorithms":["ES256","EdDSA"],"claimMappings":{"subject":{"claim":"oid","required":true}}}]`)
+
 		verificationSecretValues := make(map[string]string)
 		verificationSecretValues["twilio.sid"] = "def"
 		verificationSecretValues["twilio.token"] = "ghi"
@@ -112,6 +191,8 @@ func TestRegistrationService(t *testing.T) {
 		verificationSecretValues["aws.accesskeyid"] = "foo"
 		verificationSecretValues["aws.secretaccesskey"] = "bar"
 		verificationSecretValues["captcha.json"] = "example-content"
+		verificationSecretValues[configuration.AuthAPITokenSecretKey] = "0123456789abcdef0123456789abcdef"
+		verificationSecretValues[configuration.AuthIntrospectionClientSecretKey] = "s3cr3t"
 		secrets := make(map[string]map[string]string)
 		secrets["verification-secrets"] = verificationSecretValues
 
@@ -129,6 +210,87 @@ func TestRegistrationService(t *testing.T) {
 		assert.Equal(t, "https://sso.openshift.com/certs", regServiceCfg.Auth().AuthClientPublicKeysURL())
 		assert.Equal(t, "https://sso.test.org", regServiceCfg.Auth().SSOBaseURL())
 		assert.Equal(t, "my-realm", regServiceCfg.Auth().SSORealm())
+		assert.Equal(t, 15*time.Minute, regServiceCfg.Auth().PublicKeysRefreshInterval())
+		assert.Equal(t, 5*time.Second, regServiceCfg.Auth().PublicKeysMinRefetchInterval())
+		assert.Equal(t, 500, regServiceCfg.Auth().TokenCacheSize())
+		assert.Equal(t, time.Minute, regServiceCfg.Auth().TokenCacheMaxTTL())
+		assert.Equal(t, map[string]configuration.ClaimMapping{
+			"username":  {Claim: "upn", Required: pointer.Bool(true)},
+			"accountID": {Claim: "org.id"},
+		}, regServiceCfg.Auth().ClaimMappings())
+		assert.Equal(t, []configuration.RoleMapping{
+			{Role: "admin", Claim: "realm_access.roles", Values: []string{"sandbox-admin", "sandbox-ops"}},
+		}, regServiceCfg.Auth().RoleMappings())
+		assert.Equal(t, "https://sso.test.org/realms/my-realm", regServiceCfg.Auth().OIDCIssuerURL())
+		assert.Equal(t, "/etc/proxy/client-ca.crt", regServiceCfg.Auth().ClientCAFile())
+		assert.Equal(t, 10*time.Minute, regServiceCfg.Auth().OIDCDiscoveryRefreshInterval())
+		assert.Equal(t, "0123456789abcdef0123456789abcdef", regServiceCfg.Auth().APITokenSecret())
+		assert.Equal(t, 5, regServiceCfg.Auth().APITokenMaxPerUser())
+		assert.Equal(t, 720*time.Hour, regServiceCfg.Auth().APITokenDefaultExpiration())
+		assert.Equal(t, "https://sso.test.org/introspect", regServiceCfg.Auth().IntrospectionURL())
+		assert.Equal(t, "registration-service", regServiceCfg.Auth().IntrospectionClientID())
+		assert.Equal(t, "s3cr3t", regServiceCfg.Auth().IntrospectionClientSecret())
+		assert.Equal(t, 10*time.Second, regServiceCfg.Auth().IntrospectionCacheTTL())
+		assert.Equal(t, 500, regServiceCfg.Auth().IntrospectionCacheSize())
+		assert.True(t, regServiceCfg.Auth().IntrospectionFailOpen())
+		assert.Equal(t, configuration.KubeconfigExec{Command: "oc", Args: []string{"whoami", "--show-token"}}, regServiceCfg.Auth().KubeconfigExec())
+		assert.Equal(t, "/etc/proxy/tls.crt", regServiceCfg.Proxy().TLSCertFile())
+		assert.Equal(t, "/etc/proxy/tls.key", regServiceCfg.Proxy().TLSKeyFile())
+		assert.Equal(t, "/var/log/proxy/audit.log", regServiceCfg.Proxy().AuditFile())
+		assert.Equal(t, "https://audit.test.org/events", regServiceCfg.Proxy().AuditWebhookURL())
+		assert.Equal(t, 50, regServiceCfg.Proxy().AuditQueueSize())
+		assert.Equal(t, []configuration.AuditRedaction{
+			{Field: "sourceIP"},
+			{Field: "requestURI", Pattern: "token=[^&]*"},
+		}, regServiceCfg.Proxy().AuditRedactions())
+		assert.Equal(t, map[string]configuration.RateLimit{
+			"read":  {QPS: 50, Burst: 100},
+			"write": {QPS: 10.5, Burst: 20},
+		}, regServiceCfg.Proxy().RateLimits())
+		assert.Equal(t, 10, regServiceCfg.Proxy().MaxLongRunningRequests())
+		assert.Equal(t, 500, regServiceCfg.Proxy().AccessCacheSize())
+		assert.Equal(t, 30*time.Second, regServiceCfg.Proxy().AccessCacheTTL())
+		assert.Equal(t, map[string][]configuration.PolicyRule{
+			"viewer": {
+				{Verbs: []string{"get", "list", "watch"}},
+				{Verbs: []string{"create"}, Resources: []string{"pods/portforward"}},
+			},
+			"contributor": {{Verbs: []string{"*"}, APIGroups: []string{"", "apps"}}},
+		}, regServiceCfg.Proxy().SpaceRolePolicies())
+		assert.Equal(t, []configuration.RequestPolicy{
+			{
+				Name:       "no-load-balancers",
+				Expression: "object.spec.type == 'LoadBalancer'",
+				Message:    "LoadBalancer services are not allowed",
+			},
+			{
+				Name:       "large-configmaps",
+				Expression: "request.resource == 'configmaps' && request.contentLength > 100000",
+				Action:     configuration.RequestPolicyActionWarn,
+				DryRun:     true,
+			},
+		}, regServiceCfg.Proxy().RequestPolicies())
+		assert.True(t, regServiceCfg.Proxy().RequestPoliciesDryRun())
+		assert.Equal(t, 1024, regServiceCfg.Proxy().RequestPoliciesMaxBodySize())
+		assert.Equal(t, []string{"appstudio-env", "base1ns"}, regServiceCfg.Proxy().SubWorkspaceTiers())
+		trustedIssuers, err := regServiceCfg.Auth().TrustedIssuers()
+		require.NoError(t, err)
+		assert.Equal(t, []configuration.TrustedIssuer{
+			{
+				Issuer:        "https://sso.test.org/realms/my-realm",
+				PublicKeysURL: "https://sso.test.org/certs",
+				Audiences:     []string{"sandbox-public"},
+			},
+			{
+				Issuer:         "https://other.test.org",
+				PublicKeysURL:  "https://other.test.org/certs",
+				RequiredClaims: []string{"account_id"},
+				Algorithms:     []string{"ES256", "EdDSA"},
+				ClaimMappings: map[string]configuration.ClaimMapping{
+					"subject": {Claim: "oid", Required: pointer.Bool(true)},
+				},
+			},
+		}, trustedIssuers)
 
 		assert.True(t, regServiceCfg.Verification().Enabled())
 		assert.Equal(t, 15, regServiceCfg.Verification().DailyLimit())
@@ -152,4 +314,17 @@ func TestRegistrationService(t *testing.T) {
 		assert.False(t, regServiceCfg.Verification().CaptchaAllowLowScoreReactivation())
 		assert.Equal(t, "example-content", regServiceCfg.Verification().CaptchaServiceAccountFileContents())
 	})
+
+	t.Run("invalid trusted issuers", func(t *testing.T) {
+		// given
+		t.Setenv(configuration.AuthTrustedIssuersEnvVar, `[{"issuer":`)
+		cfg := commonconfig.NewToolchainConfigObjWithReset(t)
+
+		// when
+		regServiceCfg := configuration.NewRegistrationServiceConfig(cfg, map[string]map[string]string{})
+
+		// then
+		_, err := regServiceCfg.Auth().TrustedIssuers()
+		require.EqualError(t, err, "unable to parse 'REGISTRATION_SERVICE_AUTH_TRUSTED_ISSUERS': unexpected end of JSON input")
+	})
 }
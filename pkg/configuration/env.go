@@ -0,0 +1,172 @@
+package configuration
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// The settings below are not (yet) part of the ToolchainConfig CRD, so they are read from environment variables set on
+// the registration-service deployment. Each setting falls back to a default value if the variable is not set or cannot be parsed.
+// The secrets are never read from environment variables, but from the secret of the registration service (see AuthConfig).
+const (
+	// AuthPublicKeysRefreshIntervalEnvVar is the interval at which the public keys are fetched again in the background.
+	// A zero or negative value disables the background refresh.
+	AuthPublicKeysRefreshIntervalEnvVar = "REGISTRATION_SERVICE_AUTH_PUBLIC_KEYS_REFRESH_INTERVAL"
+	// AuthPublicKeysMinRefetchIntervalEnvVar is the minimum time between two on-demand fetches of the public keys,
+	// triggered when a token is signed with an unknown key ID.
+	AuthPublicKeysMinRefetchIntervalEnvVar = "REGISTRATION_SERVICE_AUTH_PUBLIC_KEYS_MIN_REFETCH_INTERVAL"
+	// AuthTrustedIssuersEnvVar is the JSON-encoded list of trusted token issuers (see TrustedIssuer)
+	AuthTrustedIssuersEnvVar = "REGISTRATION_SERVICE_AUTH_TRUSTED_ISSUERS"
+	// AuthClaimMappingsEnvVar is the JSON-encoded map of the claim mappings, indexed by identity field (see ClaimMapping)
+	AuthClaimMappingsEnvVar = "REGISTRATION_SERVICE_AUTH_CLAIM_MAPPINGS"
+	// AuthRoleMappingsEnvVar is the JSON-encoded list of the mappings which grant the roles to the users from the groups
+	// or roles claims of their tokens (see RoleMapping)
+	AuthRoleMappingsEnvVar = "REGISTRATION_SERVICE_AUTH_ROLE_MAPPINGS"
+	// AuthTokenCacheSizeEnvVar is the maximum number of verified tokens kept in the cache. A zero or negative value disables the cache.
+	AuthTokenCacheSizeEnvVar = "REGISTRATION_SERVICE_AUTH_TOKEN_CACHE_SIZE"
+	// AuthTokenCacheMaxTTLEnvVar is the maximum time a verified token is kept in the cache, even if it expires later.
+	AuthTokenCacheMaxTTLEnvVar = "REGISTRATION_SERVICE_AUTH_TOKEN_CACHE_MAX_TTL"
+	// AuthClientCAFileEnvVar is the path of the PEM-encoded certificates of the CAs which sign the client certificates
+	// accepted to authenticate the requests to the proxy, when it serves HTTPS. An empty value disables the client certificates.
+	AuthClientCAFileEnvVar = "REGISTRATION_SERVICE_AUTH_CLIENT_CA_FILE"
+	// AuthOIDCIssuerURLEnvVar is the URL of the OpenID Provider whose `/.well-known/openid-configuration` document is used
+	// for token validation and web login
+	AuthOIDCIssuerURLEnvVar = "REGISTRATION_SERVICE_AUTH_OIDC_ISSUER_URL"
+	// AuthOIDCDiscoveryRefreshIntervalEnvVar is the interval at which the OpenID configuration is read again in the background.
+	// A zero or negative value disables the background refresh.
+	AuthOIDCDiscoveryRefreshIntervalEnvVar = "REGISTRATION_SERVICE_AUTH_OIDC_DISCOVERY_REFRESH_INTERVAL"
+	// AuthAPITokenMaxPerUserEnvVar is the maximum number of unexpired API tokens of a user. A zero or negative value removes the limit.
+	AuthAPITokenMaxPerUserEnvVar = "REGISTRATION_SERVICE_AUTH_API_TOKEN_MAX_PER_USER" //nolint:gosec
+	// AuthAPITokenDefaultExpirationEnvVar is the lifetime of the API tokens created without an expiration.
+	// A zero or negative value makes these API tokens never expire.
+	AuthAPITokenDefaultExpirationEnvVar = "REGISTRATION_SERVICE_AUTH_API_TOKEN_DEFAULT_EXPIRATION" //nolint:gosec
+	// AuthIntrospectionURLEnvVar is the URL of the OAuth 2.0 token introspection endpoint (RFC 7662) used to check that the
+	// tokens are still active. An empty value disables the introspection.
+	AuthIntrospectionURLEnvVar = "REGISTRATION_SERVICE_AUTH_INTROSPECTION_URL"
+	// AuthIntrospectionClientIDEnvVar is the client ID used to authenticate with the token introspection endpoint
+	AuthIntrospectionClientIDEnvVar = "REGISTRATION_SERVICE_AUTH_INTROSPECTION_CLIENT_ID"
+	// AuthIntrospectionCacheTTLEnvVar is the time the result of the introspection of a token is kept in the cache.
+	// A zero or negative value disables the cache.
+	AuthIntrospectionCacheTTLEnvVar = "REGISTRATION_SERVICE_AUTH_INTROSPECTION_CACHE_TTL"
+	// AuthIntrospectionCacheSizeEnvVar is the maximum number of introspection results kept in the cache.
+	// A zero or negative value disables the cache.
+	AuthIntrospectionCacheSizeEnvVar = "REGISTRATION_SERVICE_AUTH_INTROSPECTION_CACHE_SIZE"
+	// AuthIntrospectionFailOpenEnvVar accepts the tokens when the token introspection endpoint cannot be reached
+	// or returns an error, instead of rejecting them
+	AuthIntrospectionFailOpenEnvVar = "REGISTRATION_SERVICE_AUTH_INTROSPECTION_FAIL_OPEN"
+	// AuthKubeconfigExecEnvVar is the JSON-encoded exec credential plugin of the kubeconfigs generated for the users (see KubeconfigExec)
+	AuthKubeconfigExecEnvVar = "REGISTRATION_SERVICE_AUTH_KUBECONFIG_EXEC"
+
+	// ProxyTLSCertFileEnvVar is the path of the PEM-encoded certificate with which the proxy serves HTTPS.
+	// The proxy serves plain HTTP if the certificate or the key is not set.
+	ProxyTLSCertFileEnvVar = "REGISTRATION_SERVICE_PROXY_TLS_CERT_FILE"
+	// ProxyTLSKeyFileEnvVar is the path of the PEM-encoded private key of the certificate with which the proxy serves HTTPS
+	ProxyTLSKeyFileEnvVar = "REGISTRATION_SERVICE_PROXY_TLS_KEY_FILE"
+	// ProxyAuditFileEnvVar is the path of the file to which the audit events of the proxied requests are appended as JSON lines.
+	// The events are written to the standard output if the path is `-`. An empty value disables the file sink.
+	ProxyAuditFileEnvVar = "REGISTRATION_SERVICE_PROXY_AUDIT_FILE"
+	// ProxyAuditWebhookURLEnvVar is the URL to which the audit events of the proxied requests are posted.
+	// An empty value disables the webhook sink.
+	ProxyAuditWebhookURLEnvVar = "REGISTRATION_SERVICE_PROXY_AUDIT_WEBHOOK_URL"
+	// ProxyAuditQueueSizeEnvVar is the maximum number of audit events waiting to be written to the sinks.
+	// The events are dropped when the queue is full.
+	ProxyAuditQueueSizeEnvVar = "REGISTRATION_SERVICE_PROXY_AUDIT_QUEUE_SIZE"
+	// ProxyAuditRedactionsEnvVar is the JSON-encoded list of the rules which redact the fields of the audit events (see AuditRedaction)
+	ProxyAuditRedactionsEnvVar = "REGISTRATION_SERVICE_PROXY_AUDIT_REDACTIONS"
+	// ProxyRateLimitsEnvVar is the JSON-encoded map of the rate limits of the proxied requests of each user, indexed by verb class
+	// (`read`, `write` or `watch`, see RateLimit). The requests of a verb class without rate limit are not limited.
+	ProxyRateLimitsEnvVar = "REGISTRATION_SERVICE_PROXY_RATE_LIMITS"
+	// ProxyMaxLongRunningRequestsEnvVar is the maximum number of concurrent long-running requests (watch, exec, logs, etc.)
+	// of each user. A zero or negative value disables the limit.
+	ProxyMaxLongRunningRequestsEnvVar = "REGISTRATION_SERVICE_PROXY_MAX_LONG_RUNNING_REQUESTS"
+	// ProxyAccessCacheSizeEnvVar is the maximum number of resolved accesses of the users to the workspaces kept in the cache.
+	// A zero or negative value disables the cache.
+	ProxyAccessCacheSizeEnvVar = "REGISTRATION_SERVICE_PROXY_ACCESS_CACHE_SIZE"
+	// ProxyAccessCacheTTLEnvVar is the maximum time a resolved access is kept in the cache, if it is not invalidated before.
+	ProxyAccessCacheTTLEnvVar = "REGISTRATION_SERVICE_PROXY_ACCESS_CACHE_TTL"
+	// ProxySpaceRolePoliciesEnvVar is the JSON-encoded map of the rules of the requests allowed for each SpaceRole (see PolicyRule),
+	// used for the NSTemplateTiers which do not define their own rules
+	ProxySpaceRolePoliciesEnvVar = "REGISTRATION_SERVICE_PROXY_SPACE_ROLE_POLICIES"
+	// ProxyRequestPoliciesEnvVar is the JSON-encoded list of the CEL policies evaluated on the proxied requests (see RequestPolicy)
+	ProxyRequestPoliciesEnvVar = "REGISTRATION_SERVICE_PROXY_REQUEST_POLICIES"
+	// ProxyRequestPoliciesDryRunEnvVar enables the dry-run mode of all the request policies: the matches are only logged and counted
+	ProxyRequestPoliciesDryRunEnvVar = "REGISTRATION_SERVICE_PROXY_REQUEST_POLICIES_DRY_RUN"
+	// ProxyRequestPoliciesMaxBodySizeEnvVar is the maximum size in bytes of the request bodies decoded for the request policies
+	ProxyRequestPoliciesMaxBodySizeEnvVar = "REGISTRATION_SERVICE_PROXY_REQUEST_POLICIES_MAX_BODY_SIZE"
+	// ProxySubWorkspaceTiersEnvVar is the JSON-encoded list of the NSTemplateTiers of the sub-workspaces which can be created
+	// through the workspaces API. The first tier is the default one. An empty list disables the creation of the sub-workspaces.
+	ProxySubWorkspaceTiersEnvVar = "REGISTRATION_SERVICE_PROXY_SUB_WORKSPACE_TIERS"
+)
+
+func getEnvString(key string, defaultValue string) string {
+	if value, found := os.LookupEnv(key); found && value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	value, found := os.LookupEnv(key)
+	if !found || value == "" {
+		return defaultValue
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		logger.Error(err, fmt.Sprintf("unable to parse '%s', using default value '%t'", key, defaultValue))
+		return defaultValue
+	}
+	return b
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value, found := os.LookupEnv(key)
+	if !found || value == "" {
+		return defaultValue
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		logger.Error(err, fmt.Sprintf("unable to parse '%s', using default value '%d'", key, defaultValue))
+		return defaultValue
+	}
+	return i
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, found := os.LookupEnv(key)
+	if !found || value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		logger.Error(err, fmt.Sprintf("unable to parse '%s', using default value '%s'", key, defaultValue))
+		return defaultValue
+	}
+	return d
+}
+
+// getEnvJSON unmarshals the JSON value of the given environment variable into `v`.
+// Returns false if the variable is not set or if its value cannot be unmarshalled.
+func getEnvJSON(key string, v interface{}) bool {
+	found, err := parseEnvJSON(key, v)
+	if err != nil {
+		logger.Error(err, fmt.Sprintf("unable to parse '%s', using default value", key))
+		return false
+	}
+	return found
+}
+
+// parseEnvJSON unmarshals the JSON value of the given environment variable into `v`.
+// Returns false if the variable is not set, and an error if its value cannot be unmarshalled.
+func parseEnvJSON(key string, v interface{}) (bool, error) {
+	value, found := os.LookupEnv(key)
+	if !found || value == "" {
+		return false, nil
+	}
+	if err := json.Unmarshal([]byte(value), v); err != nil {
+		return false, fmt.Errorf("unable to parse '%s': %w", key, err)
+	}
+	return true, nil
+}
@@ -19,10 +19,18 @@ const (
 	SubKey = "subject"
 	// OriginalSubKey is the context key for the original subject claim
 	OriginalSubKey = "originalSub"
+	// RolesKey is the context key for the roles granted to the user
+	RolesKey = "roles"
+	// IdentityKey is the context key for the identity of the authenticated user
+	IdentityKey = "identity"
 	// JWTClaimsKey is the context key for the claims struct
 	JWTClaimsKey = "jwtClaims"
 	// WorkspaceKey is the context key for the workspace name in echo.Context
 	WorkspaceKey = "workspace"
+	// ClusterAccessKey is the context key for the access to the member cluster to which the request is forwarded in echo.Context
+	ClusterAccessKey = "clusterAccess"
+	// ProxyPluginKey is the context key for the name of the proxy plugin to which the request is forwarded in echo.Context
+	ProxyPluginKey = "proxyPlugin"
 	// RequestReceivedTime is the context key for the starting time of a request made
 	RequestReceivedTime = "requestReceivedTime"
 )
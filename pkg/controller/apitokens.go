@@ -0,0 +1,281 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/application"
+	"github.com/codeready-toolchain/registration-service/pkg/application/service"
+	"github.com/codeready-toolchain/registration-service/pkg/auth"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/toolchain-common/pkg/spacebinding"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/client-go/util/retry"
+)
+
+// APITokenRequest is the body of a request to create an API token
+type APITokenRequest struct {
+	Name      string `json:"name" binding:"required"`
+	Workspace string `json:"workspace" binding:"required"`
+	ReadOnly  bool   `json:"readOnly"`
+	// ExpirationSeconds is the lifetime of the token. The default expiration of the API tokens is used if not set.
+	ExpirationSeconds *int64 `json:"expirationSeconds,omitempty"`
+}
+
+// APITokenResponse is the response to a request to create an API token. It is the only response which contains the token.
+type APITokenResponse struct {
+	auth.APITokenRecord
+	Token string `json:"token"`
+}
+
+// APITokens implements the endpoints to create, list and revoke the workspace-scoped API tokens of the user.
+type APITokens struct {
+	app    application.Application
+	signer *auth.APITokenSigner
+	now    func() time.Time
+}
+
+// NewAPITokens returns a new APITokens instance. The API tokens are disabled if no secret is configured to sign them.
+func NewAPITokens(app application.Application) (*APITokens, error) {
+	c := &APITokens{
+		app: app,
+		now: time.Now,
+	}
+	if secret := configuration.GetRegistrationServiceConfig().Auth().APITokenSecret(); secret != "" {
+		signer, err := auth.NewAPITokenSigner(secret)
+		if err != nil {
+			return nil, err
+		}
+		c.signer = signer
+	}
+	return c, nil
+}
+
+// PostHandler creates an API token for a workspace of the user, and returns it along with its record
+func (c *APITokens) PostHandler(ctx *gin.Context) {
+	userSignup, ok := c.getUserSignup(ctx)
+	if !ok {
+		return
+	}
+	var request APITokenRequest
+	if err := ctx.BindJSON(&request); err != nil {
+		log.Errorf(ctx, err, "request body does not contain required fields name and workspace")
+		crterrors.AbortWithError(ctx, http.StatusBadRequest, err, "error reading request body")
+		return
+	}
+	if request.ExpirationSeconds != nil && *request.ExpirationSeconds <= 0 {
+		crterrors.AbortWithError(ctx, http.StatusBadRequest, errors.New("expirationSeconds must be positive"), "invalid API token request")
+		return
+	}
+	allowed, err := c.hasWorkspaceAccess(userSignup.Status.CompliantUsername, request.Workspace)
+	if err != nil {
+		log.Error(ctx, err, "error checking the access to the workspace")
+		crterrors.AbortWithError(ctx, http.StatusInternalServerError, err, "error checking the access to the workspace")
+		return
+	}
+	if !allowed {
+		crterrors.AbortWithError(ctx, http.StatusForbidden, fmt.Errorf("access to workspace '%s' is forbidden", request.Workspace), "invalid API token request")
+		return
+	}
+
+	record := auth.APITokenRecord{
+		ID:        uuid.NewString(),
+		Name:      request.Name,
+		Workspace: request.Workspace,
+		ReadOnly:  request.ReadOnly,
+		CreatedAt: c.now().UTC().Truncate(time.Second),
+	}
+	authCfg := configuration.GetRegistrationServiceConfig().Auth()
+	if request.ExpirationSeconds != nil {
+		expiresAt := record.CreatedAt.Add(time.Duration(*request.ExpirationSeconds) * time.Second)
+		record.ExpiresAt = &expiresAt
+	} else if expiration := authCfg.APITokenDefaultExpiration(); expiration > 0 {
+		expiresAt := record.CreatedAt.Add(expiration)
+		record.ExpiresAt = &expiresAt
+	}
+	token, err := c.signer.Sign(userSignup.Name, record)
+	if err != nil {
+		log.Error(ctx, err, "error signing API token")
+		crterrors.AbortWithError(ctx, http.StatusInternalServerError, err, "error signing API token")
+		return
+	}
+	added := c.updateRecords(ctx, userSignup, func(records []auth.APITokenRecord) ([]auth.APITokenRecord, error) {
+		// the records of the expired tokens are dropped, so that they do not count in the limit
+		unexpired := make([]auth.APITokenRecord, 0, len(records)+1)
+		for _, r := range records {
+			if r.ExpiresAt == nil || r.ExpiresAt.After(record.CreatedAt) {
+				unexpired = append(unexpired, r)
+			}
+		}
+		if max := authCfg.APITokenMaxPerUser(); max > 0 && len(unexpired) >= max {
+			return nil, crterrors.NewForbiddenError(fmt.Sprintf("the maximum number of API tokens (%d) is reached", max), "revoke an API token before creating another one")
+		}
+		return append(unexpired, record), nil
+	})
+	if !added {
+		return
+	}
+	log.Infof(ctx, "API token '%s' created for workspace '%s'", record.ID, record.Workspace)
+	ctx.JSON(http.StatusOK, APITokenResponse{
+		APITokenRecord: record,
+		Token:          token,
+	})
+}
+
+// ListHandler returns the records of the API tokens of the user. The tokens themselves are not returned.
+func (c *APITokens) ListHandler(ctx *gin.Context) {
+	userSignup, ok := c.getUserSignup(ctx)
+	if !ok {
+		return
+	}
+	records, err := auth.APITokenRecords(userSignup)
+	if err != nil {
+		log.Error(ctx, err, "error reading API token records")
+		crterrors.AbortWithError(ctx, http.StatusInternalServerError, err, "error reading API token records")
+		return
+	}
+	ctx.JSON(http.StatusOK, records)
+}
+
+// DeleteHandler revokes the API token with the given ID
+func (c *APITokens) DeleteHandler(ctx *gin.Context) {
+	id := ctx.Param("id")
+	userSignup, ok := c.getUserSignup(ctx)
+	if !ok {
+		return
+	}
+	revoked := c.updateRecords(ctx, userSignup, func(records []auth.APITokenRecord) ([]auth.APITokenRecord, error) {
+		remaining := make([]auth.APITokenRecord, 0, len(records))
+		for _, r := range records {
+			if r.ID != id {
+				remaining = append(remaining, r)
+			}
+		}
+		if len(remaining) == len(records) {
+			return nil, crterrors.NewNotFoundError(fmt.Errorf("API token '%s' not found", id), "invalid API token request")
+		}
+		return remaining, nil
+	})
+	if !revoked {
+		return
+	}
+	log.Infof(ctx, "API token '%s' revoked", id)
+	ctx.Status(http.StatusNoContent)
+	ctx.Writer.WriteHeaderNow()
+}
+
+// getUserSignup returns the provisioned UserSignup of the user, or responds with an error and returns false
+func (c *APITokens) getUserSignup(ctx *gin.Context) (*toolchainv1alpha1.UserSignup, bool) {
+	if c.signer == nil {
+		crterrors.AbortWithError(ctx, http.StatusNotFound, errors.New("API tokens are not enabled"), "")
+		return nil, false
+	}
+	userSignup, err := c.fetchUserSignup(ctx)
+	if apierrors.IsNotFound(err) || (err == nil && userSignup == nil) {
+		log.Infof(ctx, "UserSignup resource for userID: %s, username: %s resource not found", ctx.GetString(context.SubKey), ctx.GetString(context.UsernameKey))
+		crterrors.AbortWithError(ctx, http.StatusNotFound, errors.New("user not found"), "")
+		return nil, false
+	}
+	if err != nil {
+		log.Error(ctx, err, "error getting UserSignup resource")
+		crterrors.AbortWithError(ctx, http.StatusInternalServerError, err, "error getting UserSignup resource")
+		return nil, false
+	}
+	if userSignup.Status.CompliantUsername == "" {
+		crterrors.AbortWithError(ctx, http.StatusForbidden, errors.New("user is not provisioned (yet)"), "")
+		return nil, false
+	}
+	return userSignup, true
+}
+
+// fetchUserSignup reads the UserSignup of the user from the cluster
+func (c *APITokens) fetchUserSignup(ctx *gin.Context) (*toolchainv1alpha1.UserSignup, error) {
+	return c.app.SignupService().GetUserSignupFromIdentifier(ctx.GetString(context.SubKey), ctx.GetString(context.UsernameKey))
+}
+
+// updateRecords applies the given change to the records of the API tokens of the user and stores them in the UserSignup.
+// When the update of the UserSignup conflicts with another update, the change is applied again to the UserSignup read
+// from the cluster. It responds with an error and returns false if the records cannot be updated.
+func (c *APITokens) updateRecords(ctx *gin.Context, userSignup *toolchainv1alpha1.UserSignup, change func([]auth.APITokenRecord) ([]auth.APITokenRecord, error)) bool {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		records, err := auth.APITokenRecords(userSignup)
+		if err != nil {
+			return err
+		}
+		if records, err = change(records); err != nil {
+			return err
+		}
+		if err := auth.SetAPITokenRecords(userSignup, records); err != nil {
+			return err
+		}
+		_, err = c.app.SignupService().UpdateUserSignup(userSignup)
+		if apierrors.IsConflict(err) {
+			fresh, getErr := c.fetchUserSignup(ctx)
+			if getErr != nil {
+				return getErr
+			}
+			userSignup = fresh
+		}
+		return err
+	})
+	e := &crterrors.Error{}
+	switch {
+	case err == nil:
+		return true
+	case errors.As(err, &e):
+		crterrors.AbortWithError(ctx, e.Code, errors.New(e.Message), e.Details)
+	case apierrors.IsConflict(err):
+		log.Error(ctx, err, "error updating UserSignup resource")
+		crterrors.AbortWithError(ctx, http.StatusConflict, err, "error updating UserSignup resource")
+	default:
+		log.Error(ctx, err, "error updating API token records")
+		crterrors.AbortWithError(ctx, http.StatusInternalServerError, err, "error updating API token records")
+	}
+	return false
+}
+
+// hasWorkspaceAccess returns true if the user has a SpaceBinding for the given workspace or for one of its parent workspaces
+func (c *APITokens) hasWorkspaceAccess(compliantUsername, workspace string) (bool, error) {
+	informer := c.app.InformerService()
+	space, err := informer.GetSpace(workspace)
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if lister, ok := informer.(service.EffectiveSpaceBindingLister); ok {
+		spaceBindings, err := lister.ListEffectiveSpaceBindings(space, compliantUsername)
+		if err != nil {
+			return false, err
+		}
+		return len(spaceBindings) > 0, nil
+	}
+	listSpaceBindings := func(spaceName string) ([]toolchainv1alpha1.SpaceBinding, error) {
+		spaceSelector, err := labels.NewRequirement(toolchainv1alpha1.SpaceBindingSpaceLabelKey, selection.Equals, []string{spaceName})
+		if err != nil {
+			return nil, err
+		}
+		murSelector, err := labels.NewRequirement(toolchainv1alpha1.SpaceBindingMasterUserRecordLabelKey, selection.Equals, []string{compliantUsername})
+		if err != nil {
+			return nil, err
+		}
+		return informer.ListSpaceBindings(*spaceSelector, *murSelector)
+	}
+	spaceBindings, err := spacebinding.NewLister(listSpaceBindings, informer.GetSpace).ListForSpace(space, []toolchainv1alpha1.SpaceBinding{})
+	if err != nil {
+		return false, err
+	}
+	return len(spaceBindings) > 0, nil
+}
@@ -0,0 +1,307 @@
+package controller_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	crtapi "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/auth"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+	"github.com/codeready-toolchain/registration-service/pkg/controller"
+	"github.com/codeready-toolchain/registration-service/test"
+	"github.com/codeready-toolchain/registration-service/test/fake"
+	testconfig "github.com/codeready-toolchain/toolchain-common/pkg/test/config"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const apiTokenSecret = "0123456789abcdef0123456789abcdef"
+
+type TestAPITokensSuite struct {
+	test.UnitTestSuite
+}
+
+func TestRunAPITokensSuite(t *testing.T) {
+	suite.Run(t, &TestAPITokensSuite{test.UnitTestSuite{}})
+}
+
+func (s *TestAPITokensSuite) TestAPITokensHandlers() {
+	s.SetConfig(testconfig.RegistrationService().
+		Environment(configuration.UnitTestsEnvironment).
+		Verification().Secret().Ref("registration-service-secrets"))
+	s.setAPITokenSecret(apiTokenSecret)
+	fakeClient := fake.InitClient(s.T(),
+		fake.NewSpace("john", "member-1", "john"),
+		fake.NewSpaceBinding("john-john", "john", "john", "admin"),
+		fake.NewSpace("jane", "member-1", "jane"),
+		fake.NewSpaceBinding("jane-jane", "jane", "jane", "admin"),
+	)
+	s.Application.MockInformerService(fake.GetInformerService(fakeClient)())
+
+	userSignup := &crtapi.UserSignup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "john",
+		},
+		Status: crtapi.UserSignupStatus{
+			CompliantUsername: "john",
+		},
+	}
+	var updated *crtapi.UserSignup
+	svc := &FakeSignupService{
+		MockGetUserSignupFromIdentifier: func(userID, username string) (*crtapi.UserSignup, error) {
+			if username != "john" {
+				return nil, apierrors.NewNotFound(schema.GroupResource{Resource: "usersignups"}, username)
+			}
+			return userSignup.DeepCopy(), nil
+		},
+		MockUpdateUserSignup: func(userSignup *crtapi.UserSignup) (*crtapi.UserSignup, error) {
+			updated = userSignup
+			return userSignup, nil
+		},
+	}
+	s.Application.MockSignupService(svc)
+	ctrl, err := controller.NewAPITokens(s.Application)
+	require.NoError(s.T(), err)
+	signer, err := auth.NewAPITokenSigner(apiTokenSecret)
+	require.NoError(s.T(), err)
+
+	s.Run("create token", func() {
+		// when
+		rr := s.handle(ctrl.PostHandler, http.MethodPost, "john", `{"name":"ci","workspace":"john","readOnly":true,"expirationSeconds":3600}`)
+
+		// then
+		require.Equal(s.T(), http.StatusOK, rr.Code, rr.Body.String())
+		response := controller.APITokenResponse{}
+		require.NoError(s.T(), json.Unmarshal(rr.Body.Bytes(), &response))
+		assert.NotEmpty(s.T(), response.ID)
+		assert.Equal(s.T(), "ci", response.Name)
+		assert.Equal(s.T(), "john", response.Workspace)
+		assert.True(s.T(), response.ReadOnly)
+		require.NotNil(s.T(), response.ExpiresAt)
+		claims, err := signer.Parse(response.Token)
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), "john", claims.Subject)
+		assert.Equal(s.T(), response.ID, claims.ID)
+		assert.Equal(s.T(), "john", claims.Workspace)
+		assert.True(s.T(), claims.ReadOnly)
+		// the record is stored in the UserSignup
+		records, err := auth.APITokenRecords(updated)
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), []auth.APITokenRecord{response.APITokenRecord}, records)
+		userSignup = updated
+	})
+
+	s.Run("list tokens", func() {
+		// when
+		rr := s.handle(ctrl.ListHandler, http.MethodGet, "john", "")
+
+		// then
+		require.Equal(s.T(), http.StatusOK, rr.Code)
+		records := []auth.APITokenRecord{}
+		require.NoError(s.T(), json.Unmarshal(rr.Body.Bytes(), &records))
+		require.Len(s.T(), records, 1)
+		assert.Equal(s.T(), "ci", records[0].Name)
+		assert.NotContains(s.T(), rr.Body.String(), "token")
+	})
+
+	s.Run("revoke token", func() {
+		// given
+		records, err := auth.APITokenRecords(userSignup)
+		require.NoError(s.T(), err)
+
+		// when
+		rr := s.handle(ctrl.DeleteHandler, http.MethodDelete, "john", "", gin.Param{Key: "id", Value: records[0].ID})
+
+		// then
+		require.Equal(s.T(), http.StatusNoContent, rr.Code)
+		assert.NotContains(s.T(), updated.Annotations, auth.APITokensAnnotationKey)
+		userSignup = updated
+	})
+
+	s.Run("create token with the default expiration", func() {
+		// when
+		rr := s.handle(ctrl.PostHandler, http.MethodPost, "john", `{"name":"ci","workspace":"john"}`)
+
+		// then
+		require.Equal(s.T(), http.StatusOK, rr.Code, rr.Body.String())
+		response := controller.APITokenResponse{}
+		require.NoError(s.T(), json.Unmarshal(rr.Body.Bytes(), &response))
+		require.NotNil(s.T(), response.ExpiresAt)
+		assert.Equal(s.T(), response.CreatedAt.Add(90*24*time.Hour), *response.ExpiresAt)
+		userSignup = updated
+	})
+
+	s.Run("maximum number of tokens reached", func() {
+		// given
+		s.T().Setenv(configuration.AuthAPITokenMaxPerUserEnvVar, "1")
+		updated = nil
+
+		// when
+		rr := s.handle(ctrl.PostHandler, http.MethodPost, "john", `{"name":"other","workspace":"john"}`)
+
+		// then
+		assert.Equal(s.T(), http.StatusForbidden, rr.Code)
+		assert.Contains(s.T(), rr.Body.String(), "the maximum number of API tokens (1) is reached")
+		assert.Nil(s.T(), updated)
+	})
+
+	s.Run("update conflict retried", func() {
+		// given
+		conflicts := 0
+		svc.MockUpdateUserSignup = func(userSignup *crtapi.UserSignup) (*crtapi.UserSignup, error) {
+			if conflicts == 0 {
+				conflicts++
+				return nil, apierrors.NewConflict(schema.GroupResource{Resource: "usersignups"}, userSignup.Name, errors.New("object has been modified"))
+			}
+			updated = userSignup
+			return userSignup, nil
+		}
+
+		// when
+		rr := s.handle(ctrl.PostHandler, http.MethodPost, "john", `{"name":"other","workspace":"john"}`)
+
+		// then
+		require.Equal(s.T(), http.StatusOK, rr.Code, rr.Body.String())
+		assert.Equal(s.T(), 1, conflicts)
+		records, err := auth.APITokenRecords(updated)
+		require.NoError(s.T(), err)
+		require.Len(s.T(), records, 2)
+		assert.Equal(s.T(), "ci", records[0].Name)
+		assert.Equal(s.T(), "other", records[1].Name)
+		userSignup = updated
+	})
+
+	s.Run("failures", func() {
+		tests := map[string]struct {
+			handler      gin.HandlerFunc
+			method       string
+			username     string
+			body         string
+			params       []gin.Param
+			expectedCode int
+			expectedBody string
+		}{
+			"workspace of another user": {
+				handler:      ctrl.PostHandler,
+				method:       http.MethodPost,
+				username:     "john",
+				body:         `{"name":"ci","workspace":"jane"}`,
+				expectedCode: http.StatusForbidden,
+				expectedBody: "access to workspace 'jane' is forbidden",
+			},
+			"unknown workspace": {
+				handler:      ctrl.PostHandler,
+				method:       http.MethodPost,
+				username:     "john",
+				body:         `{"name":"ci","workspace":"unknown"}`,
+				expectedCode: http.StatusForbidden,
+				expectedBody: "access to workspace 'unknown' is forbidden",
+			},
+			"missing name": {
+				handler:      ctrl.PostHandler,
+				method:       http.MethodPost,
+				username:     "john",
+				body:         `{"workspace":"john"}`,
+				expectedCode: http.StatusBadRequest,
+				expectedBody: "error reading request body",
+			},
+			"negative expiration": {
+				handler:      ctrl.PostHandler,
+				method:       http.MethodPost,
+				username:     "john",
+				body:         `{"name":"ci","workspace":"john","expirationSeconds":-1}`,
+				expectedCode: http.StatusBadRequest,
+				expectedBody: "expirationSeconds must be positive",
+			},
+			"revoke unknown token": {
+				handler:      ctrl.DeleteHandler,
+				method:       http.MethodDelete,
+				username:     "john",
+				params:       []gin.Param{{Key: "id", Value: "unknown"}},
+				expectedCode: http.StatusNotFound,
+				expectedBody: "API token 'unknown' not found",
+			},
+			"unknown user": {
+				handler:      ctrl.ListHandler,
+				method:       http.MethodGet,
+				username:     "jane",
+				expectedCode: http.StatusNotFound,
+				expectedBody: "user not found",
+			},
+		}
+		for name, tc := range tests {
+			s.Run(name, func() {
+				// when
+				rr := s.handle(tc.handler, tc.method, tc.username, tc.body, tc.params...)
+
+				// then
+				assert.Equal(s.T(), tc.expectedCode, rr.Code)
+				assert.Contains(s.T(), rr.Body.String(), tc.expectedBody)
+			})
+		}
+	})
+
+	s.Run("API tokens not enabled", func() {
+		// given
+		s.setAPITokenSecret("")
+		ctrl, err := controller.NewAPITokens(s.Application)
+		require.NoError(s.T(), err)
+
+		// when
+		rr := s.handle(ctrl.ListHandler, http.MethodGet, "john", "")
+
+		// then
+		assert.Equal(s.T(), http.StatusNotFound, rr.Code)
+		assert.Contains(s.T(), rr.Body.String(), "API tokens are not enabled")
+	})
+
+	s.Run("secret too short", func() {
+		// given
+		s.setAPITokenSecret("secret")
+
+		// when
+		_, err := controller.NewAPITokens(s.Application)
+
+		// then
+		require.EqualError(s.T(), err, "the secret of the API tokens must contain at least 32 characters")
+	})
+}
+
+// setAPITokenSecret sets the secret of the API tokens in the secret of the registration service
+func (s *TestAPITokensSuite) setAPITokenSecret(value string) {
+	s.SetSecret(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "registration-service-secrets",
+			Namespace: configuration.Namespace(),
+		},
+		Data: map[string][]byte{
+			configuration.AuthAPITokenSecretKey: []byte(value),
+		},
+	})
+}
+
+func (s *TestAPITokensSuite) handle(handler gin.HandlerFunc, method, username, body string, params ...gin.Param) *httptest.ResponseRecorder {
+	req, err := http.NewRequest(method, "/api/v1/tokens", bytes.NewBufferString(body))
+	require.NoError(s.T(), err)
+	rr := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(rr)
+	ctx.Request = req
+	ctx.Params = params
+	ctx.Set(context.SubKey, username)
+	ctx.Set(context.UsernameKey, username)
+	handler(ctx)
+	return rr
+}
@@ -0,0 +1,179 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/application"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/handlers"
+
+	"github.com/gin-gonic/gin"
+	clientcmdv1 "k8s.io/client-go/tools/clientcmd/api/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// The formats of the kubeconfig, set with the `format` query parameter
+const (
+	KubeconfigFormatYAML = "yaml"
+	KubeconfigFormatJSON = "json"
+)
+
+// The credentials of the user in the kubeconfig, set with the `credentials` query parameter
+const (
+	// KubeconfigCredentialsToken embeds the bearer token of the request in the kubeconfig
+	KubeconfigCredentialsToken = "token"
+	// KubeconfigCredentialsExec configures an exec credential plugin which gets the tokens from the SSO
+	KubeconfigCredentialsExec = "exec"
+)
+
+// Kubeconfig implements the endpoint which returns a kubeconfig to access all the workspaces of the user through the proxy
+type Kubeconfig struct {
+	app         application.Application
+	spaceLister *handlers.SpaceLister
+}
+
+// NewKubeconfig returns a new Kubeconfig instance.
+func NewKubeconfig(app application.Application) *Kubeconfig {
+	return &Kubeconfig{
+		app:         app,
+		spaceLister: handlers.NewSpaceLister(app, nil),
+	}
+}
+
+// GetHandler returns a kubeconfig with a context per workspace of the user, in YAML (default) or JSON. The user's credentials
+// are either the bearer token of the request (default) or an exec credential plugin.
+func (k *Kubeconfig) GetHandler(ctx *gin.Context) {
+	format := ctx.DefaultQuery("format", KubeconfigFormatYAML)
+	if format != KubeconfigFormatYAML && format != KubeconfigFormatJSON {
+		crterrors.AbortWithError(ctx, http.StatusBadRequest, fmt.Errorf("unsupported format '%s'", format), "the format must be 'yaml' or 'json'")
+		return
+	}
+	authInfo, err := kubeconfigAuthInfo(ctx)
+	if err != nil {
+		crterrors.AbortWithError(ctx, http.StatusBadRequest, err, "invalid kubeconfig request")
+		return
+	}
+
+	userID := ctx.GetString(context.SubKey)
+	username := ctx.GetString(context.UsernameKey)
+	userSignup, err := k.app.SignupService().GetSignupFromInformer(nil, userID, username, false)
+	if err != nil {
+		log.Error(ctx, err, "error getting UserSignup resource")
+		crterrors.AbortWithError(ctx, http.StatusInternalServerError, err, "error getting UserSignup resource")
+		return
+	}
+	if userSignup == nil {
+		log.Infof(ctx, "UserSignup resource for userID: %s, username: %s resource not found", userID, username)
+		crterrors.AbortWithError(ctx, http.StatusNotFound, errors.New("user not found"), "")
+		return
+	}
+	if userSignup.CompliantUsername == "" {
+		crterrors.AbortWithError(ctx, http.StatusForbidden, errors.New("user is not provisioned (yet)"), "")
+		return
+	}
+	if userSignup.ProxyURL == "" {
+		crterrors.AbortWithError(ctx, http.StatusServiceUnavailable, errors.New("the proxy URL is not available (yet)"), "please try again later")
+		return
+	}
+	workspaces, err := k.spaceLister.ListWorkspaces(userSignup)
+	if err != nil {
+		log.Error(ctx, err, "error listing the workspaces")
+		crterrors.AbortWithError(ctx, http.StatusInternalServerError, err, "error listing the workspaces")
+		return
+	}
+
+	kubeconfig := newKubeconfig(userSignup.ProxyURL, userSignup.CompliantUsername, workspaces, authInfo)
+	if format == KubeconfigFormatJSON {
+		ctx.JSON(http.StatusOK, kubeconfig)
+		return
+	}
+	data, err := yaml.Marshal(kubeconfig)
+	if err != nil {
+		log.Error(ctx, err, "error encoding the kubeconfig")
+		crterrors.AbortWithError(ctx, http.StatusInternalServerError, err, "error encoding the kubeconfig")
+		return
+	}
+	ctx.Data(http.StatusOK, "application/yaml", data)
+}
+
+// kubeconfigAuthInfo returns the credentials of the user in the kubeconfig, as requested with the `credentials` query parameter
+func kubeconfigAuthInfo(ctx *gin.Context) (clientcmdv1.AuthInfo, error) {
+	switch credentials := ctx.DefaultQuery("credentials", KubeconfigCredentialsToken); credentials {
+	case KubeconfigCredentialsToken:
+		fields := strings.Fields(ctx.GetHeader("Authorization"))
+		if len(fields) != 2 || fields[0] != "Bearer" {
+			return clientcmdv1.AuthInfo{}, errors.New("no bearer token found in the request, use the 'exec' credentials instead")
+		}
+		return clientcmdv1.AuthInfo{Token: fields[1]}, nil
+	case KubeconfigCredentialsExec:
+		exec := configuration.GetRegistrationServiceConfig().Auth().KubeconfigExec()
+		return clientcmdv1.AuthInfo{
+			Exec: &clientcmdv1.ExecConfig{
+				APIVersion:      "client.authentication.k8s.io/v1",
+				Command:         exec.Command,
+				Args:            exec.Args,
+				InstallHint:     exec.InstallHint,
+				InteractiveMode: clientcmdv1.IfAvailableExecInteractiveMode,
+			},
+		}, nil
+	default:
+		return clientcmdv1.AuthInfo{}, fmt.Errorf("unsupported credentials '%s', the credentials must be 'token' or 'exec'", credentials)
+	}
+}
+
+// newKubeconfig returns a kubeconfig with a cluster and a context per workspace, all using the given credentials.
+// The current context is the one of the home workspace of the user.
+func newKubeconfig(proxyURL, compliantUsername string, workspaces []toolchainv1alpha1.Workspace, authInfo clientcmdv1.AuthInfo) *clientcmdv1.Config {
+	kubeconfig := &clientcmdv1.Config{
+		Kind:       "Config",
+		APIVersion: "v1",
+		AuthInfos: []clientcmdv1.NamedAuthInfo{
+			{
+				Name:     compliantUsername,
+				AuthInfo: authInfo,
+			},
+		},
+		Clusters: []clientcmdv1.NamedCluster{},
+		Contexts: []clientcmdv1.NamedContext{},
+	}
+	for _, workspace := range workspaces {
+		kubeconfig.Clusters = append(kubeconfig.Clusters, clientcmdv1.NamedCluster{
+			Name: workspace.Name,
+			Cluster: clientcmdv1.Cluster{
+				Server: fmt.Sprintf("%s/workspaces/%s", strings.TrimSuffix(proxyURL, "/"), workspace.Name),
+			},
+		})
+		kubeconfig.Contexts = append(kubeconfig.Contexts, clientcmdv1.NamedContext{
+			Name: workspace.Name,
+			Context: clientcmdv1.Context{
+				Cluster:   workspace.Name,
+				AuthInfo:  compliantUsername,
+				Namespace: defaultNamespace(workspace),
+			},
+		})
+		if workspace.Status.Type == "home" || kubeconfig.CurrentContext == "" {
+			kubeconfig.CurrentContext = workspace.Name
+		}
+	}
+	return kubeconfig
+}
+
+// defaultNamespace returns the default namespace of the workspace, or its first namespace if none is the default one
+func defaultNamespace(workspace toolchainv1alpha1.Workspace) string {
+	for _, ns := range workspace.Status.Namespaces {
+		if ns.Type == "default" {
+			return ns.Name
+		}
+	}
+	if len(workspace.Status.Namespaces) > 0 {
+		return workspace.Status.Namespaces[0].Name
+	}
+	return ""
+}
@@ -0,0 +1,192 @@
+package controller_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+	"github.com/codeready-toolchain/registration-service/pkg/controller"
+	"github.com/codeready-toolchain/registration-service/pkg/signup"
+	"github.com/codeready-toolchain/registration-service/test"
+	"github.com/codeready-toolchain/registration-service/test/fake"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+type TestKubeconfigSuite struct {
+	test.UnitTestSuite
+}
+
+func TestRunKubeconfigSuite(t *testing.T) {
+	suite.Run(t, &TestKubeconfigSuite{test.UnitTestSuite{}})
+}
+
+func (s *TestKubeconfigSuite) TestKubeconfigHandler() {
+	// given
+	fakeClient := fake.InitClient(s.T(),
+		fake.NewSpace("john", "member-1", "john"),
+		fake.NewSpaceBinding("john-john", "john", "john", "admin"),
+		fake.NewSpace("team", "member-2", "jane"),
+		fake.NewSpaceBinding("team-john", "john", "team", "viewer"),
+		fake.NewSpace("jane", "member-1", "jane"),
+		fake.NewSpaceBinding("jane-jane", "jane", "jane", "admin"),
+	)
+	s.Application.MockInformerService(fake.GetInformerService(fakeClient)())
+	signups := map[string]*signup.Signup{
+		"john": {
+			Name:              "john",
+			CompliantUsername: "john",
+			ProxyURL:          "https://api-toolchain-host-operator.apps.host.test.org",
+			Status:            signup.Status{Ready: true},
+		},
+		"jack": {
+			Name:   "jack",
+			Status: signup.Status{Reason: "PendingApproval"},
+		},
+		"joe": {
+			Name:              "joe",
+			CompliantUsername: "joe",
+			Status:            signup.Status{Ready: true},
+		},
+	}
+	s.Application.MockSignupService(&FakeSignupService{
+		MockGetSignupFromInformer: func(_ *gin.Context, _, username string, _ bool) (*signup.Signup, error) {
+			return signups[username], nil
+		},
+	})
+	ctrl := controller.NewKubeconfig(s.Application)
+
+	s.Run("YAML with the token", func() {
+		// when
+		rr := s.handle(ctrl.GetHandler, "john", "")
+
+		// then
+		require.Equal(s.T(), http.StatusOK, rr.Code, rr.Body.String())
+		assert.Equal(s.T(), "application/yaml", rr.Header().Get("Content-Type"))
+		kubeconfig, err := clientcmd.Load(rr.Body.Bytes())
+		require.NoError(s.T(), err)
+		assertKubeconfig(s.T(), kubeconfig)
+		assert.Equal(s.T(), "token-of-john", kubeconfig.AuthInfos["john"].Token)
+		assert.Nil(s.T(), kubeconfig.AuthInfos["john"].Exec)
+	})
+
+	s.Run("JSON with the exec credential plugin", func() {
+		// when
+		rr := s.handle(ctrl.GetHandler, "john", "format=json&credentials=exec")
+
+		// then
+		require.Equal(s.T(), http.StatusOK, rr.Code, rr.Body.String())
+		assert.Equal(s.T(), "application/json; charset=utf-8", rr.Header().Get("Content-Type"))
+		require.True(s.T(), json.Valid(rr.Body.Bytes()))
+		kubeconfig, err := clientcmd.Load(rr.Body.Bytes())
+		require.NoError(s.T(), err)
+		assertKubeconfig(s.T(), kubeconfig)
+		assert.Empty(s.T(), kubeconfig.AuthInfos["john"].Token)
+		exec := kubeconfig.AuthInfos["john"].Exec
+		require.NotNil(s.T(), exec)
+		assert.Equal(s.T(), "client.authentication.k8s.io/v1", exec.APIVersion)
+		assert.Equal(s.T(), "kubectl", exec.Command)
+		assert.Equal(s.T(), []string{"oidc-login", "get-token", "--oidc-issuer-url=https://sso.devsandbox.dev/auth/realms/sandbox-dev", "--oidc-client-id=sandbox-public"}, exec.Args)
+		assert.Equal(s.T(), clientcmdapi.IfAvailableExecInteractiveMode, exec.InteractiveMode)
+	})
+
+	s.Run("failures", func() {
+		tests := map[string]struct {
+			username     string
+			query        string
+			withoutToken bool
+			expectedCode int
+			expectedBody string
+		}{
+			"unsupported format": {
+				username:     "john",
+				query:        "format=xml",
+				expectedCode: http.StatusBadRequest,
+				expectedBody: "unsupported format 'xml'",
+			},
+			"unsupported credentials": {
+				username:     "john",
+				query:        "credentials=password",
+				expectedCode: http.StatusBadRequest,
+				expectedBody: "unsupported credentials 'password'",
+			},
+			"no bearer token": {
+				username:     "john",
+				withoutToken: true,
+				expectedCode: http.StatusBadRequest,
+				expectedBody: "no bearer token found in the request",
+			},
+			"unknown user": {
+				username:     "jane",
+				expectedCode: http.StatusNotFound,
+				expectedBody: "user not found",
+			},
+			"user not provisioned": {
+				username:     "jack",
+				expectedCode: http.StatusForbidden,
+				expectedBody: "user is not provisioned (yet)",
+			},
+			"proxy URL not available": {
+				username:     "joe",
+				expectedCode: http.StatusServiceUnavailable,
+				expectedBody: "the proxy URL is not available (yet)",
+			},
+		}
+		for name, tc := range tests {
+			s.Run(name, func() {
+				// given
+				handler := ctrl.GetHandler
+				if tc.withoutToken {
+					handler = func(ctx *gin.Context) {
+						ctx.Request.Header.Del("Authorization")
+						ctrl.GetHandler(ctx)
+					}
+				}
+
+				// when
+				rr := s.handle(handler, tc.username, tc.query)
+
+				// then
+				assert.Equal(s.T(), tc.expectedCode, rr.Code)
+				assert.Contains(s.T(), rr.Body.String(), tc.expectedBody)
+			})
+		}
+	})
+}
+
+// assertKubeconfig verifies that the kubeconfig has a context per workspace of john, and that the current context is
+// the one of his home workspace
+func assertKubeconfig(t *testing.T, kubeconfig *clientcmdapi.Config) {
+	assert.Equal(t, "john", kubeconfig.CurrentContext)
+	require.Len(t, kubeconfig.Contexts, 2)
+	assert.Equal(t, "john", kubeconfig.Contexts["john"].Cluster)
+	assert.Equal(t, "john", kubeconfig.Contexts["john"].AuthInfo)
+	assert.Equal(t, "john-dev", kubeconfig.Contexts["john"].Namespace)
+	assert.Equal(t, "team", kubeconfig.Contexts["team"].Cluster)
+	assert.Equal(t, "john", kubeconfig.Contexts["team"].AuthInfo)
+	assert.Equal(t, "team-dev", kubeconfig.Contexts["team"].Namespace)
+	require.Len(t, kubeconfig.Clusters, 2)
+	assert.Equal(t, "https://api-toolchain-host-operator.apps.host.test.org/workspaces/john", kubeconfig.Clusters["john"].Server)
+	assert.Equal(t, "https://api-toolchain-host-operator.apps.host.test.org/workspaces/team", kubeconfig.Clusters["team"].Server)
+	require.Len(t, kubeconfig.AuthInfos, 1)
+}
+
+func (s *TestKubeconfigSuite) handle(handler gin.HandlerFunc, username, query string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest(http.MethodGet, "/api/v1/kubeconfig?"+query, nil)
+	require.NoError(s.T(), err)
+	req.Header.Set("Authorization", "Bearer token-of-"+username)
+	rr := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(rr)
+	ctx.Request = req
+	ctx.Set(context.SubKey, username)
+	ctx.Set(context.UsernameKey, username)
+	handler(ctx)
+	return rr
+}
@@ -84,3 +84,12 @@ func NewBadRequest(message, details string) *Error {
 		Details: details,
 	}
 }
+
+func NewServiceUnavailableError(message, details string) *Error {
+	return &Error{
+		Status:  http.StatusText(http.StatusServiceUnavailable),
+		Code:    http.StatusServiceUnavailable,
+		Message: message,
+		Details: details,
+	}
+}
@@ -83,5 +83,12 @@ func (s *TestErrorsSuite) TestErrors() {
 		require.Equal(s.T(), "bar", err.Details)
 		require.Equal(s.T(), http.StatusBadRequest, err.Code)
 		require.Equal(s.T(), http.StatusText(http.StatusBadRequest), err.Status)
+
+		err = errs.NewServiceUnavailableError("foo", "bar")
+		require.Equal(s.T(), "foo", err.Message)
+		require.Equal(s.T(), "bar", err.Details)
+		require.Equal(s.T(), http.StatusServiceUnavailable, err.Code)
+		require.Equal(s.T(), http.StatusText(http.StatusServiceUnavailable), err.Status)
+		require.Equal(s.T(), "foo: bar", err.Error())
 	})
 }
@@ -2,12 +2,16 @@ package informers
 
 import (
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/codeready-toolchain/registration-service/pkg/configuration"
 	"github.com/codeready-toolchain/registration-service/pkg/kubeclient/resources"
 	"github.com/codeready-toolchain/registration-service/pkg/log"
 
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
 
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/dynamic/dynamicinformer"
@@ -23,6 +27,55 @@ type Informer struct {
 	UserSignup        cache.GenericLister
 	ProxyPluginConfig cache.GenericLister
 	NSTemplateTier    cache.GenericLister
+
+	// SpaceBindingIndex indexes the content of the Space and SpaceBinding informers
+	SpaceBindingIndex *SpaceBindingIndex
+
+	// accessEvents dispatches the events of the resources which grant the users access to the workspaces
+	accessEvents *eventDispatcher
+}
+
+// AddAccessEventHandler adds a handler of the events of the resources which grant the users access to the workspaces:
+// the MasterUserRecords, Spaces, SpaceBindings and UserSignups. The handler does not receive the events of the initial
+// listing of the resources, only the events of their subsequent changes.
+func (i *Informer) AddAccessEventHandler(handler cache.ResourceEventHandler) {
+	i.accessEvents.add(handler)
+}
+
+// eventDispatcher dispatches the events of the informers to the handlers added once the informers are started
+type eventDispatcher struct {
+	lock     sync.RWMutex
+	handlers []cache.ResourceEventHandler
+}
+
+func (d *eventDispatcher) add(handler cache.ResourceEventHandler) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.handlers = append(d.handlers, handler)
+}
+
+func (d *eventDispatcher) OnAdd(obj interface{}) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	for _, h := range d.handlers {
+		h.OnAdd(obj)
+	}
+}
+
+func (d *eventDispatcher) OnUpdate(oldObj, newObj interface{}) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	for _, h := range d.handlers {
+		h.OnUpdate(oldObj, newObj)
+	}
+}
+
+func (d *eventDispatcher) OnDelete(obj interface{}) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	for _, h := range d.handlers {
+		h.OnDelete(obj)
+	}
 }
 
 func StartInformer(cfg *rest.Config) (*Informer, chan struct{}, error) {
@@ -70,6 +123,18 @@ func StartInformer(cfg *rest.Config) (*Informer, chan struct{}, error) {
 	informer.NSTemplateTier = genericNSTemplateTierInformer.Lister()
 	nsTemplateTierInformer := genericNSTemplateTierInformer.Informer()
 
+	// the handlers are registered before the informers are started, but the handlers of the access events
+	// are only added after the caches are synced, so that they do not receive the events of the initial listing
+	informer.accessEvents = &eventDispatcher{}
+	for _, inf := range []cache.SharedIndexInformer{masterUserRecordInformer, spaceInformer, spaceBindingInformer, userSignupInformer} {
+		inf.AddEventHandler(informer.accessEvents)
+	}
+
+	// unlike the handlers of the access events, the index is also fed with the events of the initial listing of the resources
+	informer.SpaceBindingIndex = NewSpaceBindingIndex()
+	spaceInformer.AddEventHandler(informer.SpaceBindingIndex)
+	spaceBindingInformer.AddEventHandler(informer.SpaceBindingIndex)
+
 	stopper := make(chan struct{})
 
 	log.Info(nil, "Starting proxy cache informers")
@@ -90,5 +155,26 @@ func StartInformer(cfg *rest.Config) (*Informer, chan struct{}, error) {
 	}
 	log.Info(nil, "Informer caches synced")
 
+	// the index lags behind the synced caches until its handler has processed the events of the initial listing,
+	// so it is only used once it contains the content of the caches
+	go func() {
+		err := wait.PollImmediateUntil(100*time.Millisecond, func() (bool, error) {
+			spaces, err := informer.Space.List(labels.Everything())
+			if err != nil {
+				return false, err
+			}
+			spaceBindings, err := informer.SpaceBinding.List(labels.Everything())
+			if err != nil {
+				return false, err
+			}
+			return informer.SpaceBindingIndex.SyncWith(spaces, spaceBindings), nil
+		}, stopper)
+		if err != nil {
+			log.Error(nil, err, "SpaceBinding index not synced")
+			return
+		}
+		log.Info(nil, "SpaceBinding index synced")
+	}()
+
 	return informer, stopper, nil
 }
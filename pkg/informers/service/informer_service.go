@@ -9,10 +9,12 @@ import (
 	"github.com/codeready-toolchain/registration-service/pkg/informers"
 	"github.com/codeready-toolchain/registration-service/pkg/kubeclient/resources"
 	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/toolchain-common/pkg/spacebinding"
 
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/selection"
 )
 
 type Option func(f *ServiceImpl)
@@ -112,6 +114,11 @@ func (s *ServiceImpl) GetUserSignup(name string) (*toolchainv1alpha1.UserSignup,
 }
 
 func (s *ServiceImpl) ListSpaceBindings(reqs ...labels.Requirement) ([]toolchainv1alpha1.SpaceBinding, error) {
+	if s.spaceBindingIndexSynced() {
+		if murName, spaceName, ok := indexedRequirements(reqs); ok {
+			return s.informer.SpaceBindingIndex.ListSpaceBindings(murName, spaceName), nil
+		}
+	}
 	selector := labels.NewSelector().Add(reqs...)
 	objs, err := s.informer.SpaceBinding.ByNamespace(configuration.Namespace()).List(selector)
 	if err != nil {
@@ -131,6 +138,61 @@ func (s *ServiceImpl) ListSpaceBindings(reqs ...labels.Requirement) ([]toolchain
 	return sbs, err
 }
 
+// spaceBindingIndexSynced returns true if the SpaceBindingIndex can be used, ie. if it has synced with the informer caches
+func (s *ServiceImpl) spaceBindingIndexSynced() bool {
+	return s.informer.SpaceBindingIndex != nil && s.informer.SpaceBindingIndex.HasSynced()
+}
+
+// indexedRequirements returns the names of the MasterUserRecord and of the Space selected by the given requirements,
+// if the requirements only select the SpaceBindings of a MasterUserRecord and/or of a Space.
+func indexedRequirements(reqs []labels.Requirement) (string, string, bool) {
+	var murName, spaceName string
+	for _, req := range reqs {
+		if (req.Operator() != selection.Equals && req.Operator() != selection.DoubleEquals && req.Operator() != selection.In) || req.Values().Len() != 1 {
+			return "", "", false
+		}
+		value := req.Values().List()[0]
+		switch {
+		case req.Key() == toolchainv1alpha1.SpaceBindingMasterUserRecordLabelKey && (murName == "" || murName == value):
+			murName = value
+		case req.Key() == toolchainv1alpha1.SpaceBindingSpaceLabelKey && (spaceName == "" || spaceName == value):
+			spaceName = value
+		default:
+			return "", "", false
+		}
+	}
+	return murName, spaceName, murName != "" || spaceName != ""
+}
+
+// ListEffectiveSpaceBindings returns the SpaceBindings of the given MasterUserRecord which grant access to the given Space,
+// including the ones inherited from the parent Spaces. An empty MasterUserRecord name returns the SpaceBindings of all the users.
+// The SpaceBindings are looked up in the SpaceBindingIndex when it is synced and contains the Space and its ancestors, otherwise the
+// parent Spaces and their SpaceBindings are listed from the informer caches.
+func (s *ServiceImpl) ListEffectiveSpaceBindings(space *toolchainv1alpha1.Space, murName string) ([]toolchainv1alpha1.SpaceBinding, error) {
+	if s.spaceBindingIndexSynced() {
+		if sbs, err := s.informer.SpaceBindingIndex.ListEffectiveSpaceBindings(murName, space.Name); err == nil {
+			return sbs, nil
+		}
+	}
+	listSpaceBindingsFunc := func(spaceName string) ([]toolchainv1alpha1.SpaceBinding, error) {
+		reqs := []labels.Requirement{}
+		spaceSelector, err := labels.NewRequirement(toolchainv1alpha1.SpaceBindingSpaceLabelKey, selection.Equals, []string{spaceName})
+		if err != nil {
+			return nil, err
+		}
+		reqs = append(reqs, *spaceSelector)
+		if murName != "" {
+			murSelector, err := labels.NewRequirement(toolchainv1alpha1.SpaceBindingMasterUserRecordLabelKey, selection.Equals, []string{murName})
+			if err != nil {
+				return nil, err
+			}
+			reqs = append(reqs, *murSelector)
+		}
+		return s.ListSpaceBindings(reqs...)
+	}
+	return spacebinding.NewLister(listSpaceBindingsFunc, s.GetSpace).ListForSpace(space, []toolchainv1alpha1.SpaceBinding{})
+}
+
 func (s *ServiceImpl) GetNSTemplateTier(name string) (*toolchainv1alpha1.NSTemplateTier, error) {
 	obj, err := s.informer.NSTemplateTier.ByNamespace(configuration.Namespace()).Get(name)
 	if err != nil {
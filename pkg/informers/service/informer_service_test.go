@@ -6,18 +6,24 @@ import (
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
 	appservice "github.com/codeready-toolchain/registration-service/pkg/application/service"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
 	"github.com/codeready-toolchain/registration-service/pkg/informers"
 	"github.com/codeready-toolchain/registration-service/pkg/informers/service"
 	"github.com/codeready-toolchain/registration-service/pkg/kubeclient"
 	"github.com/codeready-toolchain/registration-service/test"
+	"github.com/codeready-toolchain/registration-service/test/fake"
+	spacetest "github.com/codeready-toolchain/toolchain-common/pkg/test/space"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/selection"
 	"k8s.io/client-go/tools/cache"
 )
 
@@ -388,6 +394,147 @@ func (s *TestInformerServiceSuite) TestInformerService() {
 
 }
 
+func (s *TestInformerServiceSuite) TestInformerServiceWithSpaceBindingIndex() {
+	// given
+	index := informers.NewSpaceBindingIndex()
+	index.SetSpace(fake.NewSpace("parent", "member-1", "john"))
+	index.SetSpace(fake.NewSpace("child", "member-1", "john", spacetest.WithSpecParentSpace("parent")))
+	index.SetSpaceBinding(fake.NewSpaceBinding("john-parent", "john", "parent", "admin"))
+	index.SetSpaceBinding(fake.NewSpaceBinding("jane-child", "jane", "child", "viewer"))
+	require.True(s.T(), index.SyncWith(nil, nil))
+	spaceBindingLister := newIndexerLister(s.T(), fake.NewSpaceBinding("noise", "noise", "noise", "admin"))
+	inf := informers.Informer{
+		SpaceBinding:      spaceBindingLister,
+		Space:             newIndexerLister(s.T(), fake.NewSpace("parent", "member-1", "john"), fake.NewSpace("unindexed", "member-1", "john", spacetest.WithSpecParentSpace("parent"))),
+		SpaceBindingIndex: index,
+	}
+	svc := service.NewInformerService(fakeInformerServiceContext{
+		Svcs:     s.Application,
+		informer: inf,
+	})
+
+	s.Run("list space bindings with the index", func() {
+		// given
+		murSelector, err := labels.NewRequirement(toolchainv1alpha1.SpaceBindingMasterUserRecordLabelKey, selection.Equals, []string{"john"})
+		require.NoError(s.T(), err)
+		spaceSelector, err := labels.NewRequirement(toolchainv1alpha1.SpaceBindingSpaceLabelKey, selection.In, []string{"parent"})
+		require.NoError(s.T(), err)
+
+		// when
+		sbs, err := svc.ListSpaceBindings(*murSelector, *spaceSelector)
+
+		// then
+		require.NoError(s.T(), err)
+		require.Len(s.T(), sbs, 1)
+		assert.Equal(s.T(), "john-parent", sbs[0].Name)
+	})
+
+	s.Run("list space bindings with other selectors", func() {
+		// given
+		murSelector, err := labels.NewRequirement(toolchainv1alpha1.SpaceBindingMasterUserRecordLabelKey, selection.NotEquals, []string{"john"})
+		require.NoError(s.T(), err)
+
+		// when
+		sbs, err := svc.ListSpaceBindings(*murSelector)
+
+		// then
+		require.NoError(s.T(), err)
+		require.Len(s.T(), sbs, 1)
+		assert.Equal(s.T(), "noise", sbs[0].Name)
+	})
+
+	s.Run("list effective space bindings with the index", func() {
+		// when
+		sbs, err := svc.(appservice.EffectiveSpaceBindingLister).ListEffectiveSpaceBindings(fake.NewSpace("child", "member-1", "john"), "john")
+
+		// then
+		require.NoError(s.T(), err)
+		require.Len(s.T(), sbs, 1)
+		assert.Equal(s.T(), "john-parent", sbs[0].Name)
+	})
+
+	s.Run("list effective space bindings of a space which is not indexed", func() {
+		// when
+		sbs, err := svc.(appservice.EffectiveSpaceBindingLister).ListEffectiveSpaceBindings(fake.NewSpace("unindexed", "member-1", "john", spacetest.WithSpecParentSpace("parent")), "")
+
+		// then
+		require.NoError(s.T(), err)
+		require.Len(s.T(), sbs, 1)
+		assert.Equal(s.T(), "john-parent", sbs[0].Name)
+	})
+
+	s.Run("list space bindings from the informer cache until the index is synced", func() {
+		// given
+		svc := service.NewInformerService(fakeInformerServiceContext{
+			Svcs: s.Application,
+			informer: informers.Informer{
+				SpaceBinding:      spaceBindingLister,
+				SpaceBindingIndex: informers.NewSpaceBindingIndex(),
+			},
+		})
+		murSelector, err := labels.NewRequirement(toolchainv1alpha1.SpaceBindingMasterUserRecordLabelKey, selection.Equals, []string{"noise"})
+		require.NoError(s.T(), err)
+
+		// when
+		sbs, err := svc.ListSpaceBindings(*murSelector)
+
+		// then
+		require.NoError(s.T(), err)
+		require.Len(s.T(), sbs, 1)
+		assert.Equal(s.T(), "noise", sbs[0].Name)
+	})
+}
+
+// BenchmarkListSpaceBindings compares the listing of the SpaceBindings of a user by scanning the informer cache
+// with the listing from the SpaceBindingIndex, with 100k SpaceBindings
+func BenchmarkListSpaceBindings(b *testing.B) {
+	index := informers.NewSpaceBindingIndex()
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for i := 0; i < 100000; i++ {
+		sb := fake.NewSpaceBinding(fmt.Sprintf("sb-%d", i), fmt.Sprintf("user-%d", i%25000), fmt.Sprintf("space-%d", i), "admin")
+		sb.Namespace = configuration.Namespace()
+		index.SetSpaceBinding(sb)
+		require.NoError(b, indexer.Add(toUnstructured(b, sb)))
+	}
+	index.SyncWith(nil, nil)
+	scanning := service.NewInformerService(fakeInformerServiceContext{
+		informer: informers.Informer{SpaceBinding: cache.NewGenericLister(indexer, toolchainv1alpha1.GroupVersion.WithResource("spacebindings").GroupResource())},
+	})
+	indexed := service.NewInformerService(fakeInformerServiceContext{
+		informer: informers.Informer{SpaceBindingIndex: index},
+	})
+
+	for name, svc := range map[string]appservice.InformerService{
+		"scan":  scanning,
+		"index": indexed,
+	} {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				murSelector, err := labels.NewRequirement(toolchainv1alpha1.SpaceBindingMasterUserRecordLabelKey, selection.Equals, []string{fmt.Sprintf("user-%d", i%25000)})
+				require.NoError(b, err)
+				sbs, err := svc.ListSpaceBindings(*murSelector)
+				require.NoError(b, err)
+				require.Len(b, sbs, 4)
+			}
+		})
+	}
+}
+
+func newIndexerLister(t require.TestingT, objs ...runtime.Object) cache.GenericLister {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, obj := range objs {
+		obj.(metav1.Object).SetNamespace(configuration.Namespace())
+		require.NoError(t, indexer.Add(toUnstructured(t, obj)))
+	}
+	return cache.NewGenericLister(indexer, schema.GroupResource{Group: toolchainv1alpha1.GroupVersion.Group})
+}
+
+func toUnstructured(t require.TestingT, obj runtime.Object) *unstructured.Unstructured {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	require.NoError(t, err)
+	return &unstructured.Unstructured{Object: content}
+}
+
 type fakeInformerServiceContext struct {
 	Svcs     appservice.Services
 	informer informers.Informer
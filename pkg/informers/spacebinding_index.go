@@ -0,0 +1,346 @@
+package informers
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+)
+
+// SpaceBindingIndex indexes the SpaceBindings by MasterUserRecord and by Space, and the Spaces by their ancestors,
+// so that the bindings of the users, including the ones inherited from the parent Spaces, are looked up without
+// scanning and converting the content of the informer caches. The index is maintained incrementally with the events
+// of the Space and SpaceBinding informers, see SpaceBindingIndex.OnAdd, OnUpdate and OnDelete. Since the events are handled
+// asynchronously, the index may lag behind the synced informer caches, and should not be used until it has synced with
+// them, see SpaceBindingIndex.SyncWith.
+type SpaceBindingIndex struct {
+	lock sync.RWMutex
+	// synced is true once the index contains the content of the synced informer caches
+	synced bool
+	// spaces are the indexed Spaces by name
+	spaces map[string]*toolchainv1alpha1.Space
+	// children are the names of the Spaces by the name of their parent Space, which may not be indexed (yet)
+	children map[string]map[string]struct{}
+	// ancestors are the names of the ancestors of the Spaces by name, from the parent Space up to the root Space
+	ancestors map[string][]string
+	// bindings are the indexed SpaceBindings by name
+	bindings map[string]*toolchainv1alpha1.SpaceBinding
+	// bindingsByMUR are the SpaceBindings by the name of their MasterUserRecord, then by their name
+	bindingsByMUR map[string]map[string]*toolchainv1alpha1.SpaceBinding
+	// bindingsBySpace are the SpaceBindings by the name of their Space, then by their name
+	bindingsBySpace map[string]map[string]*toolchainv1alpha1.SpaceBinding
+}
+
+// NewSpaceBindingIndex returns an empty index
+func NewSpaceBindingIndex() *SpaceBindingIndex {
+	return &SpaceBindingIndex{
+		spaces:          map[string]*toolchainv1alpha1.Space{},
+		children:        map[string]map[string]struct{}{},
+		ancestors:       map[string][]string{},
+		bindings:        map[string]*toolchainv1alpha1.SpaceBinding{},
+		bindingsByMUR:   map[string]map[string]*toolchainv1alpha1.SpaceBinding{},
+		bindingsBySpace: map[string]map[string]*toolchainv1alpha1.SpaceBinding{},
+	}
+}
+
+var _ cache.ResourceEventHandler = &SpaceBindingIndex{}
+
+// OnAdd indexes the added Space or SpaceBinding
+func (i *SpaceBindingIndex) OnAdd(obj interface{}) {
+	i.upsert(obj)
+}
+
+// OnUpdate re-indexes the updated Space or SpaceBinding
+func (i *SpaceBindingIndex) OnUpdate(_, newObj interface{}) {
+	i.upsert(newObj)
+}
+
+// OnDelete removes the deleted Space or SpaceBinding from the index
+func (i *SpaceBindingIndex) OnDelete(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	switch o := convert(obj).(type) {
+	case *toolchainv1alpha1.Space:
+		i.DeleteSpace(o.Name)
+	case *toolchainv1alpha1.SpaceBinding:
+		i.DeleteSpaceBinding(o.Name)
+	}
+}
+
+func (i *SpaceBindingIndex) upsert(obj interface{}) {
+	switch o := convert(obj).(type) {
+	case *toolchainv1alpha1.Space:
+		i.SetSpace(o)
+	case *toolchainv1alpha1.SpaceBinding:
+		i.SetSpaceBinding(o)
+	}
+}
+
+// convert returns the Space or SpaceBinding of the given object of the informers, or nil if it is of another kind
+func convert(obj interface{}) interface{} {
+	switch o := obj.(type) {
+	case *toolchainv1alpha1.Space, *toolchainv1alpha1.SpaceBinding:
+		return o
+	case *unstructured.Unstructured:
+		var typed interface{}
+		switch o.GetKind() {
+		case "Space":
+			typed = &toolchainv1alpha1.Space{}
+		case "SpaceBinding":
+			typed = &toolchainv1alpha1.SpaceBinding{}
+		default:
+			return nil
+		}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(o.UnstructuredContent(), typed); err != nil {
+			log.Errorf(nil, err, "failed to index %s '%s'", o.GetKind(), o.GetName())
+			return nil
+		}
+		return typed
+	}
+	return nil
+}
+
+// SyncWith marks the index as synced if it contains all the given Spaces and SpaceBindings of the synced informer caches.
+// Since the caches are updated before the events are handled, the index contains all the objects of the caches only once
+// the events of the initial listing of the informers were handled. Returns true if the index is synced.
+func (i *SpaceBindingIndex) SyncWith(spaces, spaceBindings []runtime.Object) bool {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	if i.synced {
+		return true
+	}
+	for _, obj := range spaces {
+		if o, err := meta.Accessor(obj); err != nil || i.spaces[o.GetName()] == nil {
+			return false
+		}
+	}
+	for _, obj := range spaceBindings {
+		if o, err := meta.Accessor(obj); err != nil || i.bindings[o.GetName()] == nil {
+			return false
+		}
+	}
+	i.synced = true
+	return true
+}
+
+// HasSynced returns true if the index has synced with the informer caches
+func (i *SpaceBindingIndex) HasSynced() bool {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	return i.synced
+}
+
+// SetSpace adds or replaces the given Space in the index
+func (i *SpaceBindingIndex) SetSpace(space *toolchainv1alpha1.Space) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	previousParent := ""
+	if previous, found := i.spaces[space.Name]; found {
+		previousParent = previous.Spec.ParentSpace
+	}
+	i.spaces[space.Name] = space
+	if previousParent != space.Spec.ParentSpace {
+		i.removeChild(previousParent, space.Name)
+		if space.Spec.ParentSpace != "" {
+			if i.children[space.Spec.ParentSpace] == nil {
+				i.children[space.Spec.ParentSpace] = map[string]struct{}{}
+			}
+			i.children[space.Spec.ParentSpace][space.Name] = struct{}{}
+		}
+	}
+	// the ancestors of the descendants change when a Space is added (ie. a missing ancestor is indexed) or moved
+	i.updateAncestors(space.Name)
+}
+
+// DeleteSpace removes the Space with the given name from the index
+func (i *SpaceBindingIndex) DeleteSpace(name string) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	space, found := i.spaces[name]
+	if !found {
+		return
+	}
+	delete(i.spaces, name)
+	delete(i.ancestors, name)
+	i.removeChild(space.Spec.ParentSpace, name)
+	// the descendants are kept, but their ancestors end with the parent of the deleted Space
+	for child := range i.children[name] {
+		i.updateAncestors(child)
+	}
+}
+
+func (i *SpaceBindingIndex) removeChild(parent, child string) {
+	if parent == "" {
+		return
+	}
+	delete(i.children[parent], child)
+	if len(i.children[parent]) == 0 {
+		delete(i.children, parent)
+	}
+}
+
+// updateAncestors computes the ancestors of the Space with the given name and of all its descendants
+func (i *SpaceBindingIndex) updateAncestors(name string) {
+	queue := []string{name}
+	visited := map[string]struct{}{}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if _, found := visited[current]; found {
+			// the Spaces are in a cycle
+			continue
+		}
+		visited[current] = struct{}{}
+		if space, found := i.spaces[current]; found {
+			i.ancestors[current] = i.computeAncestors(space)
+		}
+		for child := range i.children[current] {
+			queue = append(queue, child)
+		}
+	}
+}
+
+func (i *SpaceBindingIndex) computeAncestors(space *toolchainv1alpha1.Space) []string {
+	var ancestors []string
+	visited := map[string]struct{}{space.Name: {}}
+	for parent := space.Spec.ParentSpace; parent != ""; {
+		if _, found := visited[parent]; found {
+			// the Spaces are in a cycle
+			break
+		}
+		visited[parent] = struct{}{}
+		ancestors = append(ancestors, parent)
+		parentSpace, found := i.spaces[parent]
+		if !found {
+			break
+		}
+		parent = parentSpace.Spec.ParentSpace
+	}
+	return ancestors
+}
+
+// SetSpaceBinding adds or replaces the given SpaceBinding in the index
+func (i *SpaceBindingIndex) SetSpaceBinding(binding *toolchainv1alpha1.SpaceBinding) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.deleteSpaceBinding(binding.Name)
+	i.bindings[binding.Name] = binding
+	addBinding(i.bindingsByMUR, binding.Labels[toolchainv1alpha1.SpaceBindingMasterUserRecordLabelKey], binding)
+	addBinding(i.bindingsBySpace, binding.Labels[toolchainv1alpha1.SpaceBindingSpaceLabelKey], binding)
+}
+
+// DeleteSpaceBinding removes the SpaceBinding with the given name from the index
+func (i *SpaceBindingIndex) DeleteSpaceBinding(name string) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.deleteSpaceBinding(name)
+}
+
+func (i *SpaceBindingIndex) deleteSpaceBinding(name string) {
+	binding, found := i.bindings[name]
+	if !found {
+		return
+	}
+	delete(i.bindings, name)
+	removeBinding(i.bindingsByMUR, binding.Labels[toolchainv1alpha1.SpaceBindingMasterUserRecordLabelKey], name)
+	removeBinding(i.bindingsBySpace, binding.Labels[toolchainv1alpha1.SpaceBindingSpaceLabelKey], name)
+}
+
+func addBinding(bindings map[string]map[string]*toolchainv1alpha1.SpaceBinding, key string, binding *toolchainv1alpha1.SpaceBinding) {
+	if bindings[key] == nil {
+		bindings[key] = map[string]*toolchainv1alpha1.SpaceBinding{}
+	}
+	bindings[key][binding.Name] = binding
+}
+
+func removeBinding(bindings map[string]map[string]*toolchainv1alpha1.SpaceBinding, key, name string) {
+	delete(bindings[key], name)
+	if len(bindings[key]) == 0 {
+		delete(bindings, key)
+	}
+}
+
+// Ancestors returns the names of the ancestors of the Space with the given name, from its parent Space up to the root Space.
+// The last ancestor is not indexed when the Space has a missing ancestor.
+func (i *SpaceBindingIndex) Ancestors(spaceName string) []string {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	return append([]string(nil), i.ancestors[spaceName]...)
+}
+
+// ListSpaceBindings returns the SpaceBindings of the given MasterUserRecord and Space, sorted by name.
+// An empty MasterUserRecord (or Space) name matches the SpaceBindings of all the MasterUserRecords (or Spaces).
+func (i *SpaceBindingIndex) ListSpaceBindings(murName, spaceName string) []toolchainv1alpha1.SpaceBinding {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	return i.listSpaceBindings(murName, spaceName)
+}
+
+func (i *SpaceBindingIndex) listSpaceBindings(murName, spaceName string) []toolchainv1alpha1.SpaceBinding {
+	var candidates map[string]*toolchainv1alpha1.SpaceBinding
+	switch {
+	case spaceName != "":
+		// there are fewer SpaceBindings per Space than per MasterUserRecord
+		candidates = i.bindingsBySpace[spaceName]
+	case murName != "":
+		candidates = i.bindingsByMUR[murName]
+	default:
+		candidates = i.bindings
+	}
+	bindings := make([]toolchainv1alpha1.SpaceBinding, 0, len(candidates))
+	for _, binding := range candidates {
+		if murName != "" && binding.Labels[toolchainv1alpha1.SpaceBindingMasterUserRecordLabelKey] != murName {
+			continue
+		}
+		bindings = append(bindings, *binding.DeepCopy())
+	}
+	sort.Slice(bindings, func(a, b int) bool {
+		return bindings[a].Name < bindings[b].Name
+	})
+	return bindings
+}
+
+// ListEffectiveSpaceBindings returns the SpaceBindings of the given MasterUserRecord which grant access to the Space
+// with the given name, including the ones inherited from the ancestors of the Space unless the inheritance is disabled.
+// A SpaceBinding of a MasterUserRecord overrides the SpaceBindings of the same MasterUserRecord in the ancestors.
+// An empty MasterUserRecord name returns the effective SpaceBindings of all the MasterUserRecords.
+// It returns an error if the Space or one of its ancestors is not indexed.
+func (i *SpaceBindingIndex) ListEffectiveSpaceBindings(murName, spaceName string) ([]toolchainv1alpha1.SpaceBinding, error) {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	space, found := i.spaces[spaceName]
+	if !found {
+		return nil, fmt.Errorf("space '%s' is not indexed", spaceName)
+	}
+	bindings := i.listSpaceBindings(murName, spaceName)
+	overridden := map[string]struct{}{}
+	for _, binding := range bindings {
+		overridden[binding.Spec.MasterUserRecord] = struct{}{}
+	}
+	for _, ancestor := range i.ancestors[spaceName] {
+		if space.Spec.DisableInheritance {
+			break
+		}
+		if space, found = i.spaces[ancestor]; !found {
+			return bindings, fmt.Errorf("unable to get parent-space: space '%s' is not indexed", ancestor)
+		}
+		ancestorBindings := i.listSpaceBindings(murName, ancestor)
+		for _, binding := range ancestorBindings {
+			if _, found := overridden[binding.Spec.MasterUserRecord]; !found {
+				bindings = append(bindings, binding)
+			}
+		}
+		for _, binding := range ancestorBindings {
+			overridden[binding.Spec.MasterUserRecord] = struct{}{}
+		}
+	}
+	return bindings, nil
+}
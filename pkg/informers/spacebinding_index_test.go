@@ -0,0 +1,340 @@
+package informers_test
+
+import (
+	"fmt"
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/informers"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/test/fake"
+	spacetest "github.com/codeready-toolchain/toolchain-common/pkg/test/space"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestSpaceBindingIndex(t *testing.T) {
+	log.Init("registration-service-testing")
+
+	// given
+	newIndex := func() *informers.SpaceBindingIndex {
+		index := informers.NewSpaceBindingIndex()
+		// the objects of the informers are unstructured
+		for _, obj := range []runtime.Object{
+			fake.NewSpace("parent", "member-1", "john"),
+			fake.NewSpace("child", "member-1", "john", spacetest.WithSpecParentSpace("parent")),
+			fake.NewSpace("grandchild", "member-1", "john", spacetest.WithSpecParentSpace("child")),
+			fake.NewSpace("other", "member-1", "jane"),
+			fake.NewSpaceBinding("john-parent", "john", "parent", "admin"),
+			fake.NewSpaceBinding("jane-parent", "jane", "parent", "viewer"),
+			fake.NewSpaceBinding("jane-child", "jane", "child", "admin"),
+			fake.NewSpaceBinding("jane-other", "jane", "other", "admin"),
+		} {
+			index.OnAdd(toUnstructured(t, obj))
+		}
+		return index
+	}
+
+	t.Run("ancestors", func(t *testing.T) {
+		// given
+		index := newIndex()
+
+		// then
+		assert.Empty(t, index.Ancestors("parent"))
+		assert.Equal(t, []string{"parent"}, index.Ancestors("child"))
+		assert.Equal(t, []string{"child", "parent"}, index.Ancestors("grandchild"))
+		assert.Empty(t, index.Ancestors("unknown"))
+
+		t.Run("space moved", func(t *testing.T) {
+			// when
+			index.OnUpdate(nil, toUnstructured(t, fake.NewSpace("child", "member-1", "john", spacetest.WithSpecParentSpace("other"))))
+
+			// then
+			assert.Equal(t, []string{"other"}, index.Ancestors("child"))
+			assert.Equal(t, []string{"child", "other"}, index.Ancestors("grandchild"))
+		})
+
+		t.Run("space deleted", func(t *testing.T) {
+			// when
+			index.OnDelete(cache.DeletedFinalStateUnknown{Obj: toUnstructured(t, fake.NewSpace("other", "member-1", "jane"))})
+
+			// then
+			assert.Equal(t, []string{"other"}, index.Ancestors("child"))
+			assert.Empty(t, index.Ancestors("other"))
+
+			t.Run("space added back", func(t *testing.T) {
+				// when
+				index.OnAdd(toUnstructured(t, fake.NewSpace("other", "member-1", "jane", spacetest.WithSpecParentSpace("parent"))))
+
+				// then
+				assert.Equal(t, []string{"child", "other", "parent"}, index.Ancestors("grandchild"))
+			})
+		})
+
+		t.Run("spaces in a cycle", func(t *testing.T) {
+			// when
+			index.OnUpdate(nil, toUnstructured(t, fake.NewSpace("parent", "member-1", "john", spacetest.WithSpecParentSpace("grandchild"))))
+
+			// then
+			assert.Equal(t, []string{"child", "other", "parent"}, index.Ancestors("grandchild"))
+			assert.Equal(t, []string{"grandchild", "child", "other"}, index.Ancestors("parent"))
+		})
+	})
+
+	t.Run("list space bindings", func(t *testing.T) {
+		// given
+		index := newIndex()
+
+		// then
+		assert.Equal(t, []string{"john-parent"}, names(index.ListSpaceBindings("john", "")))
+		assert.Equal(t, []string{"jane-child", "jane-other", "jane-parent"}, names(index.ListSpaceBindings("jane", "")))
+		assert.Equal(t, []string{"jane-parent", "john-parent"}, names(index.ListSpaceBindings("", "parent")))
+		assert.Equal(t, []string{"jane-child"}, names(index.ListSpaceBindings("jane", "child")))
+		assert.Empty(t, index.ListSpaceBindings("john", "child"))
+		assert.Len(t, index.ListSpaceBindings("", ""), 4)
+
+		t.Run("returns copies", func(t *testing.T) {
+			// when
+			index.ListSpaceBindings("john", "")[0].Spec.SpaceRole = "viewer"
+
+			// then
+			assert.Equal(t, "admin", index.ListSpaceBindings("john", "")[0].Spec.SpaceRole)
+		})
+
+		t.Run("space binding moved", func(t *testing.T) {
+			// when
+			index.OnUpdate(nil, toUnstructured(t, fake.NewSpaceBinding("jane-other", "john", "other", "admin")))
+
+			// then
+			assert.Equal(t, []string{"jane-other", "john-parent"}, names(index.ListSpaceBindings("john", "")))
+			assert.Equal(t, []string{"jane-child", "jane-parent"}, names(index.ListSpaceBindings("jane", "")))
+		})
+
+		t.Run("space binding deleted", func(t *testing.T) {
+			// when
+			index.OnDelete(toUnstructured(t, fake.NewSpaceBinding("john-parent", "john", "parent", "admin")))
+
+			// then
+			assert.Equal(t, []string{"jane-other"}, names(index.ListSpaceBindings("john", "")))
+			assert.Equal(t, []string{"jane-parent"}, names(index.ListSpaceBindings("", "parent")))
+		})
+	})
+
+	t.Run("list effective space bindings", func(t *testing.T) {
+		// given
+		index := newIndex()
+
+		t.Run("inherited binding", func(t *testing.T) {
+			// when
+			bindings, err := index.ListEffectiveSpaceBindings("john", "grandchild")
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, []string{"john-parent"}, names(bindings))
+		})
+
+		t.Run("overridden binding", func(t *testing.T) {
+			// when
+			bindings, err := index.ListEffectiveSpaceBindings("jane", "grandchild")
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, []string{"jane-child"}, names(bindings))
+		})
+
+		t.Run("all users", func(t *testing.T) {
+			// when
+			bindings, err := index.ListEffectiveSpaceBindings("", "grandchild")
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, []string{"jane-child", "john-parent"}, names(bindings))
+		})
+
+		t.Run("no binding", func(t *testing.T) {
+			// when
+			bindings, err := index.ListEffectiveSpaceBindings("john", "other")
+
+			// then
+			require.NoError(t, err)
+			assert.Empty(t, bindings)
+		})
+
+		t.Run("inheritance disabled", func(t *testing.T) {
+			// given
+			index := newIndex()
+			index.OnUpdate(nil, toUnstructured(t, fake.NewSpace("child", "member-1", "john", spacetest.WithSpecParentSpace("parent"), withDisableInheritance())))
+
+			// when
+			bindings, err := index.ListEffectiveSpaceBindings("", "grandchild")
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, []string{"jane-child"}, names(bindings))
+		})
+
+		t.Run("space not indexed", func(t *testing.T) {
+			// when
+			_, err := index.ListEffectiveSpaceBindings("john", "unknown")
+
+			// then
+			require.EqualError(t, err, "space 'unknown' is not indexed")
+		})
+
+		t.Run("parent space not indexed", func(t *testing.T) {
+			// given
+			index := newIndex()
+			index.OnDelete(toUnstructured(t, fake.NewSpace("parent", "member-1", "john")))
+
+			// when
+			_, err := index.ListEffectiveSpaceBindings("john", "child")
+
+			// then
+			require.EqualError(t, err, "unable to get parent-space: space 'parent' is not indexed")
+		})
+	})
+
+	t.Run("sync with the informer caches", func(t *testing.T) {
+		// given
+		index := newIndex()
+		spaces := []runtime.Object{
+			toUnstructured(t, fake.NewSpace("parent", "member-1", "john")),
+			toUnstructured(t, fake.NewSpace("child", "member-1", "john", spacetest.WithSpecParentSpace("parent"))),
+		}
+		spaceBindings := []runtime.Object{
+			toUnstructured(t, fake.NewSpaceBinding("john-parent", "john", "parent", "admin")),
+			toUnstructured(t, fake.NewSpaceBinding("john-new", "john", "child", "admin")),
+		}
+
+		t.Run("not synced while the events of some objects are not handled", func(t *testing.T) {
+			// when
+			synced := index.SyncWith(spaces, spaceBindings)
+
+			// then
+			assert.False(t, synced)
+			assert.False(t, index.HasSynced())
+		})
+
+		t.Run("synced once the events of all the objects are handled", func(t *testing.T) {
+			// given
+			index.OnAdd(spaceBindings[1])
+
+			// when
+			synced := index.SyncWith(spaces, spaceBindings)
+
+			// then
+			assert.True(t, synced)
+			assert.True(t, index.HasSynced())
+		})
+
+		t.Run("remains synced", func(t *testing.T) {
+			// when
+			synced := index.SyncWith(append(spaces, toUnstructured(t, fake.NewSpace("unknown", "member-1", "john"))), spaceBindings)
+
+			// then
+			assert.True(t, synced)
+		})
+	})
+
+	t.Run("ignores other resources", func(t *testing.T) {
+		// given
+		index := newIndex()
+
+		// when
+		index.OnAdd(toUnstructured(t, fake.NewMasterUserRecord("john")))
+		index.OnAdd("invalid")
+
+		// then
+		assert.Len(t, index.ListSpaceBindings("", ""), 4)
+	})
+}
+
+func BenchmarkSpaceBindingIndex(b *testing.B) {
+	log.Init("registration-service-testing")
+	// 25k root spaces with 3 levels of sub-spaces, each with a binding: 100k spacebindings
+	index := informers.NewSpaceBindingIndex()
+	for i := 0; i < 25000; i++ {
+		parent := ""
+		for level := 0; level < 4; level++ {
+			name := fmt.Sprintf("space-%d-%d", i, level)
+			mur := fmt.Sprintf("user-%d", (i+level)%25000)
+			index.SetSpace(fake.NewSpace(name, "member-1", mur, spacetest.WithSpecParentSpace(parent)))
+			index.SetSpaceBinding(fake.NewSpaceBinding(name+"-"+mur, mur, name, "admin"))
+			parent = name
+		}
+	}
+
+	b.Run("list space bindings of a user", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if bindings := index.ListSpaceBindings(fmt.Sprintf("user-%d", i%25000), ""); len(bindings) != 4 {
+				b.Fatalf("expected 4 bindings, got %d", len(bindings))
+			}
+		}
+	})
+
+	b.Run("list effective space bindings of a user", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			bindings, err := index.ListEffectiveSpaceBindings(fmt.Sprintf("user-%d", i%25000), fmt.Sprintf("space-%d-3", i%25000))
+			if err != nil || len(bindings) != 1 {
+				b.Fatalf("expected 1 binding, got %d (%v)", len(bindings), err)
+			}
+		}
+	})
+
+	b.Run("ancestors", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if ancestors := index.Ancestors(fmt.Sprintf("space-%d-3", i%25000)); len(ancestors) != 3 {
+				b.Fatalf("expected 3 ancestors, got %d", len(ancestors))
+			}
+		}
+	})
+
+	b.Run("update space binding", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			name := fmt.Sprintf("space-%d-0", i%25000)
+			index.SetSpaceBinding(fake.NewSpaceBinding(name+"-new", "new-user", name, "viewer"))
+		}
+	})
+
+	b.Run("move space", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			// the ancestors of the sub-spaces are updated too
+			index.SetSpace(fake.NewSpace(fmt.Sprintf("space-%d-1", i%25000), "member-1", "user", spacetest.WithSpecParentSpace(fmt.Sprintf("space-%d-0", (i+1)%25000))))
+		}
+	})
+}
+
+func toUnstructured(t *testing.T, obj runtime.Object) *unstructured.Unstructured {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	require.NoError(t, err)
+	unobj := &unstructured.Unstructured{Object: content}
+	switch obj.(type) {
+	case *toolchainv1alpha1.Space:
+		unobj.SetKind("Space")
+	case *toolchainv1alpha1.SpaceBinding:
+		unobj.SetKind("SpaceBinding")
+	case *toolchainv1alpha1.MasterUserRecord:
+		unobj.SetKind("MasterUserRecord")
+	}
+	unobj.SetAPIVersion(toolchainv1alpha1.GroupVersion.String())
+	return unobj
+}
+
+func withDisableInheritance() spacetest.Option {
+	return func(space *toolchainv1alpha1.Space) {
+		space.Spec.DisableInheritance = true
+	}
+}
+
+func names(bindings []toolchainv1alpha1.SpaceBinding) []string {
+	names := make([]string, 0, len(bindings))
+	for _, binding := range bindings {
+		names = append(names, binding.Name)
+	}
+	return names
+}
@@ -1,20 +1,19 @@
 package middleware
 
 import (
-	"errors"
 	"net/http"
 	"strings"
 
 	"github.com/codeready-toolchain/registration-service/pkg/auth"
-	"github.com/codeready-toolchain/registration-service/pkg/context"
 	"github.com/codeready-toolchain/registration-service/pkg/log"
 
 	"github.com/gin-gonic/gin"
 )
 
-// JWTMiddleware is the JWT token validation middleware
+// JWTMiddleware is the authentication middleware. Despite its name, it authenticates the requests with all the
+// authenticators of the default chain, not only with JWT bearer tokens.
 type JWTMiddleware struct {
-	tokenParser *auth.TokenParser
+	authenticator auth.Authenticator
 }
 
 // NewAuthMiddleware returns a new middleware for JWT authentication
@@ -24,30 +23,10 @@ func NewAuthMiddleware() (*JWTMiddleware, error) {
 		return nil, err
 	}
 	return &JWTMiddleware{
-		tokenParser: tokenParserInstance,
+		authenticator: auth.NewDefaultAuthenticatorChain(tokenParserInstance),
 	}, nil
 }
 
-func (m *JWTMiddleware) extractToken(c *gin.Context) (string, error) {
-	// token lookup: header: Authorization
-	// try header field "Authorization" (will be "" when n/a)
-	headerToken := c.GetHeader("Authorization")
-	if headerToken != "" {
-		if strings.HasPrefix(headerToken, "Bearer ") {
-			// it is a bearer token, split it up and return it
-			s := strings.Fields(headerToken)
-			if len(s) == 2 {
-				return s[1], nil
-			}
-			// we're failing fast here, if there is an Authorization header, it is used or it fails
-			return "", errors.New("found bearer token header, but no token:" + headerToken)
-		}
-		// see above, failing fast
-		return "", errors.New("found unknown authorization header:" + headerToken)
-	}
-	return "", errors.New("no token found")
-}
-
 func (m *JWTMiddleware) respondWithError(c *gin.Context, code int, message interface{}) {
 	c.AbortWithStatusJSON(code, gin.H{"error": message})
 }
@@ -55,24 +34,17 @@ func (m *JWTMiddleware) respondWithError(c *gin.Context, code int, message inter
 // HandlerFunc returns the HanderFunc.
 func (m *JWTMiddleware) HandlerFunc() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// check if we have a token
-		tokenStr, err := m.extractToken(c)
-		if err != nil {
-			m.respondWithError(c, http.StatusUnauthorized, err.Error())
-			return
-		}
-		// next, check the token
-		token, err := m.tokenParser.FromString(tokenStr)
+		identity, err := m.authenticator.Authenticate(c.Request)
 		if err != nil {
 			m.respondWithError(c, http.StatusUnauthorized, err.Error())
 			return
 		}
 
-		if token.UserID == "" || token.AccountID == "" {
+		if identity.Claims != nil && (identity.UserID == "" || identity.AccountID == "") {
 			rawClaims := ""
 
 			// Tokens consist of 3 segments; header, claims and signature
-			parts := strings.Split(tokenStr, ".")
+			parts := strings.Split(strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "), ".")
 
 			// Just capture the claims segment
 			if len(parts) >= 2 {
@@ -80,22 +52,12 @@ func (m *JWTMiddleware) HandlerFunc() gin.HandlerFunc {
 			}
 
 			log.Infof(c, "Missing essential claims from token - [user_id:%s][account_id:%s] for user [%s], sub [%s].  Raw claims segment: [%s]",
-				token.UserID, token.AccountID, token.PreferredUsername, token.Subject, rawClaims)
+				identity.UserID, identity.AccountID, identity.Username, identity.Subject, rawClaims)
 		}
 
-		// all checks done, add username, subject and email to the context.
-		// the tokenparser has already checked these claims are in the token at this point.
-		c.Set(context.UserIDKey, token.UserID)
-		c.Set(context.AccountIDKey, token.AccountID)
-		c.Set(context.UsernameKey, token.PreferredUsername)
-		c.Set(context.EmailKey, token.Email)
-		c.Set(context.SubKey, token.Subject)
-		c.Set(context.OriginalSubKey, token.OriginalSub)
-		c.Set(context.GivenNameKey, token.GivenName)
-		c.Set(context.FamilyNameKey, token.FamilyName)
-		c.Set(context.CompanyKey, token.Company)
-		// for convenience, add the claims to the context.
-		c.Set(context.JWTClaimsKey, token)
+		// all checks done, add the identity and its fields to the context.
+		// the authenticator has already checked the required fields are set at this point.
+		identity.StoreInto(c)
 		c.Next()
 	}
 }
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/codeready-toolchain/registration-service/pkg/auth"
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireRole returns a middleware which only lets through the requests of the users who were granted one of the given roles.
+// It must be used after the authentication middleware.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if identity, ok := c.Value(context.IdentityKey).(*auth.Identity); ok {
+			for _, role := range roles {
+				if identity.HasRole(role) {
+					c.Next()
+					return
+				}
+			}
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("one of the following roles is required: %s", strings.Join(roles, ", "))})
+	}
+}
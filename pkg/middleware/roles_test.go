@@ -0,0 +1,82 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/codeready-toolchain/registration-service/pkg/auth"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+	"github.com/codeready-toolchain/registration-service/pkg/middleware"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireRole(t *testing.T) {
+	tests := map[string]struct {
+		identity     *auth.Identity
+		expectedCode int
+		expectedBody string
+	}{
+		"admin": {
+			identity:     &auth.Identity{Username: "jsmith", Roles: []string{configuration.AdminRole}},
+			expectedCode: http.StatusOK,
+			expectedBody: "ok",
+		},
+		"support": {
+			identity:     &auth.Identity{Username: "jsmith", Roles: []string{"support"}},
+			expectedCode: http.StatusOK,
+			expectedBody: "ok",
+		},
+		"no role": {
+			identity:     &auth.Identity{Username: "jsmith"},
+			expectedCode: http.StatusForbidden,
+			expectedBody: `{"error":"one of the following roles is required: admin, support"}`,
+		},
+		"other role": {
+			identity:     &auth.Identity{Username: "jsmith", Roles: []string{"viewer"}},
+			expectedCode: http.StatusForbidden,
+			expectedBody: `{"error":"one of the following roles is required: admin, support"}`,
+		},
+		"not authenticated": {
+			expectedCode: http.StatusForbidden,
+			expectedBody: `{"error":"one of the following roles is required: admin, support"}`,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// given
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.Use(func(c *gin.Context) {
+				if tc.identity != nil {
+					tc.identity.StoreInto(c)
+				}
+			})
+			router.GET("/api/v1/admin/test", middleware.RequireRole(configuration.AdminRole, "support"), func(c *gin.Context) {
+				c.String(http.StatusOK, "ok")
+			})
+			rr := httptest.NewRecorder()
+
+			// when
+			router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/admin/test", nil))
+
+			// then
+			assert.Equal(t, tc.expectedCode, rr.Code)
+			assert.Equal(t, tc.expectedBody, rr.Body.String())
+		})
+	}
+
+	t.Run("roles in the context", func(t *testing.T) {
+		// given
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+		// when
+		(&auth.Identity{Roles: []string{configuration.AdminRole}}).StoreInto(ctx)
+
+		// then
+		assert.Equal(t, []string{configuration.AdminRole}, ctx.GetStringSlice(context.RolesKey))
+	})
+}
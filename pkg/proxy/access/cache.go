@@ -0,0 +1,297 @@
+package access
+
+import (
+	"sync"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/utils/lru"
+)
+
+// The results of the lookups in the cache, used as the `result` label of the metrics
+const (
+	LookupHit  = "hit"
+	LookupMiss = "miss"
+)
+
+// Key identifies the access of a user to a workspace, or to all their workspaces if no workspace is given
+type Key struct {
+	UserID      string
+	Username    string
+	Workspace   string
+	ProxyPlugin string
+}
+
+// Entry is the resolved access of a user to a workspace. The entries are shared by all the requests of the user, so they
+// must not be modified. The access to the member cluster is not cached, so that the requests are always sent with the
+// current credentials of the member cluster.
+type Entry struct {
+	// Username is the compliant username of the user, or empty if the user is not provisioned
+	Username string
+	// Workspaces contains the requested workspace, or all the workspaces of the user if no workspace was requested.
+	// It is empty if the user is not allowed to access the requested workspace.
+	Workspaces []toolchainv1alpha1.Workspace
+}
+
+// Cache is a bounded cache of the resolved accesses of the users to the workspaces, so that the SpaceBindings of the
+// workspaces and of their parents are not listed again for each request. Each entry depends on the user it was resolved
+// for and on the workspaces it was resolved from, including the parent workspaces, and it is invalidated when a resource
+// related to any of them changes. The entries also expire after the TTL of the cache.
+type Cache struct {
+	lock  sync.Mutex
+	cache *lru.Cache
+	size  int
+	ttl   time.Duration
+	now   func() time.Time
+	// generation is incremented on each invalidation, so that an access resolved before an invalidation of one of its
+	// dependencies is not returned
+	generation uint64
+	// invalidated contains the last invalidation of each dependency
+	invalidated map[dependency]invalidation
+	// minGeneration is the generation before which the resolved accesses are not trusted anymore, after all the entries
+	// were invalidated or after old invalidations were forgotten
+	minGeneration uint64
+	// lookups counts the lookups per result, or is nil if the lookups are not counted
+	lookups *prometheus.CounterVec
+	// invalidations counts the invalidations per kind of the changed resource, or is nil if the invalidations are not counted
+	invalidations *prometheus.CounterVec
+}
+
+// dependency is a user or a workspace an entry was resolved for
+type dependency struct {
+	workspace bool
+	name      string
+}
+
+type invalidation struct {
+	generation uint64
+	at         time.Time
+}
+
+type cachedEntry struct {
+	entry        *Entry
+	expiresAt    time.Time
+	generation   uint64
+	dependencies []dependency
+}
+
+// NewCache returns a new Cache with the given maximum number of entries and TTL. The given counters, if not nil,
+// must have the `result` and the `kind` labels respectively.
+func NewCache(size int, ttl time.Duration, lookups, invalidations *prometheus.CounterVec) *Cache {
+	return &Cache{
+		cache:         lru.New(size),
+		size:          size,
+		ttl:           ttl,
+		now:           time.Now,
+		invalidated:   map[dependency]invalidation{},
+		lookups:       lookups,
+		invalidations: invalidations,
+	}
+}
+
+// Get returns the cached entry with the given key, if any. It also returns the current generation of the cache,
+// which must be given when adding the entry resolved after a miss.
+func (c *Cache) Get(key Key) (*Entry, uint64, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if value, found := c.cache.Get(key); found {
+		cached := value.(*cachedEntry)
+		if c.now().Before(cached.expiresAt) && !c.isInvalidated(cached) {
+			c.count(LookupHit)
+			return cached.entry, c.generation, true
+		}
+		c.cache.Remove(key)
+	}
+	c.count(LookupMiss)
+	return nil, c.generation, false
+}
+
+func (c *Cache) isInvalidated(cached *cachedEntry) bool {
+	if cached.generation < c.minGeneration {
+		return true
+	}
+	for _, dep := range cached.dependencies {
+		if inv, found := c.invalidated[dep]; found && inv.generation > cached.generation {
+			return true
+		}
+	}
+	return false
+}
+
+// Add caches the given entry, which was resolved after Get returned the given generation. Besides the user and the
+// workspaces of the key and of the entry, the entry is invalidated when any of the given workspaces changes, eg. the
+// parent workspaces whose SpaceBindings are inherited.
+func (c *Cache) Add(key Key, entry *Entry, generation uint64, workspaces ...string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if generation < c.minGeneration {
+		return
+	}
+	users := []string{key.UserID, key.Username, entry.Username}
+	workspaces = append(workspaces, key.Workspace)
+	for _, workspace := range entry.Workspaces {
+		workspaces = append(workspaces, workspace.Name)
+	}
+	c.cache.Add(key, &cachedEntry{
+		entry:        entry,
+		expiresAt:    c.now().Add(c.ttl),
+		generation:   generation,
+		dependencies: dependencies(users, workspaces),
+	})
+}
+
+// Invalidate invalidates the entries of the given users and workspaces after a change of a resource of the given kind
+func (c *Cache) Invalidate(kind string, users, workspaces []string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.generation++
+	now := c.now()
+	for _, dep := range dependencies(users, workspaces) {
+		c.invalidated[dep] = invalidation{
+			generation: c.generation,
+			at:         now,
+		}
+	}
+	if len(c.invalidated) > c.size {
+		// forget the invalidations older than the TTL: the entries resolved before them have expired by now, and the
+		// few which have not are not trusted anymore
+		for dep, inv := range c.invalidated {
+			if now.Sub(inv.at) >= c.ttl {
+				delete(c.invalidated, dep)
+				if inv.generation > c.minGeneration {
+					c.minGeneration = inv.generation
+				}
+			}
+		}
+	}
+	c.countInvalidation(kind)
+}
+
+// InvalidateAll removes all the entries after a change of a resource of the given kind
+func (c *Cache) InvalidateAll(kind string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.generation++
+	c.minGeneration = c.generation
+	c.invalidated = map[dependency]invalidation{}
+	c.cache.Clear()
+	c.countInvalidation(kind)
+}
+
+// EventHandler returns a handler of the informer events which invalidates the entries of the users and workspaces
+// related to each resource which is created, updated or deleted
+func (c *Cache) EventHandler() cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			c.invalidateRelated(kindOf(obj), obj)
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldMeta, oldErr := meta.Accessor(oldObj)
+			newMeta, newErr := meta.Accessor(newObj)
+			if oldErr == nil && newErr == nil && oldMeta.GetResourceVersion() == newMeta.GetResourceVersion() {
+				// periodic resync, nothing changed
+				return
+			}
+			// the users and workspaces related to the previous version of the resource are invalidated too, eg. when a
+			// Space is moved to another parent
+			c.invalidateRelated(kindOf(newObj), oldObj, newObj)
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			c.invalidateRelated(kindOf(obj), obj)
+		},
+	}
+}
+
+// invalidateRelated invalidates the entries of the users and workspaces related to the given objects, or all the
+// entries if the objects are not of a known kind
+func (c *Cache) invalidateRelated(kind string, objs ...interface{}) {
+	var users, workspaces []string
+	for _, obj := range objs {
+		switch o := toTyped(obj).(type) {
+		case *toolchainv1alpha1.MasterUserRecord:
+			users = append(users, o.Name, o.Spec.PropagatedClaims.Sub)
+		case *toolchainv1alpha1.UserSignup:
+			users = append(users, o.Name, o.Status.CompliantUsername, o.Spec.IdentityClaims.Sub, o.Spec.IdentityClaims.PreferredUsername)
+		case *toolchainv1alpha1.Space:
+			workspaces = append(workspaces, o.Name, o.Spec.ParentSpace)
+		case *toolchainv1alpha1.SpaceBinding:
+			users = append(users, o.Spec.MasterUserRecord)
+			workspaces = append(workspaces, o.Spec.Space)
+		default:
+			c.InvalidateAll(kind)
+			return
+		}
+	}
+	c.Invalidate(kind, users, workspaces)
+}
+
+func (c *Cache) count(result string) {
+	if c.lookups != nil {
+		c.lookups.WithLabelValues(result).Inc()
+	}
+}
+
+func (c *Cache) countInvalidation(kind string) {
+	if c.invalidations != nil {
+		c.invalidations.WithLabelValues(kind).Inc()
+	}
+}
+
+// dependencies returns the dependencies on the given users and workspaces, ignoring the empty names
+func dependencies(users, workspaces []string) []dependency {
+	deps := make([]dependency, 0, len(users)+len(workspaces))
+	for _, user := range users {
+		if user != "" {
+			deps = append(deps, dependency{name: user})
+		}
+	}
+	for _, workspace := range workspaces {
+		if workspace != "" {
+			deps = append(deps, dependency{workspace: true, name: workspace})
+		}
+	}
+	return deps
+}
+
+// toTyped returns the typed resource of the given object of the informers, or nil if it cannot be converted
+func toTyped(obj interface{}) interface{} {
+	o, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return obj
+	}
+	var typed interface{}
+	switch o.GetKind() {
+	case "MasterUserRecord":
+		typed = &toolchainv1alpha1.MasterUserRecord{}
+	case "UserSignup":
+		typed = &toolchainv1alpha1.UserSignup{}
+	case "Space":
+		typed = &toolchainv1alpha1.Space{}
+	case "SpaceBinding":
+		typed = &toolchainv1alpha1.SpaceBinding{}
+	default:
+		return nil
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(o.UnstructuredContent(), typed); err != nil {
+		return nil
+	}
+	return typed
+}
+
+func kindOf(obj interface{}) string {
+	if o, ok := obj.(runtime.Object); ok {
+		if kind := o.GetObjectKind().GroupVersionKind().Kind; kind != "" {
+			return kind
+		}
+	}
+	return "unknown"
+}
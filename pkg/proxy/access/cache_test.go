@@ -0,0 +1,376 @@
+package access
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+
+	"github.com/prometheus/client_golang/prometheus"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestCache(t *testing.T) {
+	key := Key{UserID: "528d76ff", Username: "john", Workspace: "john-dev"}
+	entry := &Entry{
+		Username:   "john",
+		Workspaces: []toolchainv1alpha1.Workspace{{ObjectMeta: metav1.ObjectMeta{Name: "john-dev"}}},
+	}
+	newCache := func() (*Cache, *prometheus.CounterVec, *prometheus.CounterVec) {
+		lookups := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "lookups"}, []string{"result"})
+		invalidations := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "invalidations"}, []string{"kind"})
+		return NewCache(10, time.Minute, lookups, invalidations), lookups, invalidations
+	}
+
+	t.Run("hit", func(t *testing.T) {
+		// given
+		c, lookups, _ := newCache()
+		_, generation, found := c.Get(key)
+		require.False(t, found)
+		c.Add(key, entry, generation)
+
+		// when
+		cached, _, found := c.Get(key)
+
+		// then
+		require.True(t, found)
+		assert.Same(t, entry, cached)
+		assert.InDelta(t, 1, promtestutil.ToFloat64(lookups.WithLabelValues(LookupHit)), 0)
+		assert.InDelta(t, 1, promtestutil.ToFloat64(lookups.WithLabelValues(LookupMiss)), 0)
+
+		t.Run("other workspace", func(t *testing.T) {
+			_, _, found := c.Get(Key{UserID: "528d76ff", Username: "john", Workspace: "jane-dev"})
+
+			assert.False(t, found)
+		})
+
+		t.Run("other proxy plugin", func(t *testing.T) {
+			_, _, found := c.Get(Key{UserID: "528d76ff", Username: "john", Workspace: "john-dev", ProxyPlugin: "tekton-results"})
+
+			assert.False(t, found)
+		})
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		// given
+		c, _, _ := newCache()
+		now := time.Now()
+		c.now = func() time.Time { return now }
+		_, generation, _ := c.Get(key)
+		c.Add(key, entry, generation)
+		now = now.Add(time.Minute)
+
+		// when
+		_, _, found := c.Get(key)
+
+		// then
+		assert.False(t, found)
+	})
+
+	t.Run("invalidated", func(t *testing.T) {
+		tests := map[string]struct {
+			users       []string
+			workspaces  []string
+			invalidated bool
+		}{
+			"user ID": {
+				users:       []string{"528d76ff"},
+				invalidated: true,
+			},
+			"username": {
+				users:       []string{"john"},
+				invalidated: true,
+			},
+			"workspace": {
+				workspaces:  []string{"john-dev"},
+				invalidated: true,
+			},
+			"parent workspace": {
+				workspaces:  []string{"john-parent"},
+				invalidated: true,
+			},
+			"other user and workspace": {
+				users:       []string{"jane"},
+				workspaces:  []string{"jane-dev"},
+				invalidated: false,
+			},
+		}
+		for name, tc := range tests {
+			t.Run(name, func(t *testing.T) {
+				// given
+				c, _, invalidations := newCache()
+				_, generation, _ := c.Get(key)
+				c.Add(key, entry, generation, "john-parent")
+
+				// when
+				c.Invalidate("SpaceBinding", tc.users, tc.workspaces)
+
+				// then
+				_, _, found := c.Get(key)
+				assert.Equal(t, tc.invalidated, !found)
+				assert.InDelta(t, 1, promtestutil.ToFloat64(invalidations.WithLabelValues("SpaceBinding")), 0)
+			})
+		}
+	})
+
+	t.Run("all invalidated", func(t *testing.T) {
+		// given
+		c, _, invalidations := newCache()
+		_, generation, _ := c.Get(key)
+		c.Add(key, entry, generation)
+
+		// when
+		c.InvalidateAll("unknown")
+
+		// then
+		_, _, found := c.Get(key)
+		assert.False(t, found)
+		assert.InDelta(t, 1, promtestutil.ToFloat64(invalidations.WithLabelValues("unknown")), 0)
+	})
+
+	t.Run("not cached when invalidated while resolving", func(t *testing.T) {
+		// given
+		c, _, _ := newCache()
+		_, generation, _ := c.Get(key)
+		c.Invalidate("Space", nil, []string{"john-dev"})
+
+		// when
+		c.Add(key, entry, generation)
+
+		// then
+		_, _, found := c.Get(key)
+		assert.False(t, found)
+	})
+
+	t.Run("cached when another workspace is invalidated while resolving", func(t *testing.T) {
+		// given
+		c, _, _ := newCache()
+		_, generation, _ := c.Get(key)
+		c.Invalidate("Space", nil, []string{"jane-dev"})
+
+		// when
+		c.Add(key, entry, generation)
+
+		// then
+		_, _, found := c.Get(key)
+		assert.True(t, found)
+	})
+
+	t.Run("old invalidations forgotten", func(t *testing.T) {
+		// given
+		c, _, _ := newCache()
+		now := time.Now()
+		c.now = func() time.Time { return now }
+		_, generation, _ := c.Get(key)
+		for i := 0; i <= 10; i++ {
+			c.Invalidate("Space", nil, []string{fmt.Sprintf("space-%d", i)})
+		}
+		now = now.Add(time.Minute)
+
+		// when
+		c.Invalidate("Space", nil, []string{"jane-dev"})
+
+		// then
+		assert.Len(t, c.invalidated, 1)
+		// the access resolved before the forgotten invalidations is not trusted anymore
+		c.Add(key, entry, generation)
+		_, generation, found := c.Get(key)
+		assert.False(t, found)
+		c.Add(key, entry, generation)
+		_, _, found = c.Get(key)
+		assert.True(t, found)
+	})
+
+	t.Run("without metrics", func(t *testing.T) {
+		c := NewCache(10, time.Minute, nil, nil)
+		_, generation, _ := c.Get(key)
+		c.Add(key, entry, generation)
+		c.Invalidate("Space", []string{"john"}, nil)
+		c.InvalidateAll("unknown")
+	})
+}
+
+func TestCacheEventHandler(t *testing.T) {
+	space := func(name, parent, resourceVersion string) *toolchainv1alpha1.Space {
+		return &toolchainv1alpha1.Space{
+			TypeMeta:   metav1.TypeMeta{Kind: "Space"},
+			ObjectMeta: metav1.ObjectMeta{Name: name, ResourceVersion: resourceVersion},
+			Spec:       toolchainv1alpha1.SpaceSpec{ParentSpace: parent},
+		}
+	}
+	spaceBinding := func(mur, space string) *toolchainv1alpha1.SpaceBinding {
+		return &toolchainv1alpha1.SpaceBinding{
+			TypeMeta:   metav1.TypeMeta{Kind: "SpaceBinding"},
+			ObjectMeta: metav1.ObjectMeta{Name: mur + "-" + space},
+			Spec:       toolchainv1alpha1.SpaceBindingSpec{MasterUserRecord: mur, Space: space},
+		}
+	}
+	mur := func(name, resourceVersion string) *toolchainv1alpha1.MasterUserRecord {
+		return &toolchainv1alpha1.MasterUserRecord{
+			TypeMeta:   metav1.TypeMeta{Kind: "MasterUserRecord"},
+			ObjectMeta: metav1.ObjectMeta{Name: name, ResourceVersion: resourceVersion},
+		}
+	}
+	userSignup := &toolchainv1alpha1.UserSignup{
+		TypeMeta:   metav1.TypeMeta{Kind: "UserSignup"},
+		ObjectMeta: metav1.ObjectMeta{Name: "john"},
+		Spec: toolchainv1alpha1.UserSignupSpec{
+			IdentityClaims: toolchainv1alpha1.IdentityClaimsEmbedded{
+				PropagatedClaims:  toolchainv1alpha1.PropagatedClaims{Sub: "528d76ff"},
+				PreferredUsername: "john@example.com",
+			},
+		},
+		Status: toolchainv1alpha1.UserSignupStatus{CompliantUsername: "john"},
+	}
+	unstructuredSpace := &unstructured.Unstructured{}
+	unstructuredSpace.SetKind("Space")
+	unstructuredSpace.SetName("john-dev")
+
+	tests := map[string]struct {
+		event               func(handler cache.ResourceEventHandler)
+		expectedInvalidated bool
+		expectedKind        string
+	}{
+		"space added": {
+			event: func(handler cache.ResourceEventHandler) {
+				handler.OnAdd(space("john-dev", "", "1"))
+			},
+			expectedInvalidated: true,
+			expectedKind:        "Space",
+		},
+		"sub-space added": {
+			event: func(handler cache.ResourceEventHandler) {
+				handler.OnAdd(space("john-test", "john-dev", "1"))
+			},
+			expectedInvalidated: true,
+			expectedKind:        "Space",
+		},
+		"parent space updated": {
+			event: func(handler cache.ResourceEventHandler) {
+				handler.OnUpdate(space("john-parent", "", "1"), space("john-parent", "", "2"))
+			},
+			expectedInvalidated: true,
+			expectedKind:        "Space",
+		},
+		"space moved from the parent space": {
+			event: func(handler cache.ResourceEventHandler) {
+				handler.OnUpdate(space("jane-dev", "john-dev", "1"), space("jane-dev", "", "2"))
+			},
+			expectedInvalidated: true,
+			expectedKind:        "Space",
+		},
+		"other space updated": {
+			event: func(handler cache.ResourceEventHandler) {
+				handler.OnUpdate(space("jane-dev", "", "1"), space("jane-dev", "", "2"))
+			},
+			expectedInvalidated: false,
+			expectedKind:        "Space",
+		},
+		"space resynced": {
+			event: func(handler cache.ResourceEventHandler) {
+				handler.OnUpdate(space("john-dev", "", "1"), space("john-dev", "", "1"))
+			},
+			expectedInvalidated: false,
+		},
+		"space deleted": {
+			event: func(handler cache.ResourceEventHandler) {
+				handler.OnDelete(space("john-dev", "", "2"))
+			},
+			expectedInvalidated: true,
+			expectedKind:        "Space",
+		},
+		"space deleted with unknown final state": {
+			event: func(handler cache.ResourceEventHandler) {
+				handler.OnDelete(cache.DeletedFinalStateUnknown{Key: "john-dev", Obj: space("john-dev", "", "2")})
+			},
+			expectedInvalidated: true,
+			expectedKind:        "Space",
+		},
+		"unstructured space added": {
+			event: func(handler cache.ResourceEventHandler) {
+				handler.OnAdd(unstructuredSpace)
+			},
+			expectedInvalidated: true,
+			expectedKind:        "Space",
+		},
+		"space binding of the user added": {
+			event: func(handler cache.ResourceEventHandler) {
+				handler.OnAdd(spaceBinding("john", "jane-dev"))
+			},
+			expectedInvalidated: true,
+			expectedKind:        "SpaceBinding",
+		},
+		"space binding of the workspace added": {
+			event: func(handler cache.ResourceEventHandler) {
+				handler.OnAdd(spaceBinding("jane", "john-dev"))
+			},
+			expectedInvalidated: true,
+			expectedKind:        "SpaceBinding",
+		},
+		"other space binding added": {
+			event: func(handler cache.ResourceEventHandler) {
+				handler.OnAdd(spaceBinding("jane", "jane-dev"))
+			},
+			expectedInvalidated: false,
+			expectedKind:        "SpaceBinding",
+		},
+		"masteruserrecord of the user updated": {
+			event: func(handler cache.ResourceEventHandler) {
+				handler.OnUpdate(mur("john", "1"), mur("john", "2"))
+			},
+			expectedInvalidated: true,
+			expectedKind:        "MasterUserRecord",
+		},
+		"other masteruserrecord updated": {
+			event: func(handler cache.ResourceEventHandler) {
+				handler.OnUpdate(mur("jane", "1"), mur("jane", "2"))
+			},
+			expectedInvalidated: false,
+			expectedKind:        "MasterUserRecord",
+		},
+		"usersignup of the user deleted": {
+			event: func(handler cache.ResourceEventHandler) {
+				handler.OnDelete(userSignup)
+			},
+			expectedInvalidated: true,
+			expectedKind:        "UserSignup",
+		},
+		"resource of an unknown kind added": {
+			event: func(handler cache.ResourceEventHandler) {
+				handler.OnAdd(&toolchainv1alpha1.ToolchainStatus{TypeMeta: metav1.TypeMeta{Kind: "ToolchainStatus"}})
+			},
+			expectedInvalidated: true,
+			expectedKind:        "ToolchainStatus",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// given
+			invalidations := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "invalidations"}, []string{"kind"})
+			c := NewCache(10, time.Minute, nil, invalidations)
+			key := Key{UserID: "528d76ff", Username: "john@example.com", Workspace: "john-dev"}
+			_, generation, _ := c.Get(key)
+			c.Add(key, &Entry{
+				Username:   "john",
+				Workspaces: []toolchainv1alpha1.Workspace{{ObjectMeta: metav1.ObjectMeta{Name: "john-dev"}}},
+			}, generation, "john-parent")
+
+			// when
+			tc.event(c.EventHandler())
+
+			// then
+			_, _, found := c.Get(key)
+			assert.Equal(t, tc.expectedInvalidated, !found)
+			if tc.expectedKind != "" {
+				assert.InDelta(t, 1, promtestutil.ToFloat64(invalidations.WithLabelValues(tc.expectedKind)), 0)
+			}
+		})
+	}
+}
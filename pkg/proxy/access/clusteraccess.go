@@ -2,6 +2,8 @@ package access
 
 import (
 	"net/url"
+
+	"k8s.io/client-go/rest"
 )
 
 // ClusterAccess holds information needed to access user namespaces in a member cluster for the specific user via impersonation
@@ -12,14 +14,34 @@ type ClusterAccess struct { // nolint:revive
 	impersonatorToken string
 	// username is the id of the user to use for impersonation
 	username string
+	// memberName is the name of the member cluster whose API server receives the requests, if any
+	memberName string
+	// restConfig is the config of the member cluster whose API server receives the requests, if any
+	restConfig *rest.Config
+}
+
+// Option configures a ClusterAccess
+type Option func(a *ClusterAccess)
+
+// WithMemberCluster sets the member cluster whose API server receives the requests. The requests are then sent with the
+// pooled transport of the member cluster, which verifies the certificate of the API server with the CA of the given config.
+func WithMemberCluster(name string, restConfig *rest.Config) Option {
+	return func(a *ClusterAccess) {
+		a.memberName = name
+		a.restConfig = restConfig
+	}
 }
 
-func NewClusterAccess(apiURL url.URL, impersonatorToken, username string) *ClusterAccess {
-	return &ClusterAccess{
+func NewClusterAccess(apiURL url.URL, impersonatorToken, username string, options ...Option) *ClusterAccess {
+	a := &ClusterAccess{
 		apiURL:            apiURL,
 		impersonatorToken: impersonatorToken,
 		username:          username,
 	}
+	for _, o := range options {
+		o(a)
+	}
+	return a
 }
 
 func (a *ClusterAccess) APIURL() url.URL {
@@ -33,3 +55,15 @@ func (a *ClusterAccess) ImpersonatorToken() string {
 func (a *ClusterAccess) Username() string {
 	return a.username
 }
+
+// MemberName returns the name of the member cluster whose API server receives the requests, or an empty string if
+// the requests are sent to another endpoint (eg. the route of a proxy plugin)
+func (a *ClusterAccess) MemberName() string {
+	return a.memberName
+}
+
+// RestConfig returns the config of the member cluster whose API server receives the requests, or nil if the requests
+// are sent to another endpoint (eg. the route of a proxy plugin)
+func (a *ClusterAccess) RestConfig() *rest.Config {
+	return a.restConfig
+}
@@ -0,0 +1,149 @@
+package access
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http2"
+	"k8s.io/client-go/rest"
+)
+
+// The settings of the pooled transports. The connections are kept alive and reused across the requests, and there is
+// no timeout once the connection is established, since the watch, exec and logs requests may last for a long time.
+const (
+	dialTimeout           = 30 * time.Second
+	keepAlive             = 30 * time.Second
+	tlsHandshakeTimeout   = 10 * time.Second
+	idleConnTimeout       = 90 * time.Second
+	expectContinueTimeout = 1 * time.Second
+	maxIdleConnsPerHost   = 100
+	// the HTTP/2 connections are checked with a ping when no frame was received for a while, so that the broken
+	// connections are closed instead of being reused by the new requests
+	http2ReadIdleTimeout = 30 * time.Second
+	http2PingTimeout     = 15 * time.Second
+)
+
+// TransportPool holds the transports of the requests to the API servers of the member clusters, so that their connections
+// are reused across the requests. The requests are multiplexed over HTTP/2 connections when the API servers support it.
+// Since the upgrades (exec, attach, port-forward) are not supported over HTTP/2, each member cluster has a second transport
+// restricted to HTTP/1.1 for the upgrade requests. The transports of a member cluster
+// are rebuilt when its config changes.
+type TransportPool struct {
+	lock       sync.Mutex
+	transports map[transportKey]*pooledTransport
+}
+
+type transportKey struct {
+	member    string
+	http1Only bool
+}
+
+type pooledTransport struct {
+	// fingerprint identifies the config the transport was built from
+	fingerprint string
+	transport   *http.Transport
+}
+
+// NewTransportPool returns a new, empty TransportPool
+func NewTransportPool() *TransportPool {
+	return &TransportPool{
+		transports: map[transportKey]*pooledTransport{},
+	}
+}
+
+// Get returns the transport of the requests to the API server of the given member cluster, built from the given config
+// of the member cluster, and restricted to HTTP/1.1 if required
+func (p *TransportPool) Get(member string, cfg *rest.Config, http1Only bool) (*http.Transport, error) {
+	key := transportKey{member: member, http1Only: http1Only}
+	fingerprint := configFingerprint(cfg)
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	pooled, found := p.transports[key]
+	if found && pooled.fingerprint == fingerprint {
+		return pooled.transport, nil
+	}
+	transport, err := newTransport(cfg, http1Only)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create the transport for the member cluster '%s': %w", member, err)
+	}
+	if found {
+		// the config of the member cluster changed, the connections in use are closed once their requests are complete
+		pooled.transport.CloseIdleConnections()
+	}
+	p.transports[key] = &pooledTransport{
+		fingerprint: fingerprint,
+		transport:   transport,
+	}
+	return transport, nil
+}
+
+func newTransport(cfg *rest.Config, http1Only bool) (*http.Transport, error) {
+	tlsConfig, err := rest.TLSConfigFor(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig == nil {
+		// no CA given, the certificate of the API server is verified with the system CAs
+		tlsConfig = &tls.Config{
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+	if http1Only {
+		tlsConfig.NextProtos = []string{"http/1.1"}
+	}
+	proxy := http.ProxyFromEnvironment
+	if cfg.Proxy != nil {
+		proxy = cfg.Proxy
+	}
+	dialer := &net.Dialer{
+		Timeout:   dialTimeout,
+		KeepAlive: keepAlive,
+	}
+	transport := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   tlsHandshakeTimeout,
+		IdleConnTimeout:       idleConnTimeout,
+		ExpectContinueTimeout: expectContinueTimeout,
+		MaxIdleConnsPerHost:   maxIdleConnsPerHost,
+	}
+	if http1Only {
+		return transport, nil
+	}
+	h2Transport, err := http2.ConfigureTransports(transport)
+	if err != nil {
+		return nil, err
+	}
+	h2Transport.ReadIdleTimeout = http2ReadIdleTimeout
+	h2Transport.PingTimeout = http2PingTimeout
+	return transport, nil
+}
+
+// configFingerprint returns a hash of the settings of the given config which are used to build the transports
+func configFingerprint(cfg *rest.Config) string {
+	h := sha256.New()
+	tlsCfg := cfg.TLSClientConfig
+	for _, value := range [][]byte{
+		[]byte(cfg.Host),
+		[]byte(fmt.Sprint(tlsCfg.Insecure)),
+		[]byte(tlsCfg.ServerName),
+		[]byte(tlsCfg.CertFile),
+		[]byte(tlsCfg.KeyFile),
+		[]byte(tlsCfg.CAFile),
+		tlsCfg.CertData,
+		tlsCfg.KeyData,
+		tlsCfg.CAData,
+	} {
+		// the length prevents the collisions between the concatenations of different values
+		_, _ = fmt.Fprintf(h, "%d:", len(value))
+		_, _ = h.Write(value)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,208 @@
+package access
+
+import (
+	"encoding/pem"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/rest"
+)
+
+func TestTransportPool(t *testing.T) {
+	// given
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+	caData := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	newConfig := func(caData []byte) *rest.Config {
+		return &rest.Config{
+			Host:            server.URL,
+			BearerToken:     "clusterSAToken",
+			TLSClientConfig: rest.TLSClientConfig{CAData: caData},
+		}
+	}
+	get := func(transport http.RoundTripper) (*http.Response, error) {
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/api", nil)
+		require.NoError(t, err)
+		resp, err := transport.RoundTrip(req)
+		if err == nil {
+			defer resp.Body.Close()
+		}
+		return resp, err
+	}
+
+	t.Run("verified with the CA of the member", func(t *testing.T) {
+		// when
+		transport, err := NewTransportPool().Get("member-1", newConfig(caData), false)
+
+		// then
+		require.NoError(t, err)
+		resp, err := get(transport)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "HTTP/2.0", resp.Proto)
+		assert.Equal(t, maxIdleConnsPerHost, transport.MaxIdleConnsPerHost)
+	})
+
+	t.Run("not verified without the CA of the member", func(t *testing.T) {
+		// when
+		transport, err := NewTransportPool().Get("member-1", newConfig(nil), false)
+
+		// then
+		require.NoError(t, err)
+		_, err = get(transport)
+		require.ErrorContains(t, err, "certificate signed by unknown authority")
+	})
+
+	t.Run("invalid CA", func(t *testing.T) {
+		// when
+		_, err := NewTransportPool().Get("member-1", newConfig([]byte("invalid")), false)
+
+		// then
+		require.ErrorContains(t, err, "unable to create the transport for the member cluster 'member-1'")
+	})
+
+	t.Run("restricted to HTTP/1.1", func(t *testing.T) {
+		// when
+		transport, err := NewTransportPool().Get("member-1", newConfig(caData), true)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []string{"http/1.1"}, transport.TLSClientConfig.NextProtos)
+		resp, err := get(transport)
+		require.NoError(t, err)
+		assert.Equal(t, "HTTP/1.1", resp.Proto)
+	})
+
+	t.Run("pooled", func(t *testing.T) {
+		// given
+		pool := NewTransportPool()
+		transport, err := pool.Get("member-1", newConfig(caData), false)
+		require.NoError(t, err)
+
+		t.Run("same member", func(t *testing.T) {
+			// when
+			cfg := newConfig(caData)
+			cfg.BearerToken = "rotatedToken" // the token is not used by the transport
+			same, err := pool.Get("member-1", cfg, false)
+
+			// then
+			require.NoError(t, err)
+			assert.Same(t, transport, same)
+		})
+
+		t.Run("other member", func(t *testing.T) {
+			// when
+			other, err := pool.Get("member-2", newConfig(caData), false)
+
+			// then
+			require.NoError(t, err)
+			assert.NotSame(t, transport, other)
+		})
+
+		t.Run("HTTP/1.1 transport of the same member", func(t *testing.T) {
+			// when
+			http1, err := pool.Get("member-1", newConfig(caData), true)
+
+			// then
+			require.NoError(t, err)
+			assert.NotSame(t, transport, http1)
+		})
+
+		t.Run("rebuilt when the config changes", func(t *testing.T) {
+			// when
+			cfg := newConfig(caData)
+			cfg.Host = "https://api.member-1.test.org:6443"
+			rebuilt, err := pool.Get("member-1", cfg, false)
+
+			// then
+			require.NoError(t, err)
+			assert.NotSame(t, transport, rebuilt)
+
+			again, err := pool.Get("member-1", cfg, false)
+			require.NoError(t, err)
+			assert.Same(t, rebuilt, again)
+		})
+	})
+}
+
+func TestTransportPoolMultiplexesWatches(t *testing.T) {
+	// given
+	const watches = 50
+	started := make(chan struct{}, watches)
+	release := make(chan struct{})
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		if r.URL.Query().Get("watch") != "true" {
+			return
+		}
+		// stream the events of the watch until it is released
+		_, _ = w.Write([]byte(`{"type":"ADDED"}`))
+		w.(http.Flusher).Flush()
+		started <- struct{}{}
+		<-release
+	}))
+	var connections int32
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&connections, 1)
+		}
+	}
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+	caData := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	transport, err := NewTransportPool().Get("member-1", &rest.Config{Host: server.URL, TLSClientConfig: rest.TLSClientConfig{CAData: caData}}, false)
+	require.NoError(t, err)
+	// open a first connection, so that the watches do not race to open their own connection
+	req, err := http.NewRequest(http.MethodHead, server.URL+"/healthz", nil)
+	require.NoError(t, err)
+	resp, err := transport.RoundTrip(req)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	// when
+	var wg sync.WaitGroup
+	errs := make(chan error, watches)
+	for i := 0; i < watches; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, err := http.NewRequest(http.MethodGet, server.URL+"/api/v1/namespaces/john-dev/pods?watch=true", nil)
+			if err != nil {
+				errs <- err
+				return
+			}
+			resp, err := transport.RoundTrip(req)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer resp.Body.Close()
+			_, err = io.ReadAll(resp.Body)
+			errs <- err
+		}()
+	}
+	for i := 0; i < watches; i++ {
+		<-started
+	}
+
+	// then
+	assert.Equal(t, int32(1), atomic.LoadInt32(&connections))
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+}
@@ -0,0 +1,150 @@
+package audit
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The results of the audit events, used as the `result` label of the metrics
+const (
+	ResultWritten = "written"
+	ResultFailed  = "failed"
+	ResultDropped = "dropped"
+)
+
+// Auditor records the audit events of the proxied requests. The events are redacted, and queued to be written by a
+// worker per sink in the background, so that the sinks do not slow down the requests nor each other. The events are
+// dropped for a sink when its queue is full.
+type Auditor struct {
+	workers    []*sinkWorker
+	redactions []redaction
+	// events counts the events per sink and result, or is nil if the events are not counted
+	events *prometheus.CounterVec
+
+	lock   sync.RWMutex
+	closed bool
+}
+
+// sinkWorker writes the queued events to a sink
+type sinkWorker struct {
+	sink  Sink
+	queue chan *Event
+	done  chan struct{}
+}
+
+// NewAuditor returns a new Auditor which writes the events to the given sinks, after applying the given redactions.
+// Each sink has its own queue of the given size.
+// The given counter, if not nil, must have the `sink` and `result` labels.
+func NewAuditor(sinks []Sink, redactions []configuration.AuditRedaction, queueSize int, events *prometheus.CounterVec) (*Auditor, error) {
+	if len(sinks) == 0 {
+		return nil, errors.New("no sink given when creating Auditor")
+	}
+	r, err := newRedactions(redactions)
+	if err != nil {
+		return nil, err
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+	a := &Auditor{
+		redactions: r,
+		events:     events,
+	}
+	for _, sink := range sinks {
+		w := &sinkWorker{
+			sink:  sink,
+			queue: make(chan *Event, queueSize),
+			done:  make(chan struct{}),
+		}
+		a.workers = append(a.workers, w)
+		go a.run(w)
+	}
+	return a, nil
+}
+
+// NewAuditorFromConfig returns a new Auditor which writes the events to the configured sinks,
+// or nil if no sink is configured
+func NewAuditorFromConfig(cfg configuration.ProxyConfig, events *prometheus.CounterVec) (*Auditor, error) {
+	var sinks []Sink
+	if path := cfg.AuditFile(); path != "" {
+		sink, err := NewFileSink(path)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+	if url := cfg.AuditWebhookURL(); url != "" {
+		sinks = append(sinks, NewWebhookSink(url))
+	}
+	if len(sinks) == 0 {
+		return nil, nil
+	}
+	return NewAuditor(sinks, cfg.AuditRedactions(), cfg.AuditQueueSize(), events)
+}
+
+// Record redacts the given event and queues it to be written to the sinks
+func (a *Auditor) Record(event *Event) {
+	for _, r := range a.redactions {
+		r.apply(event)
+	}
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	if a.closed {
+		return
+	}
+	for _, w := range a.workers {
+		select {
+		case w.queue <- event:
+		default:
+			log.Info(nil, fmt.Sprintf("dropping the audit event '%s' for the %s sink since its queue is full", event.AuditID, w.sink.Name()))
+			a.count(w.sink, ResultDropped)
+		}
+	}
+}
+
+// Close writes the queued events to the sinks and closes them
+func (a *Auditor) Close() error {
+	a.lock.Lock()
+	if a.closed {
+		a.lock.Unlock()
+		return nil
+	}
+	a.closed = true
+	for _, w := range a.workers {
+		close(w.queue)
+	}
+	a.lock.Unlock()
+
+	var closeErr error
+	for _, w := range a.workers {
+		<-w.done
+		if err := w.sink.Close(); err != nil && closeErr == nil {
+			closeErr = fmt.Errorf("unable to close the %s audit sink: %w", w.sink.Name(), err)
+		}
+	}
+	return closeErr
+}
+
+func (a *Auditor) run(w *sinkWorker) {
+	defer close(w.done)
+	for event := range w.queue {
+		if err := w.sink.Write(event); err != nil {
+			log.Error(nil, err, fmt.Sprintf("unable to write the audit event '%s' to the %s sink", event.AuditID, w.sink.Name()))
+			a.count(w.sink, ResultFailed)
+			continue
+		}
+		a.count(w.sink, ResultWritten)
+	}
+}
+
+func (a *Auditor) count(sink Sink, result string) {
+	if a.events != nil {
+		a.events.WithLabelValues(sink.Name(), result).Inc()
+	}
+}
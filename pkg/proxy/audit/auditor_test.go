@@ -0,0 +1,401 @@
+package audit_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/audit"
+
+	"github.com/prometheus/client_golang/prometheus"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewEvent(t *testing.T) {
+	received := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := map[string]struct {
+		method        string
+		path          string
+		expectedEvent audit.Event
+	}{
+		"list pods": {
+			method: http.MethodGet,
+			path:   "/api/v1/namespaces/john-dev/pods",
+			expectedEvent: audit.Event{
+				Verb:       "list",
+				APIVersion: "v1",
+				Resource:   "pods",
+				Namespace:  "john-dev",
+			},
+		},
+		"watch deployments": {
+			method: http.MethodGet,
+			path:   "/apis/apps/v1/namespaces/john-dev/deployments?watch=true",
+			expectedEvent: audit.Event{
+				Verb:       "watch",
+				APIGroup:   "apps",
+				APIVersion: "v1",
+				Resource:   "deployments",
+				Namespace:  "john-dev",
+			},
+		},
+		"exec in pod": {
+			method: http.MethodPost,
+			path:   "/api/v1/namespaces/john-dev/pods/app/exec?command=sh",
+			expectedEvent: audit.Event{
+				Verb:        "create",
+				APIVersion:  "v1",
+				Resource:    "pods",
+				Subresource: "exec",
+				Namespace:   "john-dev",
+				Name:        "app",
+			},
+		},
+		"delete configmap": {
+			method: http.MethodDelete,
+			path:   "/api/v1/namespaces/john-dev/configmaps/settings",
+			expectedEvent: audit.Event{
+				Verb:       "delete",
+				APIVersion: "v1",
+				Resource:   "configmaps",
+				Namespace:  "john-dev",
+				Name:       "settings",
+			},
+		},
+		"non-resource request": {
+			method: http.MethodGet,
+			path:   "/version",
+			expectedEvent: audit.Event{
+				Verb: "get",
+			},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// given
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			req.RemoteAddr = "10.0.0.1:51234"
+			req.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.2")
+			req.Header.Set("User-Agent", "kubectl/v1.25.0")
+
+			// when
+			event := audit.NewEvent(req, "/workspaces/john-dev"+tc.path, received)
+
+			// then
+			assert.NotEmpty(t, event.AuditID)
+			expected := tc.expectedEvent
+			expected.AuditID = event.AuditID
+			expected.Timestamp = received
+			expected.Method = tc.method
+			expected.RequestURI = "/workspaces/john-dev" + tc.path
+			expected.SourceIP = "203.0.113.7"
+			expected.UserAgent = "kubectl/v1.25.0"
+			assert.Equal(t, expected, *event)
+		})
+	}
+}
+
+func TestAuditor(t *testing.T) {
+	log.Init("registration-service-testing")
+
+	t.Run("writes the events to all the sinks", func(t *testing.T) {
+		// given
+		sink1 := &recordingSink{name: "file"}
+		sink2 := &recordingSink{name: "webhook"}
+		events := newEventsCounter()
+		auditor, err := audit.NewAuditor([]audit.Sink{sink1, sink2}, nil, 10, events)
+		require.NoError(t, err)
+
+		// when
+		auditor.Record(&audit.Event{AuditID: "1", User: "john"})
+		auditor.Record(&audit.Event{AuditID: "2", User: "jane"})
+		require.NoError(t, auditor.Close())
+
+		// then
+		for _, sink := range []*recordingSink{sink1, sink2} {
+			require.Len(t, sink.events, 2)
+			assert.Equal(t, "1", sink.events[0].AuditID)
+			assert.Equal(t, "2", sink.events[1].AuditID)
+			assert.True(t, sink.closed)
+		}
+		assert.Equal(t, 2.0, promtestutil.ToFloat64(events.WithLabelValues("file", audit.ResultWritten)))
+		assert.Equal(t, 2.0, promtestutil.ToFloat64(events.WithLabelValues("webhook", audit.ResultWritten)))
+	})
+
+	t.Run("failing sink", func(t *testing.T) {
+		// given
+		failing := &recordingSink{name: "webhook", err: errors.New("connection refused")}
+		working := &recordingSink{name: "file"}
+		events := newEventsCounter()
+		auditor, err := audit.NewAuditor([]audit.Sink{failing, working}, nil, 10, events)
+		require.NoError(t, err)
+
+		// when
+		auditor.Record(&audit.Event{AuditID: "1"})
+		require.NoError(t, auditor.Close())
+
+		// then
+		assert.Len(t, working.events, 1)
+		assert.Equal(t, 1.0, promtestutil.ToFloat64(events.WithLabelValues("webhook", audit.ResultFailed)))
+		assert.Equal(t, 1.0, promtestutil.ToFloat64(events.WithLabelValues("file", audit.ResultWritten)))
+	})
+
+	t.Run("drops the events when the queue is full", func(t *testing.T) {
+		// given
+		sink := &recordingSink{name: "file", blocked: make(chan struct{})}
+		events := newEventsCounter()
+		auditor, err := audit.NewAuditor([]audit.Sink{sink}, nil, 1, events)
+		require.NoError(t, err)
+
+		// when
+		auditor.Record(&audit.Event{AuditID: "1"}) // taken by the worker, which is blocked by the sink
+		require.Eventually(t, func() bool { return sink.writing() }, time.Second, 10*time.Millisecond)
+		auditor.Record(&audit.Event{AuditID: "2"}) // queued
+		auditor.Record(&audit.Event{AuditID: "3"}) // dropped
+		close(sink.blocked)
+		require.NoError(t, auditor.Close())
+
+		// then
+		require.Len(t, sink.events, 2)
+		assert.Equal(t, "2", sink.events[1].AuditID)
+		assert.Equal(t, 1.0, promtestutil.ToFloat64(events.WithLabelValues("file", audit.ResultDropped)))
+	})
+
+	t.Run("slow sink does not stall the other sinks", func(t *testing.T) {
+		// given
+		slow := &recordingSink{name: "webhook", blocked: make(chan struct{})}
+		fast := &recordingSink{name: "file"}
+		events := newEventsCounter()
+		auditor, err := audit.NewAuditor([]audit.Sink{slow, fast}, nil, 1, events)
+		require.NoError(t, err)
+
+		// when
+		auditor.Record(&audit.Event{AuditID: "1"}) // taken by the worker of the slow sink, which is blocked
+		require.Eventually(t, func() bool { return slow.writing() }, time.Second, 10*time.Millisecond)
+		auditor.Record(&audit.Event{AuditID: "2"}) // queued for the slow sink
+		auditor.Record(&audit.Event{AuditID: "3"}) // dropped for the slow sink only
+
+		// then
+		require.Eventually(t, func() bool { return len(fast.recorded()) == 3 }, time.Second, 10*time.Millisecond)
+		close(slow.blocked)
+		require.NoError(t, auditor.Close())
+		assert.Len(t, slow.events, 2)
+		assert.Equal(t, 1.0, promtestutil.ToFloat64(events.WithLabelValues("webhook", audit.ResultDropped)))
+		assert.Equal(t, 0.0, promtestutil.ToFloat64(events.WithLabelValues("file", audit.ResultDropped)))
+		assert.Equal(t, 3.0, promtestutil.ToFloat64(events.WithLabelValues("file", audit.ResultWritten)))
+	})
+
+	t.Run("ignores the events after being closed", func(t *testing.T) {
+		// given
+		sink := &recordingSink{name: "file"}
+		auditor, err := audit.NewAuditor([]audit.Sink{sink}, nil, 10, nil)
+		require.NoError(t, err)
+		require.NoError(t, auditor.Close())
+
+		// when
+		auditor.Record(&audit.Event{AuditID: "1"})
+
+		// then
+		assert.Empty(t, sink.events)
+		require.NoError(t, auditor.Close())
+	})
+
+	t.Run("no sink", func(t *testing.T) {
+		_, err := audit.NewAuditor(nil, nil, 10, nil)
+		require.EqualError(t, err, "no sink given when creating Auditor")
+	})
+}
+
+func TestRedactions(t *testing.T) {
+	newEvent := func() *audit.Event {
+		return &audit.Event{
+			AuditID:    "1",
+			User:       "john@email.tld",
+			UserID:     "f:528d76ff:john",
+			Workspace:  "john-dev",
+			RequestURI: "/workspaces/john-dev/api/v1/namespaces/john-dev/pods?token=s3cr3t&limit=500",
+			SourceIP:   "203.0.113.7",
+		}
+	}
+
+	t.Run("redacts the fields", func(t *testing.T) {
+		// given
+		sink := &recordingSink{name: "file"}
+		auditor, err := audit.NewAuditor([]audit.Sink{sink}, []configuration.AuditRedaction{
+			{Field: "sourceIP"},
+			{Field: "user", Pattern: "@.*$"},
+			{Field: "requestURI", Pattern: "token=[^&]*"},
+			{Field: "error"}, // empty values are not redacted
+		}, 10, nil)
+		require.NoError(t, err)
+
+		// when
+		auditor.Record(newEvent())
+		require.NoError(t, auditor.Close())
+
+		// then
+		require.Len(t, sink.events, 1)
+		expected := newEvent()
+		expected.SourceIP = audit.Redacted
+		expected.User = "john" + audit.Redacted
+		expected.RequestURI = "/workspaces/john-dev/api/v1/namespaces/john-dev/pods?" + audit.Redacted + "&limit=500"
+		assert.Equal(t, expected, sink.events[0])
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		_, err := audit.NewAuditor([]audit.Sink{&recordingSink{}}, []configuration.AuditRedaction{{Field: "password"}}, 10, nil)
+		require.EqualError(t, err, "unknown field 'password' in audit redactions, expected one of: "+
+			"compliantUsername, error, name, namespace, requestURI, sourceIP, user, userAgent, userID, workspace")
+	})
+
+	t.Run("invalid pattern", func(t *testing.T) {
+		_, err := audit.NewAuditor([]audit.Sink{&recordingSink{}}, []configuration.AuditRedaction{{Field: "user", Pattern: "("}}, 10, nil)
+		require.EqualError(t, err, "invalid pattern for field 'user' in audit redactions: error parsing regexp: missing closing ): `(`")
+	})
+}
+
+func TestFileSink(t *testing.T) {
+	// given
+	path := filepath.Join(t.TempDir(), "audit.log")
+	sink, err := audit.NewFileSink(path)
+	require.NoError(t, err)
+
+	// when
+	require.NoError(t, sink.Write(&audit.Event{AuditID: "1", User: "john", StatusCode: http.StatusOK}))
+	require.NoError(t, sink.Write(&audit.Event{AuditID: "2", User: "jane", StatusCode: http.StatusForbidden}))
+	require.NoError(t, sink.Close())
+
+	// then
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	var lines []audit.Event
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		event := audit.Event{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		lines = append(lines, event)
+	}
+	require.Len(t, lines, 2)
+	assert.Equal(t, "john", lines[0].User)
+	assert.Equal(t, http.StatusForbidden, lines[1].StatusCode)
+
+	t.Run("appends to the existing file", func(t *testing.T) {
+		sink, err := audit.NewFileSink(path)
+		require.NoError(t, err)
+		require.NoError(t, sink.Write(&audit.Event{AuditID: "3"}))
+		require.NoError(t, sink.Close())
+
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Contains(t, string(content), `"auditID":"1"`)
+		assert.Contains(t, string(content), `"auditID":"3"`)
+	})
+
+	t.Run("invalid path", func(t *testing.T) {
+		_, err := audit.NewFileSink(filepath.Join(t.TempDir(), "missing", "audit.log"))
+		require.ErrorContains(t, err, "unable to open the audit file")
+	})
+}
+
+func TestWebhookSink(t *testing.T) {
+	// given
+	var received []audit.Event
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		event := audit.Event{}
+		assert.NoError(t, json.Unmarshal(body, &event))
+		received = append(received, event)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	sink := audit.NewWebhookSink(server.URL)
+
+	t.Run("posts the event", func(t *testing.T) {
+		err := sink.Write(&audit.Event{AuditID: "1", User: "john"})
+
+		require.NoError(t, err)
+		require.Len(t, received, 1)
+		assert.Equal(t, "john", received[0].User)
+	})
+
+	t.Run("unexpected status", func(t *testing.T) {
+		status = http.StatusServiceUnavailable
+
+		err := sink.Write(&audit.Event{AuditID: "2"})
+
+		require.EqualError(t, err, "unexpected response status from the audit webhook: 503 Service Unavailable")
+	})
+
+	require.NoError(t, sink.Close())
+}
+
+func newEventsCounter() *prometheus.CounterVec {
+	return prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_audit_events_total"}, []string{"sink", "result"})
+}
+
+// recordingSink records the events in memory
+type recordingSink struct {
+	name    string
+	err     error
+	blocked chan struct{}
+
+	lock    sync.Mutex
+	inWrite bool
+	events  []*audit.Event
+	closed  bool
+}
+
+func (s *recordingSink) Name() string {
+	return s.name
+}
+
+func (s *recordingSink) Write(event *audit.Event) error {
+	s.lock.Lock()
+	s.inWrite = true
+	s.lock.Unlock()
+	if s.blocked != nil {
+		<-s.blocked
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.events = append(s.events, event)
+	return nil
+}
+
+func (s *recordingSink) writing() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.inWrite
+}
+
+func (s *recordingSink) recorded() []*audit.Event {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.events
+}
+
+func (s *recordingSink) Close() error {
+	s.closed = true
+	return nil
+}
@@ -0,0 +1,82 @@
+package audit
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/requestinfo"
+
+	"github.com/google/uuid"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+)
+
+// Event is the audit event of a proxied request
+type Event struct {
+	// AuditID is the unique ID of the event
+	AuditID   string    `json:"auditID"`
+	Timestamp time.Time `json:"timestamp"`
+	// User is the username of the authenticated user
+	User string `json:"user,omitempty"`
+	// UserID is the subject of the authenticated user
+	UserID string `json:"userID,omitempty"`
+	// CompliantUsername is the username impersonated on the member cluster
+	CompliantUsername string `json:"compliantUsername,omitempty"`
+	// APITokenID is the ID of the API token used to authenticate the request, if any
+	APITokenID string `json:"apiTokenID,omitempty"`
+	Workspace  string `json:"workspace,omitempty"`
+	// Member is the host of the API server of the member cluster to which the request was forwarded
+	Member string `json:"member,omitempty"`
+	// Plugin is the name of the proxy plugin to which the request was forwarded, if any
+	Plugin string `json:"plugin,omitempty"`
+
+	Verb        string `json:"verb,omitempty"`
+	APIGroup    string `json:"apiGroup,omitempty"`
+	APIVersion  string `json:"apiVersion,omitempty"`
+	Resource    string `json:"resource,omitempty"`
+	Subresource string `json:"subresource,omitempty"`
+	Namespace   string `json:"namespace,omitempty"`
+	Name        string `json:"name,omitempty"`
+
+	Method     string `json:"method"`
+	RequestURI string `json:"requestURI"`
+	SourceIP   string `json:"sourceIP,omitempty"`
+	UserAgent  string `json:"userAgent,omitempty"`
+
+	StatusCode int `json:"statusCode"`
+	// DurationMillis is the time taken to handle the request, in milliseconds. For the long-running requests
+	// (watch, exec, port-forward, etc.), it includes the whole duration of the connection.
+	DurationMillis int64 `json:"durationMillis"`
+	// Error is the error returned by the proxy, if the request was rejected
+	Error string `json:"error,omitempty"`
+}
+
+// NewEvent returns a new event for the given request, received at the given time. The path of the request is
+// expected to be relative to the API server of the member cluster, ie, without the workspace and plugin segments.
+func NewEvent(req *http.Request, requestURI string, received time.Time) *Event {
+	event := &Event{
+		AuditID:    uuid.NewString(),
+		Timestamp:  received.UTC(),
+		Method:     req.Method,
+		RequestURI: requestURI,
+		SourceIP:   sourceIP(req),
+		UserAgent:  req.UserAgent(),
+	}
+	if info, err := requestinfo.New(req); err == nil {
+		event.Verb = info.Verb
+		event.APIGroup = info.APIGroup
+		event.APIVersion = info.APIVersion
+		event.Resource = info.Resource
+		event.Subresource = info.Subresource
+		event.Namespace = info.Namespace
+		event.Name = info.Name
+	}
+	return event
+}
+
+// sourceIP returns the IP of the client, from the X-Forwarded-For header set by the router in front of the proxy if any
+func sourceIP(req *http.Request) string {
+	if ips := utilnet.SourceIPs(req); len(ips) > 0 {
+		return ips[0].String()
+	}
+	return ""
+}
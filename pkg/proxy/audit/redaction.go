@@ -0,0 +1,74 @@
+package audit
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+)
+
+// Redacted replaces the redacted values in the audit events
+const Redacted = "[REDACTED]"
+
+// redactableFields returns a pointer to the Event field of each field which can be redacted, indexed by its JSON name
+var redactableFields = map[string]func(event *Event) *string{
+	"user":              func(event *Event) *string { return &event.User },
+	"userID":            func(event *Event) *string { return &event.UserID },
+	"compliantUsername": func(event *Event) *string { return &event.CompliantUsername },
+	"workspace":         func(event *Event) *string { return &event.Workspace },
+	"namespace":         func(event *Event) *string { return &event.Namespace },
+	"name":              func(event *Event) *string { return &event.Name },
+	"requestURI":        func(event *Event) *string { return &event.RequestURI },
+	"sourceIP":          func(event *Event) *string { return &event.SourceIP },
+	"userAgent":         func(event *Event) *string { return &event.UserAgent },
+	"error":             func(event *Event) *string { return &event.Error },
+}
+
+type redaction struct {
+	field   func(event *Event) *string
+	pattern *regexp.Regexp
+}
+
+func newRedactions(rules []configuration.AuditRedaction) ([]redaction, error) {
+	redactions := make([]redaction, 0, len(rules))
+	for _, rule := range rules {
+		field, found := redactableFields[rule.Field]
+		if !found {
+			return nil, fmt.Errorf("unknown field '%s' in audit redactions, expected one of: %s", rule.Field, strings.Join(redactableFieldNames(), ", "))
+		}
+		r := redaction{field: field}
+		if rule.Pattern != "" {
+			pattern, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern for field '%s' in audit redactions: %w", rule.Field, err)
+			}
+			r.pattern = pattern
+		}
+		redactions = append(redactions, r)
+	}
+	return redactions, nil
+}
+
+// apply redacts the whole value of the field, or only the parts matching the pattern of the redaction
+func (r redaction) apply(event *Event) {
+	value := r.field(event)
+	if *value == "" {
+		return
+	}
+	if r.pattern == nil {
+		*value = Redacted
+		return
+	}
+	*value = r.pattern.ReplaceAllString(*value, Redacted)
+}
+
+func redactableFieldNames() []string {
+	names := make([]string, 0, len(redactableFields))
+	for name := range redactableFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,110 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Sink writes the audit events to a durable storage
+type Sink interface {
+	// Name is the name of the sink, used in the logs and metrics
+	Name() string
+	// Write writes the given event
+	Write(event *Event) error
+	// Close flushes and releases the resources of the sink
+	Close() error
+}
+
+// FileSink appends the audit events to a file, one JSON-encoded event per line
+type FileSink struct {
+	lock    sync.Mutex
+	out     io.Writer
+	closer  io.Closer
+	encoder *json.Encoder
+}
+
+// NewFileSink returns a new FileSink which appends the events to the file with the given path, or to the standard
+// output if the path is `-`. The file is created if it does not exist.
+func NewFileSink(path string) (*FileSink, error) {
+	if path == "-" {
+		return newWriterSink(os.Stdout, nil), nil
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open the audit file: %w", err)
+	}
+	return newWriterSink(f, f), nil
+}
+
+func newWriterSink(out io.Writer, closer io.Closer) *FileSink {
+	return &FileSink{
+		out:     out,
+		closer:  closer,
+		encoder: json.NewEncoder(out),
+	}
+}
+
+func (s *FileSink) Name() string {
+	return "file"
+}
+
+func (s *FileSink) Write(event *Event) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.encoder.Encode(event)
+}
+
+func (s *FileSink) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
+
+// WebhookSink posts the audit events to a webhook, one JSON-encoded event per request
+type WebhookSink struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookSink returns a new WebhookSink which posts the events to the given URL
+func NewWebhookSink(url string) *WebhookSink {
+	return &WebhookSink{
+		url: url,
+		client: &http.Client{
+			Timeout: 5 * time.Second,
+		},
+	}
+}
+
+func (s *WebhookSink) Name() string {
+	return "webhook"
+}
+
+func (s *WebhookSink) Write(event *Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response status from the audit webhook: %s", resp.Status)
+	}
+	return nil
+}
+
+func (s *WebhookSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
+	"strconv"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
 	"github.com/codeready-toolchain/registration-service/pkg/application"
@@ -9,12 +11,15 @@ import (
 	"github.com/codeready-toolchain/registration-service/pkg/context"
 	"github.com/codeready-toolchain/registration-service/pkg/proxy/metrics"
 	"github.com/codeready-toolchain/registration-service/pkg/signup"
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
 	commonproxy "github.com/codeready-toolchain/toolchain-common/pkg/proxy"
 	"github.com/gin-gonic/gin"
 
 	"github.com/labstack/echo/v4"
 	errs "github.com/pkg/errors"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/rest"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 const (
@@ -30,6 +35,11 @@ type SpaceLister struct {
 	GetSignupFunc          func(ctx *gin.Context, userID, username string, checkUserSignupCompleted bool) (*signup.Signup, error)
 	GetInformerServiceFunc func() service.InformerService
 	ProxyMetrics           *metrics.ProxyMetrics
+	// Watcher notifies the watches of the workspaces, or is nil if the workspaces cannot be watched
+	Watcher *WorkspaceWatcher
+	// NewImpersonatingClientFunc returns a client of the given member cluster which impersonates the given user,
+	// or is nil to use a client built from the rest config of the member cluster
+	NewImpersonatingClientFunc func(member *cluster.CachedToolchainCluster, username string) (runtimeclient.Client, error)
 }
 
 func NewSpaceLister(app application.Application, proxyMetrics *metrics.ProxyMetrics) *SpaceLister {
@@ -37,6 +47,7 @@ func NewSpaceLister(app application.Application, proxyMetrics *metrics.ProxyMetr
 		GetSignupFunc:          app.SignupService().GetSignupFromInformer,
 		GetInformerServiceFunc: app.InformerService,
 		ProxyMetrics:           proxyMetrics,
+		Watcher:                NewWorkspaceWatcher(DefaultBookmarkInterval),
 	}
 }
 
@@ -74,9 +85,83 @@ func createWorkspaceObject(signupName string, space *toolchainv1alpha1.Space, sp
 	wsOptions = append(wsOptions, wsAdditionalOptions...)
 
 	workspace := commonproxy.NewWorkspace(space.GetName(), wsOptions...)
+	// the workspaces can be selected with the labels of their space
+	if len(space.Labels) > 0 {
+		workspace.Labels = make(map[string]string, len(space.Labels))
+		for key, value := range space.Labels {
+			workspace.Labels[key] = value
+		}
+	}
+	// the workspace also changes when the role of the user changes
+	workspace.ResourceVersion = latestResourceVersion(space.ResourceVersion, spaceBinding.ResourceVersion)
 	return workspace
 }
 
+// latestResourceVersion returns the latest of the given resource versions. The resource versions of the resources of the
+// host cluster are comparable, since they are all stored in the same etcd.
+func latestResourceVersion(resourceVersion, other string) string {
+	if parseResourceVersion(other) > parseResourceVersion(resourceVersion) {
+		return other
+	}
+	return resourceVersion
+}
+
+// parseResourceVersion returns the numeric value of the given resource version, or 0 if it is not set or invalid
+func parseResourceVersion(resourceVersion string) uint64 {
+	value, err := strconv.ParseUint(resourceVersion, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
+// toStatusError returns the given error if it is a StatusError, or an InternalError otherwise
+func toStatusError(err error) *apierrors.StatusError {
+	statusErr := &apierrors.StatusError{}
+	if errs.As(err, &statusErr) {
+		return statusErr
+	}
+	return apierrors.NewInternalError(err)
+}
+
+// memberClientFor returns the client of the member cluster where the given space is provisioned
+func memberClientFor(GetMembersFunc cluster.GetMemberClustersFunc, space *toolchainv1alpha1.Space) (runtimeclient.Client, error) {
+	for _, member := range GetMembersFunc() {
+		if member.Name == space.Status.TargetCluster {
+			return member.Client, nil
+		}
+	}
+	return nil, fmt.Errorf("no member cluster found for space '%s'", space.Name)
+}
+
+// impersonatingMemberClientFor returns a client of the member cluster where the given space is provisioned, which
+// impersonates the given user, so that the requests of the user are authorized by the member cluster
+func impersonatingMemberClientFor(spaceLister *SpaceLister, GetMembersFunc cluster.GetMemberClustersFunc, space *toolchainv1alpha1.Space, username string) (runtimeclient.Client, error) {
+	newClient := spaceLister.NewImpersonatingClientFunc
+	if newClient == nil {
+		newClient = newImpersonatingClient
+	}
+	for _, member := range GetMembersFunc() {
+		if member.Name == space.Status.TargetCluster {
+			return newClient(member, username)
+		}
+	}
+	return nil, fmt.Errorf("no member cluster found for space '%s'", space.Name)
+}
+
+// newImpersonatingClient returns a client of the given member cluster which impersonates the given user, like the
+// proxied requests do
+func newImpersonatingClient(member *cluster.CachedToolchainCluster, username string) (runtimeclient.Client, error) {
+	config := rest.CopyConfig(member.RestConfig)
+	config.Impersonate = rest.ImpersonationConfig{
+		UserName: username,
+	}
+	return runtimeclient.New(config, runtimeclient.Options{
+		Scheme: member.Client.Scheme(),
+		Mapper: member.Client.RESTMapper(),
+	})
+}
+
 func errorResponse(ctx echo.Context, err *apierrors.StatusError) error {
 	ctx.Logger().Error(errs.Wrap(err, "workspace list error"))
 	ctx.Response().Writer.Header().Set("Content-Type", "application/json")
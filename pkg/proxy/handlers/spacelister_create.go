@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	regsercontext "github.com/codeready-toolchain/registration-service/pkg/context"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/metrics"
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	"github.com/codeready-toolchain/toolchain-common/pkg/condition"
+	commonproxy "github.com/codeready-toolchain/toolchain-common/pkg/proxy"
+	"github.com/labstack/echo/v4"
+	errs "github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// The provisioning states of the sub-workspaces, set with the `toolchain.dev.openshift.com/state` label of the workspaces
+// returned when they are created or deleted. The other states are the reasons of the failed provisioning.
+const (
+	SubWorkspaceStateProvisioning = "provisioning"
+	SubWorkspaceStateReady        = "ready"
+	SubWorkspaceStateTerminating  = "terminating"
+)
+
+// HandleSpaceCreateRequest handles the POST requests of the workspaces, which create a sub-workspace of a workspace of the user
+// with a SpaceRequest in the default namespace of the parent workspace. The requested workspace is set with the labels:
+//   - `toolchain.dev.openshift.com/parent-space`: the name of the parent workspace, where the user must be allowed to create
+//     SpaceRequests by the member cluster, since the SpaceRequest is created on behalf of the user
+//   - `toolchain.dev.openshift.com/tier` (optional): the tier of the sub-workspace, one of the given tiers (the first one by default)
+//
+// The name (or the generateName) of the requested workspace is the name of the SpaceRequest, the name of the sub-workspace is
+// generated once the SpaceRequest is reconciled. The response is the workspace of the SpaceRequest, which state is set with
+// the `toolchain.dev.openshift.com/state` label, and which can be fetched with the name of the SpaceRequest until the
+// sub-workspace is deleted (see GetUserSubWorkspaceRequest).
+func HandleSpaceCreateRequest(spaceLister *SpaceLister, GetMembersFunc cluster.GetMemberClustersFunc, tiers []string) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		requestReceivedTime := ctx.Get(regsercontext.RequestReceivedTime).(time.Time)
+		workspace, err := createSubWorkspace(ctx, spaceLister, GetMembersFunc, tiers)
+		if err != nil {
+			statusErr := toStatusError(err)
+			spaceLister.ProxyMetrics.RegServWorkspaceHistogramVec.WithLabelValues(fmt.Sprintf("%d", statusErr.ErrStatus.Code), metrics.MetricsLabelVerbCreate).Observe(time.Since(requestReceivedTime).Seconds())
+			return errorResponse(ctx, statusErr)
+		}
+		spaceLister.ProxyMetrics.RegServWorkspaceHistogramVec.WithLabelValues(fmt.Sprintf("%d", http.StatusCreated), metrics.MetricsLabelVerbCreate).Observe(time.Since(requestReceivedTime).Seconds())
+		ctx.Response().Writer.Header().Set("Content-Type", "application/json")
+		ctx.Response().Writer.WriteHeader(http.StatusCreated)
+		return json.NewEncoder(ctx.Response().Writer).Encode(workspace)
+	}
+}
+
+func createSubWorkspace(ctx echo.Context, spaceLister *SpaceLister, GetMembersFunc cluster.GetMemberClustersFunc, tiers []string) (*toolchainv1alpha1.Workspace, error) {
+	if len(tiers) == 0 {
+		return nil, apierrors.NewMethodNotSupported(workspacesResource, "create")
+	}
+	requested := &toolchainv1alpha1.Workspace{}
+	if err := json.NewDecoder(ctx.Request().Body).Decode(requested); err != nil {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("unable to decode the workspace: %s", err.Error()))
+	}
+	parentName := requested.Labels[toolchainv1alpha1.ParentSpaceLabelKey]
+	if parentName == "" {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("the parent workspace must be set with the '%s' label", toolchainv1alpha1.ParentSpaceLabelKey))
+	}
+	tier := requested.Labels[toolchainv1alpha1.TierLabelKey]
+	if tier == "" {
+		tier = tiers[0]
+	} else if !contains(tiers, tier) {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("the '%s' tier is not allowed for the sub-workspaces, the allowed tiers are %s", tier, strings.Join(tiers, ", ")))
+	}
+
+	userSignup, err := spaceLister.GetProvisionedUserSignup(ctx)
+	if err != nil {
+		return nil, err
+	}
+	parent, err := GetUserWorkspace(ctx, spaceLister, parentName)
+	if err != nil {
+		return nil, err
+	}
+	if userSignup == nil || parent == nil {
+		return nil, apierrors.NewNotFound(workspacesResource, parentName)
+	}
+	parentSpace, err := spaceLister.GetInformerServiceFunc().GetSpace(parentName)
+	if err != nil {
+		return nil, errs.Wrap(err, "unable to get space")
+	}
+	namespace := defaultNamespace(parentSpace)
+	if namespace == "" {
+		return nil, apierrors.NewConflict(workspacesResource, parentName, fmt.Errorf("the namespaces of the workspace are not provisioned yet"))
+	}
+	// the role of the user in the parent workspace is checked by the member cluster
+	memberClient, err := impersonatingMemberClientFor(spaceLister, GetMembersFunc, parentSpace, userSignup.CompliantUsername)
+	if err != nil {
+		return nil, err
+	}
+
+	spaceRequest := &toolchainv1alpha1.SpaceRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:         requested.Name,
+			GenerateName: requested.GenerateName,
+			Namespace:    namespace,
+			Labels: map[string]string{
+				// the creator of the SpaceRequest is the owner of the sub-workspace
+				toolchainv1alpha1.SpaceCreatorLabelKey: userSignup.Name,
+			},
+		},
+		Spec: toolchainv1alpha1.SpaceRequestSpec{
+			TierName: tier,
+		},
+	}
+	if spaceRequest.Name == "" && spaceRequest.GenerateName == "" {
+		spaceRequest.GenerateName = parentName + "-"
+	}
+	if err := memberClient.Create(ctx.Request().Context(), spaceRequest); err != nil {
+		if !apierrors.IsAlreadyExists(err) {
+			ctx.Logger().Error(errs.Wrapf(err, "unable to create the SpaceRequest of a sub-workspace of '%s'", parentName))
+		}
+		return nil, err
+	}
+	return workspaceFromSpaceRequest(parent, spaceRequest), nil
+}
+
+// GetUserSubWorkspaceRequest returns the workspace of the SpaceRequest with the given name, as returned when the sub-workspace was
+// requested, so that the provisioning state of the sub-workspace can be followed with the name of the SpaceRequest. The SpaceRequest
+// is looked up on behalf of the user in the default namespaces of the workspaces where the user has a SpaceBinding.
+// Returns nil if no such SpaceRequest is found.
+func GetUserSubWorkspaceRequest(ctx echo.Context, spaceLister *SpaceLister, spaceRequestName string, GetMembersFunc cluster.GetMemberClustersFunc) (*toolchainv1alpha1.Workspace, error) {
+	userSignup, err := spaceLister.GetProvisionedUserSignup(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// signup is not ready
+	if userSignup == nil {
+		return nil, nil
+	}
+	workspaces, err := spaceLister.ListWorkspaces(userSignup)
+	if err != nil {
+		ctx.Logger().Error(errs.Wrap(err, "error listing space bindings"))
+		return nil, err
+	}
+	for i := range workspaces {
+		parent := &workspaces[i]
+		parentSpace, err := spaceLister.GetInformerServiceFunc().GetSpace(parent.Name)
+		if err != nil {
+			return nil, errs.Wrap(err, "unable to get space")
+		}
+		namespace := defaultNamespace(parentSpace)
+		if namespace == "" {
+			continue
+		}
+		memberClient, err := impersonatingMemberClientFor(spaceLister, GetMembersFunc, parentSpace, userSignup.CompliantUsername)
+		if err != nil {
+			// the parent space is not provisioned yet, so it has no SpaceRequest
+			continue
+		}
+		spaceRequest := &toolchainv1alpha1.SpaceRequest{}
+		if err := memberClient.Get(ctx.Request().Context(), types.NamespacedName{Name: spaceRequestName, Namespace: namespace}, spaceRequest); err != nil {
+			if apierrors.IsNotFound(err) || apierrors.IsForbidden(err) {
+				continue
+			}
+			ctx.Logger().Error(errs.Wrapf(err, "unable to get the SpaceRequest '%s' in namespace '%s'", spaceRequestName, namespace))
+			return nil, err
+		}
+		return workspaceFromSpaceRequest(parent, spaceRequest), nil
+	}
+	return nil, nil
+}
+
+// workspaceFromSpaceRequest returns the workspace of the given SpaceRequest, which is provisioned as a sub-workspace of the given parent workspace
+func workspaceFromSpaceRequest(parent *toolchainv1alpha1.Workspace, spaceRequest *toolchainv1alpha1.SpaceRequest) *toolchainv1alpha1.Workspace {
+	namespaces := make([]toolchainv1alpha1.SpaceNamespace, 0, len(spaceRequest.Status.NamespaceAccess))
+	for _, access := range spaceRequest.Status.NamespaceAccess {
+		namespaces = append(namespaces, toolchainv1alpha1.SpaceNamespace{Name: access.Name})
+	}
+	workspace := commonproxy.NewWorkspace(spaceRequest.Name,
+		commonproxy.WithNamespaces(namespaces),
+		commonproxy.WithOwner(subWorkspaceOwner(parent, spaceRequest)),
+		// the bindings of the parent workspace are inherited by the sub-workspace
+		commonproxy.WithRole(parent.Status.Role),
+	)
+	workspace.CreationTimestamp = spaceRequest.CreationTimestamp
+	workspace.Labels = map[string]string{
+		toolchainv1alpha1.ParentSpaceLabelKey:           parent.Name,
+		toolchainv1alpha1.TierLabelKey:                  spaceRequest.Spec.TierName,
+		toolchainv1alpha1.SpaceRequestLabelKey:          spaceRequest.Name,
+		toolchainv1alpha1.SpaceRequestNamespaceLabelKey: spaceRequest.Namespace,
+		toolchainv1alpha1.StateLabelKey:                 subWorkspaceState(spaceRequest),
+	}
+	return workspace
+}
+
+// subWorkspaceOwner returns the owner of the sub-workspace of the given SpaceRequest, ie. the creator of the SpaceRequest,
+// or the owner of the given workspace if the creator of the SpaceRequest is unknown
+func subWorkspaceOwner(workspace *toolchainv1alpha1.Workspace, spaceRequest *toolchainv1alpha1.SpaceRequest) string {
+	if creator := spaceRequest.Labels[toolchainv1alpha1.SpaceCreatorLabelKey]; creator != "" {
+		return creator
+	}
+	return workspace.Status.Owner
+}
+
+// subWorkspaceState returns the provisioning state of the sub-workspace of the given SpaceRequest
+func subWorkspaceState(spaceRequest *toolchainv1alpha1.SpaceRequest) string {
+	if spaceRequest.DeletionTimestamp != nil {
+		return SubWorkspaceStateTerminating
+	}
+	ready, found := condition.FindConditionByType(spaceRequest.Status.Conditions, toolchainv1alpha1.ConditionReady)
+	switch {
+	case !found:
+		return SubWorkspaceStateProvisioning
+	case ready.Status == corev1.ConditionTrue:
+		return SubWorkspaceStateReady
+	case ready.Reason == "" || ready.Reason == toolchainv1alpha1.SpaceProvisioningReason:
+		return SubWorkspaceStateProvisioning
+	}
+	return ready.Reason
+}
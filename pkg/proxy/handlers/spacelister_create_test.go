@@ -0,0 +1,242 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	rcontext "github.com/codeready-toolchain/registration-service/pkg/context"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/handlers"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/metrics"
+	proxytest "github.com/codeready-toolchain/registration-service/pkg/proxy/test"
+	"github.com/codeready-toolchain/registration-service/test/fake"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestSpaceListerCreate(t *testing.T) {
+	log.Init("registration-service-testing")
+
+	// given
+	fakeSignupService, fakeClient := buildSpaceListerFakes(t)
+	s := &handlers.SpaceLister{
+		GetSignupFunc:          fakeSignupService.GetSignupFromInformer,
+		GetInformerServiceFunc: fake.GetInformerService(fakeClient),
+		ProxyMetrics:           metrics.NewProxyMetrics(prometheus.NewRegistry()),
+	}
+	tiers := []string{"appstudio-env", "base1ns"}
+	create := func(t *testing.T, memberClient *test.FakeClient, tiers []string, username, body string) *httptest.ResponseRecorder {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		ctx := e.NewContext(req, rec)
+		ctx.Set(rcontext.UsernameKey, username)
+		ctx.Set(rcontext.RequestReceivedTime, time.Now())
+		s.NewImpersonatingClientFunc = newImpersonatingClientFunc(memberClient, map[string][]string{"dancelover": {"dancelover-dev"}})
+		require.NoError(t, handlers.HandleSpaceCreateRequest(s, proxytest.NewGetMembersFunc(memberClient), tiers)(ctx))
+		return rec
+	}
+
+	t.Run("creates a sub-workspace with the default tier", func(t *testing.T) {
+		// given
+		memberClient := fake.InitClient(t)
+
+		// when
+		rec := create(t, memberClient, tiers, "dance.lover", `{"metadata":{"labels":{"toolchain.dev.openshift.com/parent-space":"dancelover"}}}`)
+
+		// then
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		spaceRequests := &toolchainv1alpha1.SpaceRequestList{}
+		require.NoError(t, memberClient.List(context.TODO(), spaceRequests, runtimeclient.InNamespace("dancelover-dev")))
+		require.Len(t, spaceRequests.Items, 1)
+		spaceRequest := spaceRequests.Items[0]
+		assert.True(t, strings.HasPrefix(spaceRequest.Name, "dancelover-"))
+		assert.Equal(t, "appstudio-env", spaceRequest.Spec.TierName)
+		assert.Equal(t, "dancelover", spaceRequest.Labels[toolchainv1alpha1.SpaceCreatorLabelKey])
+		workspace, err := decodeResponseToWorkspace(rec.Body.Bytes())
+		require.NoError(t, err)
+		assert.Equal(t, spaceRequest.Name, workspace.Name)
+		assert.Equal(t, map[string]string{
+			toolchainv1alpha1.ParentSpaceLabelKey:           "dancelover",
+			toolchainv1alpha1.TierLabelKey:                  "appstudio-env",
+			toolchainv1alpha1.SpaceRequestLabelKey:          spaceRequest.Name,
+			toolchainv1alpha1.SpaceRequestNamespaceLabelKey: "dancelover-dev",
+			toolchainv1alpha1.StateLabelKey:                 handlers.SubWorkspaceStateProvisioning,
+		}, workspace.Labels)
+		assert.Equal(t, "admin", workspace.Status.Role)
+		assert.Equal(t, "dancelover", workspace.Status.Owner)
+	})
+
+	t.Run("creates a sub-workspace with a name and a tier", func(t *testing.T) {
+		// given
+		memberClient := fake.InitClient(t)
+
+		// when
+		rec := create(t, memberClient, tiers, "dance.lover", `{"metadata":{"name":"dancelover-test","labels":{"toolchain.dev.openshift.com/parent-space":"dancelover","toolchain.dev.openshift.com/tier":"base1ns"}}}`)
+
+		// then
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		spaceRequest := &toolchainv1alpha1.SpaceRequest{}
+		require.NoError(t, memberClient.Get(context.TODO(), types.NamespacedName{Name: "dancelover-test", Namespace: "dancelover-dev"}, spaceRequest))
+		assert.Equal(t, "base1ns", spaceRequest.Spec.TierName)
+	})
+
+	t.Run("gets the sub-workspace with the name of the SpaceRequest", func(t *testing.T) {
+		// given
+		memberClient := fake.InitClient(t)
+		rec := create(t, memberClient, tiers, "dance.lover", `{"metadata":{"name":"dancelover-test","labels":{"toolchain.dev.openshift.com/parent-space":"dancelover"}}}`)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		get := func(t *testing.T, username string) *httptest.ResponseRecorder {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			rec := httptest.NewRecorder()
+			ctx := e.NewContext(req, rec)
+			ctx.Set(rcontext.UsernameKey, username)
+			ctx.Set(rcontext.RequestReceivedTime, time.Now())
+			ctx.SetParamNames("workspace")
+			ctx.SetParamValues("dancelover-test")
+			s.NewImpersonatingClientFunc = newImpersonatingClientFunc(memberClient, map[string][]string{"dancelover": {"dancelover-dev"}})
+			require.NoError(t, handlers.HandleSpaceGetRequest(s, proxytest.NewGetMembersFunc(memberClient))(ctx))
+			return rec
+		}
+
+		t.Run("provisioning", func(t *testing.T) {
+			// when
+			rec := get(t, "dance.lover")
+
+			// then
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+			workspace, err := decodeResponseToWorkspace(rec.Body.Bytes())
+			require.NoError(t, err)
+			assert.Equal(t, "dancelover-test", workspace.Name)
+			assert.Equal(t, handlers.SubWorkspaceStateProvisioning, workspace.Labels[toolchainv1alpha1.StateLabelKey])
+			assert.Empty(t, workspace.Status.Namespaces)
+		})
+
+		t.Run("ready", func(t *testing.T) {
+			// given
+			spaceRequest := &toolchainv1alpha1.SpaceRequest{}
+			require.NoError(t, memberClient.Get(context.TODO(), types.NamespacedName{Name: "dancelover-test", Namespace: "dancelover-dev"}, spaceRequest))
+			spaceRequest.Status.NamespaceAccess = []toolchainv1alpha1.NamespaceAccess{{Name: "dancelover-test-tenant", SecretRef: "secret"}}
+			spaceRequest.Status.Conditions = []toolchainv1alpha1.Condition{{Type: toolchainv1alpha1.ConditionReady, Status: corev1.ConditionTrue}}
+			require.NoError(t, memberClient.Status().Update(context.TODO(), spaceRequest))
+
+			// when
+			rec := get(t, "dance.lover")
+
+			// then
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+			workspace, err := decodeResponseToWorkspace(rec.Body.Bytes())
+			require.NoError(t, err)
+			assert.Equal(t, handlers.SubWorkspaceStateReady, workspace.Labels[toolchainv1alpha1.StateLabelKey])
+			assert.Equal(t, []toolchainv1alpha1.SpaceNamespace{{Name: "dancelover-test-tenant"}}, workspace.Status.Namespaces)
+		})
+
+		t.Run("not found for the users without access to the parent workspace", func(t *testing.T) {
+			// when
+			rec := get(t, "movie.lover")
+
+			// then
+			require.Equal(t, http.StatusNotFound, rec.Code, rec.Body.String())
+		})
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		tests := map[string]struct {
+			username        string
+			tiers           []string
+			body            string
+			existing        *toolchainv1alpha1.SpaceRequest
+			expectedErr     string
+			expectedErrCode int
+		}{
+			"sub-workspaces are disabled": {
+				username:        "dance.lover",
+				body:            `{"metadata":{"labels":{"toolchain.dev.openshift.com/parent-space":"dancelover"}}}`,
+				expectedErr:     "MethodNotAllowed",
+				expectedErrCode: http.StatusMethodNotAllowed,
+			},
+			"missing parent workspace": {
+				username:        "dance.lover",
+				tiers:           tiers,
+				body:            `{"metadata":{"name":"test"}}`,
+				expectedErr:     "the parent workspace must be set with the 'toolchain.dev.openshift.com/parent-space' label",
+				expectedErrCode: http.StatusBadRequest,
+			},
+			"tier not allowed": {
+				username:        "dance.lover",
+				tiers:           tiers,
+				body:            `{"metadata":{"labels":{"toolchain.dev.openshift.com/parent-space":"dancelover","toolchain.dev.openshift.com/tier":"advanced"}}}`,
+				expectedErr:     "the 'advanced' tier is not allowed for the sub-workspaces, the allowed tiers are appstudio-env, base1ns",
+				expectedErrCode: http.StatusBadRequest,
+			},
+			"invalid body": {
+				username:        "dance.lover",
+				tiers:           tiers,
+				body:            `not json`,
+				expectedErr:     "unable to decode the workspace",
+				expectedErrCode: http.StatusBadRequest,
+			},
+			"parent workspace not found": {
+				username:        "movie.lover",
+				tiers:           tiers,
+				body:            `{"metadata":{"labels":{"toolchain.dev.openshift.com/parent-space":"dancelover"}}}`,
+				expectedErr:     "\"workspaces.toolchain.dev.openshift.com \\\"dancelover\\\" not found\"",
+				expectedErrCode: http.StatusNotFound,
+			},
+			"not allowed by the member cluster": {
+				username:        "dance.lover",
+				tiers:           tiers,
+				body:            `{"metadata":{"labels":{"toolchain.dev.openshift.com/parent-space":"movielover"}}}`,
+				expectedErr:     "user 'dancelover' cannot write in namespace 'movielover-dev'",
+				expectedErrCode: http.StatusForbidden,
+			},
+			"already exists": {
+				username: "dance.lover",
+				tiers:    tiers,
+				body:     `{"metadata":{"name":"dancelover-test","labels":{"toolchain.dev.openshift.com/parent-space":"dancelover"}}}`,
+				existing: &toolchainv1alpha1.SpaceRequest{
+					ObjectMeta: metav1.ObjectMeta{Name: "dancelover-test", Namespace: "dancelover-dev"},
+				},
+				expectedErr:     "already exists",
+				expectedErrCode: http.StatusConflict,
+			},
+		}
+		for name, tc := range tests {
+			t.Run(name, func(t *testing.T) {
+				// given
+				memberClient := fake.InitClient(t)
+				if tc.existing != nil {
+					memberClient = fake.InitClient(t, tc.existing)
+				}
+
+				// when
+				rec := create(t, memberClient, tc.tiers, tc.username, tc.body)
+
+				// then
+				require.Equal(t, tc.expectedErrCode, rec.Code, rec.Body.String())
+				require.Contains(t, rec.Body.String(), tc.expectedErr)
+				spaceRequests := &toolchainv1alpha1.SpaceRequestList{}
+				require.NoError(t, memberClient.List(context.TODO(), spaceRequests))
+				if tc.existing == nil {
+					assert.Empty(t, spaceRequests.Items)
+				} else {
+					assert.Len(t, spaceRequests.Items, 1)
+				}
+			})
+		}
+	})
+}
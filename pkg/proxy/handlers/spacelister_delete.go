@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	regsercontext "github.com/codeready-toolchain/registration-service/pkg/context"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/metrics"
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	"github.com/labstack/echo/v4"
+	errs "github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// HandleSpaceDeleteRequest handles the DELETE requests of a workspace, which delete a sub-workspace created with a SpaceRequest
+// by deleting its SpaceRequest on behalf of the user. Only the owner of the sub-workspace, ie. the creator of its SpaceRequest, can
+// delete it, provided that the member cluster allows the user to delete the SpaceRequest. The response is the workspace, which
+// state is set to `terminating` with the `toolchain.dev.openshift.com/state` label until the sub-workspace is deleted.
+func HandleSpaceDeleteRequest(spaceLister *SpaceLister, GetMembersFunc cluster.GetMemberClustersFunc) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		requestReceivedTime := ctx.Get(regsercontext.RequestReceivedTime).(time.Time)
+		workspace, err := deleteSubWorkspace(ctx, spaceLister, ctx.Param("workspace"), GetMembersFunc)
+		if err != nil {
+			statusErr := toStatusError(err)
+			spaceLister.ProxyMetrics.RegServWorkspaceHistogramVec.WithLabelValues(fmt.Sprintf("%d", statusErr.ErrStatus.Code), metrics.MetricsLabelVerbDelete).Observe(time.Since(requestReceivedTime).Seconds())
+			return errorResponse(ctx, statusErr)
+		}
+		spaceLister.ProxyMetrics.RegServWorkspaceHistogramVec.WithLabelValues(fmt.Sprintf("%d", http.StatusOK), metrics.MetricsLabelVerbDelete).Observe(time.Since(requestReceivedTime).Seconds())
+		return getWorkspaceResponse(ctx, workspace)
+	}
+}
+
+func deleteSubWorkspace(ctx echo.Context, spaceLister *SpaceLister, workspaceName string, GetMembersFunc cluster.GetMemberClustersFunc) (*toolchainv1alpha1.Workspace, error) {
+	userSignup, err := spaceLister.GetProvisionedUserSignup(ctx)
+	if err != nil {
+		return nil, err
+	}
+	workspace, err := GetUserWorkspace(ctx, spaceLister, workspaceName)
+	if err != nil {
+		return nil, err
+	}
+	if userSignup == nil || workspace == nil {
+		return nil, apierrors.NewNotFound(workspacesResource, workspaceName)
+	}
+	space, err := spaceLister.GetInformerServiceFunc().GetSpace(workspaceName)
+	if err != nil {
+		return nil, errs.Wrap(err, "unable to get space")
+	}
+	spaceRequestName := space.Labels[toolchainv1alpha1.SpaceRequestLabelKey]
+	spaceRequestNamespace := space.Labels[toolchainv1alpha1.SpaceRequestNamespaceLabelKey]
+	if spaceRequestName == "" || spaceRequestNamespace == "" || space.Spec.ParentSpace == "" {
+		return nil, apierrors.NewForbidden(workspacesResource, workspaceName, fmt.Errorf("only the sub-workspaces can be deleted"))
+	}
+	// the SpaceRequest is in a namespace of the parent space, and it is deleted on behalf of the user
+	parentSpace, err := spaceLister.GetInformerServiceFunc().GetSpace(space.Spec.ParentSpace)
+	if err != nil {
+		return nil, errs.Wrap(err, "unable to get parent space")
+	}
+	memberClient, err := impersonatingMemberClientFor(spaceLister, GetMembersFunc, parentSpace, userSignup.CompliantUsername)
+	if err != nil {
+		return nil, err
+	}
+	spaceRequest := &toolchainv1alpha1.SpaceRequest{}
+	err = memberClient.Get(ctx.Request().Context(), types.NamespacedName{Name: spaceRequestName, Namespace: spaceRequestNamespace}, spaceRequest)
+	if err != nil && !apierrors.IsNotFound(err) {
+		ctx.Logger().Error(errs.Wrapf(err, "unable to get the SpaceRequest of workspace '%s'", workspaceName))
+		return nil, err
+	}
+	if owner := subWorkspaceOwner(workspace, spaceRequest); owner != userSignup.Name {
+		return nil, apierrors.NewForbidden(workspacesResource, workspaceName, fmt.Errorf("only the owner of the workspace can delete it"))
+	}
+	if err == nil {
+		if err := memberClient.Delete(ctx.Request().Context(), spaceRequest); err != nil && !apierrors.IsNotFound(err) {
+			ctx.Logger().Error(errs.Wrapf(err, "unable to delete the SpaceRequest of workspace '%s'", workspaceName))
+			return nil, err
+		}
+	}
+	// the sub-workspace is deleted once the SpaceRequest is reconciled
+	workspace.Labels[toolchainv1alpha1.StateLabelKey] = SubWorkspaceStateTerminating
+	return workspace, nil
+}
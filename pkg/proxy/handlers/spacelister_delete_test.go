@@ -0,0 +1,168 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	rcontext "github.com/codeready-toolchain/registration-service/pkg/context"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/handlers"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/metrics"
+	proxytest "github.com/codeready-toolchain/registration-service/pkg/proxy/test"
+	"github.com/codeready-toolchain/registration-service/test/fake"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	spacetest "github.com/codeready-toolchain/toolchain-common/pkg/test/space"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestSpaceListerDelete(t *testing.T) {
+	log.Init("registration-service-testing")
+
+	// given
+	fakeSignupService := fake.NewSignupService(
+		newSignup("john", "john", true),
+		newSignup("jane", "jane", true),
+	)
+	newSubSpace := func(name, parent, creator string) *toolchainv1alpha1.Space {
+		return fake.NewSpace(name, "member-2", creator,
+			spacetest.WithSpecParentSpace(parent),
+			spacetest.WithLabel(toolchainv1alpha1.SpaceRequestLabelKey, name+"-sr"),
+			spacetest.WithLabel(toolchainv1alpha1.SpaceRequestNamespaceLabelKey, parent+"-dev"))
+	}
+	fakeClient := fake.InitClient(t,
+		fake.NewSpace("john", "member-1", "john"),
+		newSubSpace("john-test", "john", "john"),
+		fake.NewSpace("jane", "member-1", "jane"),
+		newSubSpace("jane-test", "jane", "jane"),
+		newSubSpace("jane-john", "jane", "john"),
+		fake.NewSpace("shared", "member-1", "jane"),
+		newSubSpace("shared-test", "shared", "jane"),
+		fake.NewSpaceBinding("john-john", "john", "john", "admin"),
+		fake.NewSpaceBinding("jane-jane", "jane", "jane", "admin"),
+		fake.NewSpaceBinding("john-jane", "john", "jane", "viewer"),
+		fake.NewSpaceBinding("jane-shared", "jane", "shared", "admin"),
+		fake.NewSpaceBinding("john-shared", "john", "shared", "admin"),
+	)
+	s := &handlers.SpaceLister{
+		GetSignupFunc:          fakeSignupService.GetSignupFromInformer,
+		GetInformerServiceFunc: fake.GetInformerService(fakeClient),
+		ProxyMetrics:           metrics.NewProxyMetrics(prometheus.NewRegistry()),
+	}
+	newSpaceRequest := func(name, namespace, creator string) *toolchainv1alpha1.SpaceRequest {
+		return &toolchainv1alpha1.SpaceRequest{ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{toolchainv1alpha1.SpaceCreatorLabelKey: creator},
+		}}
+	}
+	newMemberClient := func(t *testing.T) *test.FakeClient {
+		return fake.InitClient(t,
+			newSpaceRequest("john-test-sr", "john-dev", "john"),
+			newSpaceRequest("jane-test-sr", "jane-dev", "jane"),
+			newSpaceRequest("jane-john-sr", "jane-dev", "john"),
+			newSpaceRequest("shared-test-sr", "shared-dev", "jane"),
+		)
+	}
+	deleteWorkspace := func(t *testing.T, memberClient *test.FakeClient, workspace string) *httptest.ResponseRecorder {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodDelete, "/", nil)
+		rec := httptest.NewRecorder()
+		ctx := e.NewContext(req, rec)
+		ctx.Set(rcontext.UsernameKey, "john")
+		ctx.Set(rcontext.RequestReceivedTime, time.Now())
+		ctx.SetParamNames("workspace")
+		ctx.SetParamValues(workspace)
+		// john is an admin of the john and shared workspaces, and a viewer of the jane workspace
+		s.NewImpersonatingClientFunc = newImpersonatingClientFunc(memberClient, map[string][]string{"john": {"john-dev", "shared-dev"}})
+		require.NoError(t, handlers.HandleSpaceDeleteRequest(s, proxytest.NewGetMembersFunc(memberClient))(ctx))
+		return rec
+	}
+
+	t.Run("deletes the SpaceRequest of the sub-workspace", func(t *testing.T) {
+		// given
+		memberClient := newMemberClient(t)
+
+		// when
+		rec := deleteWorkspace(t, memberClient, "john-test")
+
+		// then
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		err := memberClient.Get(context.TODO(), types.NamespacedName{Name: "john-test-sr", Namespace: "john-dev"}, &toolchainv1alpha1.SpaceRequest{})
+		assert.True(t, apierrors.IsNotFound(err))
+		workspace, err := decodeResponseToWorkspace(rec.Body.Bytes())
+		require.NoError(t, err)
+		assert.Equal(t, "john-test", workspace.Name)
+		assert.Equal(t, handlers.SubWorkspaceStateTerminating, workspace.Labels[toolchainv1alpha1.StateLabelKey])
+	})
+
+	t.Run("SpaceRequest already deleted", func(t *testing.T) {
+		// given
+		memberClient := fake.InitClient(t)
+
+		// when
+		rec := deleteWorkspace(t, memberClient, "john-test")
+
+		// then
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		tests := map[string]struct {
+			workspace       string
+			expectedErr     string
+			expectedErrCode int
+		}{
+			"not a sub-workspace": {
+				workspace:       "john",
+				expectedErr:     "only the sub-workspaces can be deleted",
+				expectedErrCode: http.StatusForbidden,
+			},
+			"not the owner of the sub-workspace": {
+				workspace:       "jane-test",
+				expectedErr:     "only the owner of the workspace can delete it",
+				expectedErrCode: http.StatusForbidden,
+			},
+			"admin but not the owner of the sub-workspace": {
+				workspace:       "shared-test",
+				expectedErr:     "only the owner of the workspace can delete it",
+				expectedErrCode: http.StatusForbidden,
+			},
+			"not allowed by the member cluster": {
+				workspace:       "jane-john",
+				expectedErr:     "user 'john' cannot write in namespace 'jane-dev'",
+				expectedErrCode: http.StatusForbidden,
+			},
+			"workspace not found": {
+				workspace:       "unknown",
+				expectedErr:     "\"workspaces.toolchain.dev.openshift.com \\\"unknown\\\" not found\"",
+				expectedErrCode: http.StatusNotFound,
+			},
+		}
+		for name, tc := range tests {
+			t.Run(name, func(t *testing.T) {
+				// given
+				memberClient := newMemberClient(t)
+
+				// when
+				rec := deleteWorkspace(t, memberClient, tc.workspace)
+
+				// then
+				require.Equal(t, tc.expectedErrCode, rec.Code, rec.Body.String())
+				require.Contains(t, rec.Body.String(), tc.expectedErr)
+				spaceRequests := &toolchainv1alpha1.SpaceRequestList{}
+				require.NoError(t, memberClient.List(context.TODO(), spaceRequests))
+				assert.Len(t, spaceRequests.Items, 4)
+			})
+		}
+	})
+}
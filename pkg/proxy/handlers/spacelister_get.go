@@ -8,6 +8,7 @@ import (
 	"time"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/application/service"
 	regsercontext "github.com/codeready-toolchain/registration-service/pkg/context"
 	"github.com/codeready-toolchain/registration-service/pkg/proxy/metrics"
 	"github.com/codeready-toolchain/registration-service/pkg/signup"
@@ -28,6 +29,10 @@ func HandleSpaceGetRequest(spaceLister *SpaceLister, GetMembersFunc cluster.GetM
 	return func(ctx echo.Context) error {
 		requestReceivedTime := ctx.Get(regsercontext.RequestReceivedTime).(time.Time)
 		workspace, err := GetUserWorkspaceWithBindings(ctx, spaceLister, ctx.Param("workspace"), GetMembersFunc)
+		if err == nil && workspace == nil {
+			// the workspace may be a sub-workspace which is requested with a SpaceRequest
+			workspace, err = GetUserSubWorkspaceRequest(ctx, spaceLister, ctx.Param("workspace"), GetMembersFunc)
+		}
 		if err != nil {
 			spaceLister.ProxyMetrics.RegServWorkspaceHistogramVec.WithLabelValues(fmt.Sprintf("%d", http.StatusInternalServerError), metrics.MetricsLabelVerbGet).Observe(time.Since(requestReceivedTime).Seconds()) // using list as the default value for verb to minimize label combinations for prometheus to process
 			return errorResponse(ctx, apierrors.NewInternalError(err))
@@ -55,19 +60,7 @@ func GetUserWorkspace(ctx echo.Context, spaceLister *SpaceLister, workspaceName
 	}
 
 	// recursively get all the spacebindings for the current workspace
-	listSpaceBindingsFunc := func(spaceName string) ([]toolchainv1alpha1.SpaceBinding, error) {
-		spaceSelector, err := labels.NewRequirement(toolchainv1alpha1.SpaceBindingSpaceLabelKey, selection.Equals, []string{spaceName})
-		if err != nil {
-			return nil, err
-		}
-		murSelector, err := labels.NewRequirement(toolchainv1alpha1.SpaceBindingMasterUserRecordLabelKey, selection.Equals, []string{userSignup.CompliantUsername})
-		if err != nil {
-			return nil, err
-		}
-		return spaceLister.GetInformerServiceFunc().ListSpaceBindings(*spaceSelector, *murSelector)
-	}
-	spaceBindingLister := spacebinding.NewLister(listSpaceBindingsFunc, spaceLister.GetInformerServiceFunc().GetSpace)
-	userSpaceBindings, err := spaceBindingLister.ListForSpace(space, []toolchainv1alpha1.SpaceBinding{})
+	userSpaceBindings, err := listEffectiveSpaceBindings(spaceLister, space, userSignup.CompliantUsername)
 	if err != nil {
 		ctx.Logger().Error(err, "failed to list space bindings")
 		return nil, err
@@ -99,15 +92,7 @@ func GetUserWorkspaceWithBindings(ctx echo.Context, spaceLister *SpaceLister, wo
 	}
 
 	// recursively get all the spacebindings for the current workspace
-	listSpaceBindingsFunc := func(spaceName string) ([]toolchainv1alpha1.SpaceBinding, error) {
-		spaceSelector, err := labels.NewRequirement(toolchainv1alpha1.SpaceBindingSpaceLabelKey, selection.Equals, []string{spaceName})
-		if err != nil {
-			return nil, err
-		}
-		return spaceLister.GetInformerServiceFunc().ListSpaceBindings(*spaceSelector)
-	}
-	spaceBindingLister := spacebinding.NewLister(listSpaceBindingsFunc, spaceLister.GetInformerServiceFunc().GetSpace)
-	allSpaceBindings, err := spaceBindingLister.ListForSpace(space, []toolchainv1alpha1.SpaceBinding{})
+	allSpaceBindings, err := listEffectiveSpaceBindings(spaceLister, space, "")
 	if err != nil {
 		ctx.Logger().Error(err, "failed to list space bindings")
 		return nil, err
@@ -148,6 +133,33 @@ func GetUserWorkspaceWithBindings(ctx echo.Context, spaceLister *SpaceLister, wo
 	), nil
 }
 
+// listEffectiveSpaceBindings returns the SpaceBindings of the given MUR for the given space, including the ones inherited from
+// the parent spaces. An empty MUR name returns the SpaceBindings of all the users. The SpaceBindings are listed with the index
+// of the informer service when available, otherwise the parent spaces and their SpaceBindings are listed recursively.
+func listEffectiveSpaceBindings(spaceLister *SpaceLister, space *toolchainv1alpha1.Space, murName string) ([]toolchainv1alpha1.SpaceBinding, error) {
+	informerService := spaceLister.GetInformerServiceFunc()
+	if lister, ok := informerService.(service.EffectiveSpaceBindingLister); ok {
+		return lister.ListEffectiveSpaceBindings(space, murName)
+	}
+	listSpaceBindingsFunc := func(spaceName string) ([]toolchainv1alpha1.SpaceBinding, error) {
+		requirements := []labels.Requirement{}
+		spaceSelector, err := labels.NewRequirement(toolchainv1alpha1.SpaceBindingSpaceLabelKey, selection.Equals, []string{spaceName})
+		if err != nil {
+			return nil, err
+		}
+		requirements = append(requirements, *spaceSelector)
+		if murName != "" {
+			murSelector, err := labels.NewRequirement(toolchainv1alpha1.SpaceBindingMasterUserRecordLabelKey, selection.Equals, []string{murName})
+			if err != nil {
+				return nil, err
+			}
+			requirements = append(requirements, *murSelector)
+		}
+		return informerService.ListSpaceBindings(requirements...)
+	}
+	return spacebinding.NewLister(listSpaceBindingsFunc, informerService.GetSpace).ListForSpace(space, []toolchainv1alpha1.SpaceBinding{})
+}
+
 // getUserSignupAndSpace returns the space and the usersignup for a given request.
 // When no space is found a nil value is returned instead of an error.
 func getUserSignupAndSpace(ctx echo.Context, spaceLister *SpaceLister, workspaceName string) (*signup.Signup, *toolchainv1alpha1.Space, error) {
@@ -564,6 +564,8 @@ func TestSpaceListerGet(t *testing.T) {
 				if tc.overrideGetMembersFunc != nil {
 					getMembersFunc = tc.overrideGetMembersFunc
 				}
+				// the SpaceRequests are looked up on behalf of the user when the workspace is not found
+				s.NewImpersonatingClientFunc = newImpersonatingClientFunc(memberClient, nil)
 				err := handlers.HandleSpaceGetRequest(s, getMembersFunc)(ctx)
 
 				// then
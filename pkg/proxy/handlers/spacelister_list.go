@@ -1,34 +1,175 @@
 package handlers
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
 	"github.com/codeready-toolchain/registration-service/pkg/context"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
 	"github.com/codeready-toolchain/registration-service/pkg/proxy/metrics"
+	"github.com/codeready-toolchain/registration-service/pkg/signup"
 	"github.com/labstack/echo/v4"
 	errs "github.com/pkg/errors"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/selection"
 )
 
 func HandleSpaceListRequest(spaceLister *SpaceLister) echo.HandlerFunc {
 	return func(ctx echo.Context) error {
+		options, err := parseListOptions(ctx)
+		if err != nil {
+			return errorResponse(ctx, apierrors.NewBadRequest(err.Error()))
+		}
+		if watch, _ := strconv.ParseBool(ctx.QueryParam("watch")); watch {
+			return handleSpaceWatchRequest(ctx, spaceLister, options)
+		}
 		// list all user workspaces
 		requestReceivedTime := ctx.Get(context.RequestReceivedTime).(time.Time)
+		observed := ""
+		if spaceLister.Watcher != nil {
+			// the changes received before the list are reflected by the list, so the watches can be resumed from their version
+			observed = spaceLister.Watcher.resourceVersion()
+		}
 		workspaces, err := ListUserWorkspaces(ctx, spaceLister)
 		if err != nil {
 			spaceLister.ProxyMetrics.RegServWorkspaceHistogramVec.WithLabelValues(fmt.Sprintf("%d", http.StatusInternalServerError), metrics.MetricsLabelVerbList).Observe(time.Since(requestReceivedTime).Seconds()) // using list as the default value for verb to minimize label combinations for prometheus to process
 			return errorResponse(ctx, apierrors.NewInternalError(err))
 		}
 		spaceLister.ProxyMetrics.RegServWorkspaceHistogramVec.WithLabelValues(fmt.Sprintf("%d", http.StatusOK), metrics.MetricsLabelVerbList).Observe(time.Since(requestReceivedTime).Seconds())
-		return listWorkspaceResponse(ctx, workspaces)
+		workspaceList := options.apply(workspaces)
+		workspaceList.ResourceVersion = latestResourceVersion(workspaceList.ResourceVersion, observed)
+		if asTable(ctx.Request()) {
+			return tableResponse(ctx, spaceLister, workspaceList)
+		}
+		return listWorkspaceResponse(ctx, workspaceList)
+	}
+}
+
+// listOptions are the options of the list and watch requests of the workspaces, set with the query parameters
+type listOptions struct {
+	labelSelector labels.Selector
+	fieldSelector fields.Selector
+	// limit is the maximum number of workspaces returned in a page, or 0 if the workspaces are not paginated
+	limit int
+	// start is the name of the first workspace returned in the page
+	start string
+}
+
+// workspaceFields are the fields of the workspaces supported by the field selectors
+var workspaceFields = []string{"metadata.name", "status.type", "status.role", "status.owner"}
+
+func parseListOptions(ctx echo.Context) (*listOptions, error) {
+	options := &listOptions{
+		labelSelector: labels.Everything(),
+		fieldSelector: fields.Everything(),
+	}
+	if value := ctx.QueryParam("labelSelector"); value != "" {
+		selector, err := labels.Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid labelSelector '%s': %w", value, err)
+		}
+		options.labelSelector = selector
+	}
+	if value := ctx.QueryParam("fieldSelector"); value != "" {
+		selector, err := fields.ParseSelector(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fieldSelector '%s': %w", value, err)
+		}
+		for _, requirement := range selector.Requirements() {
+			if !contains(workspaceFields, requirement.Field) {
+				return nil, fmt.Errorf("field '%s' is not supported by the fieldSelector, the supported fields are %s", requirement.Field, strings.Join(workspaceFields, ", "))
+			}
+		}
+		options.fieldSelector = selector
+	}
+	if value := ctx.QueryParam("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			return nil, fmt.Errorf("invalid limit '%s'", value)
+		}
+		options.limit = limit
+	}
+	if value := ctx.QueryParam("continue"); value != "" {
+		start, err := base64.RawURLEncoding.DecodeString(value)
+		if err != nil || len(start) == 0 {
+			return nil, fmt.Errorf("invalid continue token '%s'", value)
+		}
+		options.start = string(start)
+	}
+	return options, nil
+}
+
+// matches returns true if the given workspace matches the label and field selectors
+func (o *listOptions) matches(workspace *toolchainv1alpha1.Workspace) bool {
+	return o.labelSelector.Matches(labels.Set(workspace.Labels)) && o.fieldSelector.Matches(fields.Set{
+		"metadata.name": workspace.Name,
+		"status.type":   workspace.Status.Type,
+		"status.role":   workspace.Status.Role,
+		"status.owner":  workspace.Status.Owner,
+	})
+}
+
+// filter returns the workspaces which match the label and field selectors
+func (o *listOptions) filter(workspaces []toolchainv1alpha1.Workspace) []toolchainv1alpha1.Workspace {
+	filtered := []toolchainv1alpha1.Workspace{}
+	for i := range workspaces {
+		if o.matches(&workspaces[i]) {
+			filtered = append(filtered, workspaces[i])
+		}
+	}
+	return filtered
+}
+
+// apply returns the page of the workspaces sorted by name which match the selectors. The continue token of the list
+// is the encoded name of the first workspace of the next page.
+func (o *listOptions) apply(workspaces []toolchainv1alpha1.Workspace) *toolchainv1alpha1.WorkspaceList {
+	workspaceList := &toolchainv1alpha1.WorkspaceList{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "WorkspaceList",
+			APIVersion: "toolchain.dev.openshift.com/v1alpha1",
+		},
+	}
+	// the watches can start from the version of the list, which is the same for all the pages
+	for _, workspace := range workspaces {
+		workspaceList.ResourceVersion = latestResourceVersion(workspaceList.ResourceVersion, workspace.ResourceVersion)
+	}
+	items := o.filter(workspaces)
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Name < items[j].Name
+	})
+	if o.start != "" {
+		first := sort.Search(len(items), func(i int) bool {
+			return items[i].Name >= o.start
+		})
+		items = items[first:]
 	}
+	if o.limit > 0 && len(items) > o.limit {
+		remaining := int64(len(items) - o.limit)
+		workspaceList.Continue = base64.RawURLEncoding.EncodeToString([]byte(items[o.limit].Name))
+		workspaceList.RemainingItemCount = &remaining
+		items = items[:o.limit]
+	}
+	workspaceList.Items = items
+	return workspaceList
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
 }
 
 // ListUserWorkspaces returns a list of Workspaces for the current user.
@@ -42,26 +183,26 @@ func ListUserWorkspaces(ctx echo.Context, spaceLister *SpaceLister) ([]toolchain
 	if signup == nil {
 		return []toolchainv1alpha1.Workspace{}, nil
 	}
-	murName := signup.CompliantUsername
-
-	// get all spacebindings with given mur since no workspace was provided
-	spaceBindings, err := listSpaceBindingsForUser(spaceLister, murName)
+	workspaces, err := spaceLister.ListWorkspaces(signup)
 	if err != nil {
 		ctx.Logger().Error(errs.Wrap(err, "error listing space bindings"))
 		return nil, err
 	}
-	return workspacesFromSpaceBindings(ctx, spaceLister, signup.Name, spaceBindings), nil
+	return workspaces, nil
 }
 
-func listWorkspaceResponse(ctx echo.Context, workspaces []toolchainv1alpha1.Workspace) error {
-	workspaceList := &toolchainv1alpha1.WorkspaceList{
-		TypeMeta: metav1.TypeMeta{
-			Kind:       "WorkspaceList",
-			APIVersion: "toolchain.dev.openshift.com/v1alpha1",
-		},
-		Items: workspaces,
+// ListWorkspaces returns the Workspaces of the given provisioned user, ie. the Workspaces the user has a SpaceBinding for.
+// Unlike ListUserWorkspaces, it does not depend on the context of a proxied request.
+func (s *SpaceLister) ListWorkspaces(signup *signup.Signup) ([]toolchainv1alpha1.Workspace, error) {
+	// get all spacebindings with given mur since no workspace was provided
+	spaceBindings, err := listSpaceBindingsForUser(s, signup.CompliantUsername)
+	if err != nil {
+		return nil, err
 	}
+	return workspacesFromSpaceBindings(s, signup.Name, spaceBindings), nil
+}
 
+func listWorkspaceResponse(ctx echo.Context, workspaceList *toolchainv1alpha1.WorkspaceList) error {
 	ctx.Response().Writer.Header().Set("Content-Type", "application/json")
 	ctx.Response().Writer.WriteHeader(http.StatusOK)
 	return json.NewEncoder(ctx.Response().Writer).Encode(workspaceList)
@@ -76,7 +217,7 @@ func listSpaceBindingsForUser(spaceLister *SpaceLister, murName string) ([]toolc
 	return spaceLister.GetInformerServiceFunc().ListSpaceBindings(requirements...)
 }
 
-func workspacesFromSpaceBindings(ctx echo.Context, spaceLister *SpaceLister, signupName string, spaceBindings []toolchainv1alpha1.SpaceBinding) []toolchainv1alpha1.Workspace {
+func workspacesFromSpaceBindings(spaceLister *SpaceLister, signupName string, spaceBindings []toolchainv1alpha1.SpaceBinding) []toolchainv1alpha1.Workspace {
 	workspaces := []toolchainv1alpha1.Workspace{}
 	for i := range spaceBindings {
 		spacebinding := &spaceBindings[i]
@@ -84,7 +225,7 @@ func workspacesFromSpaceBindings(ctx echo.Context, spaceLister *SpaceLister, sig
 		if err != nil {
 			// log error and continue so that the api behaves in a best effort manner
 			// ie. if a space isn't listed something went wrong but we still want to return the other spaces if possible
-			ctx.Logger().Error(nil, err, "unable to get space", "space", spacebinding.Labels[toolchainv1alpha1.SpaceBindingSpaceLabelKey])
+			log.Error(nil, err, fmt.Sprintf("unable to get space '%s'", spacebinding.Labels[toolchainv1alpha1.SpaceBindingSpaceLabelKey]))
 			continue
 		}
 		workspace := createWorkspaceObject(signupName, space, spacebinding)
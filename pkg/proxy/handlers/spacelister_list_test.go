@@ -1,6 +1,7 @@
 package handlers_test
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -13,6 +14,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
@@ -145,4 +147,139 @@ func TestSpaceListerList(t *testing.T) {
 			})
 		}
 	})
+	t.Run("HandleSpaceListRequest with list options", func(t *testing.T) {
+		// given
+		s := &handlers.SpaceLister{
+			GetSignupFunc:          fakeSignupService.GetSignupFromInformer,
+			GetInformerServiceFunc: fake.GetInformerService(fakeClient),
+			ProxyMetrics:           metrics.NewProxyMetrics(prometheus.NewRegistry()),
+		}
+		list := func(t *testing.T, query, accept string) *httptest.ResponseRecorder {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/"+query, strings.NewReader(""))
+			if accept != "" {
+				req.Header.Set("Accept", accept)
+			}
+			rec := httptest.NewRecorder()
+			ctx := e.NewContext(req, rec)
+			ctx.Set(rcontext.UsernameKey, "dance.lover")
+			ctx.Set(rcontext.RequestReceivedTime, time.Now())
+			require.NoError(t, handlers.HandleSpaceListRequest(s)(ctx))
+			return rec
+		}
+		names := func(t *testing.T, rec *httptest.ResponseRecorder) []string {
+			require.Equal(t, http.StatusOK, rec.Code)
+			workspaceList, err := decodeResponseToWorkspaceList(rec.Body.Bytes())
+			require.NoError(t, err)
+			names := []string{}
+			for _, ws := range workspaceList.Items {
+				names = append(names, ws.Name)
+			}
+			return names
+		}
+
+		t.Run("sorted by name", func(t *testing.T) {
+			// when
+			rec := list(t, "", "")
+
+			// then
+			assert.Equal(t, []string{"dancelover", "movielover"}, names(t, rec))
+		})
+
+		t.Run("label selector", func(t *testing.T) {
+			// when
+			rec := list(t, "?labelSelector="+toolchainv1alpha1.SpaceCreatorLabelKey+"%3Dmovielover", "")
+
+			// then
+			assert.Equal(t, []string{"movielover"}, names(t, rec))
+		})
+
+		t.Run("field selector", func(t *testing.T) {
+			// when
+			rec := list(t, "?fieldSelector=status.type%3Dhome", "")
+
+			// then
+			assert.Equal(t, []string{"dancelover"}, names(t, rec))
+		})
+
+		t.Run("limit and continue", func(t *testing.T) {
+			// when
+			rec := list(t, "?limit=1", "")
+
+			// then
+			firstPage, err := decodeResponseToWorkspaceList(rec.Body.Bytes())
+			require.NoError(t, err)
+			require.Len(t, firstPage.Items, 1)
+			assert.Equal(t, "dancelover", firstPage.Items[0].Name)
+			require.NotEmpty(t, firstPage.Continue)
+			require.NotNil(t, firstPage.RemainingItemCount)
+			assert.Equal(t, int64(1), *firstPage.RemainingItemCount)
+
+			// when
+			rec = list(t, "?limit=1&continue="+firstPage.Continue, "")
+
+			// then
+			secondPage, err := decodeResponseToWorkspaceList(rec.Body.Bytes())
+			require.NoError(t, err)
+			require.Len(t, secondPage.Items, 1)
+			assert.Equal(t, "movielover", secondPage.Items[0].Name)
+			assert.Empty(t, secondPage.Continue)
+			assert.Nil(t, secondPage.RemainingItemCount)
+			assert.Equal(t, firstPage.ResourceVersion, secondPage.ResourceVersion)
+		})
+
+		t.Run("invalid options", func(t *testing.T) {
+			for query, expectedErr := range map[string]string{
+				"?labelSelector=a%3D%3D%3Db":   "invalid labelSelector",
+				"?fieldSelector=spec.tier%3Db": "field 'spec.tier' is not supported by the fieldSelector",
+				"?limit=-1":                    "invalid limit '-1'",
+				"?continue=%21":                "invalid continue token '!'",
+			} {
+				t.Run(query, func(t *testing.T) {
+					// when
+					rec := list(t, query, "")
+
+					// then
+					assert.Equal(t, http.StatusBadRequest, rec.Code)
+					assert.Contains(t, rec.Body.String(), expectedErr)
+				})
+			}
+		})
+
+		t.Run("table", func(t *testing.T) {
+			// when
+			rec := list(t, "?fieldSelector=metadata.name%3Ddancelover", "application/json;as=Table;v=v1;g=meta.k8s.io,application/json")
+
+			// then
+			require.Equal(t, http.StatusOK, rec.Code)
+			table := &metav1.Table{}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), table))
+			assert.Equal(t, "Table", table.Kind)
+			columns := []string{}
+			for _, column := range table.ColumnDefinitions {
+				columns = append(columns, column.Name)
+			}
+			assert.Equal(t, []string{"Name", "Type", "Role", "Owner", "Age", "Target Cluster", "Namespaces"}, columns)
+			require.Len(t, table.Rows, 1)
+			cells := table.Rows[0].Cells
+			assert.Equal(t, []interface{}{"dancelover", "home", "admin", "dancelover"}, cells[:4])
+			assert.Equal(t, []interface{}{"member-1", "dancelover-dev,dancelover-stage"}, cells[5:])
+			metadata := &metav1.PartialObjectMetadata{}
+			require.NoError(t, json.Unmarshal(table.Rows[0].Object.Raw, metadata))
+			assert.Equal(t, "PartialObjectMetadata", metadata.Kind)
+			assert.Equal(t, "dancelover", metadata.Name)
+		})
+
+		t.Run("table without objects", func(t *testing.T) {
+			// when
+			rec := list(t, "?includeObject=None", "application/json;as=Table;v=v1;g=meta.k8s.io")
+
+			// then
+			require.Equal(t, http.StatusOK, rec.Code)
+			table := &metav1.Table{}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), table))
+			require.Len(t, table.Rows, 2)
+			assert.Empty(t, table.Rows[0].Object.Raw)
+		})
+	})
 }
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+
+	"github.com/labstack/echo/v4"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/duration"
+)
+
+// workspaceColumns are the columns of the Table of the workspaces. The columns with a priority are only displayed in the
+// wide output of kubectl.
+var workspaceColumns = []metav1.TableColumnDefinition{
+	{Name: "Name", Type: "string", Format: "name", Description: "Name of the workspace"},
+	{Name: "Type", Type: "string", Description: "Type of the workspace, eg. home for the home workspace of the user"},
+	{Name: "Role", Type: "string", Description: "Role of the user in the workspace"},
+	{Name: "Owner", Type: "string", Description: "Name of the UserSignup which owns the workspace"},
+	{Name: "Age", Type: "string", Description: "Time elapsed since the creation of the workspace"},
+	{Name: "Target Cluster", Type: "string", Priority: 1, Description: "Cluster where the workspace is provisioned"},
+	{Name: "Namespaces", Type: "string", Priority: 1, Description: "Namespaces of the workspace"},
+}
+
+// asTable returns true if the client asks for a Table of the workspaces, as kubectl does with `Accept: application/json;as=Table;v=v1;g=meta.k8s.io`
+func asTable(req *http.Request) bool {
+	for _, accept := range strings.Split(req.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err != nil || mediaType != "application/json" || params["as"] != "Table" {
+			continue
+		}
+		if (params["g"] == "" || params["g"] == metav1.GroupName) && (params["v"] == "" || params["v"] == "v1") {
+			return true
+		}
+	}
+	return false
+}
+
+// tableResponse writes the given workspaces as a Table. The objects of the rows are set according to the `includeObject`
+// query parameter: `None`, `Metadata` (the default) or `Object`.
+func tableResponse(ctx echo.Context, spaceLister *SpaceLister, workspaceList *toolchainv1alpha1.WorkspaceList) error {
+	includeObject := metav1.IncludeObjectPolicy(ctx.QueryParam("includeObject"))
+	switch includeObject {
+	case "":
+		includeObject = metav1.IncludeMetadata
+	case metav1.IncludeNone, metav1.IncludeMetadata, metav1.IncludeObject:
+	default:
+		return errorResponse(ctx, apierrors.NewBadRequest("invalid includeObject '"+string(includeObject)+"', it must be None, Metadata or Object"))
+	}
+	table := &metav1.Table{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Table",
+			APIVersion: metav1.SchemeGroupVersion.String(),
+		},
+		ListMeta:          workspaceList.ListMeta,
+		ColumnDefinitions: workspaceColumns,
+		Rows:              []metav1.TableRow{},
+	}
+	for i := range workspaceList.Items {
+		workspace := &workspaceList.Items[i]
+		row := metav1.TableRow{
+			Cells: []interface{}{
+				workspace.Name,
+				workspace.Status.Type,
+				workspace.Status.Role,
+				workspace.Status.Owner,
+				age(workspace.CreationTimestamp),
+				targetCluster(spaceLister, workspace.Name),
+				namespaces(workspace.Status.Namespaces),
+			},
+		}
+		switch includeObject {
+		case metav1.IncludeMetadata:
+			row.Object = runtime.RawExtension{Object: &metav1.PartialObjectMetadata{
+				TypeMeta: metav1.TypeMeta{
+					Kind:       "PartialObjectMetadata",
+					APIVersion: metav1.SchemeGroupVersion.String(),
+				},
+				ObjectMeta: workspace.ObjectMeta,
+			}}
+		case metav1.IncludeObject:
+			row.Object = runtime.RawExtension{Object: workspace}
+		}
+		table.Rows = append(table.Rows, row)
+	}
+	ctx.Response().Writer.Header().Set("Content-Type", "application/json")
+	ctx.Response().Writer.WriteHeader(http.StatusOK)
+	return json.NewEncoder(ctx.Response().Writer).Encode(table)
+}
+
+func age(creationTimestamp metav1.Time) string {
+	if creationTimestamp.IsZero() {
+		return "<unknown>"
+	}
+	return duration.HumanDuration(time.Since(creationTimestamp.Time))
+}
+
+// targetCluster returns the cluster of the space of the given workspace, or an empty string if it is unknown
+func targetCluster(spaceLister *SpaceLister, workspaceName string) string {
+	space, err := spaceLister.GetInformerServiceFunc().GetSpace(workspaceName)
+	if err != nil {
+		// the other columns are still displayed
+		log.Error(nil, err, "unable to get space '"+workspaceName+"'")
+		return ""
+	}
+	return space.Status.TargetCluster
+}
+
+func namespaces(spaceNamespaces []toolchainv1alpha1.SpaceNamespace) string {
+	names := make([]string, 0, len(spaceNamespaces))
+	for _, ns := range spaceNamespaces {
+		names = append(names, ns.Name)
+	}
+	return strings.Join(names, ",")
+}
@@ -2,10 +2,12 @@ package handlers_test
 
 import (
 	"context"
+	"fmt"
 	"testing"
 
 	"github.com/codeready-toolchain/toolchain-common/pkg/test"
 	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
 	"k8s.io/apimachinery/pkg/types"
@@ -16,6 +18,7 @@ import (
 	"github.com/codeready-toolchain/registration-service/pkg/configuration"
 	"github.com/codeready-toolchain/registration-service/pkg/signup"
 	"github.com/codeready-toolchain/registration-service/test/fake"
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
 	commonproxy "github.com/codeready-toolchain/toolchain-common/pkg/proxy"
 	spacetest "github.com/codeready-toolchain/toolchain-common/pkg/test/space"
 )
@@ -161,9 +164,55 @@ func workspaceFor(t *testing.T, fakeClient client.Client, name, role string, isH
 	ws := commonproxy.NewWorkspace(name,
 		append(commonWSoptions, additionalWSOptions...)...,
 	)
+	ws.Labels = space.Labels
 	// if the user is the same as the one who created the workspace, then expect type should be "home"
 	if isHomeWorkspace {
 		ws.Status.Type = "home"
 	}
 	return *ws
 }
+
+// newImpersonatingClientFunc returns a func which returns the given client of the member cluster on behalf of a user, which
+// can only write in the given namespaces of the user, as if the other writes were not allowed by the RBAC of the member cluster
+func newImpersonatingClientFunc(memberClient client.Client, writableNamespaces map[string][]string) func(*cluster.CachedToolchainCluster, string) (client.Client, error) {
+	return func(_ *cluster.CachedToolchainCluster, username string) (client.Client, error) {
+		return impersonatingClient{Client: memberClient, username: username, writableNamespaces: writableNamespaces[username]}, nil
+	}
+}
+
+// impersonatingClient is a client of a member cluster on behalf of a user, which can only write in the given namespaces
+type impersonatingClient struct {
+	client.Client
+	username           string
+	writableNamespaces []string
+}
+
+func (c impersonatingClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if err := c.authorize(obj); err != nil {
+		return err
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c impersonatingClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if err := c.authorize(obj); err != nil {
+		return err
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func (c impersonatingClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	if err := c.authorize(obj); err != nil {
+		return err
+	}
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+func (c impersonatingClient) authorize(obj client.Object) error {
+	for _, namespace := range c.writableNamespaces {
+		if obj.GetNamespace() == namespace {
+			return nil
+		}
+	}
+	return apierrors.NewForbidden(toolchainv1alpha1.GroupVersion.WithResource("spacerequests").GroupResource(), obj.GetName(), fmt.Errorf("user '%s' cannot write in namespace '%s'", c.username, obj.GetNamespace()))
+}
@@ -0,0 +1,324 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	regsercontext "github.com/codeready-toolchain/registration-service/pkg/context"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/metrics"
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/labstack/echo/v4"
+	errs "github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var workspacesResource = schema.GroupResource{Group: "toolchain.dev.openshift.com", Resource: "workspaces"}
+
+// HandleSpaceUpdateRequest handles the PUT and PATCH requests of a workspace, which change the bindings of the workspace.
+// The PUT requests contain the whole workspace, while the PATCH requests contain a JSON merge patch or a JSON patch of the
+// workspace. Only the `status.bindings` of the workspace can be changed, by the users allowed to manage the SpaceBindingRequests
+// in the namespaces of the workspace, since the SpaceBindingRequests are managed on behalf of the user:
+//   - a binding which is not in the current bindings is created with a new SpaceBindingRequest in the default namespace of the workspace
+//   - a binding with the `update` action can have its role changed, and a binding with the `delete` action can be removed,
+//     by updating or deleting its SpaceBindingRequest
+//   - a binding with the `override` action (ie. inherited from a parent workspace) can have its role changed with a new SpaceBindingRequest
+//
+// The bindings of a PUT request must be set, an empty list removes all the bindings which can be removed. The roles must be
+// available in the tier of the workspace. The changes are applied all together or not at all. The response is the workspace
+// with its new bindings, which are reported with their new role once the SpaceBindingRequests are reconciled.
+func HandleSpaceUpdateRequest(spaceLister *SpaceLister, GetMembersFunc cluster.GetMemberClustersFunc) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		requestReceivedTime := ctx.Get(regsercontext.RequestReceivedTime).(time.Time)
+		verb := metrics.MetricsLabelVerbUpdate
+		if ctx.Request().Method == http.MethodPatch {
+			verb = metrics.MetricsLabelVerbPatch
+		}
+		workspace, err := updateUserWorkspaceBindings(ctx, spaceLister, ctx.Param("workspace"), GetMembersFunc)
+		if err != nil {
+			statusErr := toStatusError(err)
+			spaceLister.ProxyMetrics.RegServWorkspaceHistogramVec.WithLabelValues(fmt.Sprintf("%d", statusErr.ErrStatus.Code), verb).Observe(time.Since(requestReceivedTime).Seconds())
+			return errorResponse(ctx, statusErr)
+		}
+		spaceLister.ProxyMetrics.RegServWorkspaceHistogramVec.WithLabelValues(fmt.Sprintf("%d", http.StatusOK), verb).Observe(time.Since(requestReceivedTime).Seconds())
+		return getWorkspaceResponse(ctx, workspace)
+	}
+}
+
+// updateUserWorkspaceBindings applies the bindings of the requested workspace, and returns the workspace with its new bindings
+func updateUserWorkspaceBindings(ctx echo.Context, spaceLister *SpaceLister, workspaceName string, GetMembersFunc cluster.GetMemberClustersFunc) (*toolchainv1alpha1.Workspace, error) {
+	current, err := GetUserWorkspaceWithBindings(ctx, spaceLister, workspaceName, GetMembersFunc)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil {
+		return nil, apierrors.NewNotFound(workspacesResource, workspaceName)
+	}
+	desired, err := desiredWorkspace(ctx.Request(), current)
+	if err != nil {
+		return nil, err
+	}
+	if desired.Name != "" && desired.Name != current.Name {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("the name of the workspace '%s' does not match the requested workspace '%s'", desired.Name, current.Name))
+	}
+	if desired.ResourceVersion != "" && desired.ResourceVersion != current.ResourceVersion {
+		return nil, apierrors.NewConflict(workspacesResource, workspaceName, fmt.Errorf("the workspace has been modified, please apply your changes to the latest version and try again"))
+	}
+	if ctx.Request().Method == http.MethodPut && desired.Status.Bindings == nil {
+		// the bindings are not removed because a client did not send the status of the workspace
+		return nil, apierrors.NewBadRequest("the status.bindings of the workspace must be set")
+	}
+	changes, err := bindingChanges(current, desired.Status.Bindings)
+	if err != nil {
+		return nil, err
+	}
+	if len(changes) > 0 {
+		userSignup, err := spaceLister.GetProvisionedUserSignup(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if userSignup == nil {
+			return nil, apierrors.NewNotFound(workspacesResource, workspaceName)
+		}
+		space, err := spaceLister.GetInformerServiceFunc().GetSpace(workspaceName)
+		if err != nil {
+			return nil, errs.Wrap(err, "unable to get space")
+		}
+		// the role of the user in the workspace is checked by the member cluster
+		memberClient, err := impersonatingMemberClientFor(spaceLister, GetMembersFunc, space, userSignup.CompliantUsername)
+		if err != nil {
+			return nil, err
+		}
+		if err := applyBindingChanges(ctx, memberClient, space, changes); err != nil {
+			return nil, err
+		}
+	}
+	return GetUserWorkspaceWithBindings(ctx, spaceLister, workspaceName, GetMembersFunc)
+}
+
+// desiredWorkspace returns the workspace in the body of a PUT request, or the current workspace patched with the body of a PATCH request
+func desiredWorkspace(req *http.Request, current *toolchainv1alpha1.Workspace) (*toolchainv1alpha1.Workspace, error) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("unable to read the request body: %s", err.Error()))
+	}
+	if req.Method == http.MethodPatch {
+		original, err := json.Marshal(current)
+		if err != nil {
+			return nil, err
+		}
+		contentType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+		switch contentType {
+		case string(types.MergePatchType):
+			body, err = jsonpatch.MergePatch(original, body)
+		case string(types.JSONPatchType):
+			var patch jsonpatch.Patch
+			if patch, err = jsonpatch.DecodePatch(body); err == nil {
+				body, err = patch.Apply(original)
+			}
+		default:
+			return nil, apierrors.NewGenericServerResponse(http.StatusUnsupportedMediaType, "patch", workspacesResource, current.Name,
+				fmt.Sprintf("the patch type '%s' is not supported, it must be '%s' or '%s'", contentType, types.MergePatchType, types.JSONPatchType), 0, false)
+		}
+		if err != nil {
+			return nil, apierrors.NewBadRequest(fmt.Sprintf("unable to apply the patch: %s", err.Error()))
+		}
+	}
+	desired := &toolchainv1alpha1.Workspace{}
+	if err := json.Unmarshal(body, desired); err != nil {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("unable to decode the workspace: %s", err.Error()))
+	}
+	return desired, nil
+}
+
+// bindingChange is a change of the binding of a user, applied by creating, updating or deleting a SpaceBindingRequest
+type bindingChange struct {
+	masterUserRecord string
+	// role is the new role of the user, or empty if the binding is deleted
+	role string
+	// bindingRequest is the SpaceBindingRequest to update or delete, or nil if a new one is created
+	bindingRequest *toolchainv1alpha1.BindingRequest
+}
+
+// bindingChanges returns the changes from the current bindings of the workspace to the desired ones. It returns a BadRequest
+// error if the bindings cannot be changed this way, so that no change is applied.
+func bindingChanges(current *toolchainv1alpha1.Workspace, desired []toolchainv1alpha1.Binding) ([]bindingChange, error) {
+	currentBindings := make(map[string]toolchainv1alpha1.Binding, len(current.Status.Bindings))
+	for _, binding := range current.Status.Bindings {
+		// a binding managed with a SpaceBindingRequest takes precedence, eg. when it overrides an inherited binding
+		if existing, found := currentBindings[binding.MasterUserRecord]; !found || existing.BindingRequest == nil {
+			currentBindings[binding.MasterUserRecord] = binding
+		}
+	}
+	desiredRoles := make(map[string]string, len(desired))
+	var changes []bindingChange
+	for _, binding := range desired {
+		if binding.MasterUserRecord == "" {
+			return nil, apierrors.NewBadRequest("the masterUserRecord of the bindings must be set")
+		}
+		if _, found := desiredRoles[binding.MasterUserRecord]; found {
+			return nil, apierrors.NewBadRequest(fmt.Sprintf("duplicate binding for user '%s'", binding.MasterUserRecord))
+		}
+		desiredRoles[binding.MasterUserRecord] = binding.Role
+		if !contains(current.Status.AvailableRoles, binding.Role) {
+			return nil, apierrors.NewBadRequest(fmt.Sprintf("invalid role '%s' for user '%s', the available roles are %s", binding.Role, binding.MasterUserRecord, strings.Join(current.Status.AvailableRoles, ", ")))
+		}
+		existing, found := currentBindings[binding.MasterUserRecord]
+		switch {
+		case !found:
+			changes = append(changes, bindingChange{masterUserRecord: binding.MasterUserRecord, role: binding.Role})
+		case existing.Role == binding.Role:
+			// nothing to change
+		case contains(existing.AvailableActions, UpdateBindingAction):
+			changes = append(changes, bindingChange{masterUserRecord: binding.MasterUserRecord, role: binding.Role, bindingRequest: existing.BindingRequest})
+		case contains(existing.AvailableActions, OverrideBindingAction):
+			changes = append(changes, bindingChange{masterUserRecord: binding.MasterUserRecord, role: binding.Role})
+		default:
+			return nil, apierrors.NewBadRequest(fmt.Sprintf("the role of user '%s' cannot be changed", binding.MasterUserRecord))
+		}
+	}
+	for _, existing := range current.Status.Bindings {
+		if _, found := desiredRoles[existing.MasterUserRecord]; found {
+			continue
+		}
+		if !contains(existing.AvailableActions, DeleteBindingAction) {
+			return nil, apierrors.NewBadRequest(fmt.Sprintf("the binding of user '%s' cannot be removed", existing.MasterUserRecord))
+		}
+		changes = append(changes, bindingChange{masterUserRecord: existing.MasterUserRecord, bindingRequest: existing.BindingRequest})
+	}
+	return changes, nil
+}
+
+// appliedBindingChange is a change of a binding which was applied to the given SpaceBindingRequest, so that it can be reverted
+type appliedBindingChange struct {
+	change bindingChange
+	sbr    *toolchainv1alpha1.SpaceBindingRequest
+	// previousRole is the role of the SpaceBindingRequest before it was updated
+	previousRole string
+}
+
+// applyBindingChanges creates, updates or deletes the SpaceBindingRequests of the given changes with the given client of the
+// member cluster of the space. The SpaceBindingRequests of all the changes are looked up before any change is applied, and
+// the applied changes are reverted if a change fails, so that the bindings are not partially changed.
+func applyBindingChanges(ctx echo.Context, memberClient runtimeclient.Client, space *toolchainv1alpha1.Space, changes []bindingChange) error {
+	sbrs := make([]*toolchainv1alpha1.SpaceBindingRequest, len(changes))
+	for i, change := range changes {
+		if change.bindingRequest == nil {
+			namespace := defaultNamespace(space)
+			if namespace == "" {
+				return apierrors.NewConflict(workspacesResource, space.Name, fmt.Errorf("the namespaces of the workspace are not provisioned yet"))
+			}
+			sbrs[i] = newSpaceBindingRequest(namespace, change.masterUserRecord, change.role)
+			continue
+		}
+		sbr := &toolchainv1alpha1.SpaceBindingRequest{}
+		if err := memberClient.Get(ctx.Request().Context(), types.NamespacedName{Name: change.bindingRequest.Name, Namespace: change.bindingRequest.Namespace}, sbr); err != nil {
+			if apierrors.IsNotFound(err) && change.role == "" {
+				// already deleted
+				continue
+			}
+			ctx.Logger().Error(errs.Wrapf(err, "unable to get the SpaceBindingRequest of user '%s' in space '%s'", change.masterUserRecord, space.Name))
+			return err
+		}
+		sbrs[i] = sbr
+	}
+
+	applied := make([]appliedBindingChange, 0, len(changes))
+	for i, change := range changes {
+		sbr := sbrs[i]
+		if sbr == nil {
+			continue
+		}
+		previousRole := sbr.Spec.SpaceRole
+		var err error
+		switch {
+		case change.bindingRequest == nil:
+			err = memberClient.Create(ctx.Request().Context(), sbr)
+		case change.role == "":
+			if err = memberClient.Delete(ctx.Request().Context(), sbr); apierrors.IsNotFound(err) {
+				err = nil
+			}
+		default:
+			sbr.Spec.SpaceRole = change.role
+			err = memberClient.Update(ctx.Request().Context(), sbr)
+		}
+		if err != nil {
+			ctx.Logger().Error(errs.Wrapf(err, "unable to change the binding of user '%s' in space '%s'", change.masterUserRecord, space.Name))
+			if notReverted := revertBindingChanges(ctx, memberClient, space, applied); len(notReverted) > 0 {
+				return apierrors.NewInternalError(fmt.Errorf("unable to change the binding of user '%s': %s, and the changes of the bindings of users %s could not be reverted",
+					change.masterUserRecord, err.Error(), strings.Join(notReverted, ", ")))
+			}
+			return err
+		}
+		applied = append(applied, appliedBindingChange{change: change, sbr: sbr, previousRole: previousRole})
+	}
+	return nil
+}
+
+// revertBindingChanges reverts the given applied changes, and returns the users whose binding change could not be reverted
+func revertBindingChanges(ctx echo.Context, memberClient runtimeclient.Client, space *toolchainv1alpha1.Space, applied []appliedBindingChange) []string {
+	var notReverted []string
+	for i := len(applied) - 1; i >= 0; i-- {
+		a := applied[i]
+		// the changes are reverted even if the request is canceled
+		revertCtx := context.Background()
+		var err error
+		switch {
+		case a.change.bindingRequest == nil:
+			if err = memberClient.Delete(revertCtx, a.sbr); apierrors.IsNotFound(err) {
+				err = nil
+			}
+		case a.change.role == "":
+			err = memberClient.Create(revertCtx, newSpaceBindingRequest(a.sbr.Namespace, a.sbr.Spec.MasterUserRecord, a.sbr.Spec.SpaceRole))
+		default:
+			sbr := &toolchainv1alpha1.SpaceBindingRequest{}
+			if err = memberClient.Get(revertCtx, runtimeclient.ObjectKeyFromObject(a.sbr), sbr); err == nil {
+				sbr.Spec.SpaceRole = a.previousRole
+				err = memberClient.Update(revertCtx, sbr)
+			}
+		}
+		if err != nil {
+			ctx.Logger().Error(errs.Wrapf(err, "unable to revert the change of the binding of user '%s' in space '%s'", a.change.masterUserRecord, space.Name))
+			notReverted = append(notReverted, a.change.masterUserRecord)
+		}
+	}
+	return notReverted
+}
+
+// newSpaceBindingRequest returns a new SpaceBindingRequest of the given user and role in the given namespace
+func newSpaceBindingRequest(namespace, masterUserRecord, role string) *toolchainv1alpha1.SpaceBindingRequest {
+	return &toolchainv1alpha1.SpaceBindingRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: masterUserRecord + "-",
+			Namespace:    namespace,
+		},
+		Spec: toolchainv1alpha1.SpaceBindingRequestSpec{
+			MasterUserRecord: masterUserRecord,
+			SpaceRole:        role,
+		},
+	}
+}
+
+// defaultNamespace returns the default namespace of the space, or its first namespace if none is the default one
+func defaultNamespace(space *toolchainv1alpha1.Space) string {
+	for _, namespace := range space.Status.ProvisionedNamespaces {
+		if namespace.Type == "default" {
+			return namespace.Name
+		}
+	}
+	if len(space.Status.ProvisionedNamespaces) > 0 {
+		return space.Status.ProvisionedNamespaces[0].Name
+	}
+	return ""
+}
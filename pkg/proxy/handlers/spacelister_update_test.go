@@ -0,0 +1,401 @@
+package handlers_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	rcontext "github.com/codeready-toolchain/registration-service/pkg/context"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/handlers"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/metrics"
+	proxytest "github.com/codeready-toolchain/registration-service/pkg/proxy/test"
+	"github.com/codeready-toolchain/registration-service/test/fake"
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	spacebindingrequesttest "github.com/codeready-toolchain/toolchain-common/pkg/test/spacebindingrequest"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestSpaceListerUpdate(t *testing.T) {
+	log.Init("registration-service-testing")
+
+	// given
+	fakeSignupService, fakeClient := buildSpaceListerFakes(t)
+	s := &handlers.SpaceLister{
+		GetSignupFunc:          fakeSignupService.GetSignupFromInformer,
+		GetInformerServiceFunc: fake.GetInformerService(fakeClient),
+		ProxyMetrics:           metrics.NewProxyMetrics(prometheus.NewRegistry()),
+	}
+	newMemberClient := func(t *testing.T) *test.FakeClient {
+		return fake.InitClient(t,
+			spacebindingrequesttest.NewSpaceBindingRequest("animelover-sbr", "dancelover-dev",
+				spacebindingrequesttest.WithSpaceRole("viewer"),
+				spacebindingrequesttest.WithMUR("animelover"),
+			),
+			spacebindingrequesttest.NewSpaceBindingRequest("failing-sbr", "dancelover-dev",
+				spacebindingrequesttest.WithSpaceRole("admin"),
+				spacebindingrequesttest.WithMUR("someuser"),
+			),
+		)
+	}
+	var impersonatedUsers []string
+	update := func(t *testing.T, memberClient *test.FakeClient, username, workspace, method, contentType, body string) *httptest.ResponseRecorder {
+		impersonatedUsers = nil
+		s.NewImpersonatingClientFunc = func(_ *cluster.CachedToolchainCluster, username string) (runtimeclient.Client, error) {
+			impersonatedUsers = append(impersonatedUsers, username)
+			if username != "dancelover" {
+				// only the admins of the workspaces are allowed to manage the SpaceBindingRequests by the member cluster
+				forbidden := apierrors.NewForbidden(toolchainv1alpha1.GroupVersion.WithResource("spacebindingrequests").GroupResource(), "", fmt.Errorf("user '%s' cannot manage spacebindingrequests", username))
+				memberClient.MockCreate = func(context.Context, runtimeclient.Object, ...runtimeclient.CreateOption) error {
+					return forbidden
+				}
+				memberClient.MockUpdate = func(context.Context, runtimeclient.Object, ...runtimeclient.UpdateOption) error {
+					return forbidden
+				}
+				memberClient.MockDelete = func(context.Context, runtimeclient.Object, ...runtimeclient.DeleteOption) error {
+					return forbidden
+				}
+			}
+			return memberClient, nil
+		}
+		e := echo.New()
+		req := httptest.NewRequest(method, "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+		ctx := e.NewContext(req, rec)
+		ctx.Set(rcontext.UsernameKey, username)
+		ctx.Set(rcontext.RequestReceivedTime, time.Now())
+		ctx.SetParamNames("workspace")
+		ctx.SetParamValues(workspace)
+		require.NoError(t, handlers.HandleSpaceUpdateRequest(s, proxytest.NewGetMembersFunc(memberClient))(ctx))
+		return rec
+	}
+	danceloverBindings := func(bindings ...string) string {
+		return `{"status":{"bindings":[` + strings.Join(append([]string{
+			`{"masterUserRecord":"animelover","role":"viewer"}`,
+			`{"masterUserRecord":"dancelover","role":"admin"}`,
+			`{"masterUserRecord":"someuser","role":"admin"}`,
+		}, bindings...), ",") + `]}}`
+	}
+
+	t.Run("shares the workspace with a new user", func(t *testing.T) {
+		// given
+		memberClient := newMemberClient(t)
+
+		// when
+		rec := update(t, memberClient, "dance.lover", "dancelover", http.MethodPut, "application/json",
+			`{"metadata":{"name":"dancelover"},`+strings.TrimPrefix(danceloverBindings(`{"masterUserRecord":"carlover","role":"viewer"}`), "{"))
+
+		// then
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		sbr := assertSpaceBindingRequest(t, memberClient, "dancelover-dev", "carlover", "viewer")
+		workspace, err := decodeResponseToWorkspace(rec.Body.Bytes())
+		require.NoError(t, err)
+		assert.Contains(t, workspace.Status.Bindings, toolchainv1alpha1.Binding{
+			MasterUserRecord: "carlover",
+			Role:             "viewer",
+			AvailableActions: []string{"update", "delete"},
+			BindingRequest: &toolchainv1alpha1.BindingRequest{
+				Name:      sbr.Name,
+				Namespace: "dancelover-dev",
+			},
+		})
+	})
+
+	t.Run("changes the role of a user with a merge patch", func(t *testing.T) {
+		// given
+		memberClient := newMemberClient(t)
+
+		// when
+		rec := update(t, memberClient, "dance.lover", "dancelover", http.MethodPatch, "application/merge-patch+json",
+			strings.Replace(danceloverBindings(), `"animelover","role":"viewer"`, `"animelover","role":"admin"`, 1))
+
+		// then
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		sbr := &toolchainv1alpha1.SpaceBindingRequest{}
+		require.NoError(t, memberClient.Get(context.TODO(), types.NamespacedName{Name: "animelover-sbr", Namespace: "dancelover-dev"}, sbr))
+		assert.Equal(t, "admin", sbr.Spec.SpaceRole)
+		// the change is applied on behalf of the user
+		assert.Equal(t, []string{"dancelover"}, impersonatedUsers)
+	})
+
+	t.Run("removes a user with a JSON patch", func(t *testing.T) {
+		// given
+		memberClient := newMemberClient(t)
+
+		// when
+		rec := update(t, memberClient, "dance.lover", "dancelover", http.MethodPatch, "application/json-patch+json",
+			`[{"op":"test","path":"/status/bindings/2/masterUserRecord","value":"someuser"},{"op":"remove","path":"/status/bindings/2"}]`)
+
+		// then
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		sbrs := &toolchainv1alpha1.SpaceBindingRequestList{}
+		require.NoError(t, memberClient.List(context.TODO(), sbrs, runtimeclient.InNamespace("dancelover-dev")))
+		require.Len(t, sbrs.Items, 1)
+		assert.Equal(t, "animelover-sbr", sbrs.Items[0].Name)
+	})
+
+	t.Run("overrides an inherited binding", func(t *testing.T) {
+		// given
+		memberClient := newMemberClient(t)
+
+		// when
+		rec := update(t, memberClient, "dance.lover", "foodlover", http.MethodPatch, "application/merge-patch+json",
+			`{"status":{"bindings":[{"masterUserRecord":"animelover","role":"admin"},{"masterUserRecord":"dancelover","role":"admin"}]}}`)
+
+		// then
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assertSpaceBindingRequest(t, memberClient, "foodlover-dev", "animelover", "admin")
+	})
+
+	t.Run("nothing to change", func(t *testing.T) {
+		// given
+		memberClient := newMemberClient(t)
+
+		// when
+		rec := update(t, memberClient, "dance.lover", "dancelover", http.MethodPatch, "application/merge-patch+json", `{"metadata":{"labels":{"foo":"bar"}}}`)
+
+		// then
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		sbrs := &toolchainv1alpha1.SpaceBindingRequestList{}
+		require.NoError(t, memberClient.List(context.TODO(), sbrs))
+		assert.Len(t, sbrs.Items, 2)
+		assert.Empty(t, impersonatedUsers)
+	})
+
+	t.Run("reverts the applied changes when a change fails", func(t *testing.T) {
+		// given
+		memberClient := newMemberClient(t)
+		memberClient.MockDelete = func(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.DeleteOption) error {
+			if obj.GetName() == "failing-sbr" {
+				return fmt.Errorf("mock error")
+			}
+			return memberClient.Client.Delete(ctx, obj, opts...)
+		}
+		body := `{"metadata":{"name":"dancelover"},"status":{"bindings":[` +
+			`{"masterUserRecord":"animelover","role":"admin"},` +
+			`{"masterUserRecord":"carlover","role":"viewer"},` +
+			`{"masterUserRecord":"dancelover","role":"admin"}]}}`
+
+		// when
+		rec := update(t, memberClient, "dance.lover", "dancelover", http.MethodPut, "application/json", body)
+
+		// then
+		require.Equal(t, http.StatusInternalServerError, rec.Code, rec.Body.String())
+		require.Contains(t, rec.Body.String(), "mock error")
+		sbrs := &toolchainv1alpha1.SpaceBindingRequestList{}
+		require.NoError(t, memberClient.List(context.TODO(), sbrs))
+		assert.Len(t, sbrs.Items, 2)
+		for _, sbr := range sbrs.Items {
+			assert.Equal(t, map[string]string{"animelover-sbr": "viewer", "failing-sbr": "admin"}[sbr.Name], sbr.Spec.SpaceRole)
+		}
+	})
+
+	t.Run("reports the changes which could not be reverted", func(t *testing.T) {
+		// given
+		memberClient := newMemberClient(t)
+		memberClient.MockDelete = func(context.Context, runtimeclient.Object, ...runtimeclient.DeleteOption) error {
+			return fmt.Errorf("mock error")
+		}
+		body := `{"metadata":{"name":"dancelover"},"status":{"bindings":[` +
+			`{"masterUserRecord":"animelover","role":"viewer"},` +
+			`{"masterUserRecord":"carlover","role":"viewer"},` +
+			`{"masterUserRecord":"dancelover","role":"admin"}]}}`
+
+		// when
+		rec := update(t, memberClient, "dance.lover", "dancelover", http.MethodPut, "application/json", body)
+
+		// then
+		require.Equal(t, http.StatusInternalServerError, rec.Code, rec.Body.String())
+		require.Contains(t, rec.Body.String(), "unable to change the binding of user 'someuser': mock error, and the changes of the bindings of users carlover could not be reverted")
+	})
+
+	t.Run("changes nothing when a binding request is not found", func(t *testing.T) {
+		// given
+		memberClient := fake.InitClient(t,
+			spacebindingrequesttest.NewSpaceBindingRequest("failing-sbr", "dancelover-dev",
+				spacebindingrequesttest.WithSpaceRole("admin"),
+				spacebindingrequesttest.WithMUR("someuser"),
+			),
+		)
+		body := strings.Replace(danceloverBindings(`{"masterUserRecord":"carlover","role":"viewer"}`), `"animelover","role":"viewer"`, `"animelover","role":"admin"`, 1)
+
+		// when
+		rec := update(t, memberClient, "dance.lover", "dancelover", http.MethodPatch, "application/merge-patch+json", body)
+
+		// then
+		require.Equal(t, http.StatusNotFound, rec.Code, rec.Body.String())
+		require.Contains(t, rec.Body.String(), "animelover-sbr")
+		sbrs := &toolchainv1alpha1.SpaceBindingRequestList{}
+		require.NoError(t, memberClient.List(context.TODO(), sbrs))
+		assert.Len(t, sbrs.Items, 1)
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		tests := map[string]struct {
+			username        string
+			workspace       string
+			method          string
+			contentType     string
+			body            string
+			expectedErr     string
+			expectedErrCode int
+		}{
+			"role not available in the tier": {
+				username:        "dance.lover",
+				workspace:       "dancelover",
+				method:          http.MethodPatch,
+				contentType:     "application/merge-patch+json",
+				body:            danceloverBindings(`{"masterUserRecord":"carlover","role":"contributor"}`),
+				expectedErr:     "invalid role 'contributor' for user 'carlover', the available roles are admin, viewer",
+				expectedErrCode: http.StatusBadRequest,
+			},
+			"system binding cannot be changed": {
+				username:        "dance.lover",
+				workspace:       "dancelover",
+				method:          http.MethodPatch,
+				contentType:     "application/merge-patch+json",
+				body:            strings.Replace(danceloverBindings(), `"dancelover","role":"admin"`, `"dancelover","role":"viewer"`, 1),
+				expectedErr:     "the role of user 'dancelover' cannot be changed",
+				expectedErrCode: http.StatusBadRequest,
+			},
+			"system binding cannot be removed": {
+				username:        "dance.lover",
+				workspace:       "dancelover",
+				method:          http.MethodPatch,
+				contentType:     "application/json-patch+json",
+				body:            `[{"op":"remove","path":"/status/bindings/1"}]`,
+				expectedErr:     "the binding of user 'dancelover' cannot be removed",
+				expectedErrCode: http.StatusBadRequest,
+			},
+			"inherited binding cannot be removed": {
+				username:        "dance.lover",
+				workspace:       "foodlover",
+				method:          http.MethodPatch,
+				contentType:     "application/merge-patch+json",
+				body:            `{"status":{"bindings":[{"masterUserRecord":"dancelover","role":"admin"}]}}`,
+				expectedErr:     "the binding of user 'animelover' cannot be removed",
+				expectedErrCode: http.StatusBadRequest,
+			},
+			"duplicate binding": {
+				username:        "dance.lover",
+				workspace:       "dancelover",
+				method:          http.MethodPatch,
+				contentType:     "application/merge-patch+json",
+				body:            danceloverBindings(`{"masterUserRecord":"animelover","role":"admin"}`),
+				expectedErr:     "duplicate binding for user 'animelover'",
+				expectedErrCode: http.StatusBadRequest,
+			},
+			"another workspace": {
+				username:        "dance.lover",
+				workspace:       "dancelover",
+				method:          http.MethodPut,
+				contentType:     "application/json",
+				body:            `{"metadata":{"name":"movielover"}}`,
+				expectedErr:     "the name of the workspace 'movielover' does not match the requested workspace 'dancelover'",
+				expectedErrCode: http.StatusBadRequest,
+			},
+			"bindings not set": {
+				username:        "dance.lover",
+				workspace:       "dancelover",
+				method:          http.MethodPut,
+				contentType:     "application/json",
+				body:            `{"metadata":{"name":"dancelover"}}`,
+				expectedErr:     "the status.bindings of the workspace must be set",
+				expectedErrCode: http.StatusBadRequest,
+			},
+			"outdated resource version": {
+				username:        "dance.lover",
+				workspace:       "dancelover",
+				method:          http.MethodPatch,
+				contentType:     "application/merge-patch+json",
+				body:            `{"metadata":{"resourceVersion":"1"}}`,
+				expectedErr:     "the workspace has been modified",
+				expectedErrCode: http.StatusConflict,
+			},
+			"unsupported patch": {
+				username:        "dance.lover",
+				workspace:       "dancelover",
+				method:          http.MethodPatch,
+				contentType:     "application/strategic-merge-patch+json",
+				body:            `{}`,
+				expectedErr:     "the patch type 'application/strategic-merge-patch+json' is not supported",
+				expectedErrCode: http.StatusUnsupportedMediaType,
+			},
+			"invalid patch": {
+				username:        "dance.lover",
+				workspace:       "dancelover",
+				method:          http.MethodPatch,
+				contentType:     "application/json-patch+json",
+				body:            `{}`,
+				expectedErr:     "unable to apply the patch",
+				expectedErrCode: http.StatusBadRequest,
+			},
+			"not allowed by the member cluster": {
+				username:        "anime.lover",
+				workspace:       "dancelover",
+				method:          http.MethodPatch,
+				contentType:     "application/merge-patch+json",
+				body:            strings.Replace(danceloverBindings(), `"animelover","role":"viewer"`, `"animelover","role":"admin"`, 1),
+				expectedErr:     "user 'animelover' cannot manage spacebindingrequests",
+				expectedErrCode: http.StatusForbidden,
+			},
+			"workspace not found": {
+				username:        "movie.lover",
+				workspace:       "dancelover",
+				method:          http.MethodPatch,
+				contentType:     "application/merge-patch+json",
+				body:            `{}`,
+				expectedErr:     "\"workspaces.toolchain.dev.openshift.com \\\"dancelover\\\" not found\"",
+				expectedErrCode: http.StatusNotFound,
+			},
+		}
+		for name, tc := range tests {
+			t.Run(name, func(t *testing.T) {
+				// given
+				memberClient := newMemberClient(t)
+
+				// when
+				rec := update(t, memberClient, tc.username, tc.workspace, tc.method, tc.contentType, tc.body)
+
+				// then
+				require.Equal(t, tc.expectedErrCode, rec.Code, rec.Body.String())
+				require.Contains(t, rec.Body.String(), tc.expectedErr)
+				// no change is applied
+				sbrs := &toolchainv1alpha1.SpaceBindingRequestList{}
+				require.NoError(t, memberClient.List(context.TODO(), sbrs))
+				assert.Len(t, sbrs.Items, 2)
+				for _, sbr := range sbrs.Items {
+					assert.Equal(t, map[string]string{"animelover-sbr": "viewer", "failing-sbr": "admin"}[sbr.Name], sbr.Spec.SpaceRole)
+				}
+			})
+		}
+	})
+}
+
+// assertSpaceBindingRequest asserts that a SpaceBindingRequest was created for the given user and role in the given namespace
+func assertSpaceBindingRequest(t *testing.T, memberClient *test.FakeClient, namespace, mur, role string) *toolchainv1alpha1.SpaceBindingRequest {
+	sbrs := &toolchainv1alpha1.SpaceBindingRequestList{}
+	require.NoError(t, memberClient.List(context.TODO(), sbrs, runtimeclient.InNamespace(namespace)))
+	for i := range sbrs.Items {
+		if sbrs.Items[i].Spec.MasterUserRecord == mur {
+			assert.Equal(t, role, sbrs.Items[i].Spec.SpaceRole)
+			assert.True(t, strings.HasPrefix(sbrs.Items[i].Name, mur+"-"))
+			return &sbrs.Items[i]
+		}
+	}
+	require.Failf(t, "SpaceBindingRequest not found", "no SpaceBindingRequest for user '%s' in namespace '%s'", mur, namespace)
+	return nil
+}
@@ -0,0 +1,436 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/metrics"
+	"github.com/codeready-toolchain/registration-service/pkg/signup"
+
+	"github.com/labstack/echo/v4"
+	errs "github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// DefaultBookmarkInterval is the interval of the BOOKMARK events sent to the watches which allow them
+	DefaultBookmarkInterval = time.Minute
+	// maxWatchTimeout is the maximum duration of a watch, after which the client is expected to start a new watch
+	maxWatchTimeout = 30 * time.Minute
+)
+
+// WorkspaceWatcher notifies the watches of the workspaces of the changes of the Spaces and SpaceBindings of their users.
+// It receives the events of the informers with the handler returned by EventHandler.
+//
+// Since the workspaces are not stored, the deletions of the workspaces are only known while they are watched. The watcher
+// also tracks the resource versions of the events it received, so that the watches are only resumed from the resource
+// versions after which no workspace may have been removed, like the API server does with its watch cache.
+type WorkspaceWatcher struct {
+	bookmarkInterval time.Duration
+
+	lock    sync.RWMutex
+	watches map[*workspaceWatch]struct{}
+	// firstResourceVersion is the resource version of the first event received, or 0 if no event was received yet.
+	// All the changes after it are known.
+	firstResourceVersion uint64
+	// lastResourceVersion is the resource version of the latest event received
+	lastResourceVersion uint64
+	// removalResourceVersion is the resource version of the latest event which may have removed a workspace of a user,
+	// or changed it so that it does not match the selectors of a watch anymore
+	removalResourceVersion uint64
+	// removalPending is true after a deletion whose final state is unknown, until the next event is received
+	removalPending bool
+}
+
+// NewWorkspaceWatcher returns a new WorkspaceWatcher, which sends BOOKMARK events at the given interval
+func NewWorkspaceWatcher(bookmarkInterval time.Duration) *WorkspaceWatcher {
+	return &WorkspaceWatcher{
+		bookmarkInterval: bookmarkInterval,
+		watches:          map[*workspaceWatch]struct{}{},
+	}
+}
+
+// EventHandler returns the handler of the events of the MasterUserRecords, Spaces, SpaceBindings and UserSignups which
+// notifies the watches of the users concerned by the events
+func (w *WorkspaceWatcher) EventHandler() cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			w.notify(obj, false)
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldMeta, oldErr := meta.Accessor(oldObj)
+			newMeta, newErr := meta.Accessor(newObj)
+			if oldErr == nil && newErr == nil && oldMeta.GetResourceVersion() == newMeta.GetResourceVersion() {
+				// periodic resync, nothing changed
+				return
+			}
+			w.notify(newObj, true)
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				// the resource version of the deletion is unknown, it is only known to be before the next event
+				w.lock.Lock()
+				w.removalPending = true
+				w.lock.Unlock()
+				obj = tombstone.Obj
+			}
+			w.notify(obj, true)
+		},
+	}
+}
+
+// notify notifies the watches concerned by the given object, which may have been removed from the workspaces of a
+// user by an update or a deletion
+func (w *WorkspaceWatcher) notify(obj interface{}, mayRemove bool) {
+	objMeta, err := meta.Accessor(obj)
+	if err != nil {
+		return
+	}
+	kind := kindOf(obj)
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.observe(parseResourceVersion(objMeta.GetResourceVersion()), mayRemove)
+	for watch := range w.watches {
+		if watch.concernedBy(kind, objMeta) {
+			watch.notify()
+		}
+	}
+}
+
+// observe records the resource version of an event
+func (w *WorkspaceWatcher) observe(resourceVersion uint64, mayRemove bool) {
+	if resourceVersion == 0 {
+		return
+	}
+	if w.firstResourceVersion == 0 {
+		w.firstResourceVersion = resourceVersion
+	}
+	if resourceVersion > w.lastResourceVersion {
+		w.lastResourceVersion = resourceVersion
+	}
+	if (mayRemove || w.removalPending) && resourceVersion > w.removalResourceVersion {
+		w.removalResourceVersion = resourceVersion
+		w.removalPending = false
+	}
+}
+
+// resourceVersion returns the resource version of the latest event received, or an empty string if no event was received yet
+func (w *WorkspaceWatcher) resourceVersion() string {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	if w.lastResourceVersion == 0 {
+		return ""
+	}
+	return strconv.FormatUint(w.lastResourceVersion, 10)
+}
+
+// canResume returns true if the watches can be resumed from the given resource version, ie. if all the changes after
+// it are known and none of them may have removed a workspace
+func (w *WorkspaceWatcher) canResume(resourceVersion uint64) bool {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	return w.firstResourceVersion != 0 && resourceVersion >= w.firstResourceVersion &&
+		resourceVersion >= w.removalResourceVersion && !w.removalPending
+}
+
+func (w *WorkspaceWatcher) start(watch *workspaceWatch) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.watches[watch] = struct{}{}
+}
+
+func (w *WorkspaceWatcher) stop(watch *workspaceWatch) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	delete(w.watches, watch)
+}
+
+// kindOf returns the kind of the given object, which is only set in the TypeMeta of the unstructured objects of the informers
+func kindOf(obj interface{}) string {
+	switch obj.(type) {
+	case *toolchainv1alpha1.Space:
+		return "Space"
+	case *toolchainv1alpha1.SpaceBinding:
+		return "SpaceBinding"
+	case *toolchainv1alpha1.MasterUserRecord:
+		return "MasterUserRecord"
+	case *toolchainv1alpha1.UserSignup:
+		return "UserSignup"
+	}
+	if o, ok := obj.(runtime.Object); ok {
+		return o.GetObjectKind().GroupVersionKind().Kind
+	}
+	return ""
+}
+
+// workspaceWatch is a watch of the workspaces of a user
+type workspaceWatch struct {
+	spaceLister *SpaceLister
+	userID      string
+	username    string
+	// changed is signaled when the workspaces of the user may have changed. The signals are coalesced.
+	changed chan struct{}
+
+	lock sync.Mutex
+	// signup is the provisioned signup of the user, or nil until the user is provisioned
+	signup *signup.Signup
+	// spaces contains the names of the Spaces the user has a SpaceBinding for
+	spaces map[string]bool
+}
+
+func (w *workspaceWatch) notify() {
+	select {
+	case w.changed <- struct{}{}:
+	default:
+		// already signaled
+	}
+}
+
+// concernedBy returns true if an event of the given resource may change the workspaces of the user
+func (w *workspaceWatch) concernedBy(kind string, obj metav1.Object) bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	switch kind {
+	case "SpaceBinding":
+		return w.signup != nil && obj.GetLabels()[toolchainv1alpha1.SpaceBindingMasterUserRecordLabelKey] == w.signup.CompliantUsername
+	case "Space":
+		return w.spaces[obj.GetName()]
+	case "MasterUserRecord", "UserSignup":
+		return w.signup == nil
+	}
+	return false
+}
+
+// list returns the current workspaces of the user
+func (w *workspaceWatch) list() ([]toolchainv1alpha1.Workspace, error) {
+	w.lock.Lock()
+	userSignup := w.signup
+	w.lock.Unlock()
+	if userSignup == nil {
+		s, err := w.spaceLister.GetSignupFunc(nil, w.userID, w.username, false)
+		if err != nil {
+			return nil, err
+		}
+		if s == nil || s.CompliantUsername == "" {
+			// not provisioned yet
+			return nil, nil
+		}
+		userSignup = s
+	}
+	spaceBindings, err := listSpaceBindingsForUser(w.spaceLister, userSignup.CompliantUsername)
+	if err != nil {
+		return nil, err
+	}
+	spaces := make(map[string]bool, len(spaceBindings))
+	for _, spaceBinding := range spaceBindings {
+		spaces[spaceBinding.Labels[toolchainv1alpha1.SpaceBindingSpaceLabelKey]] = true
+	}
+	w.lock.Lock()
+	w.signup = userSignup
+	w.spaces = spaces
+	w.lock.Unlock()
+	return workspacesFromSpaceBindings(w.spaceLister, userSignup.Name, spaceBindings), nil
+}
+
+// handleSpaceWatchRequest streams the changes of the workspaces of the user as WatchEvents, until the request is cancelled
+// or times out. The watch starts with an ADDED event per workspace changed since the requested resource version (all of them
+// if no resource version is requested). Since the workspaces removed before the watch starts cannot be notified, the watch
+// fails with 410 Gone if a workspace may have been removed since the requested resource version, and the client is expected
+// to list the workspaces again. Only the workspaces matching the label and field selectors are watched, the other ones are
+// notified as deleted.
+func handleSpaceWatchRequest(ctx echo.Context, spaceLister *SpaceLister, options *listOptions) error {
+	requestReceivedTime := ctx.Get(context.RequestReceivedTime).(time.Time)
+	if spaceLister.Watcher == nil {
+		return errorResponse(ctx, apierrors.NewMethodNotSupported(schema.GroupResource{Group: "toolchain.dev.openshift.com", Resource: "workspaces"}, "watch"))
+	}
+	timeout := maxWatchTimeout
+	if value := ctx.QueryParam("timeoutSeconds"); value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds < 0 {
+			return errorResponse(ctx, apierrors.NewBadRequest(fmt.Sprintf("invalid timeoutSeconds '%s'", value)))
+		}
+		if seconds > 0 && time.Duration(seconds)*time.Second < timeout {
+			timeout = time.Duration(seconds) * time.Second
+		}
+	}
+	since := ctx.QueryParam("resourceVersion")
+	if since != "" && since != "0" && parseResourceVersion(since) == 0 {
+		return errorResponse(ctx, apierrors.NewBadRequest(fmt.Sprintf("invalid resourceVersion '%s'", since)))
+	}
+	allowBookmarks, _ := strconv.ParseBool(ctx.QueryParam("allowWatchBookmarks"))
+
+	userID, _ := ctx.Get(context.SubKey).(string)
+	username, _ := ctx.Get(context.UsernameKey).(string)
+	w := &workspaceWatch{
+		spaceLister: spaceLister,
+		userID:      userID,
+		username:    username,
+		changed:     make(chan struct{}, 1),
+	}
+	// the watch is started before listing the workspaces, so that no change is missed
+	spaceLister.Watcher.start(w)
+	defer spaceLister.Watcher.stop(w)
+	// the changes received before the list are reflected by the list, so the bookmarks can use their version
+	observed := spaceLister.Watcher.resourceVersion()
+	workspaces, err := w.list()
+	if err != nil {
+		ctx.Logger().Error(errs.Wrap(err, "error listing the workspaces to watch"))
+		spaceLister.ProxyMetrics.RegServWorkspaceHistogramVec.WithLabelValues(fmt.Sprintf("%d", http.StatusInternalServerError), metrics.MetricsLabelVerbWatch).Observe(time.Since(requestReceivedTime).Seconds())
+		return errorResponse(ctx, apierrors.NewInternalError(err))
+	}
+	if since != "" && since != "0" && !spaceLister.Watcher.canResume(parseResourceVersion(since)) {
+		spaceLister.ProxyMetrics.RegServWorkspaceHistogramVec.WithLabelValues(fmt.Sprintf("%d", http.StatusGone), metrics.MetricsLabelVerbWatch).Observe(time.Since(requestReceivedTime).Seconds())
+		return errorResponse(ctx, apierrors.NewResourceExpired(fmt.Sprintf("too old resource version: %s", since)))
+	}
+	spaceLister.ProxyMetrics.RegServWorkspaceHistogramVec.WithLabelValues(fmt.Sprintf("%d", http.StatusOK), metrics.MetricsLabelVerbWatch).Observe(time.Since(requestReceivedTime).Seconds())
+	workspaces = options.filter(workspaces)
+
+	ctx.Response().Header().Set("Content-Type", "application/json")
+	ctx.Response().WriteHeader(http.StatusOK)
+	ctx.Response().Flush()
+
+	stream := &workspaceStream{
+		ctx:             ctx,
+		known:           map[string]toolchainv1alpha1.Workspace{},
+		resourceVersion: latestResourceVersion(since, observed),
+	}
+	for i := range workspaces {
+		workspace := workspaces[i]
+		stream.known[workspace.Name] = workspace
+		if parseResourceVersion(workspace.ResourceVersion) > parseResourceVersion(since) {
+			if err := stream.send(watch.Added, &workspace); err != nil {
+				return nil
+			}
+		}
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	var bookmarks <-chan time.Time
+	if allowBookmarks && spaceLister.Watcher.bookmarkInterval > 0 {
+		ticker := time.NewTicker(spaceLister.Watcher.bookmarkInterval)
+		defer ticker.Stop()
+		bookmarks = ticker.C
+	}
+	for {
+		select {
+		case <-ctx.Request().Context().Done():
+			return nil
+		case <-timer.C:
+			return nil
+		case <-bookmarks:
+			if err := stream.bookmark(); err != nil {
+				return nil
+			}
+		case <-w.changed:
+			observed := spaceLister.Watcher.resourceVersion()
+			workspaces, err := w.list()
+			if err != nil {
+				ctx.Logger().Error(errs.Wrap(err, "error listing the watched workspaces"))
+				// the client is expected to start a new watch
+				_ = stream.sendStatus(apierrors.NewInternalError(err))
+				return nil
+			}
+			if err := stream.update(options.filter(workspaces)); err != nil {
+				return nil
+			}
+			stream.resourceVersion = latestResourceVersion(stream.resourceVersion, observed)
+		}
+	}
+}
+
+// workspaceStream writes the WatchEvents of the workspaces of a watch
+type workspaceStream struct {
+	ctx echo.Context
+	// known contains the workspaces known by the client, indexed by name
+	known map[string]toolchainv1alpha1.Workspace
+	// resourceVersion is the latest resource version whose changes were sent to the client
+	resourceVersion string
+}
+
+// update sends the events of the differences between the known workspaces and the given ones
+func (s *workspaceStream) update(workspaces []toolchainv1alpha1.Workspace) error {
+	current := make(map[string]bool, len(workspaces))
+	for i := range workspaces {
+		workspace := workspaces[i]
+		current[workspace.Name] = true
+		known, found := s.known[workspace.Name]
+		s.known[workspace.Name] = workspace
+		switch {
+		case !found:
+			if err := s.send(watch.Added, &workspace); err != nil {
+				return err
+			}
+		case !equality.Semantic.DeepEqual(known, workspace):
+			if err := s.send(watch.Modified, &workspace); err != nil {
+				return err
+			}
+		}
+	}
+	for name, workspace := range s.known {
+		if current[name] {
+			continue
+		}
+		delete(s.known, name)
+		if err := s.send(watch.Deleted, &workspace); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bookmark sends a BOOKMARK event with the latest resource version sent to the client, if any
+func (s *workspaceStream) bookmark() error {
+	if s.resourceVersion == "" || s.resourceVersion == "0" {
+		return nil
+	}
+	return s.send(watch.Bookmark, &toolchainv1alpha1.Workspace{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Workspace",
+			APIVersion: "toolchain.dev.openshift.com/v1alpha1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			ResourceVersion: s.resourceVersion,
+		},
+	})
+}
+
+func (s *workspaceStream) send(eventType watch.EventType, workspace *toolchainv1alpha1.Workspace) error {
+	s.resourceVersion = latestResourceVersion(s.resourceVersion, workspace.ResourceVersion)
+	return s.write(eventType, workspace)
+}
+
+func (s *workspaceStream) sendStatus(err *apierrors.StatusError) error {
+	status := err.ErrStatus
+	status.Kind = "Status"
+	status.APIVersion = "v1"
+	return s.write(watch.Error, &status)
+}
+
+func (s *workspaceStream) write(eventType watch.EventType, obj interface{}) error {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(s.ctx.Response()).Encode(metav1.WatchEvent{
+		Type:   string(eventType),
+		Object: runtime.RawExtension{Raw: data},
+	}); err != nil {
+		return err
+	}
+	s.ctx.Response().Flush()
+	return nil
+}
@@ -0,0 +1,288 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	rcontext "github.com/codeready-toolchain/registration-service/pkg/context"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/handlers"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/metrics"
+	"github.com/codeready-toolchain/registration-service/test/fake"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+func TestSpaceListerWatch(t *testing.T) {
+	// given
+	fakeSignupService := fake.NewSignupService(
+		newSignup("john", "john", true),
+		newSignup("jane", "jane", true),
+	)
+	fakeClient := fake.InitClient(t,
+		fake.NewSpace("john", "member-1", "john"),
+		fake.NewSpace("team", "member-1", "jane"),
+		fake.NewSpace("jane", "member-1", "jane"),
+		fake.NewSpaceBinding("john-john", "john", "john", "admin"),
+		fake.NewSpaceBinding("jane-jane", "jane", "jane", "admin"),
+		fake.NewSpaceBinding("team-jane", "jane", "team", "admin"),
+	)
+	watcher := handlers.NewWorkspaceWatcher(50 * time.Millisecond)
+	spaceLister := &handlers.SpaceLister{
+		GetSignupFunc:          fakeSignupService.GetSignupFromInformer,
+		GetInformerServiceFunc: fake.GetInformerService(fakeClient),
+		ProxyMetrics:           metrics.NewProxyMetrics(prometheus.NewRegistry()),
+		Watcher:                watcher,
+	}
+	server := newWorkspacesServer(spaceLister)
+	defer server.Close()
+	events := watcher.EventHandler()
+
+	t.Run("streams the changes of the workspaces of the user", func(t *testing.T) {
+		// given
+		stream := watchWorkspaces(t, server.URL+"?watch=true")
+
+		// then the current workspaces are added
+		assertWatchEvent(t, stream, watch.Added, "john", "admin")
+
+		// when the team workspace is shared with the user
+		binding := fake.NewSpaceBinding("team-john", "john", "team", "viewer")
+		require.NoError(t, fakeClient.Create(context.TODO(), binding))
+		events.OnAdd(binding)
+
+		// then
+		assertWatchEvent(t, stream, watch.Added, "team", "viewer")
+
+		// when the namespaces of the workspace are provisioned
+		space := &toolchainv1alpha1.Space{}
+		require.NoError(t, fakeClient.Get(context.TODO(), types.NamespacedName{Name: "team", Namespace: configuration.Namespace()}, space))
+		oldSpace := space.DeepCopy()
+		space.Status.ProvisionedNamespaces = append(space.Status.ProvisionedNamespaces, toolchainv1alpha1.SpaceNamespace{Name: "team-test"})
+		require.NoError(t, fakeClient.Update(context.TODO(), space))
+		events.OnUpdate(oldSpace, space)
+
+		// then
+		workspace := assertWatchEvent(t, stream, watch.Modified, "team", "viewer")
+		assert.Len(t, workspace.Status.Namespaces, 3)
+		assert.Equal(t, space.ResourceVersion, workspace.ResourceVersion)
+
+		// when the workspace of another user changes
+		otherBinding := fake.NewSpaceBinding("jane-john", "jane", "john", "viewer")
+		require.NoError(t, fakeClient.Create(context.TODO(), otherBinding))
+		events.OnAdd(otherBinding)
+		// and the role of the user changes
+		oldBinding := binding.DeepCopy()
+		binding.Spec.SpaceRole = "contributor"
+		require.NoError(t, fakeClient.Update(context.TODO(), binding))
+		events.OnUpdate(oldBinding, binding)
+
+		// then only the change of the role is notified
+		assertWatchEvent(t, stream, watch.Modified, "team", "contributor")
+
+		// when the workspace is not shared with the user anymore
+		require.NoError(t, fakeClient.Delete(context.TODO(), binding))
+		events.OnDelete(binding)
+
+		// then
+		assertWatchEvent(t, stream, watch.Deleted, "team", "contributor")
+	})
+
+	t.Run("starts from the resource version of the list", func(t *testing.T) {
+		// given
+		list := listWorkspaces(t, server.URL)
+
+		// when
+		stream := watchWorkspaces(t, server.URL+"?watch=true&allowWatchBookmarks=true&resourceVersion="+list.ResourceVersion)
+
+		// then no workspace is added again, only bookmarks are sent
+		event := nextWatchEvent(t, stream)
+		require.Equal(t, string(watch.Bookmark), event.Type)
+		bookmark := &toolchainv1alpha1.Workspace{}
+		require.NoError(t, json.Unmarshal(event.Object.Raw, bookmark))
+		assert.Equal(t, list.ResourceVersion, bookmark.ResourceVersion)
+	})
+
+	t.Run("too old resource version", func(t *testing.T) {
+		// given
+		list := listWorkspaces(t, server.URL)
+		// a workspace may have been removed after the list
+		removed := fake.NewSpaceBinding("team-jane", "jane", "team", "admin")
+		removed.ResourceVersion = strconv.FormatUint(parseUint(t, list.ResourceVersion)+1, 10)
+		events.OnDelete(removed)
+
+		// when
+		resp, err := http.Get(server.URL + "?watch=true&resourceVersion=" + list.ResourceVersion)
+
+		// then
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusGone, resp.StatusCode)
+
+		t.Run("resumed from the resource version of a new list", func(t *testing.T) {
+			// given
+			list := listWorkspaces(t, server.URL)
+			assert.Equal(t, removed.ResourceVersion, list.ResourceVersion)
+
+			// when
+			stream := watchWorkspaces(t, server.URL+"?watch=true&allowWatchBookmarks=true&resourceVersion="+list.ResourceVersion)
+
+			// then
+			event := nextWatchEvent(t, stream)
+			require.Equal(t, string(watch.Bookmark), event.Type)
+		})
+	})
+
+	t.Run("not resumed before any event is received", func(t *testing.T) {
+		// given
+		server := newWorkspacesServer(&handlers.SpaceLister{
+			GetSignupFunc:          fakeSignupService.GetSignupFromInformer,
+			GetInformerServiceFunc: fake.GetInformerService(fakeClient),
+			ProxyMetrics:           metrics.NewProxyMetrics(prometheus.NewRegistry()),
+			Watcher:                handlers.NewWorkspaceWatcher(time.Minute),
+		})
+		defer server.Close()
+		list := listWorkspaces(t, server.URL)
+
+		// when
+		resp, err := http.Get(server.URL + "?watch=true&resourceVersion=" + list.ResourceVersion)
+
+		// then
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusGone, resp.StatusCode)
+		status := &metav1.Status{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(status))
+		assert.Equal(t, metav1.StatusReasonExpired, status.Reason)
+	})
+
+	t.Run("ends after the timeout", func(t *testing.T) {
+		// given
+		stream := watchWorkspaces(t, server.URL+"?watch=true&timeoutSeconds=1")
+		assertWatchEvent(t, stream, watch.Added, "john", "admin")
+
+		// then
+		select {
+		case _, open := <-stream:
+			assert.False(t, open)
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "the watch did not end")
+		}
+	})
+
+	t.Run("invalid resource version", func(t *testing.T) {
+		// when
+		resp, err := http.Get(server.URL + "?watch=true&resourceVersion=abc")
+
+		// then
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("not supported without watcher", func(t *testing.T) {
+		// given
+		server := newWorkspacesServer(&handlers.SpaceLister{
+			GetSignupFunc:          fakeSignupService.GetSignupFromInformer,
+			GetInformerServiceFunc: fake.GetInformerService(fakeClient),
+			ProxyMetrics:           metrics.NewProxyMetrics(prometheus.NewRegistry()),
+		})
+		defer server.Close()
+
+		// when
+		resp, err := http.Get(server.URL + "?watch=true")
+
+		// then
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	})
+}
+
+// newWorkspacesServer returns a server which handles the requests of the `john` user on the workspaces
+func newWorkspacesServer(spaceLister *handlers.SpaceLister) *httptest.Server {
+	e := echo.New()
+	e.GET("/", func(ctx echo.Context) error {
+		ctx.Set(rcontext.SubKey, "john-id")
+		ctx.Set(rcontext.UsernameKey, "john")
+		ctx.Set(rcontext.RequestReceivedTime, time.Now())
+		return handlers.HandleSpaceListRequest(spaceLister)(ctx)
+	})
+	return httptest.NewServer(e)
+}
+
+// listWorkspaces lists the workspaces with the given URL
+func listWorkspaces(t *testing.T, url string) *toolchainv1alpha1.WorkspaceList {
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	list := &toolchainv1alpha1.WorkspaceList{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(list))
+	require.NotEmpty(t, list.ResourceVersion)
+	return list
+}
+
+func parseUint(t *testing.T, value string) uint64 {
+	result, err := strconv.ParseUint(value, 10, 64)
+	require.NoError(t, err)
+	return result
+}
+
+// watchWorkspaces starts a watch with the given URL, and returns its events. The channel is closed when the watch ends.
+func watchWorkspaces(t *testing.T, url string) <-chan metav1.WatchEvent {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	events := make(chan metav1.WatchEvent)
+	go func() {
+		defer close(events)
+		defer resp.Body.Close()
+		decoder := json.NewDecoder(resp.Body)
+		for {
+			event := metav1.WatchEvent{}
+			if err := decoder.Decode(&event); err != nil {
+				return
+			}
+			events <- event
+		}
+	}()
+	return events
+}
+
+func nextWatchEvent(t *testing.T, stream <-chan metav1.WatchEvent) metav1.WatchEvent {
+	select {
+	case event, open := <-stream:
+		require.True(t, open, "the watch ended")
+		return event
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "no watch event received")
+		return metav1.WatchEvent{}
+	}
+}
+
+func assertWatchEvent(t *testing.T, stream <-chan metav1.WatchEvent, eventType watch.EventType, name, role string) *toolchainv1alpha1.Workspace {
+	event := nextWatchEvent(t, stream)
+	require.Equal(t, string(eventType), event.Type)
+	workspace := &toolchainv1alpha1.Workspace{}
+	require.NoError(t, json.Unmarshal(event.Object.Raw, workspace))
+	assert.Equal(t, "Workspace", workspace.Kind)
+	assert.Equal(t, name, workspace.Name)
+	assert.Equal(t, role, workspace.Status.Role)
+	return workspace
+}
@@ -5,9 +5,14 @@ import (
 )
 
 const (
-	MetricLabelRejected  = "Rejected"
-	MetricsLabelVerbGet  = "Get"
-	MetricsLabelVerbList = "List"
+	MetricLabelRejected    = "Rejected"
+	MetricsLabelVerbGet    = "Get"
+	MetricsLabelVerbList   = "List"
+	MetricsLabelVerbWatch  = "Watch"
+	MetricsLabelVerbUpdate = "Update"
+	MetricsLabelVerbPatch  = "Patch"
+	MetricsLabelVerbCreate = "Create"
+	MetricsLabelVerbDelete = "Delete"
 )
 
 type ProxyMetrics struct {
@@ -15,7 +20,20 @@ type ProxyMetrics struct {
 	RegServProxyAPIHistogramVec *prometheus.HistogramVec
 	// RegServWorkspaceHistogramVec measures the response time for either response or error from proxy when there is no routing
 	RegServWorkspaceHistogramVec *prometheus.HistogramVec
-	Reg                          *prometheus.Registry
+	// RegServProxyAuditEventsCounterVec counts the audit events of the proxied requests, per sink and result (written, failed or dropped)
+	RegServProxyAuditEventsCounterVec *prometheus.CounterVec
+	// RegServProxyThrottledRequestsCounterVec counts the requests rejected by the rate limits, per user tier and limit
+	// (read, write, watch or long-running)
+	RegServProxyThrottledRequestsCounterVec *prometheus.CounterVec
+	// RegServProxyAccessCacheLookupsCounterVec counts the lookups in the cache of the accesses of the users to the workspaces, per result (hit or miss)
+	RegServProxyAccessCacheLookupsCounterVec *prometheus.CounterVec
+	// RegServProxyAccessCacheInvalidationsCounterVec counts the invalidations of the cache of the accesses of the users to the workspaces,
+	// per kind of the changed resource
+	RegServProxyAccessCacheInvalidationsCounterVec *prometheus.CounterVec
+	// RegServProxyRequestPolicyMatchesCounterVec counts the proxied requests matching the request policies, per policy and outcome
+	// (denied, warned, dry-run or failed)
+	RegServProxyRequestPolicyMatchesCounterVec *prometheus.CounterVec
+	Reg                                        *prometheus.Registry
 }
 
 const metricsPrefix = "sandbox_"
@@ -23,12 +41,42 @@ const metricsPrefix = "sandbox_"
 func NewProxyMetrics(reg *prometheus.Registry) *ProxyMetrics {
 	regServProxyAPIHistogramVec := newHistogramVec("proxy_api_http_request_time", "time taken by proxy to route to a target cluster", "status_code", "route_to")
 	regServWorkspaceHistogramVec := newHistogramVec("proxy_workspace_http_request_time", "time for response of a request to proxy ", "status_code", "kube_verb")
+	regServProxyAuditEventsCounterVec := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: metricsPrefix + "proxy_audit_events_total",
+		Help: "number of audit events of the proxied requests, per sink and result",
+	}, []string{"sink", "result"})
+	regServProxyThrottledRequestsCounterVec := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: metricsPrefix + "proxy_throttled_requests_total",
+		Help: "number of proxied requests rejected by the rate limits, per user tier and limit",
+	}, []string{"tier", "limit"})
+	regServProxyAccessCacheLookupsCounterVec := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: metricsPrefix + "proxy_access_cache_lookups_total",
+		Help: "number of lookups in the cache of the accesses of the users to the workspaces, per result",
+	}, []string{"result"})
+	regServProxyAccessCacheInvalidationsCounterVec := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: metricsPrefix + "proxy_access_cache_invalidations_total",
+		Help: "number of invalidations of the cache of the accesses of the users to the workspaces, per kind of the changed resource",
+	}, []string{"kind"})
+	regServProxyRequestPolicyMatchesCounterVec := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: metricsPrefix + "proxy_request_policy_matches_total",
+		Help: "number of proxied requests matching the request policies, per policy and outcome",
+	}, []string{"policy", "outcome"})
 	reg.MustRegister(regServProxyAPIHistogramVec)
 	reg.MustRegister(regServWorkspaceHistogramVec)
+	reg.MustRegister(regServProxyAuditEventsCounterVec)
+	reg.MustRegister(regServProxyThrottledRequestsCounterVec)
+	reg.MustRegister(regServProxyAccessCacheLookupsCounterVec)
+	reg.MustRegister(regServProxyAccessCacheInvalidationsCounterVec)
+	reg.MustRegister(regServProxyRequestPolicyMatchesCounterVec)
 	return &ProxyMetrics{
-		RegServWorkspaceHistogramVec: regServWorkspaceHistogramVec,
-		RegServProxyAPIHistogramVec:  regServProxyAPIHistogramVec,
-		Reg:                          reg,
+		RegServWorkspaceHistogramVec:                   regServWorkspaceHistogramVec,
+		RegServProxyAPIHistogramVec:                    regServProxyAPIHistogramVec,
+		RegServProxyAuditEventsCounterVec:              regServProxyAuditEventsCounterVec,
+		RegServProxyThrottledRequestsCounterVec:        regServProxyThrottledRequestsCounterVec,
+		RegServProxyAccessCacheLookupsCounterVec:       regServProxyAccessCacheLookupsCounterVec,
+		RegServProxyAccessCacheInvalidationsCounterVec: regServProxyAccessCacheInvalidationsCounterVec,
+		RegServProxyRequestPolicyMatchesCounterVec:     regServProxyRequestPolicyMatchesCounterVec,
+		Reg: reg,
 	}
 }
 
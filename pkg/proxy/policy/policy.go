@@ -0,0 +1,149 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/application/service"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/requestinfo"
+
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+// TierPoliciesAnnotationKey is the annotation of the NSTemplateTiers which contains the JSON-encoded rules of the
+// requests allowed for each SpaceRole of the tier, indexed by role (see configuration.PolicyRule). The default rules
+// are used for the tiers without this annotation.
+const TierPoliciesAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "proxy-space-role-policies"
+
+// reviewResources are the resources of the authorization.k8s.io API group with which the users check their own permissions
+// (eg. with `kubectl auth can-i`), which the member clusters allow to all the users
+var reviewResources = []string{"selfsubjectaccessreviews", "selfsubjectrulesreviews", "localsubjectaccessreviews"}
+
+// Enforcer rejects the requests which the SpaceRole of the user can never perform in a workspace, before they are
+// forwarded to the member cluster. It does not replace the RBAC of the member clusters: the allowed requests may still
+// be rejected by the member clusters.
+type Enforcer struct {
+	defaults map[string][]configuration.PolicyRule
+
+	lock sync.Mutex
+	// tiers contains the rules parsed from the annotation of the NSTemplateTiers, indexed by tier name
+	tiers map[string]*tierPolicies
+}
+
+type tierPolicies struct {
+	resourceVersion string
+	policies        map[string][]configuration.PolicyRule
+}
+
+// NewEnforcer returns a new Enforcer with the given default rules, indexed by SpaceRole
+func NewEnforcer(defaults map[string][]configuration.PolicyRule) *Enforcer {
+	return &Enforcer{
+		defaults: defaults,
+		tiers:    map[string]*tierPolicies{},
+	}
+}
+
+// Authorize returns an error if the SpaceRole of the user in the given workspace does not allow the given request.
+// The Space of the workspace and its NSTemplateTier are read with the given informer service. The path of the request
+// is expected to be relative to the API server of the member cluster. Only the requests on the resources are restricted,
+// and the requests of the roles without rules are always allowed, as well as the reviews of the permissions of the user.
+func (e *Enforcer) Authorize(informer service.InformerService, req *http.Request, workspace *toolchainv1alpha1.Workspace) error {
+	role := workspace.Status.Role
+	rules, found := e.policies(informer, workspace.Name)[role]
+	if !found {
+		return nil
+	}
+	info, err := requestinfo.New(req)
+	if err != nil || !info.IsResourceRequest || isReviewRequest(info) {
+		return nil
+	}
+	verb := info.Verb
+	if verb == "get" && httpstream.IsUpgradeRequest(req) {
+		// the exec, attach and port-forward requests upgraded from a GET request are not read-only
+		verb = "create"
+	}
+	for _, rule := range rules {
+		if matches(rule, verb, info) {
+			return nil
+		}
+	}
+	return fmt.Errorf("the '%s' role does not allow to %s %s in workspace '%s'", role, verb, resourceOf(info), workspace.Name)
+}
+
+// policies returns the rules of the SpaceRoles of the NSTemplateTier of the given workspace, or the default rules if the
+// tier does not define its own rules
+func (e *Enforcer) policies(informer service.InformerService, workspace string) map[string][]configuration.PolicyRule {
+	space, err := informer.GetSpace(workspace)
+	if err != nil {
+		return e.defaults
+	}
+	tier, err := informer.GetNSTemplateTier(space.Spec.TierName)
+	if err != nil {
+		return e.defaults
+	}
+	value, found := tier.Annotations[TierPoliciesAnnotationKey]
+	if !found {
+		return e.defaults
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if cached, found := e.tiers[tier.Name]; found && cached.resourceVersion == tier.ResourceVersion {
+		return cached.policies
+	}
+	policies := map[string][]configuration.PolicyRule{}
+	if err := json.Unmarshal([]byte(value), &policies); err != nil {
+		log.Error(nil, err, fmt.Sprintf("unable to parse the '%s' annotation of the '%s' NSTemplateTier, using the default rules", TierPoliciesAnnotationKey, tier.Name))
+		policies = e.defaults
+	}
+	e.tiers[tier.Name] = &tierPolicies{
+		resourceVersion: tier.ResourceVersion,
+		policies:        policies,
+	}
+	return policies
+}
+
+// isReviewRequest returns true if the request creates a review of the permissions of the user
+func isReviewRequest(info *request.RequestInfo) bool {
+	return info.APIGroup == "authorization.k8s.io" && info.Verb == "create" && info.Subresource == "" && contains(reviewResources, info.Resource)
+}
+
+// matches returns true if the given rule allows the given verb on the resource of the request
+func matches(rule configuration.PolicyRule, verb string, info *request.RequestInfo) bool {
+	if !contains(rule.Verbs, verb) {
+		return false
+	}
+	if len(rule.APIGroups) > 0 && !contains(rule.APIGroups, info.APIGroup) {
+		return false
+	}
+	if len(rule.Resources) == 0 {
+		return true
+	}
+	if info.Subresource == "" {
+		return contains(rule.Resources, info.Resource)
+	}
+	return contains(rule.Resources, info.Resource+"/"+info.Subresource) || contains(rule.Resources, info.Resource+"/*")
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value || v == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// resourceOf returns the resource of the request, with its subresource if any (eg. pods/exec)
+func resourceOf(info *request.RequestInfo) string {
+	if info.Subresource != "" {
+		return info.Resource + "/" + info.Subresource
+	}
+	return info.Resource
+}
@@ -0,0 +1,204 @@
+package policy_test
+
+import (
+	"net/http"
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/policy"
+	"github.com/codeready-toolchain/registration-service/test/fake"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAuthorize(t *testing.T) {
+	log.Init("registration-service-testing")
+
+	// given
+	customTier := fake.NewBase1NSTemplateTier()
+	customTier.Name = "custom"
+	customTier.ResourceVersion = "1"
+	customTier.Annotations = map[string]string{
+		policy.TierPoliciesAnnotationKey: `{"viewer":[{"verbs":["get","list","watch"]},{"verbs":["create"],"resources":["pods/exec"]}],"contributor":[{"verbs":["*"],"apiGroups":["apps"],"resources":["deployments"]}]}`,
+	}
+	invalidTier := fake.NewBase1NSTemplateTier()
+	invalidTier.Name = "invalid"
+	invalidTier.Annotations = map[string]string{
+		policy.TierPoliciesAnnotationKey: `not json`,
+	}
+	customSpace := fake.NewSpace("custom", "member-1", "john")
+	customSpace.Spec.TierName = "custom"
+	invalidSpace := fake.NewSpace("invalid", "member-1", "john")
+	invalidSpace.Spec.TierName = "invalid"
+	fakeClient := fake.InitClient(t,
+		fake.NewSpace("base", "member-1", "john"), customSpace, invalidSpace,
+		fake.NewBase1NSTemplateTier(), customTier, invalidTier,
+	)
+	informer := fake.GetInformerService(fakeClient)()
+	enforcer := policy.NewEnforcer(map[string][]configuration.PolicyRule{
+		"viewer": {{Verbs: []string{"get", "list", "watch"}}},
+	})
+
+	tests := map[string]struct {
+		workspace     string
+		role          string
+		method        string
+		path          string
+		upgrade       bool
+		expectedError string
+	}{
+		"viewer can get a pod": {
+			workspace: "base",
+			role:      "viewer",
+			method:    http.MethodGet,
+			path:      "/api/v1/namespaces/base-dev/pods/app",
+		},
+		"viewer can list the deployments": {
+			workspace: "base",
+			role:      "viewer",
+			method:    http.MethodGet,
+			path:      "/apis/apps/v1/namespaces/base-dev/deployments",
+		},
+		"viewer can watch the pods": {
+			workspace: "base",
+			role:      "viewer",
+			method:    http.MethodGet,
+			path:      "/api/v1/namespaces/base-dev/pods?watch=true",
+		},
+		"viewer cannot create a deployment": {
+			workspace:     "base",
+			role:          "viewer",
+			method:        http.MethodPost,
+			path:          "/apis/apps/v1/namespaces/base-dev/deployments",
+			expectedError: "the 'viewer' role does not allow to create deployments in workspace 'base'",
+		},
+		"viewer cannot delete a pod": {
+			workspace:     "base",
+			role:          "viewer",
+			method:        http.MethodDelete,
+			path:          "/api/v1/namespaces/base-dev/pods/app",
+			expectedError: "the 'viewer' role does not allow to delete pods in workspace 'base'",
+		},
+		"viewer cannot exec in a pod": {
+			workspace:     "base",
+			role:          "viewer",
+			method:        http.MethodGet,
+			path:          "/api/v1/namespaces/base-dev/pods/app/exec?command=sh",
+			upgrade:       true,
+			expectedError: "the 'viewer' role does not allow to create pods/exec in workspace 'base'",
+		},
+		"viewer can access the non-resource URLs": {
+			workspace: "base",
+			role:      "viewer",
+			method:    http.MethodPost,
+			path:      "/apis",
+		},
+		"viewer can review its own permissions": {
+			workspace: "base",
+			role:      "viewer",
+			method:    http.MethodPost,
+			path:      "/apis/authorization.k8s.io/v1/selfsubjectaccessreviews",
+		},
+		"viewer can review its own rules": {
+			workspace: "base",
+			role:      "viewer",
+			method:    http.MethodPost,
+			path:      "/apis/authorization.k8s.io/v1/selfsubjectrulesreviews",
+		},
+		"viewer can review the permissions in a namespace": {
+			workspace: "base",
+			role:      "viewer",
+			method:    http.MethodPost,
+			path:      "/apis/authorization.k8s.io/v1/namespaces/base-dev/localsubjectaccessreviews",
+		},
+		"viewer cannot review the permissions of the other users": {
+			workspace:     "base",
+			role:          "viewer",
+			method:        http.MethodPost,
+			path:          "/apis/authorization.k8s.io/v1/subjectaccessreviews",
+			expectedError: "the 'viewer' role does not allow to create subjectaccessreviews in workspace 'base'",
+		},
+		"admin is not restricted": {
+			workspace: "base",
+			role:      "admin",
+			method:    http.MethodDelete,
+			path:      "/api/v1/namespaces/base-dev/pods/app",
+		},
+		"viewer can exec in a pod with the rules of the tier": {
+			workspace: "custom",
+			role:      "viewer",
+			method:    http.MethodGet,
+			path:      "/api/v1/namespaces/custom-dev/pods/app/exec?command=sh",
+			upgrade:   true,
+		},
+		"viewer cannot attach to a pod with the rules of the tier": {
+			workspace:     "custom",
+			role:          "viewer",
+			method:        http.MethodPost,
+			path:          "/api/v1/namespaces/custom-dev/pods/app/attach",
+			expectedError: "the 'viewer' role does not allow to create pods/attach in workspace 'custom'",
+		},
+		"contributor can update a deployment with the rules of the tier": {
+			workspace: "custom",
+			role:      "contributor",
+			method:    http.MethodPut,
+			path:      "/apis/apps/v1/namespaces/custom-dev/deployments/app",
+		},
+		"contributor cannot update a deployment of another API group": {
+			workspace:     "custom",
+			role:          "contributor",
+			method:        http.MethodPut,
+			path:          "/apis/extensions/v1beta1/namespaces/custom-dev/deployments/app",
+			expectedError: "the 'contributor' role does not allow to update deployments in workspace 'custom'",
+		},
+		"contributor cannot delete a pod with the rules of the tier": {
+			workspace:     "custom",
+			role:          "contributor",
+			method:        http.MethodDelete,
+			path:          "/api/v1/namespaces/custom-dev/pods/app",
+			expectedError: "the 'contributor' role does not allow to delete pods in workspace 'custom'",
+		},
+		"default rules are used when the annotation of the tier is invalid": {
+			workspace:     "invalid",
+			role:          "viewer",
+			method:        http.MethodPost,
+			path:          "/api/v1/namespaces/invalid-dev/pods",
+			expectedError: "the 'viewer' role does not allow to create pods in workspace 'invalid'",
+		},
+		"default rules are used when the space does not exist": {
+			workspace:     "unknown",
+			role:          "viewer",
+			method:        http.MethodPost,
+			path:          "/api/v1/namespaces/unknown-dev/pods",
+			expectedError: "the 'viewer' role does not allow to create pods in workspace 'unknown'",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			req, err := http.NewRequest(tc.method, "http://localhost"+tc.path, nil)
+			require.NoError(t, err)
+			if tc.upgrade {
+				req.Header.Set("Connection", "Upgrade")
+				req.Header.Set("Upgrade", "SPDY/3.1")
+			}
+			workspace := &toolchainv1alpha1.Workspace{
+				ObjectMeta: metav1.ObjectMeta{Name: tc.workspace},
+				Status:     toolchainv1alpha1.WorkspaceStatus{Role: tc.role},
+			}
+
+			// when
+			err = enforcer.Authorize(informer, req, workspace)
+
+			// then
+			if tc.expectedError == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expectedError)
+			}
+		})
+	}
+}
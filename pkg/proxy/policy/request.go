@@ -0,0 +1,272 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/requestinfo"
+
+	"github.com/google/cel-go/cel"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+)
+
+// The outcomes of the request policies, used as the `outcome` label of the metrics
+const (
+	OutcomeDenied = "denied"
+	OutcomeWarned = "warned"
+	OutcomeDryRun = "dry-run"
+	OutcomeFailed = "failed"
+)
+
+// costLimit bounds the cost of the evaluation of an expression, so that a policy cannot slow down all the requests
+const costLimit = 1000000
+
+// RequestAttributes are the attributes of a proxied request which are available to the expressions of the request policies
+type RequestAttributes struct {
+	// Request is the proxied request, with a path relative to the API server of the member cluster
+	Request  *http.Request
+	UserID   string
+	Username string
+	// UserTier is the tier of the MasterUserRecord of the user
+	UserTier  string
+	Workspace *toolchainv1alpha1.Workspace
+	// WorkspaceTier is the NSTemplateTier of the Space of the workspace
+	WorkspaceTier string
+}
+
+// RequestPolicies evaluates the configured CEL expressions on the proxied requests. The expressions can use the following variables:
+//   - `request`: `verb`, `apiGroup`, `apiVersion`, `resource`, `subresource`, `name`, `namespace`, `path`, `method`,
+//     `isResourceRequest`, `upgrade` (true for exec, attach and port-forward) and `contentLength` (the size of the body,
+//     or -1 if unknown)
+//   - `user`: `id`, `username` and `tier`
+//   - `workspace`: `name`, `role`, `type` and `tier`
+//   - `object`: the request body decoded from JSON, or null if there is no body
+//
+// The expressions which cannot be evaluated (eg. a missing field of the body) do not match the request. Since `object` is
+// null when the body is not JSON (eg. YAML or protobuf) or is too large, such requests are denied by the `deny` policies
+// which use `object`, so that these policies cannot be bypassed by sending the body in another encoding.
+type RequestPolicies struct {
+	policies    []*requestPolicy
+	maxBodySize int
+	// needsBody is true if one of the expressions uses the request body
+	needsBody bool
+	matches   *prometheus.CounterVec
+}
+
+type requestPolicy struct {
+	configuration.RequestPolicy
+	program cel.Program
+	// usesObject is true if the expression uses the request body
+	usesObject bool
+}
+
+// NewRequestPolicies compiles the given policies. All the policies only log and count the matching requests in dry-run mode.
+// The matches are counted per policy and outcome in the given counter (if not nil).
+func NewRequestPolicies(policies []configuration.RequestPolicy, dryRun bool, maxBodySize int, matches *prometheus.CounterVec) (*RequestPolicies, error) {
+	env, err := cel.NewEnv(
+		cel.Variable("request", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("user", cel.MapType(cel.StringType, cel.StringType)),
+		cel.Variable("workspace", cel.MapType(cel.StringType, cel.StringType)),
+		cel.Variable("object", cel.DynType),
+	)
+	if err != nil {
+		return nil, err
+	}
+	p := &RequestPolicies{
+		maxBodySize: maxBodySize,
+		matches:     matches,
+	}
+	for _, policy := range policies {
+		switch policy.Action {
+		case "":
+			policy.Action = configuration.RequestPolicyActionDeny
+		case configuration.RequestPolicyActionDeny, configuration.RequestPolicyActionWarn:
+		default:
+			return nil, fmt.Errorf("invalid action '%s' of the '%s' request policy, it must be 'deny' or 'warn'", policy.Action, policy.Name)
+		}
+		ast, issues := env.Compile(policy.Expression)
+		if issues != nil && issues.Err() != nil {
+			return nil, fmt.Errorf("invalid expression of the '%s' request policy: %w", policy.Name, issues.Err())
+		}
+		if ast.OutputType() != cel.BoolType {
+			return nil, fmt.Errorf("invalid expression of the '%s' request policy: it must return a bool, not %s", policy.Name, ast.OutputType())
+		}
+		program, err := env.Program(ast, cel.CostLimit(costLimit))
+		if err != nil {
+			return nil, fmt.Errorf("invalid expression of the '%s' request policy: %w", policy.Name, err)
+		}
+		checked, err := cel.AstToCheckedExpr(ast)
+		if err != nil {
+			return nil, err
+		}
+		usesObject := false
+		for _, ref := range checked.GetReferenceMap() {
+			if ref.GetName() == "object" {
+				usesObject = true
+				p.needsBody = true
+			}
+		}
+		policy.DryRun = policy.DryRun || dryRun
+		p.policies = append(p.policies, &requestPolicy{
+			RequestPolicy: policy,
+			program:       program,
+			usesObject:    usesObject,
+		})
+	}
+	return p, nil
+}
+
+// NewRequestPoliciesFromConfig returns the configured request policies, or nil if no policy is configured
+func NewRequestPoliciesFromConfig(cfg configuration.ProxyConfig, matches *prometheus.CounterVec) (*RequestPolicies, error) {
+	policies := cfg.RequestPolicies()
+	if len(policies) == 0 {
+		return nil, nil
+	}
+	return NewRequestPolicies(policies, cfg.RequestPoliciesDryRun(), cfg.RequestPoliciesMaxBodySize(), matches)
+}
+
+// Evaluate evaluates all the policies on the given request. It returns a Forbidden error with the message of the first matching
+// `deny` policy, and otherwise the messages of the matching `warn` policies, which should be returned to the client as warnings.
+// The matches of the policies in dry-run mode are only logged.
+func (p *RequestPolicies) Evaluate(attrs RequestAttributes) ([]string, error) {
+	vars, undecodable, err := p.variables(attrs)
+	if err != nil {
+		return nil, crterrors.NewBadRequest("unable to read the request body", err.Error())
+	}
+	var warnings []string
+	for _, policy := range p.policies {
+		if undecodable && policy.usesObject && policy.Action == configuration.RequestPolicyActionDeny && !policy.DryRun {
+			p.count(policy, OutcomeDenied)
+			return nil, crterrors.NewForbiddenError("request denied", fmt.Sprintf("the '%s' policy denies the request: the request body cannot be evaluated, it must be JSON and not larger than %d bytes",
+				policy.Name, p.maxBodySize))
+		}
+		value, _, err := policy.program.Eval(vars)
+		if err != nil {
+			p.count(policy, OutcomeFailed)
+			log.Infof(nil, "unable to evaluate the '%s' request policy: %s", policy.Name, err.Error())
+			continue
+		}
+		if matched, ok := value.Value().(bool); !ok || !matched {
+			continue
+		}
+		message := policy.Message
+		if message == "" {
+			message = fmt.Sprintf("the request matches the '%s' policy", policy.Name)
+		}
+		if policy.DryRun {
+			p.count(policy, OutcomeDryRun)
+			log.Infof(nil, "the request of '%s' to '%s' in workspace '%s' matches the '%s' request policy (%s, dry-run): %s",
+				attrs.Username, attrs.Request.URL.Path, attrs.Workspace.Name, policy.Name, policy.Action, message)
+			continue
+		}
+		if policy.Action == configuration.RequestPolicyActionWarn {
+			p.count(policy, OutcomeWarned)
+			warnings = append(warnings, message)
+			continue
+		}
+		p.count(policy, OutcomeDenied)
+		return nil, crterrors.NewForbiddenError("request denied", fmt.Sprintf("the '%s' policy denies the request: %s", policy.Name, message))
+	}
+	return warnings, nil
+}
+
+func (p *RequestPolicies) count(policy *requestPolicy, outcome string) {
+	if p.matches != nil {
+		p.matches.WithLabelValues(policy.Name, outcome).Inc()
+	}
+}
+
+// variables returns the variables of the expressions for the given request, and true if the request has a body which
+// cannot be decoded
+func (p *RequestPolicies) variables(attrs RequestAttributes) (map[string]interface{}, bool, error) {
+	req := attrs.Request
+	request := map[string]interface{}{
+		"verb":              "",
+		"apiGroup":          "",
+		"apiVersion":        "",
+		"resource":          "",
+		"subresource":       "",
+		"name":              "",
+		"namespace":         "",
+		"path":              req.URL.Path,
+		"method":            req.Method,
+		"isResourceRequest": false,
+		"upgrade":           httpstream.IsUpgradeRequest(req),
+		"contentLength":     req.ContentLength,
+	}
+	if info, err := requestinfo.New(req); err == nil {
+		request["verb"] = info.Verb
+		request["apiGroup"] = info.APIGroup
+		request["apiVersion"] = info.APIVersion
+		request["resource"] = info.Resource
+		request["subresource"] = info.Subresource
+		request["name"] = info.Name
+		request["namespace"] = info.Namespace
+		request["isResourceRequest"] = info.IsResourceRequest
+	}
+	var object interface{}
+	undecodable := false
+	if p.needsBody {
+		body, size, err := p.readBody(req)
+		if err != nil {
+			return nil, false, err
+		}
+		if size > req.ContentLength {
+			request["contentLength"] = size
+		}
+		object = body
+		undecodable = size > 0 && body == nil
+	}
+	return map[string]interface{}{
+		"request": request,
+		"user": map[string]string{
+			"id":       attrs.UserID,
+			"username": attrs.Username,
+			"tier":     attrs.UserTier,
+		},
+		"workspace": map[string]string{
+			"name": attrs.Workspace.Name,
+			"role": attrs.Workspace.Status.Role,
+			"type": attrs.Workspace.Status.Type,
+			"tier": attrs.WorkspaceTier,
+		},
+		"object": object,
+	}, undecodable, nil
+}
+
+// readBody returns the body of the request decoded from JSON and its size, or nil if there is no body, or if it is not JSON
+// or too large. The size of a body which is too large is only known to be larger than the maximum size. The body can still
+// be read once it is returned.
+func (p *RequestPolicies) readBody(req *http.Request) (interface{}, int64, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, 0, nil
+	}
+	data, err := io.ReadAll(io.LimitReader(req.Body, int64(p.maxBodySize)+1))
+	if err != nil {
+		return nil, 0, fmt.Errorf("unable to read the request body: %w", err)
+	}
+	if len(data) > p.maxBodySize {
+		// the rest of the body is still forwarded
+		req.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(data), req.Body), Closer: req.Body}
+		return nil, int64(len(data)), nil
+	}
+	req.Body = readCloser{Reader: bytes.NewReader(data), Closer: req.Body}
+	var object interface{}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, int64(len(data)), nil
+	}
+	return object, int64(len(data)), nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,309 @@
+package policy_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/policy"
+
+	"github.com/prometheus/client_golang/prometheus"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNewRequestPolicies(t *testing.T) {
+	tests := map[string]struct {
+		policy        configuration.RequestPolicy
+		expectedError string
+	}{
+		"valid": {
+			policy: configuration.RequestPolicy{Name: "valid", Expression: "request.verb == 'delete' && workspace.role == 'viewer'"},
+		},
+		"invalid action": {
+			policy:        configuration.RequestPolicy{Name: "invalid", Expression: "true", Action: "block"},
+			expectedError: "invalid action 'block' of the 'invalid' request policy, it must be 'deny' or 'warn'",
+		},
+		"invalid syntax": {
+			policy:        configuration.RequestPolicy{Name: "invalid", Expression: "request.verb =="},
+			expectedError: "invalid expression of the 'invalid' request policy",
+		},
+		"unknown variable": {
+			policy:        configuration.RequestPolicy{Name: "invalid", Expression: "cluster.name == 'member-1'"},
+			expectedError: "undeclared reference to 'cluster'",
+		},
+		"not a bool": {
+			policy:        configuration.RequestPolicy{Name: "invalid", Expression: "request.verb"},
+			expectedError: "invalid expression of the 'invalid' request policy: it must return a bool",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// when
+			_, err := policy.NewRequestPolicies([]configuration.RequestPolicy{tc.policy}, false, 1024, nil)
+
+			// then
+			if tc.expectedError == "" {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.expectedError)
+			}
+		})
+	}
+}
+
+func TestEvaluateRequestPolicies(t *testing.T) {
+	log.Init("registration-service-testing")
+
+	// given
+	newAttributes := func(method, path, body string) policy.RequestAttributes {
+		var req *http.Request
+		if body == "" {
+			req = httptest.NewRequest(method, path, nil)
+		} else {
+			req = httptest.NewRequest(method, path, strings.NewReader(body))
+		}
+		return policy.RequestAttributes{
+			Request:  req,
+			UserID:   "john-id",
+			Username: "john",
+			UserTier: "deactivate30",
+			Workspace: &toolchainv1alpha1.Workspace{
+				ObjectMeta: metav1.ObjectMeta{Name: "team"},
+				Status: toolchainv1alpha1.WorkspaceStatus{
+					Role: "contributor",
+				},
+			},
+			WorkspaceTier: "base1ns",
+		}
+	}
+	newPolicies := func(t *testing.T, dryRun bool, policies ...configuration.RequestPolicy) (*policy.RequestPolicies, *prometheus.CounterVec) {
+		matches := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "matches"}, []string{"policy", "outcome"})
+		p, err := policy.NewRequestPolicies(policies, dryRun, 64, matches)
+		require.NoError(t, err)
+		return p, matches
+	}
+	noLoadBalancers := configuration.RequestPolicy{
+		Name:       "no-load-balancers",
+		Expression: "request.resource == 'services' && request.verb in ['create', 'update', 'patch'] && has(object.spec) && has(object.spec.type) && object.spec.type == 'LoadBalancer'",
+		Message:    "the services of type LoadBalancer are not allowed",
+	}
+	warnLoadBalancers := configuration.RequestPolicy{
+		Name:       "warn-load-balancers",
+		Expression: noLoadBalancers.Expression,
+		Action:     configuration.RequestPolicyActionWarn,
+	}
+	noExec := configuration.RequestPolicy{
+		Name:       "no-exec",
+		Expression: "request.subresource == 'exec' && workspace.role != 'admin'",
+	}
+	largeConfigMaps := configuration.RequestPolicy{
+		Name:       "large-configmaps",
+		Expression: "request.resource == 'configmaps' && request.contentLength > 32",
+		Action:     configuration.RequestPolicyActionWarn,
+		Message:    "the ConfigMap is large",
+	}
+	tierOnly := configuration.RequestPolicy{
+		Name:       "tier",
+		Expression: "user.tier == 'deactivate30' && workspace.tier == 'base1ns' && user.username == 'john' && workspace.name == 'team' && request.namespace == 'team-dev'",
+		Action:     configuration.RequestPolicyActionWarn,
+	}
+
+	t.Run("denied", func(t *testing.T) {
+		// given
+		p, matches := newPolicies(t, false, noLoadBalancers, noExec)
+		attrs := newAttributes(http.MethodPost, "/api/v1/namespaces/team-dev/services", `{"kind":"Service","spec":{"type":"LoadBalancer"}}`)
+
+		// when
+		warnings, err := p.Evaluate(attrs)
+
+		// then
+		require.EqualError(t, err, "request denied: the 'no-load-balancers' policy denies the request: the services of type LoadBalancer are not allowed")
+		assert.Equal(t, http.StatusForbidden, err.(*crterrors.Error).Code)
+		assert.Empty(t, warnings)
+		assert.Equal(t, float64(1), promtestutil.ToFloat64(matches.WithLabelValues("no-load-balancers", policy.OutcomeDenied)))
+		// the body can still be forwarded
+		body, err := io.ReadAll(attrs.Request.Body)
+		require.NoError(t, err)
+		assert.Equal(t, `{"kind":"Service","spec":{"type":"LoadBalancer"}}`, string(body))
+	})
+
+	t.Run("denied with the default message", func(t *testing.T) {
+		// given
+		p, _ := newPolicies(t, false, noLoadBalancers, noExec)
+		attrs := newAttributes(http.MethodPost, "/api/v1/namespaces/team-dev/pods/app/exec?command=sh", "")
+
+		// when
+		_, err := p.Evaluate(attrs)
+
+		// then
+		require.EqualError(t, err, "request denied: the 'no-exec' policy denies the request: the request matches the 'no-exec' policy")
+	})
+
+	t.Run("allowed", func(t *testing.T) {
+		// given
+		p, matches := newPolicies(t, false, noLoadBalancers, noExec)
+		attrs := newAttributes(http.MethodPost, "/api/v1/namespaces/team-dev/services", `{"kind":"Service","spec":{"type":"ClusterIP"}}`)
+
+		// when
+		warnings, err := p.Evaluate(attrs)
+
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, warnings)
+		assert.Equal(t, 0, promtestutil.CollectAndCount(matches))
+	})
+
+	t.Run("warned", func(t *testing.T) {
+		// given
+		p, matches := newPolicies(t, false, noLoadBalancers, largeConfigMaps, tierOnly)
+		attrs := newAttributes(http.MethodPost, "/api/v1/namespaces/team-dev/configmaps", `{"kind":"ConfigMap","data":{"key":"a large value"}}`)
+
+		// when
+		warnings, err := p.Evaluate(attrs)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []string{"the ConfigMap is large", "the request matches the 'tier' policy"}, warnings)
+		assert.Equal(t, float64(1), promtestutil.ToFloat64(matches.WithLabelValues("large-configmaps", policy.OutcomeWarned)))
+		assert.Equal(t, float64(1), promtestutil.ToFloat64(matches.WithLabelValues("tier", policy.OutcomeWarned)))
+	})
+
+	t.Run("body too large to be decoded", func(t *testing.T) {
+		// given
+		value := strings.Repeat("a", 100)
+		newLargeAttributes := func() policy.RequestAttributes {
+			attrs := newAttributes(http.MethodPost, "/api/v1/namespaces/team-dev/configmaps", `{"kind":"ConfigMap","data":{"key":"`+value+`"}}`)
+			attrs.Request.ContentLength = -1 // sent in chunks
+			return attrs
+		}
+
+		t.Run("warned", func(t *testing.T) {
+			// given
+			p, _ := newPolicies(t, false, warnLoadBalancers, largeConfigMaps)
+			attrs := newLargeAttributes()
+
+			// when
+			warnings, err := p.Evaluate(attrs)
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, []string{"the ConfigMap is large"}, warnings)
+			// the whole body can still be forwarded
+			body, err := io.ReadAll(attrs.Request.Body)
+			require.NoError(t, err)
+			assert.Equal(t, `{"kind":"ConfigMap","data":{"key":"`+value+`"}}`, string(body))
+		})
+
+		t.Run("denied by the policies using the body", func(t *testing.T) {
+			// given
+			p, matches := newPolicies(t, false, noLoadBalancers, largeConfigMaps)
+
+			// when
+			_, err := p.Evaluate(newLargeAttributes())
+
+			// then
+			require.EqualError(t, err, "request denied: the 'no-load-balancers' policy denies the request: the request body cannot be evaluated, it must be JSON and not larger than 64 bytes")
+			assert.Equal(t, float64(1), promtestutil.ToFloat64(matches.WithLabelValues("no-load-balancers", policy.OutcomeDenied)))
+		})
+	})
+
+	t.Run("body not in JSON", func(t *testing.T) {
+		// the deny policies using the body cannot be bypassed by sending the body in YAML
+		yamlService := "kind: Service\nspec:\n  type: LoadBalancer\n"
+
+		t.Run("denied by the policies using the body", func(t *testing.T) {
+			// given
+			p, _ := newPolicies(t, false, noLoadBalancers)
+			attrs := newAttributes(http.MethodPost, "/api/v1/namespaces/team-dev/services", yamlService)
+			attrs.Request.Header.Set("Content-Type", "application/yaml")
+
+			// when
+			_, err := p.Evaluate(attrs)
+
+			// then
+			require.EqualError(t, err, "request denied: the 'no-load-balancers' policy denies the request: the request body cannot be evaluated, it must be JSON and not larger than 64 bytes")
+		})
+
+		t.Run("not denied by the other policies", func(t *testing.T) {
+			// given
+			p, _ := newPolicies(t, false, noExec, warnLoadBalancers)
+			attrs := newAttributes(http.MethodPost, "/api/v1/namespaces/team-dev/services", yamlService)
+
+			// when
+			warnings, err := p.Evaluate(attrs)
+
+			// then
+			require.NoError(t, err)
+			assert.Empty(t, warnings)
+		})
+
+		t.Run("not denied in dry-run mode", func(t *testing.T) {
+			// given
+			p, _ := newPolicies(t, true, noLoadBalancers)
+			attrs := newAttributes(http.MethodPost, "/api/v1/namespaces/team-dev/services", yamlService)
+
+			// when
+			_, err := p.Evaluate(attrs)
+
+			// then
+			require.NoError(t, err)
+		})
+	})
+
+	t.Run("dry-run", func(t *testing.T) {
+		for name, tc := range map[string]struct {
+			dryRun     bool
+			policyMode bool
+		}{
+			"all the policies": {dryRun: true},
+			"single policy":    {policyMode: true},
+		} {
+			t.Run(name, func(t *testing.T) {
+				// given
+				dryRunExec := noExec
+				dryRunExec.DryRun = tc.policyMode
+				dryRunConfigMaps := largeConfigMaps
+				dryRunConfigMaps.DryRun = tc.policyMode
+				p, matches := newPolicies(t, tc.dryRun, dryRunExec, dryRunConfigMaps)
+
+				// when
+				warnings1, err1 := p.Evaluate(newAttributes(http.MethodPost, "/api/v1/namespaces/team-dev/pods/app/exec?command=sh", ""))
+				warnings2, err2 := p.Evaluate(newAttributes(http.MethodPost, "/api/v1/namespaces/team-dev/configmaps", `{"kind":"ConfigMap","data":{"key":"a large value"}}`))
+
+				// then
+				require.NoError(t, err1)
+				require.NoError(t, err2)
+				assert.Empty(t, warnings1)
+				assert.Empty(t, warnings2)
+				assert.Equal(t, float64(1), promtestutil.ToFloat64(matches.WithLabelValues("no-exec", policy.OutcomeDryRun)))
+				assert.Equal(t, float64(1), promtestutil.ToFloat64(matches.WithLabelValues("large-configmaps", policy.OutcomeDryRun)))
+			})
+		}
+	})
+
+	t.Run("failed evaluation does not match", func(t *testing.T) {
+		// given
+		p, matches := newPolicies(t, false, configuration.RequestPolicy{
+			Name:       "missing-field",
+			Expression: "object.spec.type == 'LoadBalancer'",
+		})
+
+		// when
+		warnings, err := p.Evaluate(newAttributes(http.MethodPost, "/api/v1/namespaces/team-dev/configmaps", `{"kind":"ConfigMap"}`))
+
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, warnings)
+		assert.Equal(t, float64(1), promtestutil.ToFloat64(matches.WithLabelValues("missing-field", policy.OutcomeFailed)))
+	})
+}
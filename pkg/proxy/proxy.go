@@ -10,11 +10,10 @@ import (
 	"net"
 	"net/http"
 	"net/http/httputil"
-	"net/textproto"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
-	"unicode/utf8"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
 	"github.com/codeready-toolchain/registration-service/pkg/application"
@@ -24,16 +23,24 @@ import (
 	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
 	"github.com/codeready-toolchain/registration-service/pkg/log"
 	"github.com/codeready-toolchain/registration-service/pkg/proxy/access"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/audit"
 	"github.com/codeready-toolchain/registration-service/pkg/proxy/handlers"
 	"github.com/codeready-toolchain/registration-service/pkg/proxy/metrics"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/policy"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/ratelimit"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/requestinfo"
+	"github.com/codeready-toolchain/registration-service/pkg/util"
 	commoncluster "github.com/codeready-toolchain/toolchain-common/pkg/cluster"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	glog "github.com/labstack/gommon/log"
 	errs "github.com/pkg/errors"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/client-go/tools/cache"
 
 	"k8s.io/apiserver/pkg/util/wsstream"
 	"k8s.io/client-go/rest"
@@ -41,8 +48,7 @@ import (
 )
 
 const (
-	DefaultPort          = "8081"
-	bearerProtocolPrefix = "base64url.bearer.authorization.k8s.io." //nolint:gosec
+	DefaultPort = "8081"
 
 	proxyHealthEndpoint          = "/proxyhealth"
 	authEndpoint                 = "/auth/"
@@ -50,25 +56,66 @@ const (
 	pluginsEndpoint              = "/plugins/"
 )
 
-func ssoWellKnownTarget() string {
-	return fmt.Sprintf("%s/auth/realms/%s/.well-known/openid-configuration", configuration.GetRegistrationServiceConfig().Auth().SSOBaseURL(), configuration.GetRegistrationServiceConfig().Auth().SSORealm())
+// ssoWellKnownTarget returns the URL of the OpenID configuration of the SSO
+func (p *Proxy) ssoWellKnownTarget() string {
+	if p.oidcDiscovery != nil {
+		return p.oidcDiscovery.WellKnownURL()
+	}
+	return fmt.Sprintf("%s/auth/realms/%s%s", configuration.GetRegistrationServiceConfig().Auth().SSOBaseURL(), configuration.GetRegistrationServiceConfig().Auth().SSORealm(), auth.WellKnownOpenIDConfigurationPath)
 }
 
-func openidAuthEndpoint() string {
+// openidAuthEndpoint returns the path of the authorization endpoint of the SSO
+func (p *Proxy) openidAuthEndpoint() string {
+	if p.oidcDiscovery != nil {
+		if endpoint, err := url.Parse(p.oidcDiscovery.Metadata().AuthorizationEndpoint); err == nil && endpoint.Path != "" {
+			return endpoint.Path
+		}
+	}
 	return fmt.Sprintf("/auth/realms/%s/protocol/openid-connect/auth", configuration.GetRegistrationServiceConfig().Auth().SSORealm())
 }
 
-func authorizationEndpointTarget() string {
-	return fmt.Sprintf("%s%s", configuration.GetRegistrationServiceConfig().Auth().SSOBaseURL(), openidAuthEndpoint())
+// ssoBaseURL returns the scheme and host of the SSO
+func (p *Proxy) ssoBaseURL() (*url.URL, error) {
+	if p.oidcDiscovery != nil {
+		issuerURL, err := url.Parse(p.oidcDiscovery.Metadata().Issuer)
+		if err != nil {
+			return nil, err
+		}
+		return &url.URL{Scheme: issuerURL.Scheme, Host: issuerURL.Host}, nil
+	}
+	return url.Parse(configuration.GetRegistrationServiceConfig().Auth().SSOBaseURL())
+}
+
+// authorizationEndpointTarget returns the URL of the authorization endpoint of the SSO
+func (p *Proxy) authorizationEndpointTarget() (*url.URL, error) {
+	if p.oidcDiscovery != nil {
+		return url.Parse(p.oidcDiscovery.Metadata().AuthorizationEndpoint)
+	}
+	return p.ssoBaseURL()
 }
 
 type Proxy struct {
 	app            application.Application
 	cl             client.Client
-	tokenParser    *auth.TokenParser
+	authenticator  auth.Authenticator
+	oidcDiscovery  *auth.OIDCDiscovery
 	spaceLister    *handlers.SpaceLister
 	metrics        *metrics.ProxyMetrics
 	getMembersFunc commoncluster.GetMemberClustersFunc
+	// openidAuthPath is the path of the route of the authorization endpoint, captured when the proxy is started
+	openidAuthPath string
+	// auditor records the audit events of the proxied requests, or is nil if no audit sink is configured
+	auditor *audit.Auditor
+	// limiter limits the rate and the concurrency of the requests of each user, or is nil if no limit is configured
+	limiter *ratelimit.Limiter
+	// accessCache caches the resolved accesses of the users to the workspaces, or is nil if the cache is disabled
+	accessCache *access.Cache
+	// transports holds the transports of the requests to the API servers of the member clusters
+	transports *access.TransportPool
+	// policies rejects the requests which the SpaceRole of the user can never perform, or is nil if the requests are not restricted
+	policies *policy.Enforcer
+	// requestPolicies denies or warns about the requests matching the configured CEL policies, or is nil if no policy is configured
+	requestPolicies *policy.RequestPolicies
 }
 
 func NewProxy(app application.Application, proxyMetrics *metrics.ProxyMetrics, getMembersFunc commoncluster.GetMemberClustersFunc) (*Proxy, error) {
@@ -85,15 +132,53 @@ func newProxyWithClusterClient(app application.Application, cln client.Client, p
 		return nil, err
 	}
 
+	authenticator := auth.NewDefaultAuthenticatorChain(tokenParser)
+	if secret := configuration.GetRegistrationServiceConfig().Auth().APITokenSecret(); secret != "" {
+		signer, err := auth.NewAPITokenSigner(secret)
+		if err != nil {
+			return nil, err
+		}
+		// the API tokens are checked first, since they are also bearer tokens
+		apiTokenAuthenticator := auth.NewAPITokenAuthenticator(signer, func(name string) (*toolchainv1alpha1.UserSignup, error) {
+			return app.InformerService().GetUserSignup(name)
+		})
+		authenticator = append(auth.AuthenticatorChain{apiTokenAuthenticator}, authenticator...)
+	}
+
+	auditor, err := audit.NewAuditorFromConfig(configuration.GetRegistrationServiceConfig().Proxy(), proxyMetrics.RegServProxyAuditEventsCounterVec)
+	if err != nil {
+		return nil, err
+	}
+	limiter, err := ratelimit.NewLimiterFromConfig(configuration.GetRegistrationServiceConfig().Proxy())
+	if err != nil {
+		return nil, err
+	}
+	requestPolicies, err := policy.NewRequestPoliciesFromConfig(configuration.GetRegistrationServiceConfig().Proxy(), proxyMetrics.RegServProxyRequestPolicyMatchesCounterVec)
+	if err != nil {
+		return nil, err
+	}
+	var accessCache *access.Cache
+	if size := configuration.GetRegistrationServiceConfig().Proxy().AccessCacheSize(); size > 0 {
+		accessCache = access.NewCache(size, configuration.GetRegistrationServiceConfig().Proxy().AccessCacheTTL(),
+			proxyMetrics.RegServProxyAccessCacheLookupsCounterVec, proxyMetrics.RegServProxyAccessCacheInvalidationsCounterVec)
+	}
+
 	// init handlers
 	spaceLister := handlers.NewSpaceLister(app, proxyMetrics)
 	return &Proxy{
-		app:            app,
-		cl:             cln,
-		tokenParser:    tokenParser,
-		spaceLister:    spaceLister,
-		metrics:        proxyMetrics,
-		getMembersFunc: getMembersFunc,
+		app:             app,
+		cl:              cln,
+		authenticator:   authenticator,
+		oidcDiscovery:   auth.DefaultOIDCDiscovery(),
+		spaceLister:     spaceLister,
+		metrics:         proxyMetrics,
+		getMembersFunc:  getMembersFunc,
+		auditor:         auditor,
+		limiter:         limiter,
+		accessCache:     accessCache,
+		transports:      access.NewTransportPool(),
+		policies:        policy.NewEnforcer(configuration.GetRegistrationServiceConfig().Proxy().SpaceRolePolicies()),
+		requestPolicies: requestPolicies,
 	}, nil
 }
 
@@ -105,6 +190,7 @@ func (p *Proxy) StartProxy(port string) *http.Server {
 	// middleware before routing
 	router.Pre(
 		p.addStartTime(),
+		p.auditRequest(), // record the audit event once the request has been handled by the other middlewares and the routes
 		middleware.RemoveTrailingSlash(),
 		p.stripInvalidHeaders(),
 		p.addUserContext(), // get user information from token before handling request
@@ -137,25 +223,33 @@ func (p *Proxy) StartProxy(port string) *http.Server {
 	)
 
 	// routes
-	wg := router.Group("/apis/toolchain.dev.openshift.com/v1alpha1/workspaces")
+	wg := router.Group("/apis/toolchain.dev.openshift.com/v1alpha1/workspaces", p.restrictWorkspacesToAPITokenScope())
 	// Space lister routes
 	wg.GET("/:workspace", handlers.HandleSpaceGetRequest(p.spaceLister, p.getMembersFunc))
 	wg.GET("", handlers.HandleSpaceListRequest(p.spaceLister))
+	wg.PUT("/:workspace", handlers.HandleSpaceUpdateRequest(p.spaceLister, p.getMembersFunc))
+	wg.PATCH("/:workspace", handlers.HandleSpaceUpdateRequest(p.spaceLister, p.getMembersFunc))
+	wg.POST("", handlers.HandleSpaceCreateRequest(p.spaceLister, p.getMembersFunc, configuration.GetRegistrationServiceConfig().Proxy().SubWorkspaceTiers()))
+	wg.DELETE("/:workspace", handlers.HandleSpaceDeleteRequest(p.spaceLister, p.getMembersFunc))
 	router.GET(proxyHealthEndpoint, p.health)
 	// SSO routes. Used by web login (oc login -w).
 	// Here is the expected flow for the "oc login -w" command:
 	// 1. "oc login -w --server=<proxy_url>"
 	// 2. oc calls <proxy_url>/.well-known/oauth-authorization-server (wellKnownOauthConfigEndpoint endpoint)
 	// 3. proxy forwards it to <sso_url>/auth/realms/<sso_realm>/.well-known/openid-configuration
+	//    (or to the configuration document of the OpenID Provider, if configured)
 	// 4. oc starts an OAuth flow by opening a browser for <proxy_url>/auth/realms/<realm>/protocol/openid-connect/auth
 	// 5. proxy redirects (the request is not proxied but redirected via 403 See Others response!) the request
 	//    to <sso_url>/auth/realms/<realm>/protocol/openid-connect/auth
 	//    Note: oc uses this hardcoded public (no secret) oauth client name: "openshift-cli-client" which has to exist in SSO to make this flow work.
 	// 6. user provides the login credentials in the sso login page
 	// 7. all following oc requests (<proxy_url>/auth/*) go to the proxy and forwarded to SSO as is. This is used to obtain the generated token by oc.
-	router.Any(wellKnownOauthConfigEndpoint, p.oauthConfiguration)     // <- this is the step 2 in the flow above
-	router.Any(fmt.Sprintf("%s*", openidAuthEndpoint()), p.openidAuth) // <- this is the step 5 in the flow above
-	router.Any(fmt.Sprintf("%s*", authEndpoint), p.auth)               // <- this is the step 7.
+	// the path of the authorization endpoint is captured once, so that the route and the unsecured endpoints stay in sync
+	// when the OpenID configuration is refreshed
+	p.openidAuthPath = p.openidAuthEndpoint()
+	router.Any(wellKnownOauthConfigEndpoint, p.oauthConfiguration) // <- this is the step 2 in the flow above
+	router.Any(p.openidAuthPath, p.openidAuth)                     // <- this is the step 5 in the flow above
+	router.Any(fmt.Sprintf("%s*", authEndpoint), p.auth)           // <- this is the step 7.
 	// The main proxy route
 	router.Any("/*", p.handleRequestAndRedirect)
 
@@ -169,12 +263,40 @@ func (p *Proxy) StartProxy(port string) *http.Server {
 		ReadHeaderTimeout: 2 * time.Second,
 		TLSConfig: &tls.Config{
 			MinVersion: tls.VersionTLS12,
-			NextProtos: []string{"http/1.1"}, // disable HTTP/2 for now
+			NextProtos: []string{"h2", "http/1.1"},
 		},
 	}
+	cfg := configuration.GetRegistrationServiceConfig()
+	certFile, keyFile := cfg.Proxy().TLSCertFile(), cfg.Proxy().TLSKeyFile()
+	serveTLS := certFile != "" && keyFile != ""
+	if caFile := cfg.Auth().ClientCAFile(); caFile != "" {
+		// the client certificates can only be verified during the TLS handshake
+		if !serveTLS {
+			log.Error(nil, fmt.Errorf("the certificate and key of the proxy are not set"), "the client certificates are not accepted")
+		} else if err := auth.ConfigureClientCertificates(srv.TLSConfig, caFile); err != nil {
+			log.Error(nil, err, "the client certificates are not accepted")
+		}
+	}
+	if err := util.EnableHTTP2(srv); err != nil {
+		log.Error(nil, err, "unable to enable HTTP/2, only HTTP/1.1 is served")
+	}
+	if p.auditor != nil {
+		// write the remaining audit events when the proxy server shuts down
+		srv.RegisterOnShutdown(func() {
+			if err := p.auditor.Close(); err != nil {
+				log.Error(nil, err, "failed to close the auditor")
+			}
+		})
+	}
 	// listen concurrently to allow for graceful shutdown
 	go func() {
-		if err := srv.ListenAndServe(); err != nil {
+		var err error
+		if serveTLS {
+			err = srv.ListenAndServeTLS(certFile, keyFile)
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil {
 			log.Error(nil, err, err.Error())
 		}
 	}()
@@ -183,15 +305,16 @@ func (p *Proxy) StartProxy(port string) *http.Server {
 }
 
 // unsecured returns true if the request does not require authentication
-func unsecured(ctx echo.Context) bool {
+func (p *Proxy) unsecured(ctx echo.Context) bool {
 	uri := ctx.Request().URL.RequestURI()
-	return uri == proxyHealthEndpoint || uri == wellKnownOauthConfigEndpoint || strings.HasPrefix(uri, authEndpoint)
+	return uri == proxyHealthEndpoint || uri == wellKnownOauthConfigEndpoint || strings.HasPrefix(uri, authEndpoint) ||
+		(p.openidAuthPath != "" && ctx.Request().URL.Path == p.openidAuthPath)
 }
 
 // auth handles requests to SSO. Used by web login.
 func (p *Proxy) auth(ctx echo.Context) error {
 	req := ctx.Request()
-	targetURL, err := url.Parse(configuration.GetRegistrationServiceConfig().Auth().SSOBaseURL())
+	targetURL, err := p.ssoBaseURL()
 	if err != nil {
 		return err
 	}
@@ -203,7 +326,7 @@ func (p *Proxy) auth(ctx echo.Context) error {
 
 // oauthConfiguration handles requests to oauth configuration and proxies them to the corresponding SSO endpoint. Used by web login.
 func (p *Proxy) oauthConfiguration(ctx echo.Context) error {
-	targetURL, err := url.Parse(ssoWellKnownTarget())
+	targetURL, err := url.Parse(p.ssoWellKnownTarget())
 	if err != nil {
 		return err
 	}
@@ -212,7 +335,7 @@ func (p *Proxy) oauthConfiguration(ctx echo.Context) error {
 
 // openidAuth handles requests to the openID Connect authentication endpoint. Used by web login.
 func (p *Proxy) openidAuth(ctx echo.Context) error {
-	targetURL, err := url.Parse(authorizationEndpointTarget())
+	targetURL, err := p.authorizationEndpointTarget()
 	if err != nil {
 		return err
 	}
@@ -270,41 +393,266 @@ func (p *Proxy) processRequest(ctx echo.Context) (string, *access.ClusterAccess,
 	if err != nil {
 		return "", nil, crterrors.NewBadRequest("unable to get workspace context", err.Error())
 	}
+	if workspaceName, err = restrictToAPITokenScope(ctx, workspaceName); err != nil {
+		return "", nil, err
+	}
+	requestedNamespace := namespaceFromCtx(ctx)
+	if workspaceName == "" && requestedNamespace != "" {
+		// no workspace was requested: route the request to the workspace owning the namespace, so that the namespaces
+		// of the workspaces shared with the user can also be accessed without the workspace prefix
+		if workspaceName, err = p.workspaceOfNamespace(ctx, access.Key{
+			UserID:      userID,
+			Username:    username,
+			ProxyPlugin: proxyPluginName,
+		}, requestedNamespace); err != nil {
+			return "", nil, err
+		}
+	}
 
 	ctx.Set(context.WorkspaceKey, workspaceName) // set workspace context for logging
-	cluster, err := p.app.MemberClusterService().GetClusterAccess(userID, username, workspaceName, proxyPluginName)
+	ctx.Set(context.ProxyPluginKey, proxyPluginName)
+	cluster, err := p.clusterAccess(userID, username, workspaceName, proxyPluginName)
 	if err != nil {
-		return "", nil, crterrors.NewInternalError(errs.New("unable to get target cluster"), err.Error())
+		return "", nil, err
 	}
-
+	ctx.Set(context.ClusterAccessKey, cluster) // set target cluster context for auditing
 	// before proxying the request, verify that the user has a spacebinding for the workspace and that the namespace (if any) belongs to the workspace
+	resolved, err := p.resolveAccess(ctx, access.Key{
+		UserID:      userID,
+		Username:    username,
+		Workspace:   workspaceName,
+		ProxyPlugin: proxyPluginName,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	if workspaceName != "" && len(resolved.Workspaces) == 0 {
+		// not found
+		return "", nil, crterrors.NewForbiddenError("invalid workspace request", fmt.Sprintf("access to workspace '%s' is forbidden", workspaceName))
+	}
+	workspace, err := validateWorkspaceRequest(workspaceName, requestedNamespace, resolved.Workspaces)
+	if err != nil {
+		return "", nil, crterrors.NewForbiddenError("invalid workspace request", err.Error())
+	}
+	if p.policies != nil && proxyPluginName == "" {
+		// reject the requests which the role of the user can never perform, before they reach the member cluster
+		if err := p.policies.Authorize(p.spaceLister.GetInformerServiceFunc(), ctx.Request(), workspace); err != nil {
+			return "", nil, crterrors.NewForbiddenError("invalid workspace request", err.Error())
+		}
+	}
+	if p.requestPolicies != nil && proxyPluginName == "" {
+		if err := p.evaluateRequestPolicies(ctx, userID, cluster.Username(), workspace); err != nil {
+			return "", nil, err
+		}
+	}
+
+	return proxyPluginName, cluster, nil
+}
+
+// evaluateRequestPolicies returns an error if the request matches a `deny` request policy, and adds the messages of the
+// matching `warn` policies as warnings in the response
+func (p *Proxy) evaluateRequestPolicies(ctx echo.Context, userID, username string, workspace *toolchainv1alpha1.Workspace) error {
+	workspaceTier := ""
+	if space, err := p.spaceLister.GetInformerServiceFunc().GetSpace(workspace.Name); err == nil {
+		workspaceTier = space.Spec.TierName
+	}
+	warnings, err := p.requestPolicies.Evaluate(policy.RequestAttributes{
+		Request:       ctx.Request(),
+		UserID:        userID,
+		Username:      username,
+		UserTier:      p.userTier(username),
+		Workspace:     workspace,
+		WorkspaceTier: workspaceTier,
+	})
+	if err != nil {
+		return err
+	}
+	for _, warning := range warnings {
+		// the warnings are displayed by kubectl and client-go, see https://kubernetes.io/blog/2020/09/03/warnings/
+		ctx.Response().Header().Add("Warning", fmt.Sprintf("299 - %q", warning))
+	}
+	return nil
+}
+
+// workspaceOfNamespace returns the name of the workspace of the user owning the given namespace. It returns an empty
+// string if the namespace belongs to the home workspace of the user, or to none of their workspaces, in which case the
+// request is sent to the home workspace as before.
+func (p *Proxy) workspaceOfNamespace(ctx echo.Context, key access.Key, namespace string) (string, error) {
+	resolved, err := p.resolveAccess(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	for _, workspace := range resolved.Workspaces {
+		for _, ns := range workspace.Status.Namespaces {
+			if ns.Name != namespace {
+				continue
+			}
+			if workspace.Status.Type == "home" {
+				return "", nil
+			}
+			return workspace.Name, nil
+		}
+	}
+	return "", nil
+}
+
+// clusterAccess returns the access to the target cluster of the request. It is not cached, so that the requests are
+// sent with the current config and credentials of the member cluster, eg. after its token is rotated.
+func (p *Proxy) clusterAccess(userID, username, workspace, proxyPluginName string) (*access.ClusterAccess, error) {
+	cluster, err := p.app.MemberClusterService().GetClusterAccess(userID, username, workspace, proxyPluginName)
+	if err != nil {
+		ce := &crterrors.Error{}
+		if errors.As(err, &ce) {
+			// return the error as is, so that the user is told why the request can't be proxied, eg. when their account is not provisioned yet
+			return nil, ce
+		}
+		return nil, crterrors.NewInternalError(errs.New("unable to get target cluster"), err.Error())
+	}
+	return cluster, nil
+}
+
+// resolveAccess returns the workspaces the user is allowed to access: the requested workspace, or all the workspaces
+// of the user if no workspace was requested. The resolved access is cached if the cache is enabled.
+func (p *Proxy) resolveAccess(ctx echo.Context, key access.Key) (*access.Entry, error) {
+	var generation uint64
+	if p.accessCache != nil {
+		entry, gen, found := p.accessCache.Get(key)
+		if found {
+			return entry, nil
+		}
+		generation = gen
+	}
+
+	userSignup, err := p.spaceLister.GetProvisionedUserSignup(ctx)
+	if err != nil {
+		return nil, crterrors.NewInternalError(errs.New("unable to retrieve user workspaces"), err.Error())
+	}
 	var workspaces []toolchainv1alpha1.Workspace
-	if workspaceName != "" {
+	if key.Workspace != "" {
 		// when a workspace name was provided
 		// validate that the user has access to the workspace by getting all spacebindings recursively, starting from this workspace and going up to the parent workspaces till the "root" of the workspace tree.
-		workspace, err := handlers.GetUserWorkspace(ctx, p.spaceLister, workspaceName)
+		workspace, err := handlers.GetUserWorkspace(ctx, p.spaceLister, key.Workspace)
 		if err != nil {
-			return "", nil, crterrors.NewInternalError(errs.New("unable to retrieve user workspaces"), err.Error())
+			return nil, crterrors.NewInternalError(errs.New("unable to retrieve user workspaces"), err.Error())
 		}
-		if workspace == nil {
-			// not found
-			return "", nil, crterrors.NewForbiddenError("invalid workspace request", fmt.Sprintf("access to workspace '%s' is forbidden", workspaceName))
+		if workspace != nil {
+			// workspace was found means we can forward the request
+			workspaces = []toolchainv1alpha1.Workspace{*workspace}
 		}
-		// workspace was found means we can forward the request
-		workspaces = []toolchainv1alpha1.Workspace{*workspace}
 	} else {
 		// list all workspaces
 		workspaces, err = handlers.ListUserWorkspaces(ctx, p.spaceLister)
 		if err != nil {
-			return "", nil, crterrors.NewInternalError(errs.New("unable to retrieve user workspaces"), err.Error())
+			return nil, crterrors.NewInternalError(errs.New("unable to retrieve user workspaces"), err.Error())
 		}
 	}
-	requestedNamespace := namespaceFromCtx(ctx)
-	if err := validateWorkspaceRequest(workspaceName, requestedNamespace, workspaces); err != nil {
-		return "", nil, crterrors.NewForbiddenError("invalid workspace request", err.Error())
+
+	entry := &access.Entry{
+		Workspaces: workspaces,
 	}
+	if userSignup != nil {
+		entry.Username = userSignup.CompliantUsername
+	}
+	if p.accessCache != nil {
+		names := []string{key.Workspace}
+		for _, workspace := range workspaces {
+			names = append(names, workspace.Name)
+		}
+		// the access also depends on the SpaceBindings inherited from the parent workspaces
+		p.accessCache.Add(key, entry, generation, p.parentWorkspaces(names)...)
+	}
+	return entry, nil
+}
 
-	return proxyPluginName, cluster, nil
+// parentWorkspaces returns the names of the parent workspaces of the given workspaces, up to the root workspaces
+func (p *Proxy) parentWorkspaces(names []string) []string {
+	var parents []string
+	visited := map[string]bool{}
+	for _, name := range names {
+		for name != "" && !visited[name] {
+			visited[name] = true
+			space, err := p.spaceLister.GetInformerServiceFunc().GetSpace(name)
+			if err != nil {
+				break
+			}
+			name = space.Spec.ParentSpace
+			if name != "" {
+				parents = append(parents, name)
+			}
+		}
+	}
+	return parents
+}
+
+// AccessCacheEventHandler returns the handler of the informer events which invalidates the cache of the accesses of
+// the users to the workspaces, or nil if the cache is disabled
+func (p *Proxy) AccessCacheEventHandler() cache.ResourceEventHandler {
+	if p.accessCache == nil {
+		return nil
+	}
+	return p.accessCache.EventHandler()
+}
+
+// WorkspaceWatchEventHandler returns the handler of the informer events which notifies the watches of the workspaces
+func (p *Proxy) WorkspaceWatchEventHandler() cache.ResourceEventHandler {
+	return p.spaceLister.Watcher.EventHandler()
+}
+
+// restrictToAPITokenScope restricts the requests authenticated with an API token to the workspace of the token,
+// and to the read-only requests if the token is read-only. Returns the workspace of the token if no workspace was requested.
+func restrictToAPITokenScope(ctx echo.Context, workspaceName string) (string, error) {
+	identity, _ := ctx.Get(context.IdentityKey).(*auth.Identity)
+	if identity == nil || identity.APIToken == nil {
+		return workspaceName, nil
+	}
+	scope := identity.APIToken
+	if workspaceName == "" {
+		workspaceName = scope.Workspace
+	} else if workspaceName != scope.Workspace {
+		return "", crterrors.NewForbiddenError("invalid workspace request", fmt.Sprintf("access to workspace '%s' is forbidden with an API token for workspace '%s'", workspaceName, scope.Workspace))
+	}
+	if scope.ReadOnly && !isReadOnlyRequest(ctx.Request()) {
+		return "", crterrors.NewForbiddenError("invalid API token request", fmt.Sprintf("%s requests are forbidden with a read-only API token", ctx.Request().Method))
+	}
+	return workspaceName, nil
+}
+
+// isReadOnlyRequest returns true if the request does not modify any resource. Upgraded connections (exec, attach,
+// port-forward) are not read-only, even though they may be initiated with a GET request.
+func isReadOnlyRequest(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return !httpstream.IsUpgradeRequest(req) && !wsstream.IsWebSocketRequest(req)
+	default:
+		return false
+	}
+}
+
+// restrictWorkspacesToAPITokenScope restricts the workspaces API to the workspace of the API token, if the request
+// is authenticated with an API token
+func (p *Proxy) restrictWorkspacesToAPITokenScope() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			identity, _ := ctx.Get(context.IdentityKey).(*auth.Identity)
+			if identity == nil || identity.APIToken == nil {
+				return next(ctx)
+			}
+			workspace := ctx.Param("workspace")
+			if workspace == "" && ctx.Request().Method == http.MethodPost {
+				return crterrors.NewForbiddenError("invalid workspace request", "creating the workspaces is forbidden with an API token")
+			}
+			if workspace == "" {
+				return crterrors.NewForbiddenError("invalid workspace request", "listing the workspaces is forbidden with an API token")
+			}
+			if workspace != identity.APIToken.Workspace {
+				return crterrors.NewForbiddenError("invalid workspace request", fmt.Sprintf("access to workspace '%s' is forbidden with an API token for workspace '%s'", workspace, identity.APIToken.Workspace))
+			}
+			if identity.APIToken.ReadOnly && !isReadOnlyRequest(ctx.Request()) {
+				return crterrors.NewForbiddenError("invalid API token request", fmt.Sprintf("%s requests are forbidden with a read-only API token", ctx.Request().Method))
+			}
+			return next(ctx)
+		}
+	}
 }
 
 func (p *Proxy) handleRequestAndRedirect(ctx echo.Context) error {
@@ -314,14 +662,76 @@ func (p *Proxy) handleRequestAndRedirect(ctx echo.Context) error {
 		p.metrics.RegServProxyAPIHistogramVec.WithLabelValues(fmt.Sprintf("%d", http.StatusNotAcceptable), metrics.MetricLabelRejected).Observe(time.Since(requestReceivedTime).Seconds())
 		return err
 	}
-	reverseProxy := p.newReverseProxy(ctx, cluster, len(proxyPluginName) > 0)
+	release, allowed := p.throttle(ctx, cluster)
+	if !allowed {
+		return nil
+	}
+	defer release()
+	reverseProxy, err := p.newReverseProxy(ctx, cluster, len(proxyPluginName) > 0)
+	if err != nil {
+		return crterrors.NewInternalError(errs.New("unable to create the reverse proxy"), err.Error())
+	}
 	routeTime := time.Since(requestReceivedTime)
 	p.metrics.RegServProxyAPIHistogramVec.WithLabelValues(fmt.Sprintf("%d", http.StatusAccepted), cluster.APIURL().Host).Observe(routeTime.Seconds())
-	// Note that ServeHttp is non-blocking and uses a go routine under the hood
-	reverseProxy.ServeHTTP(ctx.Response().Writer, ctx.Request())
+	// Note that ServeHttp is non-blocking and uses a go routine under the hood.
+	// The response is written through echo, so that its status code is available for auditing
+	reverseProxy.ServeHTTP(ctx.Response(), ctx.Request())
 	return nil
 }
 
+// throttle applies the rate limit of the verb class of the request and the cap on the concurrent long-running requests
+// of the user. It returns false if the request was rejected, in which case a 429 response was written. Otherwise, the returned
+// function must be called once the request is complete.
+func (p *Proxy) throttle(ctx echo.Context, cluster *access.ClusterAccess) (func(), bool) {
+	if p.limiter == nil {
+		return func() {}, true
+	}
+	user := cluster.Username()
+	info, err := requestinfo.New(ctx.Request())
+	if err != nil {
+		// the request is forwarded as is, the member cluster rejects it if it is invalid
+		return func() {}, true
+	}
+	class := ratelimit.ClassOf(info)
+	if delay := p.limiter.Allow(user, class); delay > 0 {
+		retryAfter := ratelimit.RetryAfterSeconds(delay)
+		p.tooManyRequests(ctx, user, string(class), retryAfter,
+			fmt.Sprintf("too many %s requests, please retry after %d seconds", class, retryAfter))
+		return nil, false
+	}
+	if !requestinfo.IsLongRunning(info) {
+		return func() {}, true
+	}
+	release, acquired := p.limiter.AcquireLongRunning(user)
+	if !acquired {
+		p.tooManyRequests(ctx, user, "long-running", 1, "too many concurrent long-running requests (watch, exec, logs, etc.), please close some of them")
+		return nil, false
+	}
+	return release, true
+}
+
+// tooManyRequests writes a 429 response with a Kubernetes Status, and counts the rejected request
+func (p *Proxy) tooManyRequests(ctx echo.Context, user, limit string, retryAfterSeconds int, message string) {
+	p.metrics.RegServProxyThrottledRequestsCounterVec.WithLabelValues(p.userTier(user), limit).Inc()
+	log.InfoEchof(ctx, "request throttled: %s", message)
+	status := apierrors.NewTooManyRequests(message, retryAfterSeconds).ErrStatus
+	status.APIVersion = "v1"
+	status.Kind = "Status"
+	ctx.Response().Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds))
+	if err := ctx.JSON(http.StatusTooManyRequests, status); err != nil {
+		ctx.Logger().Error(err)
+	}
+}
+
+// userTier returns the tier of the MasterUserRecord with the given name, or `unknown` if it cannot be retrieved
+func (p *Proxy) userTier(name string) string {
+	mur, err := p.app.InformerService().GetMasterUserRecord(name)
+	if err != nil || mur.Spec.TierName == "" {
+		return "unknown"
+	}
+	return mur.Spec.TierName
+}
+
 func getWorkspaceContext(req *http.Request) (string, string, error) {
 	path := req.URL.Path
 	proxyPluginName := ""
@@ -370,33 +780,74 @@ func getWorkspaceContext(req *http.Request) (string, string, error) {
 	return proxyPluginName, workspace, nil
 }
 
+// customHTTPErrorHandler writes the error as a Kubernetes Status, so that kubectl and the other clients show the
+// errors of the proxy as they show the errors of the API server
 func customHTTPErrorHandler(cause error, ctx echo.Context) {
-	code := http.StatusInternalServerError
+	ctx.Logger().Error(cause)
+	status := errorStatus(cause)
+	if err := ctx.JSON(int(status.Code), status); err != nil {
+		ctx.Logger().Error(err)
+	}
+}
+
+// statusReasons are the reasons of the Kubernetes Status per status code of the response
+var statusReasons = map[int]metav1.StatusReason{
+	http.StatusBadRequest:          metav1.StatusReasonBadRequest,
+	http.StatusUnauthorized:        metav1.StatusReasonUnauthorized,
+	http.StatusForbidden:           metav1.StatusReasonForbidden,
+	http.StatusNotFound:            metav1.StatusReasonNotFound,
+	http.StatusMethodNotAllowed:    metav1.StatusReasonMethodNotAllowed,
+	http.StatusConflict:            metav1.StatusReasonConflict,
+	http.StatusTooManyRequests:     metav1.StatusReasonTooManyRequests,
+	http.StatusInternalServerError: metav1.StatusReasonInternalError,
+	http.StatusServiceUnavailable:  metav1.StatusReasonServiceUnavailable,
+}
+
+// errorStatus returns the Kubernetes Status of the response to the given error
+func errorStatus(err error) *metav1.Status {
+	code, message := http.StatusInternalServerError, err.Error()
 	ce := &crterrors.Error{}
-	if errors.As(cause, &ce) {
+	he := &echo.HTTPError{}
+	if errors.As(err, &ce) {
 		code = ce.Code
+	} else if errors.As(err, &he) {
+		code, message = he.Code, fmt.Sprint(he.Message)
 	}
-	ctx.Logger().Error(cause)
-	if err := ctx.String(code, cause.Error()); err != nil {
-		ctx.Logger().Error(err)
+	reason, found := statusReasons[code]
+	if !found {
+		reason = metav1.StatusReasonUnknown
+	}
+	return &metav1.Status{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Status",
+			APIVersion: "v1",
+		},
+		Status:  metav1.StatusFailure,
+		Message: message,
+		Reason:  reason,
+		Code:    int32(code),
 	}
 }
 
-// addUserContext updates echo.Context with the User ID extracted from the Bearer token.
+// errorStatusCode returns the status code of the response to the given error
+func errorStatusCode(err error) int {
+	return int(errorStatus(err).Code)
+}
+
+// addUserContext updates echo.Context with the identity of the user authenticated by the authenticator chain.
 // To be used for storing the user ID and logging only.
 func (p *Proxy) addUserContext() echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(ctx echo.Context) error {
-			if unsecured(ctx) { // skip only for unsecured endpoints
+			if p.unsecured(ctx) { // skip only for unsecured endpoints
 				return next(ctx)
 			}
 
-			userID, username, err := p.extractUserID(ctx.Request())
+			identity, err := p.authenticator.Authenticate(ctx.Request())
 			if err != nil {
 				return crterrors.NewUnauthorizedError("invalid bearer token", err.Error())
 			}
-			ctx.Set(context.SubKey, userID)
-			ctx.Set(context.UsernameKey, username)
+			identity.StoreInto(ctx)
 
 			return next(ctx)
 		}
@@ -430,38 +881,58 @@ func (p *Proxy) addStartTime() echo.MiddlewareFunc {
 	}
 }
 
-func (p *Proxy) extractUserID(req *http.Request) (string, string, error) {
-	userToken := ""
-	var err error
-	if wsstream.IsWebSocketRequest(req) {
-		userToken, err = extractTokenFromWebsocketRequest(req)
-		if err != nil {
-			return "", "", err
-		}
-	} else {
-		userToken, err = extractUserToken(req)
-		if err != nil {
-			return "", "", err
+// auditRequest records the audit event of each request to the secured endpoints, once it has been handled
+func (p *Proxy) auditRequest() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			if p.auditor == nil || p.unsecured(ctx) {
+				return next(ctx)
+			}
+			// keep the original URI, since the workspace and plugin segments are removed from the path before forwarding the request
+			requestURI := ctx.Request().URL.RequestURI()
+			err := next(ctx)
+			p.auditor.Record(newAuditEvent(ctx, requestURI, err))
+			return err
 		}
 	}
-
-	token, err := p.tokenParser.FromString(userToken)
-	if err != nil {
-		return "", "", crterrors.NewUnauthorizedError("unable to extract userID from token", err.Error())
-	}
-	return token.Subject, token.PreferredUsername, nil
 }
 
-func extractUserToken(req *http.Request) (string, error) {
-	a := req.Header.Get("Authorization")
-	token := strings.Split(a, "Bearer ")
-	if len(token) < 2 {
-		return "", crterrors.NewUnauthorizedError("no token found", "a Bearer token is expected")
+// newAuditEvent returns the audit event of the given request, which was handled with the given error
+func newAuditEvent(ctx echo.Context, requestURI string, err error) *audit.Event {
+	received, found := ctx.Get(context.RequestReceivedTime).(time.Time)
+	if !found {
+		received = time.Now()
 	}
-	return token[1], nil
+	event := audit.NewEvent(ctx.Request(), requestURI, received)
+	event.DurationMillis = time.Since(received).Milliseconds()
+	if identity, ok := ctx.Get(context.IdentityKey).(*auth.Identity); ok {
+		event.User = identity.Username
+		event.UserID = identity.Subject
+		if identity.APIToken != nil {
+			event.APITokenID = identity.APIToken.ID
+		}
+	}
+	event.Workspace, _ = ctx.Get(context.WorkspaceKey).(string)
+	event.Plugin, _ = ctx.Get(context.ProxyPluginKey).(string)
+	if cluster, ok := ctx.Get(context.ClusterAccessKey).(*access.ClusterAccess); ok {
+		event.CompliantUsername = cluster.Username()
+		apiURL := cluster.APIURL()
+		event.Member = apiURL.Host
+	}
+	switch {
+	case err != nil:
+		event.StatusCode = errorStatusCode(err)
+		event.Error = err.Error()
+	case !ctx.Response().Committed && httpstream.IsUpgradeRequest(ctx.Request()):
+		// the connection was hijacked by the reverse proxy, which wrote the response of the member cluster directly
+		event.StatusCode = http.StatusSwitchingProtocols
+	default:
+		event.StatusCode = ctx.Response().Status
+	}
+	return event
 }
 
-func (p *Proxy) newReverseProxy(ctx echo.Context, target *access.ClusterAccess, isPlugin bool) *httputil.ReverseProxy {
+func (p *Proxy) newReverseProxy(ctx echo.Context, target *access.ClusterAccess, isPlugin bool) (*httputil.ReverseProxy, error) {
 	req := ctx.Request()
 	targetQuery := target.APIURL().RawQuery
 	director := func(req *http.Request) {
@@ -495,14 +966,42 @@ func (p *Proxy) newReverseProxy(ctx echo.Context, target *access.ClusterAccess,
 		// Set impersonation header
 		req.Header.Set("Impersonate-User", target.Username())
 	}
-	transport := getTransport(req.Header)
+	transport, err := p.transportFor(target, req)
+	if err != nil {
+		return nil, err
+	}
 	m := &responseModifier{req.Header.Get("Origin")}
 	return &httputil.ReverseProxy{
 		Director:       director,
 		Transport:      transport,
 		FlushInterval:  -1,
 		ModifyResponse: m.addCorsToResponse,
+	}, nil
+}
+
+// transportFor returns the transport of the given request to the given target. The requests to the API server of a member
+// cluster are sent with the pooled transport of the member cluster, which falls back to HTTP/1.1 for the SPDY and WebSocket
+// upgrade requests. The other requests (eg. to the route of a proxy plugin) are sent with a new transport.
+func (p *Proxy) transportFor(target *access.ClusterAccess, req *http.Request) (http.RoundTripper, error) {
+	if target.RestConfig() == nil || p.transports == nil {
+		return getTransport(req.Header), nil
+	}
+	return p.transports.Get(target.MemberName(), p.memberConfig(target), httpstream.IsUpgradeRequest(req))
+}
+
+// memberConfig returns the current config of the member cluster of the given target, so that the pooled transport of the
+// member cluster is rebuilt as soon as its CA or its certificates change. It returns the config of the target if the
+// member cluster is not found.
+func (p *Proxy) memberConfig(target *access.ClusterAccess) *rest.Config {
+	if p.getMembersFunc == nil {
+		return target.RestConfig()
+	}
+	for _, member := range p.getMembersFunc() {
+		if member.Name == target.MemberName() && member.RestConfig != nil {
+			return member.RestConfig
+		}
 	}
+	return target.RestConfig()
 }
 
 // TODO: use transport from the cached ToolchainCluster instance
@@ -522,8 +1021,8 @@ var noTimeoutDialerProxy = func(ctx gocontext.Context, network, addr string) (ne
 	return dialer.DialContext(ctx, network, addr)
 }
 
+// getTransport returns a new transport for the requests which are not sent to the API server of a member cluster
 func getTransport(reqHeader http.Header) *http.Transport {
-	// TODO: use transport from the cached ToolchainCluster instance
 	transport := noTimeoutDefaultTransport()
 
 	if !configuration.GetRegistrationServiceConfig().IsProdEnvironment() {
@@ -533,7 +1032,7 @@ func getTransport(reqHeader http.Header) *http.Transport {
 	}
 
 	// for exec and rsh command we cannot use h2 because it doesn't support "Upgrade: SPDY/3.1" header https://github.com/kubernetes/kubernetes/issues/7452
-	if strings.HasPrefix(strings.ToLower(reqHeader.Get(httpstream.HeaderUpgrade)), "spdy/") {
+	if isSPDYUpgrade(reqHeader) {
 		// thus, we need to switch to http/1.1
 		transport.ForceAttemptHTTP2 = false
 		transport.TLSClientConfig = &tls.Config{ // nolint:gosec
@@ -544,6 +1043,11 @@ func getTransport(reqHeader http.Header) *http.Transport {
 	return transport
 }
 
+// isSPDYUpgrade returns true if the request with the given headers is upgraded to SPDY (exec, attach, port-forward)
+func isSPDYUpgrade(reqHeader http.Header) bool {
+	return strings.HasPrefix(strings.ToLower(reqHeader.Get(httpstream.HeaderUpgrade)), "spdy/")
+}
+
 func singleJoiningSlash(a, b string) string {
 	aslash := strings.HasSuffix(a, "/")
 	bslash := strings.HasPrefix(b, "/")
@@ -579,60 +1083,26 @@ func newClusterClient() (client.Client, error) {
 	return cl, nil
 }
 
-var ph = textproto.CanonicalMIMEHeaderKey("Sec-WebSocket-Protocol")
-
-func extractTokenFromWebsocketRequest(req *http.Request) (string, error) {
-	token := ""
-	sawTokenProtocol := false
-	for _, protocolHeader := range req.Header[ph] {
-		for _, protocol := range strings.Split(protocolHeader, ",") {
-			protocol = strings.TrimSpace(protocol)
-			if !strings.HasPrefix(protocol, bearerProtocolPrefix) {
-				continue
-			}
-
-			if sawTokenProtocol {
-				return "", errs.New("multiple base64.bearer.authorization tokens specified")
-			}
-			sawTokenProtocol = true
-
-			encodedToken := strings.TrimPrefix(protocol, bearerProtocolPrefix)
-			decodedToken, err := base64.RawURLEncoding.DecodeString(encodedToken)
-			if err != nil {
-				return "", errs.Wrap(err, "invalid base64.bearer.authorization token encoding")
-			}
-			if !utf8.Valid(decodedToken) {
-				return "", errs.New("invalid base64.bearer.authorization token: contains non UTF-8-encoded runes")
-			}
-			token = string(decodedToken)
-		}
-	}
-
-	if len(token) == 0 {
-		return "", errs.New("no base64.bearer.authorization token found")
-	}
-
-	return token, nil
-}
-
 func replaceTokenInWebsocketRequest(req *http.Request, newToken string) {
 	var protocols []string
 	encodedToken := base64.RawURLEncoding.EncodeToString([]byte(newToken))
-	for _, protocolHeader := range req.Header[ph] {
+	for _, protocolHeader := range req.Header[auth.WebSocketProtocolHeader] {
 		for _, protocol := range strings.Split(protocolHeader, ",") {
 			protocol = strings.TrimSpace(protocol)
-			if !strings.HasPrefix(protocol, bearerProtocolPrefix) {
+			if !strings.HasPrefix(protocol, auth.WebSocketBearerProtocolPrefix) {
 				protocols = append(protocols, protocol)
 				continue
 			}
 			// Replace the token
-			protocols = append(protocols, bearerProtocolPrefix+encodedToken)
+			protocols = append(protocols, auth.WebSocketBearerProtocolPrefix+encodedToken)
 		}
 	}
-	req.Header.Set(ph, strings.Join(protocols, ","))
+	req.Header.Set(auth.WebSocketProtocolHeader, strings.Join(protocols, ","))
 }
 
-func validateWorkspaceRequest(requestedWorkspace, requestedNamespace string, workspaces []toolchainv1alpha1.Workspace) error {
+// validateWorkspaceRequest returns the requested workspace, or an error if the user is not allowed to access the workspace or
+// the namespace
+func validateWorkspaceRequest(requestedWorkspace, requestedNamespace string, workspaces []toolchainv1alpha1.Workspace) (*toolchainv1alpha1.Workspace, error) {
 	// check workspace access
 	isHomeWSRequested := requestedWorkspace == ""
 
@@ -644,7 +1114,7 @@ func validateWorkspaceRequest(requestedWorkspace, requestedNamespace string, wor
 		}
 	}
 	if allowedWorkspace == -1 {
-		return fmt.Errorf("access to workspace '%s' is forbidden", requestedWorkspace)
+		return nil, fmt.Errorf("access to workspace '%s' is forbidden", requestedWorkspace)
 	}
 
 	// check namespace access
@@ -658,10 +1128,10 @@ func validateWorkspaceRequest(requestedWorkspace, requestedNamespace string, wor
 			}
 		}
 		if !allowedNamespace {
-			return fmt.Errorf("access to namespace '%s' in workspace '%s' is forbidden", requestedNamespace, workspaces[allowedWorkspace].Name)
+			return nil, fmt.Errorf("access to namespace '%s' in workspace '%s' is forbidden", requestedNamespace, workspaces[allowedWorkspace].Name)
 		}
 	}
-	return nil
+	return &workspaces[allowedWorkspace], nil
 }
 
 func namespaceFromCtx(ctx echo.Context) string {
@@ -4,11 +4,15 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -19,27 +23,40 @@ import (
 
 	appservice "github.com/codeready-toolchain/registration-service/pkg/application/service"
 	"github.com/codeready-toolchain/registration-service/pkg/auth"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	regsercontext "github.com/codeready-toolchain/registration-service/pkg/context"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
 	"github.com/codeready-toolchain/registration-service/pkg/proxy/access"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/audit"
 	"github.com/codeready-toolchain/registration-service/pkg/proxy/handlers"
 	"github.com/codeready-toolchain/registration-service/pkg/proxy/metrics"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/policy"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/ratelimit"
 	"github.com/codeready-toolchain/registration-service/pkg/proxy/service"
 	proxytest "github.com/codeready-toolchain/registration-service/pkg/proxy/test"
 	"github.com/codeready-toolchain/registration-service/pkg/signup"
 	"github.com/codeready-toolchain/registration-service/test"
 	"github.com/codeready-toolchain/registration-service/test/fake"
 	"github.com/prometheus/client_golang/prometheus"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
 	commoncluster "github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	commonconfig "github.com/codeready-toolchain/toolchain-common/pkg/configuration"
 	commontest "github.com/codeready-toolchain/toolchain-common/pkg/test"
 	authsupport "github.com/codeready-toolchain/toolchain-common/pkg/test/auth"
 	testconfig "github.com/codeready-toolchain/toolchain-common/pkg/test/config"
+	spacetest "github.com/codeready-toolchain/toolchain-common/pkg/test/space"
 
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
 	routev1 "github.com/openshift/api/route/v1"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	"golang.org/x/net/http2"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/intstr"
@@ -124,6 +141,29 @@ func (s *TestProxySuite) TestProxy() {
 				s.assertResponseBody(resp, `{"alive": true}`)
 			})
 
+			s.Run("health check ok over HTTP/2", func() {
+				client := &http.Client{
+					Transport: &http2.Transport{
+						// HTTP/2 over cleartext connections
+						AllowHTTP: true,
+						DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+							return (&net.Dialer{}).DialContext(ctx, network, addr)
+						},
+					},
+				}
+
+				// when
+				resp, err := client.Get("http://localhost:8081/proxyhealth")
+
+				// then
+				require.NoError(s.T(), err)
+				require.NotNil(s.T(), resp)
+				defer resp.Body.Close()
+				assert.Equal(s.T(), http.StatusOK, resp.StatusCode)
+				assert.Equal(s.T(), "HTTP/2.0", resp.Proto)
+				s.assertResponseBody(resp, `{"alive": true}`)
+			})
+
 			s.checkPlainHTTPErrors(fakeApp)
 			s.checkWebsocketsError()
 			s.checkWebLogin()
@@ -147,7 +187,7 @@ func (s *TestProxySuite) checkPlainHTTPErrors(fakeApp *fake.ProxyFakeApp) {
 			require.NotNil(s.T(), resp)
 			defer resp.Body.Close()
 			assert.Equal(s.T(), http.StatusUnauthorized, resp.StatusCode)
-			s.assertResponseBody(resp, "invalid bearer token: no token found: a Bearer token is expected")
+			s.assertErrorStatus(resp, metav1.StatusReasonUnauthorized, "invalid bearer token: no token found: a Bearer token is expected")
 		})
 
 		s.Run("unauthorized if can't parse token", func() {
@@ -163,7 +203,7 @@ func (s *TestProxySuite) checkPlainHTTPErrors(fakeApp *fake.ProxyFakeApp) {
 			require.NotNil(s.T(), resp)
 			defer resp.Body.Close()
 			assert.Equal(s.T(), http.StatusUnauthorized, resp.StatusCode)
-			s.assertResponseBody(resp, "invalid bearer token: unable to extract userID from token: token is malformed: token contains an invalid number of segments")
+			s.assertErrorStatus(resp, metav1.StatusReasonUnauthorized, "invalid bearer token: unable to extract userID from token: token is malformed: token contains an invalid number of segments")
 		})
 
 		s.Run("unauthorized if can't extract userID from a valid token", func() {
@@ -180,7 +220,7 @@ func (s *TestProxySuite) checkPlainHTTPErrors(fakeApp *fake.ProxyFakeApp) {
 			require.NotNil(s.T(), resp)
 			defer resp.Body.Close()
 			assert.Equal(s.T(), http.StatusUnauthorized, resp.StatusCode)
-			s.assertResponseBody(resp, "invalid bearer token: unable to extract userID from token: token does not comply to expected claims: subject missing")
+			s.assertErrorStatus(resp, metav1.StatusReasonUnauthorized, "invalid bearer token: unable to extract userID from token: token does not comply to expected claims: subject missing")
 		})
 
 		s.Run("unauthorized if workspace context is invalid", func() {
@@ -197,7 +237,7 @@ func (s *TestProxySuite) checkPlainHTTPErrors(fakeApp *fake.ProxyFakeApp) {
 			require.NotNil(s.T(), resp)
 			defer resp.Body.Close()
 			assert.Equal(s.T(), http.StatusBadRequest, resp.StatusCode)
-			s.assertResponseBody(resp, "unable to get workspace context: workspace request path has too few segments '/workspaces/myworkspace'; expected path format: /workspaces/<workspace_name>/api/...")
+			s.assertErrorStatus(resp, metav1.StatusReasonBadRequest, "unable to get workspace context: workspace request path has too few segments '/workspaces/myworkspace'; expected path format: /workspaces/<workspace_name>/api/...")
 		})
 
 		s.Run("internal error if get accesses returns an error", func() {
@@ -214,7 +254,7 @@ func (s *TestProxySuite) checkPlainHTTPErrors(fakeApp *fake.ProxyFakeApp) {
 			require.NotNil(s.T(), resp)
 			defer resp.Body.Close()
 			assert.Equal(s.T(), http.StatusInternalServerError, resp.StatusCode)
-			s.assertResponseBody(resp, "unable to get target cluster: some-error")
+			s.assertErrorStatus(resp, metav1.StatusReasonInternalError, "unable to get target cluster: some-error")
 		})
 
 		s.Run("internal error if accessing incorrect url", func() {
@@ -232,6 +272,29 @@ func (s *TestProxySuite) checkPlainHTTPErrors(fakeApp *fake.ProxyFakeApp) {
 			defer resp.Body.Close()
 			assert.Equal(s.T(), http.StatusInternalServerError, resp.StatusCode)
 		})
+
+		for reason, cause := range map[metav1.StatusReason]*crterrors.Error{
+			metav1.StatusReasonForbidden:          crterrors.NewForbiddenError("user is not provisioned (yet)", "your account is pending phone verification"),
+			metav1.StatusReasonNotFound:           crterrors.NewNotFoundError(errors.New("the requested space is not available"), "workspace 'mycoolworkspace' does not exist or was deleted"),
+			metav1.StatusReasonServiceUnavailable: crterrors.NewServiceUnavailableError("no member clusters found", "the cluster hosting the workspace is not available, please try again later"),
+		} {
+			s.Run(string(reason)+" if get accesses returns an error with a status", func() {
+				// given
+				req := s.request()
+				fakeApp.Accesses = map[string]*access.ClusterAccess{}
+				fakeApp.Err = cause
+
+				// when
+				resp, err := http.DefaultClient.Do(req)
+
+				// then
+				require.NoError(s.T(), err)
+				require.NotNil(s.T(), resp)
+				defer resp.Body.Close()
+				assert.Equal(s.T(), cause.Code, resp.StatusCode)
+				s.assertErrorStatus(resp, reason, cause.Error())
+			})
+		}
 	})
 }
 
@@ -300,7 +363,7 @@ func (s *TestProxySuite) checkWebsocketsError() {
 				require.NotNil(s.T(), resp)
 				defer resp.Body.Close()
 				assert.Equal(s.T(), http.StatusUnauthorized, resp.StatusCode)
-				s.assertResponseBody(resp, tc.ExpectedError)
+				s.assertErrorStatus(resp, metav1.StatusReasonUnauthorized, tc.ExpectedError)
 			})
 		}
 	})
@@ -382,6 +445,209 @@ func (s *TestProxySuite) checkWebLogin() {
 	})
 }
 
+func (s *TestProxySuite) TestWebLoginWithOIDCDiscovery() {
+	// given
+	// use a mock sso server with a configuration document whose endpoints are not the default Keycloak ones
+	var ssoURL string
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		switch p := r.URL.Path; p {
+		case "/realms/my-realm/.well-known/openid-configuration":
+			_, err := fmt.Fprintf(w, `{"issuer":"%[1]s/realms/my-realm","jwks_uri":"%[1]s/realms/my-realm/certs","authorization_endpoint":"%[1]s/realms/my-realm/authorize"}`, ssoURL)
+			require.NoError(s.T(), err)
+		case "/auth/anything":
+			_, err := w.Write([]byte("mock auth"))
+			require.NoError(s.T(), err)
+		default:
+			_, err := w.Write([]byte("unknown"))
+			require.NoError(s.T(), err)
+		}
+	}))
+	defer testServer.Close()
+	ssoURL = testServer.URL
+	discovery, err := auth.NewOIDCDiscovery(testServer.URL + "/realms/my-realm")
+	require.NoError(s.T(), err)
+	defer discovery.Stop()
+
+	p := &Proxy{
+		app:           &fake.ProxyFakeApp{},
+		oidcDiscovery: discovery,
+		metrics:       metrics.NewProxyMetrics(prometheus.NewRegistry()),
+	}
+	server := p.StartProxy("8083")
+	require.NotNil(s.T(), server)
+	defer func() {
+		_ = server.Close()
+	}()
+	client := &http.Client{
+		CheckRedirect: func(_ *http.Request, _ []*http.Request) error {
+			return http.ErrUseLastResponse // Do not follow redirects, so we can check the actual response from the proxy
+		}}
+	require.Eventually(s.T(), func() bool {
+		resp, err := client.Get("http://localhost:8083/.well-known/oauth-authorization-server")
+		if err != nil {
+			return false
+		}
+		defer resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}, 10*time.Second, 100*time.Millisecond)
+
+	s.Run("well-known configuration request", func() {
+		// when
+		resp, err := client.Get("http://localhost:8083/.well-known/oauth-authorization-server")
+
+		// then
+		require.NoError(s.T(), err)
+		defer resp.Body.Close()
+		assert.Equal(s.T(), http.StatusOK, resp.StatusCode)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(s.T(), err)
+		assert.Contains(s.T(), string(body), `"authorization_endpoint":"`+testServer.URL+`/realms/my-realm/authorize"`)
+	})
+
+	s.Run("oidc", func() {
+		// when
+		resp, err := client.Get("http://localhost:8083/realms/my-realm/authorize?state=mystate&code=mycode")
+
+		// then
+		require.NoError(s.T(), err)
+		defer resp.Body.Close()
+		assert.Equal(s.T(), http.StatusSeeOther, resp.StatusCode)
+		assert.Equal(s.T(), testServer.URL+"/realms/my-realm/authorize?state=mystate&code=mycode", resp.Header.Get("Location"))
+	})
+
+	s.Run("other auth requests", func() {
+		// when
+		resp, err := client.Get("http://localhost:8083/auth/anything")
+
+		// then
+		require.NoError(s.T(), err)
+		defer resp.Body.Close()
+		assert.Equal(s.T(), http.StatusOK, resp.StatusCode)
+		s.assertResponseBody(resp, "mock auth")
+	})
+}
+
+func (s *TestProxySuite) TestStartProxyWithClientCertificates() {
+	// given
+	serverCA := fake.NewCACertificate(s.T(), "server-ca")
+	certFile, keyFile := fake.NewServingCertificate(s.T(), serverCA).WriteFiles(s.T())
+	clientCA := fake.NewCACertificate(s.T(), "client-ca")
+	clientCAFile, _ := clientCA.WriteFiles(s.T())
+	s.T().Setenv(configuration.ProxyTLSCertFileEnvVar, certFile)
+	s.T().Setenv(configuration.ProxyTLSKeyFileEnvVar, keyFile)
+	s.T().Setenv(configuration.AuthClientCAFileEnvVar, clientCAFile)
+	p := &Proxy{
+		app:     &fake.ProxyFakeApp{},
+		metrics: metrics.NewProxyMetrics(prometheus.NewRegistry()),
+	}
+	server := p.StartProxy("8084")
+	require.NotNil(s.T(), server)
+	defer func() {
+		_ = server.Close()
+	}()
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(serverCA.Cert)
+	get := func(cert *fake.Certificate) (*http.Response, error) {
+		client := &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{
+					MinVersion: tls.VersionTLS12,
+					RootCAs:    rootCAs,
+					// the certificate is sent even if it is not signed by one of the CAs requested by the proxy
+					GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+						if cert == nil {
+							return &tls.Certificate{}, nil
+						}
+						tlsCert := cert.TLSCertificate(s.T())
+						return &tlsCert, nil
+					},
+				},
+			},
+		}
+		return client.Get("https://localhost:8084" + proxyHealthEndpoint)
+	}
+	require.Eventually(s.T(), func() bool {
+		resp, err := get(nil)
+		if err != nil {
+			return false
+		}
+		defer resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}, 10*time.Second, 100*time.Millisecond)
+
+	s.Run("verified client certificate", func() {
+		// when
+		resp, err := get(fake.NewClientCertificate(s.T(), clientCA, pkix.Name{CommonName: "smith"}))
+
+		// then
+		require.NoError(s.T(), err)
+		defer resp.Body.Close()
+		assert.Equal(s.T(), http.StatusOK, resp.StatusCode)
+		require.NotNil(s.T(), resp.TLS)
+	})
+
+	s.Run("client certificate signed by another CA", func() {
+		// when
+		_, err := get(fake.NewClientCertificate(s.T(), fake.NewCACertificate(s.T(), "other-ca"), pkix.Name{CommonName: "smith"}))
+
+		// then
+		require.Error(s.T(), err)
+	})
+}
+
+func (s *TestProxySuite) TestUnsecured() {
+	tests := map[string]struct {
+		openidAuthPath string
+		requestURI     string
+		expected       bool
+	}{
+		"health endpoint": {
+			requestURI: proxyHealthEndpoint,
+			expected:   true,
+		},
+		"auth endpoint": {
+			requestURI: "/auth/realms/sandbox-dev/protocol/openid-connect/token",
+			expected:   true,
+		},
+		"authorization endpoint": {
+			openidAuthPath: "/realms/my-realm/authorize",
+			requestURI:     "/realms/my-realm/authorize?state=mystate&code=mycode",
+			expected:       true,
+		},
+		"path under the authorization endpoint": {
+			openidAuthPath: "/realms/my-realm/authorize",
+			requestURI:     "/realms/my-realm/authorize/api/v1/pods",
+		},
+		"short authorization endpoint": {
+			openidAuthPath: "/authorize",
+			requestURI:     "/authorizeapi/v1/pods",
+		},
+		"root authorization endpoint": {
+			openidAuthPath: "/",
+			requestURI:     "/api/v1/namespaces",
+		},
+		"proxy not started": {
+			requestURI: "/api/v1/namespaces",
+		},
+	}
+
+	for k, tc := range tests {
+		s.T().Run(k, func(t *testing.T) {
+			// given
+			ctx := echo.New().NewContext(httptest.NewRequest(http.MethodGet, tc.requestURI, nil), httptest.NewRecorder())
+			p := &Proxy{openidAuthPath: tc.openidAuthPath}
+
+			// when
+			unsecured := p.unsecured(ctx)
+
+			// then
+			assert.Equal(t, tc.expected, unsecured)
+		})
+	}
+}
+
 func (s *TestProxySuite) checkProxyOK(fakeApp *fake.ProxyFakeApp, p *Proxy) {
 	s.Run("successfully proxy", func() {
 		userID := uuid.New()
@@ -1042,16 +1308,783 @@ func (s *TestProxySuite) TestValidateWorkspaceRequest() {
 
 	for k, tc := range tests {
 		s.T().Run(k, func(t *testing.T) {
-			err := validateWorkspaceRequest(tc.requestedWorkspace, tc.requestedNamespace, tc.workspaces)
+			_, err := validateWorkspaceRequest(tc.requestedWorkspace, tc.requestedNamespace, tc.workspaces)
+			if tc.expectedErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
+
+func (s *TestProxySuite) TestRestrictToAPITokenScope() {
+	newContext := func(method string, identity *auth.Identity, upgrade bool) echo.Context {
+		req := httptest.NewRequest(method, "/api/v1/namespaces/john-dev/pods", nil)
+		if upgrade {
+			upgradeToWebsocket(req)
+		}
+		ctx := echo.New().NewContext(req, httptest.NewRecorder())
+		if identity != nil {
+			ctx.Set(regsercontext.IdentityKey, identity)
+		}
+		return ctx
+	}
+	apiToken := func(workspace string, readOnly bool) *auth.Identity {
+		return &auth.Identity{
+			Username: "john",
+			APIToken: &auth.APITokenClaims{Workspace: workspace, ReadOnly: readOnly},
+		}
+	}
+
+	tests := map[string]struct {
+		ctx                echo.Context
+		requestedWorkspace string
+		expectedWorkspace  string
+		expectedErr        string
+	}{
+		"SSO token": {
+			ctx:                newContext(http.MethodDelete, &auth.Identity{Username: "john"}, false),
+			requestedWorkspace: "",
+			expectedWorkspace:  "",
+		},
+		"API token without requested workspace": {
+			ctx:                newContext(http.MethodPost, apiToken("john", false), false),
+			requestedWorkspace: "",
+			expectedWorkspace:  "john",
+		},
+		"API token with requested workspace": {
+			ctx:                newContext(http.MethodPost, apiToken("john", false), false),
+			requestedWorkspace: "john",
+			expectedWorkspace:  "john",
+		},
+		"API token with another workspace": {
+			ctx:                newContext(http.MethodGet, apiToken("john", false), false),
+			requestedWorkspace: "jane",
+			expectedErr:        "invalid workspace request: access to workspace 'jane' is forbidden with an API token for workspace 'john'",
+		},
+		"read-only API token with read request": {
+			ctx:                newContext(http.MethodGet, apiToken("john", true), false),
+			requestedWorkspace: "john",
+			expectedWorkspace:  "john",
+		},
+		"read-only API token with write request": {
+			ctx:                newContext(http.MethodPatch, apiToken("john", true), false),
+			requestedWorkspace: "john",
+			expectedErr:        "invalid API token request: PATCH requests are forbidden with a read-only API token",
+		},
+		"read-only API token with upgrade request": {
+			ctx:                newContext(http.MethodGet, apiToken("john", true), true),
+			requestedWorkspace: "john",
+			expectedErr:        "invalid API token request: GET requests are forbidden with a read-only API token",
+		},
+	}
+
+	for k, tc := range tests {
+		s.T().Run(k, func(t *testing.T) {
+			workspace, err := restrictToAPITokenScope(tc.ctx, tc.requestedWorkspace)
+			if tc.expectedErr == "" {
+				require.NoError(t, err)
+				assert.Equal(t, tc.expectedWorkspace, workspace)
+			} else {
+				require.EqualError(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
+
+func (s *TestProxySuite) TestRestrictWorkspacesToAPITokenScope() {
+	apiToken := func(workspace string, readOnly bool) *auth.Identity {
+		return &auth.Identity{
+			Username: "john",
+			APIToken: &auth.APITokenClaims{Workspace: workspace, ReadOnly: readOnly},
+		}
+	}
+
+	tests := map[string]struct {
+		method             string
+		identity           *auth.Identity
+		requestedWorkspace string
+		expectedErr        string
+	}{
+		"SSO token": {
+			method:   http.MethodPost,
+			identity: &auth.Identity{Username: "john"},
+		},
+		"API token listing the workspaces": {
+			method:      http.MethodGet,
+			identity:    apiToken("john", false),
+			expectedErr: "invalid workspace request: listing the workspaces is forbidden with an API token",
+		},
+		"API token creating a workspace": {
+			method:      http.MethodPost,
+			identity:    apiToken("john", false),
+			expectedErr: "invalid workspace request: creating the workspaces is forbidden with an API token",
+		},
+		"API token with requested workspace": {
+			method:             http.MethodPatch,
+			identity:           apiToken("john", false),
+			requestedWorkspace: "john",
+		},
+		"API token with another workspace": {
+			method:             http.MethodGet,
+			identity:           apiToken("john", false),
+			requestedWorkspace: "jane",
+			expectedErr:        "invalid workspace request: access to workspace 'jane' is forbidden with an API token for workspace 'john'",
+		},
+		"read-only API token with read request": {
+			method:             http.MethodGet,
+			identity:           apiToken("john", true),
+			requestedWorkspace: "john",
+		},
+		"read-only API token with write request": {
+			method:             http.MethodDelete,
+			identity:           apiToken("john", true),
+			requestedWorkspace: "john",
+			expectedErr:        "invalid API token request: DELETE requests are forbidden with a read-only API token",
+		},
+		"read-only API token with patch request": {
+			method:             http.MethodPatch,
+			identity:           apiToken("john", true),
+			requestedWorkspace: "john",
+			expectedErr:        "invalid API token request: PATCH requests are forbidden with a read-only API token",
+		},
+		"read-only API token with put request": {
+			method:             http.MethodPut,
+			identity:           apiToken("john", true),
+			requestedWorkspace: "john",
+			expectedErr:        "invalid API token request: PUT requests are forbidden with a read-only API token",
+		},
+	}
+
+	for k, tc := range tests {
+		s.T().Run(k, func(t *testing.T) {
+			// given
+			ctx := echo.New().NewContext(httptest.NewRequest(tc.method, "/apis/toolchain.dev.openshift.com/v1alpha1/workspaces", nil), httptest.NewRecorder())
+			ctx.Set(regsercontext.IdentityKey, tc.identity)
+			if tc.requestedWorkspace != "" {
+				ctx.SetParamNames("workspace")
+				ctx.SetParamValues(tc.requestedWorkspace)
+			}
+			called := false
+			next := func(echo.Context) error {
+				called = true
+				return nil
+			}
+
+			// when
+			err := (&Proxy{}).restrictWorkspacesToAPITokenScope()(next)(ctx)
+
+			// then
 			if tc.expectedErr == "" {
 				require.NoError(t, err)
+				assert.True(t, called)
 			} else {
 				require.EqualError(t, err, tc.expectedErr)
+				assert.False(t, called)
 			}
 		})
 	}
 }
 
+func (s *TestProxySuite) TestAuditRequest() {
+	// given
+	sink := &recordingAuditSink{}
+	auditor, err := audit.NewAuditor([]audit.Sink{sink}, []configuration.AuditRedaction{{Field: "sourceIP"}}, 10, nil)
+	require.NoError(s.T(), err)
+	p := &Proxy{auditor: auditor}
+	memberURL, err := url.Parse("https://api.member-1.test.org:6443")
+	require.NoError(s.T(), err)
+	identity := &auth.Identity{
+		Subject:  "f:528d76ff:john",
+		Username: "john@email.tld",
+		APIToken: &auth.APITokenClaims{RegisteredClaims: jwt.RegisteredClaims{ID: "b4c8b5d6"}, Workspace: "john-dev"},
+	}
+	handle := func(method, path string, handler echo.HandlerFunc) {
+		req := httptest.NewRequest(method, path, nil)
+		ctx := echo.New().NewContext(req, httptest.NewRecorder())
+		ctx.Set(regsercontext.RequestReceivedTime, time.Now())
+		_ = p.auditRequest()(handler)(ctx)
+	}
+
+	// when
+	handle(http.MethodDelete, "/workspaces/john-dev/api/v1/namespaces/john-dev/pods/app", func(ctx echo.Context) error {
+		identity.StoreInto(ctx)
+		ctx.Set(regsercontext.WorkspaceKey, "john-dev")
+		ctx.Set(regsercontext.ClusterAccessKey, access.NewClusterAccess(*memberURL, "token", "john"))
+		ctx.Request().URL.Path = "/api/v1/namespaces/john-dev/pods/app"
+		return ctx.NoContent(http.StatusOK)
+	})
+	handle(http.MethodGet, "/workspaces/jane-dev/api/v1/namespaces/jane-dev/secrets", func(ctx echo.Context) error {
+		identity.StoreInto(ctx)
+		return crterrors.NewForbiddenError("invalid workspace request", "access to workspace 'jane-dev' is forbidden with an API token for workspace 'john-dev'")
+	})
+	handle(http.MethodGet, proxyHealthEndpoint, func(ctx echo.Context) error {
+		return ctx.NoContent(http.StatusOK)
+	})
+	require.NoError(s.T(), auditor.Close())
+
+	// then
+	require.Len(s.T(), sink.events, 2) // the unsecured endpoints are not audited
+	deleted := sink.events[0]
+	assert.Equal(s.T(), "john@email.tld", deleted.User)
+	assert.Equal(s.T(), "f:528d76ff:john", deleted.UserID)
+	assert.Equal(s.T(), "b4c8b5d6", deleted.APITokenID)
+	assert.Equal(s.T(), "john", deleted.CompliantUsername)
+	assert.Equal(s.T(), "john-dev", deleted.Workspace)
+	assert.Equal(s.T(), "api.member-1.test.org:6443", deleted.Member)
+	assert.Equal(s.T(), "delete", deleted.Verb)
+	assert.Equal(s.T(), "pods", deleted.Resource)
+	assert.Equal(s.T(), "john-dev", deleted.Namespace)
+	assert.Equal(s.T(), "app", deleted.Name)
+	assert.Equal(s.T(), "/workspaces/john-dev/api/v1/namespaces/john-dev/pods/app", deleted.RequestURI)
+	assert.Equal(s.T(), audit.Redacted, deleted.SourceIP)
+	assert.Equal(s.T(), http.StatusOK, deleted.StatusCode)
+	assert.Empty(s.T(), deleted.Error)
+
+	rejected := sink.events[1]
+	assert.Equal(s.T(), "john@email.tld", rejected.User)
+	assert.Empty(s.T(), rejected.Member)
+	assert.Equal(s.T(), http.StatusForbidden, rejected.StatusCode)
+	assert.Equal(s.T(), "invalid workspace request: access to workspace 'jane-dev' is forbidden with an API token for workspace 'john-dev'", rejected.Error)
+
+	s.Run("no auditor", func() {
+		p := &Proxy{}
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/namespaces", nil)
+		called := false
+
+		err := p.auditRequest()(func(ctx echo.Context) error {
+			called = true
+			return nil
+		})(echo.New().NewContext(req, httptest.NewRecorder()))
+
+		require.NoError(s.T(), err)
+		assert.True(s.T(), called)
+	})
+}
+
+// recordingAuditSink records the audit events in memory
+type recordingAuditSink struct {
+	events []*audit.Event
+}
+
+func (s *recordingAuditSink) Name() string {
+	return "recording"
+}
+
+func (s *recordingAuditSink) Write(event *audit.Event) error {
+	s.events = append(s.events, event)
+	return nil
+}
+
+func (s *recordingAuditSink) Close() error {
+	return nil
+}
+
+func (s *TestProxySuite) TestThrottle() {
+	// given
+	limiter, err := ratelimit.NewLimiter(map[string]configuration.RateLimit{"write": {QPS: 0.1, Burst: 1}}, 1)
+	require.NoError(s.T(), err)
+	inf := fake.NewFakeInformer()
+	inf.GetMurFunc = func(name string) (*toolchainv1alpha1.MasterUserRecord, error) {
+		return &toolchainv1alpha1.MasterUserRecord{Spec: toolchainv1alpha1.MasterUserRecordSpec{TierName: "deactivate30"}}, nil
+	}
+	p := &Proxy{
+		app:     &fake.ProxyFakeApp{InformerServiceMock: inf},
+		metrics: metrics.NewProxyMetrics(prometheus.NewRegistry()),
+		limiter: limiter,
+	}
+	memberURL, err := url.Parse("https://api.member-1.test.org:6443")
+	require.NoError(s.T(), err)
+	cluster := access.NewClusterAccess(*memberURL, "token", "john")
+	throttle := func(method, path string) (func(), bool, *httptest.ResponseRecorder) {
+		rec := httptest.NewRecorder()
+		ctx := echo.New().NewContext(httptest.NewRequest(method, path, nil), rec)
+		release, allowed := p.throttle(ctx, cluster)
+		return release, allowed, rec
+	}
+
+	s.Run("rate limit", func() {
+		// when
+		_, allowed, _ := throttle(http.MethodDelete, "/api/v1/namespaces/john-dev/pods/app")
+		require.True(s.T(), allowed)
+		_, allowed, rec := throttle(http.MethodPost, "/api/v1/namespaces/john-dev/configmaps")
+
+		// then
+		require.False(s.T(), allowed)
+		assert.Equal(s.T(), http.StatusTooManyRequests, rec.Code)
+		assert.Equal(s.T(), "10", rec.Header().Get("Retry-After"))
+		assert.JSONEq(s.T(), `{
+			"kind": "Status",
+			"apiVersion": "v1",
+			"metadata": {},
+			"status": "Failure",
+			"message": "too many write requests, please retry after 10 seconds",
+			"reason": "TooManyRequests",
+			"details": {"retryAfterSeconds": 10},
+			"code": 429
+		}`, rec.Body.String())
+		assert.InDelta(s.T(), 1, promtestutil.ToFloat64(p.metrics.RegServProxyThrottledRequestsCounterVec.WithLabelValues("deactivate30", "write")), 0)
+
+		s.Run("other verb classes are not limited", func() {
+			_, allowed, _ := throttle(http.MethodGet, "/api/v1/namespaces/john-dev/pods")
+
+			assert.True(s.T(), allowed)
+		})
+	})
+
+	s.Run("long-running requests", func() {
+		// when
+		release, allowed, _ := throttle(http.MethodGet, "/api/v1/namespaces/john-dev/pods?watch=true")
+		require.True(s.T(), allowed)
+		_, allowed, rec := throttle(http.MethodGet, "/api/v1/namespaces/john-dev/pods/app/log?follow=true")
+
+		// then
+		require.False(s.T(), allowed)
+		assert.Equal(s.T(), http.StatusTooManyRequests, rec.Code)
+		assert.Equal(s.T(), "1", rec.Header().Get("Retry-After"))
+		assert.Contains(s.T(), rec.Body.String(), "too many concurrent long-running requests")
+		assert.InDelta(s.T(), 1, promtestutil.ToFloat64(p.metrics.RegServProxyThrottledRequestsCounterVec.WithLabelValues("deactivate30", "long-running")), 0)
+
+		s.Run("slot released", func() {
+			release()
+
+			release, allowed, _ := throttle(http.MethodGet, "/api/v1/namespaces/john-dev/pods/app/log?follow=true")
+
+			require.True(s.T(), allowed)
+			release()
+		})
+	})
+
+	s.Run("no limiter", func() {
+		p := &Proxy{}
+		ctx := echo.New().NewContext(httptest.NewRequest(http.MethodDelete, "/api/v1/namespaces/john-dev/pods/app", nil), httptest.NewRecorder())
+
+		release, allowed := p.throttle(ctx, cluster)
+
+		assert.True(s.T(), allowed)
+		release()
+	})
+}
+
+func (s *TestProxySuite) TestProcessRequestWithAccessCache() {
+	// given
+	proxyMetrics := metrics.NewProxyMetrics(prometheus.NewRegistry())
+	p, listed := newAccessTestProxy(s.T(), access.NewCache(10, time.Minute,
+		proxyMetrics.RegServProxyAccessCacheLookupsCounterVec, proxyMetrics.RegServProxyAccessCacheInvalidationsCounterVec))
+	processRequest := func(path string) error {
+		_, cluster, err := p.processRequest(newAccessTestContext(path))
+		if err == nil {
+			assert.Equal(s.T(), "smith2", cluster.Username())
+		}
+		return err
+	}
+
+	// when
+	err1 := processRequest("/workspaces/mycoolworkspace/api/v1/namespaces/mycoolworkspace-dev/pods")
+	err2 := processRequest("/workspaces/mycoolworkspace/api/v1/namespaces/mycoolworkspace-stage/pods")
+
+	// then
+	require.NoError(s.T(), err1)
+	require.NoError(s.T(), err2)
+	assert.Equal(s.T(), 1, *listed) // the access was resolved once
+	assert.InDelta(s.T(), 1, promtestutil.ToFloat64(proxyMetrics.RegServProxyAccessCacheLookupsCounterVec.WithLabelValues(access.LookupHit)), 0)
+	assert.InDelta(s.T(), 1, promtestutil.ToFloat64(proxyMetrics.RegServProxyAccessCacheLookupsCounterVec.WithLabelValues(access.LookupMiss)), 0)
+
+	s.Run("namespace is still checked", func() {
+		err := processRequest("/workspaces/mycoolworkspace/api/v1/namespaces/other-dev/pods")
+
+		require.EqualError(s.T(), err, "invalid workspace request: access to namespace 'other-dev' in workspace 'mycoolworkspace' is forbidden")
+		assert.Equal(s.T(), 1, *listed)
+	})
+
+	s.Run("forbidden workspace is cached", func() {
+		err1 := processRequest("/workspaces/otherworkspace/api/v1/namespaces/otherworkspace-dev/pods")
+		err2 := processRequest("/workspaces/otherworkspace/api/v1/namespaces/otherworkspace-dev/pods")
+
+		require.EqualError(s.T(), err1, "invalid workspace request: access to workspace 'otherworkspace' is forbidden")
+		require.EqualError(s.T(), err2, "invalid workspace request: access to workspace 'otherworkspace' is forbidden")
+		assert.Equal(s.T(), 2, *listed)
+	})
+
+	s.Run("resolved again after invalidation", func() {
+		p.AccessCacheEventHandler().OnAdd(fake.NewSpaceBinding("mycoolworkspace-jane", "jane", "mycoolworkspace", "admin"))
+
+		err := processRequest("/workspaces/mycoolworkspace/api/v1/namespaces/mycoolworkspace-dev/pods")
+
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), 3, *listed)
+	})
+
+	s.Run("not resolved again after a change of another workspace", func() {
+		p.AccessCacheEventHandler().OnAdd(fake.NewSpaceBinding("otherworkspace-jane", "jane", "otherworkspace", "admin"))
+
+		err := processRequest("/workspaces/mycoolworkspace/api/v1/namespaces/mycoolworkspace-dev/pods")
+
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), 3, *listed)
+	})
+
+	s.Run("resolved again after a change of the parent workspace", func() {
+		p.AccessCacheEventHandler().OnAdd(fake.NewSpaceBinding("parentworkspace-jane", "jane", "parentworkspace", "admin"))
+
+		err := processRequest("/workspaces/mycoolworkspace/api/v1/namespaces/mycoolworkspace-dev/pods")
+
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), 4, *listed)
+	})
+
+	s.Run("cluster access is not cached", func() {
+		// given the token of the member cluster is rotated
+		memberURL, err := url.Parse("https://api.member-2.test.org:6443")
+		require.NoError(s.T(), err)
+		accesses := p.app.(*fake.ProxyFakeApp).Accesses
+		previous := accesses["smith2-id"]
+		accesses["smith2-id"] = access.NewClusterAccess(*memberURL, "rotatedSAToken", "smith2")
+		defer func() {
+			accesses["smith2-id"] = previous
+		}()
+
+		// when
+		_, cluster, err := p.processRequest(newAccessTestContext("/workspaces/mycoolworkspace/api/v1/namespaces/mycoolworkspace-dev/pods"))
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), "rotatedSAToken", cluster.ImpersonatorToken())
+		assert.Equal(s.T(), 4, *listed) // the access to the workspace is still cached
+	})
+
+	s.Run("without cache", func() {
+		p, listed := newAccessTestProxy(s.T(), nil)
+
+		_, _, err1 := p.processRequest(newAccessTestContext("/workspaces/mycoolworkspace/api/v1/namespaces/mycoolworkspace-dev/pods"))
+		_, _, err2 := p.processRequest(newAccessTestContext("/workspaces/mycoolworkspace/api/v1/namespaces/mycoolworkspace-dev/pods"))
+
+		require.NoError(s.T(), err1)
+		require.NoError(s.T(), err2)
+		assert.Equal(s.T(), 2, *listed)
+		assert.Nil(s.T(), p.AccessCacheEventHandler())
+	})
+}
+
+func (s *TestProxySuite) TestProcessRequestWithoutWorkspace() {
+	// given
+	p, _ := newAccessTestProxy(s.T(), access.NewCache(10, time.Minute, nil, nil))
+	processRequest := func(path string) (echo.Context, error) {
+		ctx := newAccessTestContext(path)
+		_, _, err := p.processRequest(ctx)
+		return ctx, err
+	}
+
+	s.Run("namespace of the home workspace", func() {
+		// when
+		ctx, err := processRequest("/api/v1/namespaces/smith2-dev/pods")
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Empty(s.T(), ctx.Get(regsercontext.WorkspaceKey))
+	})
+
+	s.Run("namespace of a shared workspace", func() {
+		// when
+		ctx, err := processRequest("/api/v1/namespaces/parentworkspace-stage/pods")
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), "parentworkspace", ctx.Get(regsercontext.WorkspaceKey))
+	})
+
+	s.Run("namespace of a workspace of another user", func() {
+		// when
+		_, err := processRequest("/api/v1/namespaces/otherworkspace-dev/pods")
+
+		// then
+		require.EqualError(s.T(), err, "invalid workspace request: access to namespace 'otherworkspace-dev' in workspace 'smith2' is forbidden")
+	})
+
+	s.Run("no namespace", func() {
+		// when
+		ctx, err := processRequest("/api/v1/nodes")
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Empty(s.T(), ctx.Get(regsercontext.WorkspaceKey))
+	})
+}
+
+func (s *TestProxySuite) TestProcessRequestWithSpaceRolePolicies() {
+	// given
+	p, _ := newAccessTestProxy(s.T(), nil)
+	p.policies = policy.NewEnforcer(map[string][]configuration.PolicyRule{
+		"admin": {{Verbs: []string{"get", "list", "watch"}}},
+	})
+	processRequest := func(method, path string) error {
+		ctx := newAccessTestContext(path)
+		ctx.Request().Method = method
+		_, _, err := p.processRequest(ctx)
+		return err
+	}
+
+	s.Run("allowed by the rules of the role", func() {
+		// when
+		err := processRequest(http.MethodGet, "/workspaces/mycoolworkspace/api/v1/namespaces/mycoolworkspace-dev/pods")
+
+		// then
+		require.NoError(s.T(), err)
+	})
+
+	s.Run("not allowed by the rules of the role", func() {
+		// when
+		err := processRequest(http.MethodPost, "/workspaces/mycoolworkspace/api/v1/namespaces/mycoolworkspace-dev/pods")
+
+		// then
+		require.EqualError(s.T(), err, "invalid workspace request: the 'admin' role does not allow to create pods in workspace 'mycoolworkspace'")
+		assert.Equal(s.T(), http.StatusForbidden, errorStatusCode(err))
+	})
+
+	s.Run("read-only role can run kubectl auth can-i", func() {
+		// when
+		err := processRequest(http.MethodPost, "/workspaces/mycoolworkspace/apis/authorization.k8s.io/v1/selfsubjectaccessreviews")
+
+		// then
+		require.NoError(s.T(), err)
+
+		// when
+		err = processRequest(http.MethodPost, "/workspaces/mycoolworkspace/apis/authorization.k8s.io/v1/selfsubjectrulesreviews")
+
+		// then
+		require.NoError(s.T(), err)
+	})
+
+	s.Run("requests of the plugins are not restricted", func() {
+		// when
+		err := processRequest(http.MethodPost, "/plugins/myplugin/workspaces/mycoolworkspace/api/v1/namespaces/mycoolworkspace-dev/pods")
+
+		// then
+		require.NoError(s.T(), err)
+	})
+}
+
+func (s *TestProxySuite) TestProcessRequestWithRequestPolicies() {
+	// given
+	p, _ := newAccessTestProxy(s.T(), nil)
+	p.app.(*fake.ProxyFakeApp).InformerServiceMock = fake.GetInformerService(fake.InitClient(s.T()))()
+	requestPolicies, err := policy.NewRequestPolicies([]configuration.RequestPolicy{
+		{
+			Name:       "no-load-balancers",
+			Expression: "request.resource == 'services' && has(object.spec.type) && object.spec.type == 'LoadBalancer'",
+			Message:    "the services of type LoadBalancer are not allowed",
+		},
+		{
+			Name:       "deprecated-workspace",
+			Expression: "workspace.name == 'mycoolworkspace' && workspace.tier == 'base1ns' && request.verb == 'create'",
+			Action:     configuration.RequestPolicyActionWarn,
+			Message:    "this workspace is going to be deleted",
+		},
+	}, false, 1024, nil)
+	require.NoError(s.T(), err)
+	p.requestPolicies = requestPolicies
+	processRequest := func(body string) (echo.Context, error) {
+		ctx := newAccessTestContext("/workspaces/mycoolworkspace/api/v1/namespaces/mycoolworkspace-dev/services")
+		ctx.Request().Method = http.MethodPost
+		ctx.Request().Body = io.NopCloser(strings.NewReader(body))
+		_, _, err := p.processRequest(ctx)
+		return ctx, err
+	}
+
+	s.Run("denied", func() {
+		// when
+		_, err := processRequest(`{"kind":"Service","spec":{"type":"LoadBalancer"}}`)
+
+		// then
+		require.EqualError(s.T(), err, "request denied: the 'no-load-balancers' policy denies the request: the services of type LoadBalancer are not allowed")
+		assert.Equal(s.T(), http.StatusForbidden, errorStatusCode(err))
+	})
+
+	s.Run("warned", func() {
+		// when
+		ctx, err := processRequest(`{"kind":"Service","spec":{"type":"ClusterIP"}}`)
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), []string{`299 - "this workspace is going to be deleted"`}, ctx.Response().Header().Values("Warning"))
+	})
+}
+
+func BenchmarkProcessRequest(b *testing.B) {
+	b.Setenv(commonconfig.WatchNamespaceEnvVar, commontest.HostOperatorNs)
+	run := func(b *testing.B, accessCache *access.Cache) {
+		p, _ := newAccessTestProxy(b, accessCache)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, _, err := p.processRequest(newAccessTestContext("/workspaces/mycoolworkspace/api/v1/namespaces/mycoolworkspace-dev/pods")); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+
+	b.Run("without cache", func(b *testing.B) {
+		run(b, nil)
+	})
+
+	b.Run("with cache", func(b *testing.B) {
+		run(b, access.NewCache(10, time.Minute, nil, nil))
+	})
+}
+
+// newAccessTestContext returns the context of a GET request of the `smith2` user with the given path
+func newAccessTestContext(path string) echo.Context {
+	ctx := echo.New().NewContext(httptest.NewRequest(http.MethodGet, path, nil), httptest.NewRecorder())
+	ctx.Set(regsercontext.SubKey, "smith2-id")
+	ctx.Set(regsercontext.UsernameKey, "smith2")
+	return ctx
+}
+
+// newAccessTestProxy returns a Proxy which grants the `smith2` user access to their `smith2` home workspace, to the
+// `parentworkspace` workspace and to its `mycoolworkspace` sub-workspace, with the given access cache. It also returns the number of times the access was resolved.
+func newAccessTestProxy(t testing.TB, accessCache *access.Cache) (*Proxy, *int) {
+	memberURL, err := url.Parse("https://api.member-2.test.org:6443")
+	require.NoError(t, err)
+	listed := 0
+	inf := fake.NewFakeInformer()
+	inf.GetSpaceFunc = func(name string) (*toolchainv1alpha1.Space, error) {
+		switch name {
+		case "mycoolworkspace":
+			return fake.NewSpace("mycoolworkspace", "member-2", "smith2", spacetest.WithSpecParentSpace("parentworkspace")), nil
+		case "parentworkspace":
+			return fake.NewSpace("parentworkspace", "member-2", "jane"), nil
+		case "otherworkspace":
+			return fake.NewSpace("otherworkspace", "member-2", "jane"), nil
+		case "smith2":
+			return fake.NewSpace("smith2", "member-2", "smith2"), nil
+		}
+		return nil, fmt.Errorf("space not found error")
+	}
+	inf.GetNSTemplateTierFunc = func(_ string) (*toolchainv1alpha1.NSTemplateTier, error) {
+		return fake.NewBase1NSTemplateTier(), nil
+	}
+	inf.ListSpaceBindingFunc = func(reqs ...labels.Requirement) ([]toolchainv1alpha1.SpaceBinding, error) {
+		if len(reqs) == 1 && reqs[0].Key() == toolchainv1alpha1.SpaceBindingMasterUserRecordLabelKey && reqs[0].Values().Has("smith2") {
+			// all the workspaces of the user
+			return []toolchainv1alpha1.SpaceBinding{
+				*fake.NewSpaceBinding("smith2-smith2", "smith2", "smith2", "admin"),
+				*fake.NewSpaceBinding("parentworkspace-smith2", "smith2", "parentworkspace", "admin"),
+			}, nil
+		}
+		for _, req := range reqs {
+			if req.Key() == toolchainv1alpha1.SpaceBindingSpaceLabelKey && req.Values().Has("mycoolworkspace") {
+				listed++
+			}
+			if req.Key() == toolchainv1alpha1.SpaceBindingSpaceLabelKey && req.Values().Has("otherworkspace") {
+				listed++
+				return nil, nil
+			}
+		}
+		for _, req := range reqs {
+			if req.Key() == toolchainv1alpha1.SpaceBindingSpaceLabelKey && req.Values().Has("parentworkspace") {
+				return []toolchainv1alpha1.SpaceBinding{*fake.NewSpaceBinding("parentworkspace-smith2", "smith2", "parentworkspace", "admin")}, nil
+			}
+		}
+		return nil, nil
+	}
+	return &Proxy{
+		app: &fake.ProxyFakeApp{
+			Accesses: map[string]*access.ClusterAccess{
+				"smith2-id": access.NewClusterAccess(*memberURL, "clusterSAToken", "smith2"),
+			},
+		},
+		spaceLister: &handlers.SpaceLister{
+			GetSignupFunc: func(_ *gin.Context, _, _ string, _ bool) (*signup.Signup, error) {
+				return &signup.Signup{Name: "smith2", CompliantUsername: "smith2", Status: signup.Status{Ready: true}}, nil
+			},
+			GetInformerServiceFunc: func() appservice.InformerService {
+				return inf
+			},
+		},
+		accessCache: accessCache,
+	}, &listed
+}
+
+func (s *TestProxySuite) TestTransportFor() {
+	// given
+	p := &Proxy{transports: access.NewTransportPool()}
+	memberURL, err := url.Parse("https://api.member-1.test.org:6443")
+	require.NoError(s.T(), err)
+	member := access.NewClusterAccess(*memberURL, "token", "john", access.WithMemberCluster("member-1", &rest.Config{Host: memberURL.String()}))
+	newRequest := func(upgrade string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/namespaces/john-dev/pods", nil)
+		if upgrade != "" {
+			req.Header.Set("Connection", "Upgrade")
+			req.Header.Set("Upgrade", upgrade)
+		}
+		return req
+	}
+
+	s.Run("member cluster", func() {
+		// when
+		transport1, err1 := p.transportFor(member, newRequest(""))
+		transport2, err2 := p.transportFor(member, newRequest(""))
+
+		// then
+		require.NoError(s.T(), err1)
+		require.NoError(s.T(), err2)
+		assert.Same(s.T(), transport1, transport2)
+		assert.False(s.T(), transport1.(*http.Transport).TLSClientConfig.InsecureSkipVerify)
+		assert.Contains(s.T(), transport1.(*http.Transport).TLSClientConfig.NextProtos, "h2")
+
+		for _, upgrade := range []string{"SPDY/3.1", "websocket"} {
+			s.Run(upgrade+" upgrade", func() {
+				// when
+				transport, err := p.transportFor(member, newRequest(upgrade))
+
+				// then
+				require.NoError(s.T(), err)
+				assert.NotSame(s.T(), transport1, transport)
+				assert.Equal(s.T(), []string{"http/1.1"}, transport.(*http.Transport).TLSClientConfig.NextProtos)
+			})
+		}
+	})
+
+	s.Run("current config of the member cluster", func() {
+		// given
+		p := &Proxy{transports: access.NewTransportPool()}
+		insecure := false
+		p.getMembersFunc = func(_ ...commoncluster.Condition) []*commoncluster.CachedToolchainCluster {
+			return []*commoncluster.CachedToolchainCluster{{
+				Config: &commoncluster.Config{
+					Name:       "member-1",
+					RestConfig: &rest.Config{Host: memberURL.String(), TLSClientConfig: rest.TLSClientConfig{Insecure: insecure}},
+				},
+			}}
+		}
+		transport1, err := p.transportFor(member, newRequest(""))
+		require.NoError(s.T(), err)
+
+		// when the config of the member cluster changes while the config of the target does not
+		insecure = true
+		transport2, err := p.transportFor(member, newRequest(""))
+
+		// then
+		require.NoError(s.T(), err)
+		assert.NotSame(s.T(), transport1, transport2)
+		assert.True(s.T(), transport2.(*http.Transport).TLSClientConfig.InsecureSkipVerify)
+	})
+
+	s.Run("proxy plugin route", func() {
+		// given
+		plugin := access.NewClusterAccess(*memberURL, "token", "john")
+
+		// when
+		transport1, err1 := p.transportFor(plugin, newRequest(""))
+		transport2, err2 := p.transportFor(plugin, newRequest(""))
+
+		// then
+		require.NoError(s.T(), err1)
+		require.NoError(s.T(), err2)
+		assert.NotSame(s.T(), transport1, transport2)
+	})
+}
+
 func (s *TestProxySuite) TestGetTransport() {
 
 	s.T().Run("when not prod", func(_ *testing.T) {
@@ -1181,3 +2214,16 @@ func (s *TestProxySuite) assertResponseBody(resp *http.Response, expectedBody st
 	require.NoError(s.T(), err)
 	assert.Equal(s.T(), expectedBody, buf.String())
 }
+
+// assertErrorStatus verifies that the response body is a Kubernetes Status with the expected reason and message
+func (s *TestProxySuite) assertErrorStatus(resp *http.Response, expectedReason metav1.StatusReason, expectedMessage string) {
+	assert.Equal(s.T(), "application/json; charset=UTF-8", resp.Header.Get("Content-Type"))
+	status := &metav1.Status{}
+	require.NoError(s.T(), json.NewDecoder(resp.Body).Decode(status))
+	assert.Equal(s.T(), "Status", status.Kind)
+	assert.Equal(s.T(), "v1", status.APIVersion)
+	assert.Equal(s.T(), metav1.StatusFailure, status.Status)
+	assert.Equal(s.T(), expectedReason, status.Reason)
+	assert.Equal(s.T(), expectedMessage, status.Message)
+	assert.Equal(s.T(), int32(resp.StatusCode), status.Code)
+}
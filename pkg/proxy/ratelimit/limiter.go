@@ -0,0 +1,145 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+
+	"golang.org/x/time/rate"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/utils/lru"
+)
+
+// Class is the class of the verb of a request, which has its own rate limit
+type Class string
+
+const (
+	// ReadClass is the class of the get and list requests
+	ReadClass Class = "read"
+	// WriteClass is the class of the requests which modify resources, including the exec, attach and port-forward requests
+	WriteClass Class = "write"
+	// WatchClass is the class of the watch requests
+	WatchClass Class = "watch"
+)
+
+// maxUsers is the maximum number of users whose token buckets are kept in memory. The buckets of the least recently
+// seen users are dropped first, which resets their limits.
+const maxUsers = 10000
+
+// ClassOf returns the verb class of the request with the given information
+func ClassOf(info *request.RequestInfo) Class {
+	switch info.Verb {
+	case "watch":
+		return WatchClass
+	case "get", "list":
+		return ReadClass
+	default:
+		return WriteClass
+	}
+}
+
+// Limiter limits the rate of the requests of each user per verb class, and the number of their concurrent long-running requests
+type Limiter struct {
+	limits         map[Class]configuration.RateLimit
+	maxLongRunning int
+
+	// buckets contains the token buckets of the users, indexed by user and verb class
+	buckets *lru.Cache
+	lock    sync.Mutex
+	// longRunning contains the number of long-running requests in progress, indexed by user
+	longRunning map[string]int
+}
+
+// NewLimiter returns a new Limiter with the given rate limits, indexed by verb class, and with the given maximum number
+// of concurrent long-running requests per user. The requests of a verb class without rate limit are not limited,
+// and the number of long-running requests is not limited if zero or negative.
+func NewLimiter(limits map[string]configuration.RateLimit, maxLongRunning int) (*Limiter, error) {
+	l := &Limiter{
+		limits:         make(map[Class]configuration.RateLimit, len(limits)),
+		maxLongRunning: maxLongRunning,
+		buckets:        lru.New(maxUsers),
+		longRunning:    map[string]int{},
+	}
+	for class, limit := range limits {
+		switch Class(class) {
+		case ReadClass, WriteClass, WatchClass:
+		default:
+			return nil, fmt.Errorf("unknown verb class '%s' in rate limits, expected one of: read, write, watch", class)
+		}
+		if limit.QPS <= 0 || limit.Burst <= 0 {
+			return nil, fmt.Errorf("invalid rate limit for verb class '%s': qps and burst must be positive", class)
+		}
+		l.limits[Class(class)] = limit
+	}
+	return l, nil
+}
+
+// NewLimiterFromConfig returns a new Limiter with the configured limits, or nil if no limit is configured
+func NewLimiterFromConfig(cfg configuration.ProxyConfig) (*Limiter, error) {
+	limits := cfg.RateLimits()
+	maxLongRunning := cfg.MaxLongRunningRequests()
+	if len(limits) == 0 && maxLongRunning <= 0 {
+		return nil, nil
+	}
+	return NewLimiter(limits, maxLongRunning)
+}
+
+// Allow returns zero if the user is allowed to send a request of the given verb class now, or the time to wait before
+// retrying otherwise. The token of an allowed request is consumed.
+func (l *Limiter) Allow(user string, class Class) time.Duration {
+	limit, found := l.limits[class]
+	if !found {
+		return 0
+	}
+	l.lock.Lock()
+	key := string(class) + "/" + user
+	var bucket *rate.Limiter
+	if b, found := l.buckets.Get(key); found {
+		bucket = b.(*rate.Limiter)
+	} else {
+		bucket = rate.NewLimiter(rate.Limit(limit.QPS), limit.Burst)
+		l.buckets.Add(key, bucket)
+	}
+	l.lock.Unlock()
+
+	reservation := bucket.Reserve()
+	if delay := reservation.Delay(); delay > 0 {
+		// the request is rejected, so it must not consume a token
+		reservation.Cancel()
+		return delay
+	}
+	return 0
+}
+
+// AcquireLongRunning reserves a slot for a long-running request of the given user. It returns false if the user already
+// reached the maximum number of concurrent long-running requests, otherwise the returned function must be called to release
+// the slot once the request is complete.
+func (l *Limiter) AcquireLongRunning(user string) (func(), bool) {
+	if l.maxLongRunning <= 0 {
+		return func() {}, true
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.longRunning[user] >= l.maxLongRunning {
+		return nil, false
+	}
+	l.longRunning[user]++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.lock.Lock()
+			defer l.lock.Unlock()
+			if l.longRunning[user]--; l.longRunning[user] <= 0 {
+				delete(l.longRunning, user)
+			}
+		})
+	}, true
+}
+
+// RetryAfterSeconds returns the number of seconds to wait before retrying, rounded up to at least one second
+func RetryAfterSeconds(delay time.Duration) int {
+	return int(math.Max(1, math.Ceil(delay.Seconds())))
+}
@@ -0,0 +1,200 @@
+package ratelimit_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/ratelimit"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/requestinfo"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassOf(t *testing.T) {
+	tests := map[string]struct {
+		method        string
+		path          string
+		expectedClass ratelimit.Class
+	}{
+		"get pod": {
+			method:        http.MethodGet,
+			path:          "/api/v1/namespaces/john-dev/pods/app",
+			expectedClass: ratelimit.ReadClass,
+		},
+		"list pods": {
+			method:        http.MethodGet,
+			path:          "/api/v1/namespaces/john-dev/pods",
+			expectedClass: ratelimit.ReadClass,
+		},
+		"watch pods": {
+			method:        http.MethodGet,
+			path:          "/api/v1/namespaces/john-dev/pods?watch=true",
+			expectedClass: ratelimit.WatchClass,
+		},
+		"create deployment": {
+			method:        http.MethodPost,
+			path:          "/apis/apps/v1/namespaces/john-dev/deployments",
+			expectedClass: ratelimit.WriteClass,
+		},
+		"delete pod": {
+			method:        http.MethodDelete,
+			path:          "/api/v1/namespaces/john-dev/pods/app",
+			expectedClass: ratelimit.WriteClass,
+		},
+		"exec in pod": {
+			method:        http.MethodPost,
+			path:          "/api/v1/namespaces/john-dev/pods/app/exec?command=sh",
+			expectedClass: ratelimit.WriteClass,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			info, err := requestinfo.New(httptest.NewRequest(tc.method, tc.path, nil))
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expectedClass, ratelimit.ClassOf(info))
+		})
+	}
+}
+
+func TestNewLimiter(t *testing.T) {
+	t.Run("unknown verb class", func(t *testing.T) {
+		_, err := ratelimit.NewLimiter(map[string]configuration.RateLimit{"delete": {QPS: 1, Burst: 1}}, 0)
+
+		require.EqualError(t, err, "unknown verb class 'delete' in rate limits, expected one of: read, write, watch")
+	})
+
+	t.Run("invalid rate limit", func(t *testing.T) {
+		_, err := ratelimit.NewLimiter(map[string]configuration.RateLimit{"read": {QPS: 1}}, 0)
+
+		require.EqualError(t, err, "invalid rate limit for verb class 'read': qps and burst must be positive")
+	})
+
+	t.Run("from config", func(t *testing.T) {
+		t.Run("nothing configured", func(t *testing.T) {
+			l, err := ratelimit.NewLimiterFromConfig(configuration.ProxyConfig{})
+
+			require.NoError(t, err)
+			assert.Nil(t, l)
+		})
+
+		t.Run("rate limits", func(t *testing.T) {
+			t.Setenv(configuration.ProxyRateLimitsEnvVar, `{"write":{"qps":1,"burst":1}}`)
+
+			l, err := ratelimit.NewLimiterFromConfig(configuration.ProxyConfig{})
+
+			require.NoError(t, err)
+			require.NotNil(t, l)
+			assert.Zero(t, l.Allow("john", ratelimit.WriteClass))
+			assert.NotZero(t, l.Allow("john", ratelimit.WriteClass))
+		})
+
+		t.Run("max long-running requests", func(t *testing.T) {
+			t.Setenv(configuration.ProxyMaxLongRunningRequestsEnvVar, "1")
+
+			l, err := ratelimit.NewLimiterFromConfig(configuration.ProxyConfig{})
+
+			require.NoError(t, err)
+			require.NotNil(t, l)
+			_, acquired := l.AcquireLongRunning("john")
+			assert.True(t, acquired)
+			_, acquired = l.AcquireLongRunning("john")
+			assert.False(t, acquired)
+		})
+	})
+}
+
+func TestAllow(t *testing.T) {
+	// given
+	l, err := ratelimit.NewLimiter(map[string]configuration.RateLimit{
+		"read":  {QPS: 0.5, Burst: 2},
+		"write": {QPS: 1, Burst: 1},
+	}, 0)
+	require.NoError(t, err)
+
+	t.Run("within burst", func(t *testing.T) {
+		assert.Zero(t, l.Allow("john", ratelimit.ReadClass))
+		assert.Zero(t, l.Allow("john", ratelimit.ReadClass))
+	})
+
+	t.Run("burst exceeded", func(t *testing.T) {
+		delay := l.Allow("john", ratelimit.ReadClass)
+
+		assert.Greater(t, delay, time.Duration(0))
+		assert.LessOrEqual(t, delay, 2*time.Second)
+		// the rejected request did not consume a token, so the delay does not grow
+		assert.LessOrEqual(t, l.Allow("john", ratelimit.ReadClass), delay)
+	})
+
+	t.Run("verb classes are limited separately", func(t *testing.T) {
+		assert.Zero(t, l.Allow("john", ratelimit.WriteClass))
+		assert.NotZero(t, l.Allow("john", ratelimit.WriteClass))
+	})
+
+	t.Run("users are limited separately", func(t *testing.T) {
+		assert.Zero(t, l.Allow("jane", ratelimit.ReadClass))
+		assert.Zero(t, l.Allow("jane", ratelimit.WriteClass))
+	})
+
+	t.Run("verb class without limit", func(t *testing.T) {
+		for i := 0; i < 100; i++ {
+			assert.Zero(t, l.Allow("john", ratelimit.WatchClass))
+		}
+	})
+}
+
+func TestAcquireLongRunning(t *testing.T) {
+	t.Run("limited", func(t *testing.T) {
+		// given
+		l, err := ratelimit.NewLimiter(nil, 2)
+		require.NoError(t, err)
+		release1, acquired := l.AcquireLongRunning("john")
+		require.True(t, acquired)
+		_, acquired = l.AcquireLongRunning("john")
+		require.True(t, acquired)
+
+		// when
+		_, acquired = l.AcquireLongRunning("john")
+
+		// then
+		assert.False(t, acquired)
+
+		t.Run("other user", func(t *testing.T) {
+			_, acquired := l.AcquireLongRunning("jane")
+
+			assert.True(t, acquired)
+		})
+
+		t.Run("released", func(t *testing.T) {
+			release1()
+			release1() // releasing twice does not free another slot
+
+			_, acquired := l.AcquireLongRunning("john")
+			assert.True(t, acquired)
+			_, acquired = l.AcquireLongRunning("john")
+			assert.False(t, acquired)
+		})
+	})
+
+	t.Run("unlimited", func(t *testing.T) {
+		l, err := ratelimit.NewLimiter(nil, 0)
+		require.NoError(t, err)
+
+		for i := 0; i < 100; i++ {
+			_, acquired := l.AcquireLongRunning("john")
+			assert.True(t, acquired)
+		}
+	})
+}
+
+func TestRetryAfterSeconds(t *testing.T) {
+	assert.Equal(t, 1, ratelimit.RetryAfterSeconds(0))
+	assert.Equal(t, 1, ratelimit.RetryAfterSeconds(200*time.Millisecond))
+	assert.Equal(t, 2, ratelimit.RetryAfterSeconds(1500*time.Millisecond))
+	assert.Equal(t, 3, ratelimit.RetryAfterSeconds(3*time.Second))
+}
@@ -0,0 +1,32 @@
+package requestinfo
+
+import (
+	"net/http"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+// factory parses the Kubernetes API requests
+var factory = &request.RequestInfoFactory{
+	APIPrefixes:          sets.NewString("api", "apis"),
+	GrouplessAPIPrefixes: sets.NewString("api"),
+}
+
+var (
+	longRunningVerbs        = sets.NewString("watch", "proxy")
+	longRunningSubresources = sets.NewString("attach", "exec", "proxy", "log", "portforward")
+)
+
+// New returns the information of the given Kubernetes API request (verb, resource, namespace, etc.).
+// The path of the request is expected to be relative to the API server of the member cluster, ie, without the workspace
+// and plugin segments.
+func New(req *http.Request) (*request.RequestInfo, error) {
+	return factory.NewRequestInfo(req)
+}
+
+// IsLongRunning returns true if the request with the given information keeps the connection open (watch, exec, logs, etc.),
+// as defined by the Kubernetes API server
+func IsLongRunning(info *request.RequestInfo) bool {
+	return longRunningVerbs.Has(info.Verb) || (info.IsResourceRequest && longRunningSubresources.Has(info.Subresource))
+}
@@ -9,8 +9,10 @@ import (
 	"github.com/codeready-toolchain/registration-service/pkg/application/service"
 	"github.com/codeready-toolchain/registration-service/pkg/application/service/base"
 	servicecontext "github.com/codeready-toolchain/registration-service/pkg/application/service/context"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
 	"github.com/codeready-toolchain/registration-service/pkg/log"
 	"github.com/codeready-toolchain/registration-service/pkg/proxy/access"
+	"github.com/codeready-toolchain/registration-service/pkg/signup"
 	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
 
 	routev1 "github.com/openshift/api/route/v1"
@@ -47,7 +49,7 @@ func (s *ServiceImpl) GetClusterAccess(userID, username, workspace, proxyPluginN
 	}
 	// if signup has the CompliantUsername set it means that MUR was created and useraccount is provisioned
 	if signup == nil || signup.CompliantUsername == "" {
-		cause := errs.New("user is not provisioned (yet)")
+		cause := notProvisionedError(signup)
 		log.Error(nil, cause, fmt.Sprintf("signup object: %+v", signup))
 		return nil, cause
 	}
@@ -62,7 +64,7 @@ func (s *ServiceImpl) GetClusterAccess(userID, username, workspace, proxyPluginN
 	if err != nil {
 		// log the actual error but do not return it so that it doesn't reveal information about a space that may not belong to the requestor
 		log.Error(nil, err, "unable to get target cluster for workspace "+workspace)
-		return nil, fmt.Errorf("the requested space is not available")
+		return nil, crterrors.NewNotFoundError(errs.New("the requested space is not available"), fmt.Sprintf("workspace '%s' does not exist or was deleted", workspace))
 	}
 
 	return s.accessForSpace(space, signup.CompliantUsername, proxyPluginName)
@@ -72,7 +74,7 @@ func (s *ServiceImpl) accessForSpace(space *toolchainv1alpha1.Space, username, p
 	// Get the target member
 	members := s.GetMembersFunc()
 	if len(members) == 0 {
-		return nil, errs.New("no member clusters found")
+		return nil, crterrors.NewServiceUnavailableError("no member clusters found", memberUnavailableDetails)
 	}
 	for _, member := range members {
 		if member.Name == space.Status.TargetCluster {
@@ -80,22 +82,20 @@ func (s *ServiceImpl) accessForSpace(space *toolchainv1alpha1.Space, username, p
 			if err != nil {
 				return nil, err
 			}
-			// requests use impersonation so are made with member ToolchainCluster token, not user tokens
-			impersonatorToken := member.RestConfig.BearerToken
-			return access.NewClusterAccess(*apiURL, impersonatorToken, username), nil
+			return newClusterAccess(*apiURL, member, username, proxyPluginName), nil
 		}
 	}
 
 	errMsg := fmt.Sprintf("no member cluster found for space '%s'", space.Name)
 	log.Error(nil, fmt.Errorf("no matching target cluster '%s' for the space", space.Status.TargetCluster), errMsg)
-	return nil, errs.New(errMsg)
+	return nil, crterrors.NewServiceUnavailableError(errMsg, memberUnavailableDetails)
 }
 
 func (s *ServiceImpl) accessForCluster(apiEndpoint, clusterName, username, proxyPluginName string) (*access.ClusterAccess, error) {
 	// Get the target member
 	members := s.GetMembersFunc()
 	if len(members) == 0 {
-		return nil, errs.New("no member clusters found")
+		return nil, crterrors.NewServiceUnavailableError("no member clusters found", memberUnavailableDetails)
 	}
 	for _, member := range members {
 		// also check that the member cluster name matches because the api endpoint is the same for both members
@@ -105,13 +105,40 @@ func (s *ServiceImpl) accessForCluster(apiEndpoint, clusterName, username, proxy
 			if err != nil {
 				return nil, err
 			}
-			// requests use impersonation so are made with member ToolchainCluster token, not user tokens
-			impersonatorToken := member.RestConfig.BearerToken
-			return access.NewClusterAccess(*apiURL, impersonatorToken, username), nil
+			return newClusterAccess(*apiURL, member, username, proxyPluginName), nil
 		}
 	}
 
-	return nil, errs.New("no member cluster found for the user")
+	return nil, crterrors.NewServiceUnavailableError("no member cluster found for the user", memberUnavailableDetails)
+}
+
+// memberUnavailableDetails are the details of the errors returned when the member cluster of the user or of the
+// workspace is not available, which is usually temporary
+const memberUnavailableDetails = "the cluster hosting the workspace is not available, please try again later"
+
+// notProvisionedError returns the error explaining to the user why their account is not provisioned: the user must
+// sign up, verify their phone number or wait for the approval, or the account is still being provisioned.
+func notProvisionedError(signup *signup.Signup) *crterrors.Error {
+	switch {
+	case signup == nil:
+		return crterrors.NewForbiddenError("user is not provisioned (yet)", "no active account found for the user, please sign up")
+	case signup.Status.VerificationRequired:
+		return crterrors.NewForbiddenError("user is not provisioned (yet)", "your account is pending phone verification")
+	case signup.Status.Reason == toolchainv1alpha1.UserSignupPendingApprovalReason:
+		return crterrors.NewForbiddenError("user is not provisioned (yet)", "your account is pending approval")
+	default:
+		return crterrors.NewServiceUnavailableError("user is not provisioned (yet)", "your account is being provisioned, please try again in a few moments")
+	}
+}
+
+func newClusterAccess(apiURL url.URL, member *cluster.CachedToolchainCluster, username, proxyPluginName string) *access.ClusterAccess {
+	// requests use impersonation so are made with member ToolchainCluster token, not user tokens
+	impersonatorToken := member.RestConfig.BearerToken
+	if len(proxyPluginName) > 0 {
+		// the requests are sent to the route of the proxy plugin, not to the API server of the member
+		return access.NewClusterAccess(apiURL, impersonatorToken, username)
+	}
+	return access.NewClusterAccess(apiURL, impersonatorToken, username, access.WithMemberCluster(member.Name, member.RestConfig))
 }
 
 func (s *ServiceImpl) getMemberURL(proxyPluginName string, member *cluster.CachedToolchainCluster) (*url.URL, error) {
@@ -4,9 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
 	"testing"
 
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
 	"github.com/codeready-toolchain/registration-service/pkg/proxy/access"
 	"github.com/codeready-toolchain/registration-service/pkg/proxy/service"
 	"github.com/codeready-toolchain/registration-service/pkg/signup"
@@ -50,6 +52,19 @@ func (s *TestClusterServiceSuite) TestGetClusterAccess() {
 		Status: signup.Status{
 			Ready: false,
 		},
+	}), fake.Signup("321-verification-required", &signup.Signup{
+		CompliantUsername: "",
+		Username:          "jane@",
+		Status: signup.Status{
+			Reason:               toolchainv1alpha1.UserSignupPendingApprovalReason,
+			VerificationRequired: true,
+		},
+	}), fake.Signup("654-pending-approval", &signup.Signup{
+		CompliantUsername: "",
+		Username:          "jack@",
+		Status: signup.Status{
+			Reason: toolchainv1alpha1.UserSignupPendingApprovalReason,
+		},
 	}), fake.Signup("789-ready", &signup.Signup{
 		APIEndpoint:       "https://api.endpoint.member-2.com:6443",
 		ClusterName:       "member-2",
@@ -127,7 +142,7 @@ func (s *TestClusterServiceSuite) TestGetClusterAccess() {
 			_, err := svc.GetClusterAccess("unknown_id", "", "", "")
 
 			// then
-			require.EqualError(s.T(), err, "user is not provisioned (yet)")
+			requireErrorWithCode(s.T(), err, http.StatusForbidden, "user is not provisioned (yet): no active account found for the user, please sign up")
 		})
 
 		s.Run("username is not found", func() {
@@ -135,7 +150,7 @@ func (s *TestClusterServiceSuite) TestGetClusterAccess() {
 			_, err := svc.GetClusterAccess("", "unknown_username", "", "")
 
 			// then
-			require.EqualError(s.T(), err, "user is not provisioned (yet)")
+			requireErrorWithCode(s.T(), err, http.StatusForbidden, "user is not provisioned (yet): no active account found for the user, please sign up")
 		})
 
 		s.Run("user is not provisioned yet", func() {
@@ -143,7 +158,23 @@ func (s *TestClusterServiceSuite) TestGetClusterAccess() {
 			_, err := svc.GetClusterAccess("456-not-ready", "", "", "")
 
 			// then
-			require.EqualError(s.T(), err, "user is not provisioned (yet)")
+			requireErrorWithCode(s.T(), err, http.StatusServiceUnavailable, "user is not provisioned (yet): your account is being provisioned, please try again in a few moments")
+		})
+
+		s.Run("user is pending phone verification", func() {
+			// when
+			_, err := svc.GetClusterAccess("321-verification-required", "", "", "")
+
+			// then
+			requireErrorWithCode(s.T(), err, http.StatusForbidden, "user is not provisioned (yet): your account is pending phone verification")
+		})
+
+		s.Run("user is pending approval", func() {
+			// when
+			_, err := svc.GetClusterAccess("654-pending-approval", "", "", "")
+
+			// then
+			requireErrorWithCode(s.T(), err, http.StatusForbidden, "user is not provisioned (yet): your account is pending approval")
 		})
 	})
 
@@ -164,7 +195,7 @@ func (s *TestClusterServiceSuite) TestGetClusterAccess() {
 
 			// then
 			// original error is only logged so that it doesn't reveal information about a space that may not belong to the requestor
-			require.EqualError(s.T(), err, "the requested space is not available")
+			requireErrorWithCode(s.T(), err, http.StatusNotFound, "the requested space is not available: workspace 'smith2' does not exist or was deleted")
 		})
 
 		s.Run("space not found", func() {
@@ -172,7 +203,7 @@ func (s *TestClusterServiceSuite) TestGetClusterAccess() {
 			_, err := svc.GetClusterAccess("789-ready", "", "unknown", "") // unknown workspace requested
 
 			// then
-			require.EqualError(s.T(), err, "the requested space is not available")
+			requireErrorWithCode(s.T(), err, http.StatusNotFound, "the requested space is not available: workspace 'unknown' does not exist or was deleted")
 		})
 	})
 
@@ -194,7 +225,7 @@ func (s *TestClusterServiceSuite) TestGetClusterAccess() {
 				_, err := svc.GetClusterAccess("789-ready", "", "", "")
 
 				// then
-				require.EqualError(s.T(), err, "no member clusters found")
+				requireErrorWithCode(s.T(), err, http.StatusServiceUnavailable, "no member clusters found: the cluster hosting the workspace is not available, please try again later")
 			})
 
 			s.Run("workspace context case", func() {
@@ -202,7 +233,7 @@ func (s *TestClusterServiceSuite) TestGetClusterAccess() {
 				_, err := svc.GetClusterAccess("789-ready", "", "smith2", "")
 
 				// then
-				require.EqualError(s.T(), err, "no member clusters found")
+				requireErrorWithCode(s.T(), err, http.StatusServiceUnavailable, "no member clusters found: the cluster hosting the workspace is not available, please try again later")
 			})
 		})
 
@@ -224,7 +255,7 @@ func (s *TestClusterServiceSuite) TestGetClusterAccess() {
 				_, err := svc.GetClusterAccess("012-ready-unknown-cluster", "", "", "")
 
 				// then
-				require.EqualError(s.T(), err, "no member cluster found for the user")
+				requireErrorWithCode(s.T(), err, http.StatusServiceUnavailable, "no member cluster found for the user: the cluster hosting the workspace is not available, please try again later")
 			})
 
 			s.Run("workspace context case", func() {
@@ -232,7 +263,7 @@ func (s *TestClusterServiceSuite) TestGetClusterAccess() {
 				_, err := svc.GetClusterAccess("012-ready-unknown-cluster", "", "unknown-cluster", "")
 
 				// then
-				require.EqualError(s.T(), err, "no member cluster found for space 'unknown-cluster'")
+				requireErrorWithCode(s.T(), err, http.StatusServiceUnavailable, "no member cluster found for space 'unknown-cluster': the cluster hosting the workspace is not available, please try again later")
 			})
 		})
 	})
@@ -380,7 +411,7 @@ func (s *TestClusterServiceSuite) TestGetClusterAccess() {
 			require.NoError(s.T(), err)
 			assert.Equal(s.T(), "smith2", ca.Username())
 
-			s.assertClusterAccess(access.NewClusterAccess(*expectedURL, expectedToken, ""), ca)
+			s.assertClusterAccess(access.NewClusterAccess(*expectedURL, expectedToken, "", access.WithMemberCluster("member-2", memberArray[1].RestConfig)), ca)
 
 			s.Run("cluster access correct when username provided", func() {
 				// when
@@ -391,7 +422,7 @@ func (s *TestClusterServiceSuite) TestGetClusterAccess() {
 				require.NotNil(s.T(), ca)
 				expectedURL, err := url.Parse("https://api.endpoint.member-2.com:6443")
 				require.NoError(s.T(), err)
-				s.assertClusterAccess(access.NewClusterAccess(*expectedURL, expectedToken, "smith"), ca)
+				s.assertClusterAccess(access.NewClusterAccess(*expectedURL, expectedToken, "smith", access.WithMemberCluster("member-2", memberArray[1].RestConfig)), ca)
 				assert.Equal(s.T(), "smith2", ca.Username())
 			})
 
@@ -404,7 +435,7 @@ func (s *TestClusterServiceSuite) TestGetClusterAccess() {
 				require.NotNil(s.T(), ca)
 				expectedURL, err := url.Parse("https://api.endpoint.member-2.com:6443")
 				require.NoError(s.T(), err)
-				s.assertClusterAccess(access.NewClusterAccess(*expectedURL, expectedToken, "smith"), ca)
+				s.assertClusterAccess(access.NewClusterAccess(*expectedURL, expectedToken, "smith", access.WithMemberCluster("member-2", memberArray[1].RestConfig)), ca)
 				assert.Equal(s.T(), "smith2", ca.Username())
 
 				s.Run("another workspace on another cluster", func() {
@@ -416,7 +447,7 @@ func (s *TestClusterServiceSuite) TestGetClusterAccess() {
 					require.NotNil(s.T(), ca)
 					expectedURL, err := url.Parse("https://api.endpoint.member-1.com:6443")
 					require.NoError(s.T(), err)
-					s.assertClusterAccess(access.NewClusterAccess(*expectedURL, "def456", "smith"), ca)
+					s.assertClusterAccess(access.NewClusterAccess(*expectedURL, "def456", "smith", access.WithMemberCluster("member-1", memberArray[0].RestConfig)), ca)
 					assert.Equal(s.T(), "smith2", ca.Username())
 				})
 			})
@@ -429,6 +460,8 @@ func (s *TestClusterServiceSuite) assertClusterAccess(expected, actual *access.C
 	require.NotNil(s.T(), actual)
 	assert.Equal(s.T(), expected.APIURL(), actual.APIURL())
 	assert.Equal(s.T(), expected.ImpersonatorToken(), actual.ImpersonatorToken())
+	assert.Equal(s.T(), expected.MemberName(), actual.MemberName())
+	assert.Same(s.T(), expected.RestConfig(), actual.RestConfig())
 }
 
 func (s *TestClusterServiceSuite) memberClusters() []*commoncluster.CachedToolchainCluster {
@@ -447,3 +480,11 @@ func (s *TestClusterServiceSuite) memberClusters() []*commoncluster.CachedToolch
 	}
 	return cls
 }
+
+// requireErrorWithCode verifies that the given error has the expected message and is returned with the expected status code
+func requireErrorWithCode(t *testing.T, err error, expectedCode int, expectedMessage string) {
+	require.EqualError(t, err, expectedMessage)
+	ce := &crterrors.Error{}
+	require.ErrorAs(t, err, &ce)
+	assert.Equal(t, expectedCode, ce.Code)
+}
@@ -46,6 +46,7 @@ func (srv *RegistrationServer) SetupRoutes(proxyPort string, reg *prometheus.Reg
 
 	// Register all of the metrics in the standard registry.
 	reg.MustRegister(counter, histVec, inFlightGauge)
+	auth.RegisterMetrics(reg)
 
 	srv.routesSetup.Do(func() {
 		// creating the controllers
@@ -54,6 +55,13 @@ func (srv *RegistrationServer) SetupRoutes(proxyPort string, reg *prometheus.Reg
 		analyticsCtrl := controller.NewAnalytics()
 		signupCtrl := controller.NewSignup(srv.application)
 		usernamesCtrl := controller.NewUsernames(srv.application)
+		kubeconfigCtrl := controller.NewKubeconfig(srv.application)
+		var apiTokensCtrl *controller.APITokens
+		apiTokensCtrl, err = controller.NewAPITokens(srv.application)
+		if err != nil {
+			err = errs.Wrapf(err, "failed to init API tokens controller")
+			return
+		}
 
 		// unsecured routes
 		unsecuredV1 := srv.router.Group("/api/v1")
@@ -86,10 +94,20 @@ func (srv *RegistrationServer) SetupRoutes(proxyPort string, reg *prometheus.Reg
 		securedV1.GET("/signup/verification/:code", signupCtrl.VerifyPhoneCodeHandler) // TODO: also provide a `POST /signup/verification/phone-code` +deprecate this one + migrate UI?
 		securedV1.POST("/signup/verification/activation-code", signupCtrl.VerifyActivationCodeHandler)
 		securedV1.GET("/usernames/:username", usernamesCtrl.GetHandler)
+		// kubeconfig to access all the workspaces of the user through the proxy
+		securedV1.GET("/kubeconfig", kubeconfigCtrl.GetHandler)
+		// workspace-scoped API tokens, accepted by the proxy
+		securedV1.POST("/tokens", apiTokensCtrl.PostHandler)
+		securedV1.GET("/tokens", apiTokensCtrl.ListHandler)
+		securedV1.DELETE("/tokens/:id", apiTokensCtrl.DeleteHandler)
+
+		// admin routes, only for the users who were granted the admin role
+		adminV1 := securedV1.Group("/admin", middleware.RequireRole(configuration.AdminRole))
 
-		// if we are in testing mode, we also add a secured health route for testing
+		// if we are in testing mode, we also add secured health routes for testing
 		if configuration.IsTestingMode() {
 			securedV1.GET("/auth_test", healthCheckCtrl.GetHandler)
+			adminV1.GET("/auth_test", healthCheckCtrl.GetHandler)
 		}
 
 		// Create the route for static content, served from /
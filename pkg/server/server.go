@@ -12,6 +12,7 @@ import (
 
 	"github.com/codeready-toolchain/registration-service/pkg/application"
 	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/util"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-contrib/gzip"
@@ -86,9 +87,12 @@ func New(application application.Application) *RegistrationServer {
 		Handler:      srv.router,
 		TLSConfig: &tls.Config{
 			MinVersion: tls.VersionTLS12,
-			NextProtos: []string{"http/1.1"}, // disable HTTP/2 for now
+			NextProtos: []string{"h2", "http/1.1"},
 		},
 	}
+	if err := util.EnableHTTP2(srv.httpServer); err != nil {
+		log.Error(err, "unable to enable HTTP/2, only HTTP/1.1 is served")
+	}
 	if configuration.HTTPCompressResponses {
 		srv.router.Use(gzip.Gzip(gzip.DefaultCompression))
 	}
@@ -81,8 +81,8 @@ func (s *ServiceImpl) newUserSignup(ctx *gin.Context) (*toolchainv1alpha1.UserSi
 			userID, accountID, username, ctx.GetString(context.SubKey))
 	}
 
-	if isCRTAdmin(username) {
-		log.Info(ctx, fmt.Sprintf("A crtadmin user '%s' just tried to signup - the UserID is: '%s'", ctx.GetString(context.UsernameKey), ctx.GetString(context.SubKey)))
+	if isAdmin(ctx) || isCRTAdmin(username) {
+		log.Info(ctx, fmt.Sprintf("An admin user '%s' just tried to signup - the UserID is: '%s'", ctx.GetString(context.UsernameKey), ctx.GetString(context.SubKey)))
 		return nil, apierrors.NewForbidden(schema.GroupResource{}, "", fmt.Errorf("failed to create usersignup for %s", username))
 	}
 
@@ -151,6 +151,18 @@ func (s *ServiceImpl) newUserSignup(ctx *gin.Context) (*toolchainv1alpha1.UserSi
 	return userSignup, nil
 }
 
+// isAdmin returns true if the admin role was granted to the user, in which case the user is not allowed to sign up
+func isAdmin(ctx *gin.Context) bool {
+	for _, role := range ctx.GetStringSlice(context.RolesKey) {
+		if role == configuration.AdminRole {
+			return true
+		}
+	}
+	return false
+}
+
+// isCRTAdmin returns true if the username ends with the `crtadmin` suffix of the admin accounts, which are not
+// allowed to sign up even when no admin role is granted from the claims of their tokens
 func isCRTAdmin(username string) bool {
 	newUsername := regexp.MustCompile("[^A-Za-z0-9]").ReplaceAllString(strings.Split(username, "@")[0], "-")
 	return strings.HasSuffix(newUsername, "crtadmin")
@@ -495,6 +495,27 @@ func (s *TestSignupServiceSuite) TestUserWithExcludedDomainEmailSignsUp() {
 	require.False(s.T(), states.VerificationRequired(&val))
 }
 
+func (s *TestSignupServiceSuite) TestAdminUserSignup() {
+	s.ServiceConfiguration(configuration.Namespace(), true, "redhat.com", 5)
+
+	userID, err := uuid.NewV4()
+	require.NoError(s.T(), err)
+
+	rr := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(rr)
+	ctx.Set(context.UsernameKey, "jsmith")
+	ctx.Set(context.SubKey, userID.String())
+	ctx.Set(context.RolesKey, []string{configuration.AdminRole})
+	ctx.Set(context.EmailKey, "jsmith@redhat.com")
+	ctx.Set(context.GivenNameKey, "jane")
+	ctx.Set(context.FamilyNameKey, "smith")
+	ctx.Set(context.CompanyKey, "red hat")
+
+	userSignup, err := s.Application.SignupService().Signup(ctx)
+	require.EqualError(s.T(), err, "forbidden: failed to create usersignup for jsmith")
+	require.Nil(s.T(), userSignup)
+}
+
 func (s *TestSignupServiceSuite) TestCRTAdminUserSignup() {
 	s.ServiceConfiguration(configuration.Namespace(), true, "redhat.com", 5)
 
@@ -503,6 +524,7 @@ func (s *TestSignupServiceSuite) TestCRTAdminUserSignup() {
 
 	rr := httptest.NewRecorder()
 	ctx, _ := gin.CreateTestContext(rr)
+	// no admin role is granted, but the username ends with the crtadmin suffix
 	ctx.Set(context.UsernameKey, "jsmith-crtadmin")
 	ctx.Set(context.SubKey, userID.String())
 	ctx.Set(context.EmailKey, "jsmith@redhat.com")
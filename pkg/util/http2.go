@@ -0,0 +1,22 @@
+package util
+
+import (
+	"net/http"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// EnableHTTP2 enables HTTP/2 on the given server, both over TLS and over cleartext connections (h2c), since the servers
+// may be exposed behind routes which terminate TLS. The HTTP/1.1 requests are still served, including the SPDY and
+// WebSocket upgrade requests (eg. exec, rsh), which are not supported over HTTP/2. The handler of the server must be set.
+func EnableHTTP2(srv *http.Server) error {
+	h2s := &http2.Server{
+		IdleTimeout: srv.IdleTimeout,
+	}
+	if err := http2.ConfigureServer(srv, h2s); err != nil {
+		return err
+	}
+	srv.Handler = h2c.NewHandler(srv.Handler, h2s)
+	return nil
+}
@@ -0,0 +1,151 @@
+package util_test
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/codeready-toolchain/registration-service/pkg/util"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+)
+
+func TestEnableHTTP2(t *testing.T) {
+	// given
+	const watches = 50
+	started := make(chan struct{}, watches)
+	release := make(chan struct{})
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "" {
+			// switch protocols, as the API server does for the exec and rsh requests
+			conn, rw, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			_, _ = fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: %s\r\n\r\n", r.Header.Get("Upgrade"))
+			_ = rw.Flush()
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = fmt.Fprint(w, r.Proto)
+		if r.URL.Query().Get("watch") != "true" {
+			return
+		}
+		// stream the events of the watch until it is released
+		w.(http.Flusher).Flush()
+		started <- struct{}{}
+		<-release
+	}))
+	var connections int32
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&connections, 1)
+		}
+	}
+	require.NoError(t, util.EnableHTTP2(server.Config))
+	server.Start()
+	defer server.Close()
+
+	t.Run("HTTP/2", func(t *testing.T) {
+		// when
+		resp, err := newH2CClient().Get(server.URL + "/api")
+
+		// then
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "HTTP/2.0", string(body))
+	})
+
+	t.Run("HTTP/1.1", func(t *testing.T) {
+		// when
+		resp, err := http.Get(server.URL + "/api")
+
+		// then
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "HTTP/1.1", string(body))
+	})
+
+	for _, upgrade := range []string{"SPDY/3.1", "websocket"} {
+		t.Run(upgrade+" upgrade", func(t *testing.T) {
+			// given
+			conn, err := net.Dial("tcp", server.Listener.Addr().String())
+			require.NoError(t, err)
+			defer conn.Close()
+
+			// when
+			_, err = fmt.Fprintf(conn, "POST /api/v1/namespaces/john-dev/pods/app/exec?command=sh HTTP/1.1\r\nHost: %s\r\nConnection: Upgrade\r\nUpgrade: %s\r\n\r\n", server.Listener.Addr(), upgrade)
+			require.NoError(t, err)
+
+			// then
+			resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+			assert.Equal(t, upgrade, resp.Header.Get("Upgrade"))
+		})
+	}
+
+	t.Run("concurrent watches over one connection", func(t *testing.T) {
+		// given
+		atomic.StoreInt32(&connections, 0)
+		client := newH2CClient()
+
+		// when
+		var wg sync.WaitGroup
+		errs := make(chan error, watches)
+		for i := 0; i < watches; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp, err := client.Get(server.URL + "/api/v1/namespaces/john-dev/pods?watch=true")
+				if err != nil {
+					errs <- err
+					return
+				}
+				defer resp.Body.Close()
+				_, err = io.ReadAll(resp.Body)
+				errs <- err
+			}()
+		}
+		for i := 0; i < watches; i++ {
+			<-started
+		}
+
+		// then
+		assert.Equal(t, int32(1), atomic.LoadInt32(&connections))
+		close(release)
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			require.NoError(t, err)
+		}
+	})
+}
+
+// newH2CClient returns a client which sends the requests over HTTP/2 cleartext connections
+func newH2CClient() *http.Client {
+	return &http.Client{
+		Transport: &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, network, addr)
+			},
+		},
+	}
+}
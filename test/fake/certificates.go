@@ -0,0 +1,103 @@
+package fake
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Certificate is a certificate and its private key generated for the tests
+type Certificate struct {
+	Cert    *x509.Certificate
+	Key     *ecdsa.PrivateKey
+	CertPEM []byte
+	KeyPEM  []byte
+}
+
+// NewCACertificate returns a new self-signed CA certificate with the given common name
+func NewCACertificate(t *testing.T, commonName string) *Certificate {
+	return newCertificate(t, nil, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: commonName},
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+	})
+}
+
+// NewServingCertificate returns a new certificate signed by the given CA, with which a server can serve HTTPS on localhost
+func NewServingCertificate(t *testing.T, ca *Certificate) *Certificate {
+	return newCertificate(t, ca, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "localhost"},
+		DNSNames:    []string{"localhost"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+}
+
+// NewClientCertificate returns a new client certificate signed by the given CA, with the given subject and email addresses
+func NewClientCertificate(t *testing.T, ca *Certificate, subject pkix.Name, emailAddresses ...string) *Certificate {
+	return newCertificate(t, ca, &x509.Certificate{
+		Subject:        subject,
+		EmailAddresses: emailAddresses,
+		KeyUsage:       x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+}
+
+func newCertificate(t *testing.T, ca *Certificate, template *x509.Certificate) *Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	serialNumber, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+	template.SerialNumber = serialNumber
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+
+	// the CA certificates are self-signed
+	parent, parentKey := template, key
+	if ca != nil {
+		parent, parentKey = ca.Cert, ca.Key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return &Certificate{
+		Cert:    cert,
+		Key:     key,
+		CertPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		KeyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+// TLSCertificate returns the certificate and its private key to be used in a TLS config
+func (c *Certificate) TLSCertificate(t *testing.T) tls.Certificate {
+	cert, err := tls.X509KeyPair(c.CertPEM, c.KeyPEM)
+	require.NoError(t, err)
+	return cert
+}
+
+// WriteFiles writes the PEM-encoded certificate and private key to files in a temporary directory, and returns their paths
+func (c *Certificate) WriteFiles(t *testing.T) (string, string) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	require.NoError(t, os.WriteFile(certFile, c.CertPEM, 0600))
+	require.NoError(t, os.WriteFile(keyFile, c.KeyPEM, 0600))
+	return certFile, keyFile
+}
@@ -0,0 +1,78 @@
+package fake
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// IntrospectionServer is a stub of an OAuth 2.0 token introspection endpoint (RFC 7662).
+// The tokens are inactive unless they are activated.
+type IntrospectionServer struct {
+	*httptest.Server
+	ClientID     string
+	ClientSecret string
+
+	mu       sync.Mutex
+	active   map[string]bool
+	failing  bool
+	requests int
+}
+
+// NewIntrospectionServer starts a new IntrospectionServer which requires the given client credentials,
+// and stops it at the end of the test
+func NewIntrospectionServer(t *testing.T, clientID, clientSecret string) *IntrospectionServer {
+	s := &IntrospectionServer{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		active:       map[string]bool{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.introspect))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// SetActive sets whether the given token is active
+func (s *IntrospectionServer) SetActive(token string, active bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active[token] = active
+}
+
+// SetFailing makes the server respond with an internal error to all the requests
+func (s *IntrospectionServer) SetFailing(failing bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failing = failing
+}
+
+// Requests returns the number of introspection requests received by the server
+func (s *IntrospectionServer) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func (s *IntrospectionServer) introspect(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	if s.failing {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if clientID, clientSecret, _ := r.BasicAuth(); clientID != s.ClientID || clientSecret != s.ClientSecret {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"active": s.active[r.PostFormValue("token")],
+	})
+}
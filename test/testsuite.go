@@ -107,9 +107,10 @@ func (s *UnitTestSuite) SetSecret(secret *corev1.Secret) {
 	if err == nil {
 		err = s.ConfigClient.Delete(context.TODO(), sec)
 		require.NoError(s.T(), err)
+	} else {
+		require.True(s.T(), errors.IsNotFound(err), "unexpected error", err.Error())
 	}
 
-	require.True(s.T(), errors.IsNotFound(err), "unexpected error")
 	err = s.ConfigClient.Create(context.TODO(), secret)
 	require.NoError(s.T(), err)
 	// update config cache